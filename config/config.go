@@ -0,0 +1,226 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+)
+
+const appName = "monitoring-with-go"
+
+const (
+	ScopeUser    = "user"
+	ScopeMachine = "machine"
+)
+
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// DatabaseConfig selects the database the app works on. Path is used by the sqlite driver,
+// DSN (e.g. "host=localhost user=monitoring dbname=monitoring") by the postgres driver.
+type DatabaseConfig struct {
+	Driver string `json:"driver"`
+	Path   string `json:"path"`
+	DSN    string `json:"dsn,omitempty"`
+}
+
+// Config holds the file locations of the app. Relative paths are resolved against DataDir,
+// so the app does not depend on the working directory it was started from.
+type Config struct {
+	DataDir    string         `json:"-"`
+	Database   DatabaseConfig `json:"database"`
+	JournalDir string         `json:"journalDir"`
+	BackupDir  string         `json:"backupDir"`
+	ArchiveDir string         `json:"archiveDir"`
+	ExportDir  string         `json:"exportDir"`
+}
+
+// Default returns the configuration used when nothing overrides it
+func Default() Config {
+	return Config{
+		Database:   DatabaseConfig{Driver: DriverSQLite, Path: "database.db"},
+		JournalDir: "journal",
+		BackupDir:  "backups",
+		ArchiveDir: "archives",
+		ExportDir:  "exports",
+	}
+}
+
+// overrides holds the command line flags; an empty value means "not given"
+var overrides struct {
+	configFile string
+	dataDir    string
+	scope      string
+	dbDriver   string
+	dbPath     string
+	dbDSN      string
+	journalDir string
+	backupDir  string
+	archiveDir string
+	exportDir  string
+}
+
+// RegisterFlags adds the path flags to fs; call it before fs.Parse and Load after
+func RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&overrides.configFile, "config", "", "path of the config file (default <data dir>/config.json)")
+	fs.StringVar(&overrides.dataDir, "data-dir", "", "directory holding the config, database, journal, backups, archives and exports")
+	fs.StringVar(&overrides.scope, "scope", "", "default data directory: user (per-user) or machine (shared by all users)")
+	fs.StringVar(&overrides.dbDriver, "db-driver", "", "database driver: sqlite or postgres")
+	fs.StringVar(&overrides.dbPath, "db", "", "path of the SQLite database file")
+	fs.StringVar(&overrides.dbDSN, "db-dsn", "", "connection string of the PostgreSQL database")
+	fs.StringVar(&overrides.journalDir, "journal-dir", "", "directory of the journal files")
+	fs.StringVar(&overrides.backupDir, "backup-dir", "", "directory of the database backups")
+	fs.StringVar(&overrides.archiveDir, "archive-dir", "", "directory of the monthly event archives")
+	fs.StringVar(&overrides.exportDir, "export-dir", "", "default directory of the exported files")
+}
+
+// Load builds the configuration from, in increasing priority: the defaults, the config file,
+// the MONITORING_* environment variables and the command line flags. Missing directories
+// are created; a missing config file in the data directory is written with the defaults.
+func Load() (*Config, error) {
+	scope := pick(ScopeUser, os.Getenv("MONITORING_SCOPE"), overrides.scope)
+	dataDir := pick("", os.Getenv("MONITORING_DATA_DIR"), overrides.dataDir)
+	if dataDir == "" {
+		dir, err := defaultDataDir(scope)
+		if err != nil {
+			return nil, err
+		}
+		dataDir = dir
+	}
+	dataDir, err := filepath.Abs(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory %s: %v", dataDir, err)
+	}
+
+	cfg := Default()
+	configFile := pick("", os.Getenv("MONITORING_CONFIG"), overrides.configFile)
+	if configFile != "" {
+		// فایل صریحاً داده شده باید وجود داشته باشد
+		if err := readFile(configFile, &cfg); err != nil {
+			return nil, err
+		}
+	} else {
+		configFile = filepath.Join(dataDir, "config.json")
+		err := readFile(configFile, &cfg)
+		if errors.Is(err, os.ErrNotExist) {
+			err = writeFile(configFile, cfg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg.DataDir = dataDir
+	cfg.Database.Driver = pick(DriverSQLite, cfg.Database.Driver, os.Getenv("MONITORING_DB_DRIVER"), overrides.dbDriver)
+	cfg.Database.Path = pick(cfg.Database.Path, os.Getenv("MONITORING_DB_PATH"), overrides.dbPath)
+	cfg.Database.DSN = pick(cfg.Database.DSN, os.Getenv("MONITORING_DB_DSN"), overrides.dbDSN)
+	cfg.JournalDir = pick(cfg.JournalDir, os.Getenv("MONITORING_JOURNAL_DIR"), overrides.journalDir)
+	cfg.BackupDir = pick(cfg.BackupDir, os.Getenv("MONITORING_BACKUP_DIR"), overrides.backupDir)
+	cfg.ArchiveDir = pick(cfg.ArchiveDir, os.Getenv("MONITORING_ARCHIVE_DIR"), overrides.archiveDir)
+	cfg.ExportDir = pick(cfg.ExportDir, os.Getenv("MONITORING_EXPORT_DIR"), overrides.exportDir)
+
+	cfg.Database.Path = cfg.resolve(cfg.Database.Path)
+	cfg.JournalDir = cfg.resolve(cfg.JournalDir)
+	cfg.BackupDir = cfg.resolve(cfg.BackupDir)
+	cfg.ArchiveDir = cfg.resolve(cfg.ArchiveDir)
+	cfg.ExportDir = cfg.resolve(cfg.ExportDir)
+
+	dirs := []string{cfg.JournalDir, cfg.BackupDir, cfg.ArchiveDir, cfg.ExportDir}
+	switch cfg.Database.Driver {
+	case DriverSQLite:
+		dirs = append(dirs, filepath.Dir(cfg.Database.Path))
+	case DriverPostgres:
+		if cfg.Database.DSN == "" {
+			return nil, errors.New("the postgres driver needs a database dsn")
+		}
+	default:
+		return nil, fmt.Errorf("unknown database driver %q, use %s or %s", cfg.Database.Driver, DriverSQLite, DriverPostgres)
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory %s: %v", dir, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// OpenJournal opens today's journal file for appending
+func (c *Config) OpenJournal() (*os.File, error) {
+	name := filepath.Join(c.JournalDir, time.Now().Format("2006-01-02")+".log")
+	return os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+}
+
+func (c *Config) resolve(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.DataDir, path)
+}
+
+// defaultDataDir is %APPDATA%\monitoring-with-go for a user and %ProgramData%\monitoring-with-go
+// for the machine on Windows, with the usual equivalents elsewhere.
+func defaultDataDir(scope string) (string, error) {
+	switch scope {
+	case ScopeUser:
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to find the user data directory: %v", err)
+		}
+		return filepath.Join(dir, appName), nil
+	case ScopeMachine:
+		switch runtime.GOOS {
+		case "windows":
+			programData := os.Getenv("ProgramData")
+			if programData == "" {
+				programData = `C:\ProgramData`
+			}
+			return filepath.Join(programData, appName), nil
+		case "darwin":
+			return filepath.Join("/Library/Application Support", appName), nil
+		default:
+			return filepath.Join("/var/lib", appName), nil
+		}
+	default:
+		return "", fmt.Errorf("unknown scope %q, use %s or %s", scope, ScopeUser, ScopeMachine)
+	}
+}
+
+func readFile(path string, cfg *Config) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, cfg); err != nil {
+		return fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return nil
+}
+
+func writeFile(path string, cfg Config) error {
+	content, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0o644)
+}
+
+// pick returns the last non-empty value
+func pick(values ...string) string {
+	result := ""
+	for _, v := range values {
+		if v != "" {
+			result = v
+		}
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// clearEnv keeps the MONITORING_* variables of the machine running the tests out of Load
+func clearEnv(t *testing.T) {
+	for _, name := range []string{"MONITORING_SCOPE", "MONITORING_DATA_DIR", "MONITORING_CONFIG", "MONITORING_DB_DRIVER",
+		"MONITORING_DB_PATH", "MONITORING_DB_DSN", "MONITORING_JOURNAL_DIR", "MONITORING_BACKUP_DIR",
+		"MONITORING_ARCHIVE_DIR", "MONITORING_EXPORT_DIR"} {
+		t.Setenv(name, "")
+	}
+}
+
+// parseFlags sets the flag overrides to args and restores them when the test ends
+func parseFlags(t *testing.T, args ...string) {
+	t.Helper()
+	saved := overrides
+	t.Cleanup(func() { overrides = saved })
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadDefaults(t *testing.T) {
+	clearEnv(t)
+	dataDir := filepath.Join(t.TempDir(), "data")
+	parseFlags(t, "-data-dir", dataDir)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	// مسیرهای نسبی کنار پوشه داده قرار می‌گیرند، نه پوشه جاری
+	want := map[string]string{
+		"database": filepath.Join(dataDir, "database.db"),
+		"journal":  filepath.Join(dataDir, "journal"),
+		"backups":  filepath.Join(dataDir, "backups"),
+		"archives": filepath.Join(dataDir, "archives"),
+		"exports":  filepath.Join(dataDir, "exports"),
+	}
+	got := map[string]string{
+		"database": cfg.Database.Path,
+		"journal":  cfg.JournalDir,
+		"backups":  cfg.BackupDir,
+		"archives": cfg.ArchiveDir,
+		"exports":  cfg.ExportDir,
+	}
+	for name, path := range want {
+		if got[name] != path {
+			t.Errorf("%s = %s, want %s", name, got[name], path)
+		}
+	}
+	for _, dir := range []string{cfg.JournalDir, cfg.BackupDir, cfg.ArchiveDir, cfg.ExportDir} {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			t.Errorf("%s was not created: %v", dir, err)
+		}
+	}
+	if cfg.Database.Driver != DriverSQLite {
+		t.Errorf("driver %q, want %q", cfg.Database.Driver, DriverSQLite)
+	}
+	if _, err := os.Stat(filepath.Join(dataDir, "config.json")); err != nil {
+		t.Errorf("the default config file was not written: %v", err)
+	}
+}
+
+func TestLoadPriority(t *testing.T) {
+	clearEnv(t)
+	dataDir := t.TempDir()
+	other := t.TempDir()
+	content := `{"database": {"driver": "sqlite", "path": "from-file.db"}, "journalDir": "file-journal", "backupDir": "file-backups"}`
+	if err := os.WriteFile(filepath.Join(dataDir, "config.json"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("MONITORING_DATA_DIR", dataDir)
+	t.Setenv("MONITORING_JOURNAL_DIR", "env-journal")
+	t.Setenv("MONITORING_BACKUP_DIR", "env-backups")
+	parseFlags(t, "-backup-dir", filepath.Join(other, "flag-backups"))
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cases := []struct{ name, got, want string }{
+		{"file over default", cfg.Database.Path, filepath.Join(dataDir, "from-file.db")},
+		{"environment over file", cfg.JournalDir, filepath.Join(dataDir, "env-journal")},
+		{"flag over environment", cfg.BackupDir, filepath.Join(other, "flag-backups")},
+		{"default", cfg.ExportDir, filepath.Join(dataDir, "exports")},
+	}
+	for _, c := range cases {
+		if c.got != c.want {
+			t.Errorf("%s: %s, want %s", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestLoadRejectsBadSettings(t *testing.T) {
+	cases := []struct {
+		name string
+		env  map[string]string
+	}{
+		{"postgres without dsn", map[string]string{"MONITORING_DB_DRIVER": DriverPostgres}},
+		{"unknown driver", map[string]string{"MONITORING_DB_DRIVER": "mysql"}},
+		{"missing config file", map[string]string{"MONITORING_CONFIG": "does-not-exist.json"}},
+		{"unknown scope", map[string]string{"MONITORING_DATA_DIR": "", "MONITORING_SCOPE": "everyone"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			clearEnv(t)
+			parseFlags(t)
+			t.Setenv("MONITORING_DATA_DIR", t.TempDir())
+			for name, value := range c.env {
+				t.Setenv(name, value)
+			}
+			if _, err := Load(); err == nil {
+				t.Error("Load accepted the settings")
+			}
+		})
+	}
+}
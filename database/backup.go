@@ -0,0 +1,411 @@
+package database
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+const (
+	BackupScheduled  = "scheduled"
+	BackupManual     = "manual"
+	BackupPreRestore = "pre-restore"
+)
+
+const backupTimeLayout = "20060102-150405"
+
+// backupName matches database-<time>-<reason>.db.gz; only such files are listed or restored
+var backupName = regexp.MustCompile(`^database-(\d{8}-\d{6})-(scheduled|manual|pre-restore)\.db\.gz$`)
+
+var ErrBackupUnsupported = errors.New("backups are only available for the sqlite database, use pg_dump for postgres")
+
+// BackupFile is one compressed copy of the database. Its sha256 is kept next to it in
+// <name>.sha256, in the format of sha256sum, so it can also be checked by hand.
+type BackupFile struct {
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+	Checksum  string    `json:"checksum"`
+}
+
+// BackupCheck is the result of verifying a backup
+type BackupCheck struct {
+	ChecksumOK       bool   `json:"checksumOk"`
+	IntegrityOK      bool   `json:"integrityOk"`
+	ForeignKeysOK    bool   `json:"foreignKeysOk"`
+	MigrationVersion int    `json:"migrationVersion"`
+	Problem          string `json:"problem,omitempty"`
+}
+
+func (c BackupCheck) OK() bool {
+	return c.Problem == ""
+}
+
+// CreateBackup writes a consistent copy of the live database into dir while other
+// connections keep writing: VACUUM INTO reads inside one transaction.
+func CreateBackup(db *gorm.DB, dir string, reason string) (*BackupFile, error) {
+	if db.Dialector.Name() != "sqlite" {
+		return nil, ErrBackupUnsupported
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("database-%s-%s.db.gz", now.Format(backupTimeLayout), reason)
+	if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", name)
+	}
+
+	snapshot := filepath.Join(dir, "."+strings.TrimSuffix(name, ".gz")+".tmp")
+	os.Remove(snapshot)
+	defer os.Remove(snapshot)
+	if err := db.Exec("VACUUM INTO ?", snapshot).Error; err != nil {
+		return nil, fmt.Errorf("failed to copy database: %v", err)
+	}
+
+	checksum, size, err := compressFile(snapshot, filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+".sha256"), []byte(checksum+"  "+name+"\n"), 0o644); err != nil {
+		os.Remove(filepath.Join(dir, name))
+		return nil, err
+	}
+	return &BackupFile{Name: name, Reason: reason, Size: size, CreatedAt: now.Truncate(time.Second), Checksum: checksum}, nil
+}
+
+// compressFile gzips src into dst through a temporary file and returns the sha256 and size of dst
+func compressFile(src, dst string) (string, int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return "", 0, err
+	}
+	defer in.Close()
+
+	tmp := dst + ".tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp)
+
+	hash := sha256.New()
+	counter := &countingWriter{w: io.MultiWriter(out, hash)}
+	zw := gzip.NewWriter(counter)
+	if _, err := io.Copy(zw, in); err != nil {
+		out.Close()
+		return "", 0, err
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return "", 0, err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return "", 0, err
+	}
+	if err := out.Close(); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp, dst); err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), counter.n, nil
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// ListBackups returns the backups in dir, newest first
+func ListBackups(dir string) ([]BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupFile{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []BackupFile{}
+	for _, entry := range entries {
+		m := backupName.FindStringSubmatch(entry.Name())
+		if m == nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		createdAt, _ := time.ParseInLocation(backupTimeLayout, m[1], time.Local)
+		backups = append(backups, BackupFile{
+			Name:      entry.Name(),
+			Reason:    m[2],
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+			Checksum:  readChecksum(filepath.Join(dir, entry.Name())),
+		})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Name > backups[j].Name })
+	return backups, nil
+}
+
+func readChecksum(path string) string {
+	content, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		return ""
+	}
+	checksum, _, _ := strings.Cut(strings.TrimSpace(string(content)), " ")
+	return checksum
+}
+
+// PruneBackups removes the backups beyond the newest keepCount and those older than
+// keepDays; zero turns a rule off and the newest backup is always kept.
+func PruneBackups(dir string, keepCount, keepDays int) ([]string, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+
+	var removed []string
+	for i, backup := range backups {
+		if i == 0 {
+			continue
+		}
+		if (keepCount > 0 && i >= keepCount) || (keepDays > 0 && backup.CreatedAt.Before(cutoff)) {
+			path := filepath.Join(dir, backup.Name)
+			if err := os.Remove(path); err != nil {
+				return removed, err
+			}
+			os.Remove(path + ".sha256")
+			removed = append(removed, backup.Name)
+		}
+	}
+	return removed, nil
+}
+
+// BackupPath returns the path of the backup called name in dir, refusing anything that
+// is not a backup file name.
+func BackupPath(dir, name string) (string, error) {
+	if !backupName.MatchString(name) {
+		return "", fmt.Errorf("invalid backup name %q", name)
+	}
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", fmt.Errorf("backup %s not found", name)
+	}
+	return path, nil
+}
+
+// VerifyBackup checks the checksum of a backup, then unpacks it and runs SQLite's integrity
+// and foreign key checks on the copy. The unpacked file is returned for a restore; the caller
+// removes it.
+func VerifyBackup(path string) (BackupCheck, string, error) {
+	var check BackupCheck
+
+	expected := readChecksum(path)
+	actual, err := fileChecksum(path)
+	if err != nil {
+		return check, "", err
+	}
+	if expected == "" || expected != actual {
+		check.Problem = "checksum does not match"
+		return check, "", nil
+	}
+	check.ChecksumOK = true
+
+	unpacked := strings.TrimSuffix(path, ".gz") + ".verify.tmp"
+	if err := decompressFile(path, unpacked); err != nil {
+		os.Remove(unpacked)
+		check.Problem = fmt.Sprintf("cannot unpack: %v", err)
+		return check, "", nil
+	}
+
+	problem, version, err := inspectDatabase(unpacked, &check)
+	if err != nil || problem != "" {
+		os.Remove(unpacked)
+		check.Problem = problem
+		return check, "", err
+	}
+	check.MigrationVersion = version
+	return check, unpacked, nil
+}
+
+func inspectDatabase(path string, check *BackupCheck) (string, int, error) {
+	conn, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return "", 0, err
+	}
+	defer conn.Close()
+
+	var integrity string
+	if err := conn.QueryRow("PRAGMA integrity_check").Scan(&integrity); err != nil {
+		return fmt.Sprintf("not a database: %v", err), 0, nil
+	}
+	if integrity != "ok" {
+		return "integrity check failed: " + integrity, 0, nil
+	}
+	check.IntegrityOK = true
+
+	rows, err := conn.Query("PRAGMA foreign_key_check")
+	if err != nil {
+		return "", 0, err
+	}
+	violations := rows.Next()
+	rows.Close()
+	if violations {
+		return "foreign key check failed", 0, nil
+	}
+	check.ForeignKeysOK = true
+
+	// نسخه‌ای که از برنامه جدیدتر آمده با این نسخه قابل استفاده نیست
+	var version sql.NullInt64
+	if err := conn.QueryRow(`SELECT MAX(version) FROM "SchemaMigration"`).Scan(&version); err != nil {
+		return "no migration history", 0, nil
+	}
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		return "", 0, err
+	}
+	if latest := migrations[len(migrations)-1].Version; int(version.Int64) > latest {
+		return fmt.Sprintf("made by a newer version (migration %d, this build knows %d)", version.Int64, latest), 0, nil
+	}
+	return "", int(version.Int64), nil
+}
+
+func fileChecksum(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func decompressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	zr, err := gzip.NewReader(in)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, zr); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// RestoreBackup replaces the content of the live database with a verified backup. The
+// current state is backed up first, the pages are copied with SQLite's online backup API so
+// open connections stay valid, and pending migrations are applied to the restored schema.
+func RestoreBackup(db *gorm.DB, dir string, path string) (*BackupFile, error) {
+	if db.Dialector.Name() != "sqlite" {
+		return nil, ErrBackupUnsupported
+	}
+	check, unpacked, err := VerifyBackup(path)
+	if err != nil {
+		return nil, err
+	}
+	if !check.OK() {
+		return nil, fmt.Errorf("backup failed verification: %s", check.Problem)
+	}
+	defer os.Remove(unpacked)
+
+	safety, err := CreateBackup(db, dir, BackupPreRestore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up the current database: %v", err)
+	}
+
+	if err := copyDatabase(db, unpacked); err != nil {
+		return safety, fmt.Errorf("restore failed, the previous state is in %s: %v", safety.Name, err)
+	}
+	if _, err := Migrate(db, false); err != nil {
+		return safety, fmt.Errorf("restored, but migrating it failed: %v", err)
+	}
+	if err := EnsureSearchIndex(db); err != nil {
+		return safety, fmt.Errorf("restored, but %v", err)
+	}
+	return safety, nil
+}
+
+// copyDatabase overwrites the main database of db with the file at src, page by page
+func copyDatabase(db *gorm.DB, src string) error {
+	srcDB, err := sql.Open("sqlite3", "file:"+src+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer srcDB.Close()
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	destConn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+	srcConn, err := srcDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	return destConn.Raw(func(dest any) error {
+		return srcConn.Raw(func(src any) error {
+			destSQLite, ok := dest.(*sqlite3.SQLiteConn)
+			srcSQLite, ok2 := src.(*sqlite3.SQLiteConn)
+			if !ok || !ok2 {
+				return errors.New("not a sqlite connection")
+			}
+			backup, err := destSQLite.Backup("main", srcSQLite, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := backup.Step(-1); err != nil {
+				backup.Finish()
+				return err
+			}
+			return backup.Finish()
+		})
+	})
+}
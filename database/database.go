@@ -2,13 +2,13 @@ package database
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
+	"monitoring-with-go/config"
 	"monitoring-with-go/seeders"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -16,47 +16,73 @@ import (
 
 var DB *gorm.DB
 
-// Init initializes the database connection and applies schema.sql
-func Init() (*gorm.DB, error) {
-	dbPath := "database.db"
-
-	// مسیر مطلق فایل دیتابیس
-	absPath, err := filepath.Abs(dbPath)
+// Open connects to the database of cfg without touching its schema
+func Open(cfg *config.Config) (*gorm.DB, error) {
+	dialector, err := dialectorFor(cfg.Database)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		return nil, err
 	}
-	log.Printf("Using database path: %s\n", absPath)
 
 	// اتصال با GORM
-	DB, err = gorm.Open(sqlite.Open(absPath), &gorm.Config{
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info), // برای لاگ کردن query ها
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect database: %v", err)
 	}
 
-	// فعال کردن foreign key در SQLite
 	sqlDB, err := DB.DB()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get sql.DB from gorm: %v", err)
 	}
-	_, err = sqlDB.Exec("PRAGMA foreign_keys = ON;")
-	if err != nil {
-		return nil, fmt.Errorf("failed to enable foreign keys: %v", err)
-	}
 
 	// حالا تنظیم connection pool روی sqlDB
 	sqlDB.SetMaxOpenConns(20)                 // حداکثر کانکشن باز
 	sqlDB.SetMaxIdleConns(10)                 // کانکشن idle
 	sqlDB.SetConnMaxLifetime(time.Minute * 5) // مدت زمان حداکثر برای هر کانکشن
 
-	//sqlDB.Exec(`DROP INDEX IF EXISTS idx_event_deduphash_active;`)
-	sqlDB.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_event_deduphash_active
-         ON "Event"("dedupHash") WHERE "deletedAt" IS NULL;`)
+	return DB, nil
+}
+
+// dialectorFor picks the GORM driver of the configured backend
+func dialectorFor(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	switch cfg.Driver {
+	case config.DriverPostgres:
+		log.Println("Using PostgreSQL database")
+		return postgres.Open(cfg.DSN), nil
+	case config.DriverSQLite, "":
+		// مسیر مطلق فایل دیتابیس
+		absPath, err := filepath.Abs(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		log.Printf("Using database path: %s\n", absPath)
 
-	// اجرای schema.sql
-	if err := applySchema(); err != nil {
-		return nil, fmt.Errorf("failed to apply schema.sql: %v", err)
+		// foreign key در SQLite برای هر کانکشن جدا فعال می‌شود، پس در DSN می‌آید
+		return sqlite.Open(absPath + "?_foreign_keys=1"), nil
+	default:
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+}
+
+// Init opens the database, applies the pending migrations and runs the seeders
+func Init(cfg *config.Config) (*gorm.DB, error) {
+	if _, err := Open(cfg); err != nil {
+		return nil, err
+	}
+
+	// اجرای migration های جدید
+	applied, err := Migrate(DB, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range applied {
+		log.Printf("Applied migration %04d_%s\n", m.Version, m.Name)
+	}
+
+	// ایندکس جستجو با trigger ها به‌روز می‌ماند؛ نبودنش مانع بالا آمدن برنامه نمی‌شود
+	if err := EnsureSearchIndex(DB); err != nil {
+		log.Printf("❌ %v", err)
 	}
 
 	// اجرای Seeder
@@ -67,36 +93,7 @@ func Init() (*gorm.DB, error) {
 	seeders.SeedZoneTypes(DB)
 	seeders.SeedPanelTypes(DB)
 	seeders.SeedAlarms(DB)
+	seeders.SeedAppSettings(DB)
 
 	return DB, nil
 }
-
-// applySchema reads schema.sql and executes it statement by statement
-func applySchema() error {
-	schemaPath := "database/schema.sql"
-
-	absSchemaPath, err := filepath.Abs(schemaPath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path for schema.sql: %v", err)
-	}
-
-	content, err := ioutil.ReadFile(absSchemaPath)
-	if err != nil {
-		return fmt.Errorf("failed to read schema.sql: %v", err)
-	}
-
-	// جدا کردن statement ها با ;
-	statements := strings.Split(string(content), ";")
-	for _, stmt := range statements {
-		stmt = strings.TrimSpace(stmt)
-		if stmt == "" {
-			continue
-		}
-		if err := DB.Exec(stmt).Error; err != nil {
-			return fmt.Errorf("failed to execute statement: %s, error: %v", stmt, err)
-		}
-	}
-
-	log.Println("Database schema checked/created successfully.")
-	return nil
-}
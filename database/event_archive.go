@@ -0,0 +1,296 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+const archiveBatchSize = 1000
+
+// archiveName matches events-<year>-<month>.db, one archive per month of Event.createdAt
+var archiveName = regexp.MustCompile(`^events-(\d{4})-(\d{2})\.db$`)
+
+// archiveTables are stored in every archive next to Event, so an archive can be browsed
+// with its branches, alarms and zones after they are changed or removed in the live database.
+var archiveTables = []any{
+	&models.Event{},
+	&models.Branch{},
+	&models.Location{},
+	&models.Alarm{},
+	&models.AlarmCategory{},
+	&models.Zone{},
+	&models.Partition{},
+	&models.Employee{},
+	&ArchiveInfo{},
+}
+
+// ArchiveInfo is the single row describing the content of an archive
+type ArchiveInfo struct {
+	Month      string    `gorm:"primaryKey;column:month" json:"month"`
+	EventCount int64     `gorm:"column:eventCount" json:"eventCount"`
+	FirstEvent time.Time `gorm:"column:firstEvent" json:"firstEvent"`
+	LastEvent  time.Time `gorm:"column:lastEvent" json:"lastEvent"`
+	UpdatedAt  time.Time `gorm:"column:updatedAt" json:"updatedAt"`
+}
+
+func (ArchiveInfo) TableName() string {
+	return "ArchiveInfo"
+}
+
+// ArchiveEvents moves the events created before cutoff out of db into the monthly archives
+// in dir and returns the number of events moved per archive. Every batch is committed to its
+// archive before it is deleted from db, so an interrupted run only leaves events that the
+// next run archives again. Deleted events are archived too, so they do not stay in db forever.
+func ArchiveEvents(db *gorm.DB, dir string, cutoff time.Time) (map[string]int, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	archives := map[string]*gorm.DB{}
+	defer func() {
+		for _, archive := range archives {
+			closeArchive(archive)
+		}
+	}()
+
+	moved := map[string]int{}
+	for {
+		var events []models.Event
+		err := db.Unscoped().Where(`"createdAt" < ?`, cutoff).Order(`"createdAt"`).Limit(archiveBatchSize).Find(&events).Error
+		if err != nil {
+			return moved, err
+		}
+		if len(events) == 0 {
+			return moved, nil
+		}
+
+		months := map[string][]models.Event{}
+		for _, event := range events {
+			name := fmt.Sprintf("events-%s.db", event.CreatedAt.Local().Format("2006-01"))
+			months[name] = append(months[name], event)
+		}
+		for name, batch := range months {
+			archive, ok := archives[name]
+			if !ok {
+				archive, err = openArchive(filepath.Join(dir, name), true)
+				if err != nil {
+					return moved, fmt.Errorf("failed to open archive %s: %v", name, err)
+				}
+				archives[name] = archive
+			}
+			if err := writeArchiveBatch(db, archive, name, batch); err != nil {
+				return moved, fmt.Errorf("failed to write archive %s: %v", name, err)
+			}
+
+			ids := make([]string, len(batch))
+			for i, event := range batch {
+				ids[i] = event.ID
+			}
+			// حذف مستقیم تا برای هر رویداد بایگانی‌شده ActionLog نوشته نشود
+			if err := db.Exec(`DELETE FROM "Event" WHERE id IN ?`, ids).Error; err != nil {
+				return moved, err
+			}
+			moved[name] += len(batch)
+		}
+	}
+}
+
+// writeArchiveBatch stores events and the current state of the rows they point to
+func writeArchiveBatch(db, archive *gorm.DB, name string, events []models.Event) error {
+	var branchIDs, alarmIDs, zoneIDs, partitionIDs, employeeIDs []string
+	for _, event := range events {
+		branchIDs = appendID(branchIDs, event.BranchID)
+		alarmIDs = appendID(alarmIDs, event.AlarmID)
+		zoneIDs = appendID(zoneIDs, event.ZoneID)
+		partitionIDs = appendID(partitionIDs, event.PartitionID)
+		employeeIDs = appendID(employeeIDs, event.EmployeeID)
+	}
+
+	var branches []models.Branch
+	var alarms []models.Alarm
+	var zones []models.Zone
+	var partitions []models.Partition
+	var employees []models.Employee
+	var locations []models.Location
+	var categories []models.AlarmCategory
+	loads := []struct {
+		ids  []string
+		dest any
+	}{
+		{branchIDs, &branches},
+		{alarmIDs, &alarms},
+		{zoneIDs, &zones},
+		{partitionIDs, &partitions},
+		{employeeIDs, &employees},
+	}
+	for _, load := range loads {
+		if len(load.ids) == 0 {
+			continue
+		}
+		if err := db.Unscoped().Where("id IN ?", load.ids).Find(load.dest).Error; err != nil {
+			return err
+		}
+	}
+	var locationIDs, categoryIDs []string
+	for _, branch := range branches {
+		locationIDs = appendID(locationIDs, branch.LocationID)
+	}
+	for _, alarm := range alarms {
+		if alarm.CategoryID != nil {
+			categoryIDs = appendID(categoryIDs, *alarm.CategoryID)
+		}
+	}
+	if len(locationIDs) > 0 {
+		if err := db.Unscoped().Where("id IN ?", locationIDs).Find(&locations).Error; err != nil {
+			return err
+		}
+	}
+	if len(categoryIDs) > 0 {
+		if err := db.Unscoped().Where("id IN ?", categoryIDs).Find(&categories).Error; err != nil {
+			return err
+		}
+	}
+
+	return archive.Transaction(func(tx *gorm.DB) error {
+		// ردیف‌های مرتبط با آخرین وضعیتشان جایگزین می‌شوند؛ رویدادهای تکراری نادیده گرفته می‌شوند
+		for _, rows := range []any{branches, alarms, zones, partitions, employees, locations, categories} {
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(rows, 100).Error; err != nil && !errors.Is(err, gorm.ErrEmptySlice) {
+				return err
+			}
+		}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(events, 100).Error; err != nil {
+			return err
+		}
+
+		m := archiveName.FindStringSubmatch(name)
+		info := ArchiveInfo{Month: m[1] + "-" + m[2], UpdatedAt: time.Now()}
+		if err := tx.Model(&models.Event{}).Unscoped().Count(&info.EventCount).Error; err != nil {
+			return err
+		}
+		info.FirstEvent = events[0].CreatedAt
+		info.LastEvent = events[len(events)-1].CreatedAt
+		var existing ArchiveInfo
+		if tx.First(&existing).Error == nil {
+			if existing.FirstEvent.Before(info.FirstEvent) {
+				info.FirstEvent = existing.FirstEvent
+			}
+			if existing.LastEvent.After(info.LastEvent) {
+				info.LastEvent = existing.LastEvent
+			}
+		}
+		return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&info).Error
+	})
+}
+
+func appendID(ids []string, id string) []string {
+	if id == "" {
+		return ids
+	}
+	for _, existing := range ids {
+		if existing == id {
+			return ids
+		}
+	}
+	return append(ids, id)
+}
+
+// openArchive opens the archive at path; create makes a missing archive and its schema
+func openArchive(path string, create bool) (*gorm.DB, error) {
+	dsn := "file:" + path + "?mode=ro"
+	if create {
+		dsn = "file:" + path + "?mode=rwc"
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	archive, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		return nil, err
+	}
+	if !create {
+		return archive, nil
+	}
+
+	if err := archive.AutoMigrate(archiveTables...); err != nil {
+		closeArchive(archive)
+		return nil, err
+	}
+	// در بایگانی hash تکراری مجاز است؛ یکتایی فقط برای رویدادهای زنده معنی دارد. ایندکس با همان
+	// نام ساخته می‌شود تا AutoMigrate دفعه بعد نسخه یکتا را دوباره نسازد
+	statements := []string{
+		`DROP INDEX IF EXISTS "idx_event_deduphash_active"`,
+		`CREATE INDEX "idx_event_deduphash_active" ON "Event"("dedupHash")`,
+		`CREATE INDEX IF NOT EXISTS "idx_Event_createdAt" ON "Event"("createdAt")`,
+		`CREATE INDEX IF NOT EXISTS "idx_Event_branchId" ON "Event"("branchId")`,
+		`CREATE INDEX IF NOT EXISTS "idx_Event_alarmId" ON "Event"("alarmId")`,
+	}
+	for _, statement := range statements {
+		if err := archive.Exec(statement).Error; err != nil {
+			closeArchive(archive)
+			return nil, err
+		}
+	}
+	return archive, nil
+}
+
+// OpenEventArchive opens the archive called name in dir for reading; close it with CloseEventArchive
+func OpenEventArchive(dir, name string) (*gorm.DB, error) {
+	if !archiveName.MatchString(name) {
+		return nil, fmt.Errorf("invalid archive name %q", name)
+	}
+	path := filepath.Join(dir, name)
+	archive, err := openArchive(path, false)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("archive %s not found", name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// بایگانی‌های قدیمی‌تر جدول AlarmCategory ندارند؛ یک بار با اسکیمای فعلی باز می‌شوند
+	if !archive.Migrator().HasTable(&models.AlarmCategory{}) {
+		closeArchive(archive)
+		return openArchive(path, true)
+	}
+	return archive, nil
+}
+
+// CloseEventArchive releases the file held by an archive opened with OpenEventArchive
+func CloseEventArchive(archive *gorm.DB) {
+	closeArchive(archive)
+}
+
+func closeArchive(archive *gorm.DB) {
+	if sqlDB, err := archive.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// ListEventArchives returns the archive names in dir, newest month first
+func ListEventArchives(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && archiveName.MatchString(entry.Name()) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
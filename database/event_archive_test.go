@@ -0,0 +1,60 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+func TestArchiveEventsMovesDeletedEvents(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	now := time.Now()
+	old := now.AddDate(0, 0, -40)
+	events := []models.Event{
+		{ID: "kept", DedupHash: "kept", ConfirmationStatus: "Unconfirmed", CreatedAt: now},
+		{ID: "expired", DedupHash: "expired", ConfirmationStatus: "Unconfirmed", CreatedAt: old},
+		{ID: "deleted", DedupHash: "deleted", ConfirmationStatus: "Unconfirmed", CreatedAt: old.Add(time.Minute)},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("create events: %v", err)
+	}
+	if err := db.Delete(&models.Event{}, "id = ?", "deleted").Error; err != nil {
+		t.Fatalf("delete event: %v", err)
+	}
+
+	dir := filepath.Join(t.TempDir(), "archive")
+	moved, err := ArchiveEvents(db, dir, now.AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("ArchiveEvents: %v", err)
+	}
+	name := "events-" + old.Local().Format("2006-01") + ".db"
+	if len(moved) != 1 || moved[name] != 2 {
+		t.Fatalf("moved = %v, want 2 events in %s", moved, name)
+	}
+
+	var live []string
+	if err := db.Unscoped().Model(&models.Event{}).Pluck("id", &live).Error; err != nil {
+		t.Fatalf("read live events: %v", err)
+	}
+	if len(live) != 1 || live[0] != "kept" {
+		t.Errorf("live events = %v, want [kept]", live)
+	}
+
+	archive, err := OpenEventArchive(dir, name)
+	if err != nil {
+		t.Fatalf("OpenEventArchive: %v", err)
+	}
+	defer CloseEventArchive(archive)
+	var visible, all int64
+	archive.Model(&models.Event{}).Count(&visible)
+	archive.Unscoped().Model(&models.Event{}).Count(&all)
+	if visible != 1 || all != 2 {
+		t.Errorf("archive has %d visible of %d events, want 1 of 2", visible, all)
+	}
+}
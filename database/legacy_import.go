@@ -0,0 +1,738 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// legacyRef is a foreign key of the old system. Its value is either the legacy integer id,
+// also kept in the old_<column> column when the table has one, or an already converted uuid.
+type legacyRef struct {
+	Column string
+	Table  string
+}
+
+// legacyTable describes how the rows of one old table land in the new schema
+type legacyTable struct {
+	Name  string
+	Model any
+	Refs  []legacyRef
+	// Match lists the columns that identify a row the new system already has (seeded data);
+	// such a row is linked to the legacy id instead of being inserted twice.
+	Match []string
+	// MatchOnly tables are defined by the seeders; legacy rows are mapped, never inserted
+	MatchOnly bool
+	// Required references make no sense when missing; such rows fail instead of losing them
+	Required []string
+	Renames  map[string]string
+}
+
+// legacyTables is the import order; a table may only refer to the ones before it,
+// later references (and a table's own) are filled in once every table is in.
+var legacyTables = []legacyTable{
+	{Name: "Location", Model: &models.Location{}, Refs: []legacyRef{{"parentId", "Location"}}, Match: []string{"label", "type", "parentId"}},
+	{Name: "Receiver", Model: &models.Receiver{}, Match: []string{"token"}},
+	{Name: "PanelType", Model: &models.PanelType{}, Match: []string{"name"}},
+	{Name: "ZoneType", Model: &models.ZoneType{}, Match: []string{"label"}},
+	{Name: "AlarmCategory", Model: &models.AlarmCategory{}, Match: []string{"code"}},
+	{Name: "Alarm", Model: &models.Alarm{}, Refs: []legacyRef{{"panelTypeId", "PanelType"}, {"categoryId", "AlarmCategory"}}, Match: []string{"code", "protocol", "panelTypeId"}},
+	{Name: "Branch", Model: &models.Branch{}, Refs: []legacyRef{{"locationId", "Location"}, {"panelTypeId", "PanelType"}, {"receiverId", "Receiver"}, {"mainPartitionId", "Partition"}}},
+	{Name: "Partition", Model: &models.Partition{}, Refs: []legacyRef{{"branchId", "Branch"}, {"branchDefaultId", "Branch"}}},
+	{Name: "Zone", Model: &models.Zone{}, Refs: []legacyRef{{"partitionId", "Partition"}, {"zoneTypeId", "ZoneType"}}},
+	{Name: "Employee", Model: &models.Employee{}, Refs: []legacyRef{{"branchId", "Branch"}}},
+	{Name: "Equipment", Model: &models.Equipment{}, Refs: []legacyRef{{"branchId", "Branch"}}},
+	{Name: "Permission", Model: &models.Permission{}, Match: []string{"action", "model", "field"}, MatchOnly: true},
+	{Name: "User", Model: &models.User{}, Refs: []legacyRef{{"locationId", "Location"}}, Match: []string{"username"}},
+	{Name: "UserPermission", Model: &models.UserPermission{}, Refs: []legacyRef{{"userId", "User"}, {"permissionId", "Permission"}}, Match: []string{"userId", "permissionId"}, Required: []string{"userId", "permissionId"}},
+	{Name: "Event", Model: &models.Event{}, Refs: []legacyRef{{"alarmId", "Alarm"}, {"branchId", "Branch"}, {"zoneId", "Zone"}, {"partitionId", "Partition"}, {"employeeId", "Employee"}},
+		Renames: map[string]string{"confermationStatus": "confirmationStatus"}},
+}
+
+const (
+	legacyBatchSize = 500
+	legacyMaxErrors = 20
+)
+
+var errLegacyDryRun = errors.New("dry run")
+
+// LegacyTableReport reconciles one table: every source row ends up Existing (imported by an
+// earlier run), Matched (linked to a seeded row), Imported, Failed or Unmatched, and Present
+// counts the source rows whose record is in the database after the run.
+type LegacyTableReport struct {
+	Table          string         `json:"table"`
+	MissingTable   bool           `json:"missingTable,omitempty"`
+	Source         int            `json:"source"`
+	Existing       int            `json:"existing"`
+	Matched        int            `json:"matched"`
+	Imported       int            `json:"imported"`
+	Failed         int            `json:"failed"`
+	Unmatched      int            `json:"unmatched"`
+	Present        int            `json:"present"`
+	Unresolved     map[string]int `json:"unresolved,omitempty"` // references to rows that were never found
+	IgnoredColumns []string       `json:"ignoredColumns,omitempty"`
+	Errors         []string       `json:"errors,omitempty"`
+
+	ignored map[string]bool
+	mapped  map[string]int // new id -> number of source rows mapped onto it
+}
+
+type LegacyImportReport struct {
+	StartedAt  time.Time            `json:"startedAt"`
+	FinishedAt time.Time            `json:"finishedAt"`
+	DryRun     bool                 `json:"dryRun"`
+	Tables     []*LegacyTableReport `json:"tables"`
+}
+
+// legacyPending is a reference to a table imported later, set once that table is in
+type legacyPending struct {
+	Table, ID, Column, RefTable, Key string
+}
+
+type legacyImporter struct {
+	src     LegacySource
+	keys    map[string]map[string]string // table -> "old:<legacy id>" or "id:<source uuid>" -> new id
+	done    map[string]bool
+	pending []legacyPending
+	report  *LegacyImportReport
+}
+
+// ImportLegacy copies the old system's data into db. Rows already imported are recognised by
+// their old_id (or kept uuid) and skipped, and every batch is committed on its own, so an
+// interrupted import is resumed by running it again. With dryRun everything is rolled back.
+func ImportLegacy(db *gorm.DB, src LegacySource, dryRun bool) (*LegacyImportReport, error) {
+	im := &legacyImporter{
+		src:    src,
+		keys:   map[string]map[string]string{},
+		done:   map[string]bool{},
+		report: &LegacyImportReport{StartedAt: time.Now(), DryRun: dryRun},
+	}
+
+	run := func(tx *gorm.DB) error {
+		for _, table := range legacyTables {
+			if err := im.importTable(tx, table); err != nil {
+				return fmt.Errorf("%s: %v", table.Name, err)
+			}
+		}
+		if err := im.resolvePending(tx); err != nil {
+			return err
+		}
+		if err := im.reconcile(tx); err != nil {
+			return err
+		}
+		if dryRun {
+			return errLegacyDryRun
+		}
+		return nil
+	}
+
+	var err error
+	if dryRun {
+		// در حالت آزمایشی همه‌چیز در یک تراکنش انجام و در پایان برگردانده می‌شود
+		err = db.Transaction(run)
+		if errors.Is(err, errLegacyDryRun) {
+			err = nil
+		}
+	} else {
+		err = run(db)
+	}
+	im.report.FinishedAt = time.Now()
+	return im.report, err
+}
+
+func (im *legacyImporter) importTable(db *gorm.DB, table legacyTable) error {
+	rep := &LegacyTableReport{Table: table.Name, Unresolved: map[string]int{}, ignored: map[string]bool{}, mapped: map[string]int{}}
+	im.report.Tables = append(im.report.Tables, rep)
+
+	s, err := schema.Parse(table.Model, &sync.Map{}, db.NamingStrategy)
+	if err != nil {
+		return err
+	}
+	columns := map[string]*schema.Field{}
+	for name, field := range s.FieldsByDBName {
+		columns[strings.ToLower(name)] = field
+	}
+
+	keys, err := loadLegacyKeys(db, table)
+	if err != nil {
+		return err
+	}
+	im.keys[table.Name] = keys
+
+	var batch []map[string]any
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range batch {
+				im.importRow(tx, table, columns, rep, row)
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+
+	selfRef := ""
+	for _, ref := range table.Refs {
+		if ref.Table == table.Name {
+			selfRef = ref.Column
+		}
+	}
+
+	var buffered []map[string]any
+	found, err := im.src.Rows(table.Name, func(row map[string]any) error {
+		// جدول‌های درختی کامل خوانده می‌شوند تا والدها قبل از فرزندان بیایند
+		if selfRef != "" {
+			buffered = append(buffered, row)
+			return nil
+		}
+		batch = append(batch, row)
+		if len(batch) < legacyBatchSize {
+			return nil
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+		if rep.Source%(legacyBatchSize*20) == 0 {
+			log.Printf("legacy import %s: %d rows", table.Name, rep.Source)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		rep.MissingTable = true
+	}
+	for _, row := range parentsFirst(buffered, selfRef, keys) {
+		batch = append(batch, row)
+		if len(batch) >= legacyBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return err
+	}
+
+	im.done[table.Name] = true
+	for column := range rep.ignored {
+		rep.IgnoredColumns = append(rep.IgnoredColumns, column)
+	}
+	sort.Strings(rep.IgnoredColumns)
+	log.Printf("legacy import %s: %d read, %d imported, %d failed", table.Name, rep.Source, rep.Imported, rep.Failed)
+	return nil
+}
+
+// importRow maps, links or inserts one legacy row; a failure is recorded, not returned
+func (im *legacyImporter) importRow(tx *gorm.DB, table legacyTable, columns map[string]*schema.Field, rep *LegacyTableReport, row map[string]any) {
+	rep.Source++
+	keys := im.keys[table.Name]
+
+	legacyID, sourceID := legacyRowKeys(row)
+	if legacyID == 0 && sourceID == "" {
+		rep.fail("row without an id")
+		return
+	}
+	label := fmt.Sprintf("id %v", row["id"])
+
+	// ارجاع‌ها؛ ارجاع به جدول‌های بعدی بعد از ورود همه جدول‌ها پر می‌شود
+	values := map[string]any{}
+	var pending []legacyPending
+	skip := map[string]bool{"id": true, "old_id": true}
+	for _, ref := range table.Refs {
+		skip[strings.ToLower(ref.Column)] = true
+		skip[strings.ToLower("old_"+ref.Column)] = true
+
+		key, oldID := legacyRefKey(row, ref.Column)
+		if key == "" {
+			continue
+		}
+		if oldID != 0 && columns[strings.ToLower("old_"+ref.Column)] != nil {
+			values["old_"+ref.Column] = oldID
+		}
+		if id, ok := im.keys[ref.Table][key]; ok {
+			values[ref.Column] = id
+		} else if !im.done[ref.Table] {
+			pending = append(pending, legacyPending{Table: table.Name, Column: ref.Column, RefTable: ref.Table, Key: key})
+		} else {
+			rep.Unresolved[ref.Column]++
+		}
+	}
+
+	for _, column := range table.Required {
+		if values[column] == nil && !hasPending(pending, column) {
+			rep.fail(fmt.Sprintf("%s: %s not found", label, column))
+			return
+		}
+	}
+
+	existing, ok := keys[legacyKey(legacyID)]
+	if !ok && sourceID != "" {
+		existing, ok = keys["id:"+sourceID]
+	}
+	if ok {
+		rep.Existing++
+		im.mapRow(table.Name, rep, legacyID, sourceID, existing)
+		im.addPending(pending, existing)
+		return
+	}
+
+	for name, value := range row {
+		if skip[strings.ToLower(name)] || value == nil {
+			continue
+		}
+		target := name
+		if renamed, ok := table.Renames[name]; ok {
+			target = renamed
+		}
+		field := columns[strings.ToLower(target)]
+		if field == nil {
+			rep.ignored[name] = true
+			continue
+		}
+		converted, err := convertLegacyValue(field, value)
+		if err != nil {
+			rep.fail(fmt.Sprintf("%s: %s: %v", label, name, err))
+			return
+		}
+		if converted != nil {
+			values[field.DBName] = converted
+		}
+	}
+
+	if len(table.Match) > 0 {
+		query := tx.Table(table.Name).Limit(1)
+		for _, column := range table.Match {
+			query = query.Where(clause.Eq{Column: clause.Column{Name: column}, Value: values[column]})
+		}
+		var ids []string
+		if err := query.Pluck("id", &ids).Error; err != nil {
+			rep.fail(fmt.Sprintf("%s: %v", label, err))
+			return
+		}
+		if len(ids) > 0 {
+			rep.Matched++
+			// ردیف seed شده به شناسه قدیمی وصل می‌شود تا اجرای بعدی آن را بشناسد
+			if !table.MatchOnly && legacyID != 0 {
+				tx.Table(table.Name).Where("id = ? AND (old_id IS NULL OR old_id = 0)", ids[0]).Update("old_id", legacyID)
+			}
+			im.mapRow(table.Name, rep, legacyID, sourceID, ids[0])
+			im.addPending(pending, ids[0])
+			return
+		}
+	}
+	if table.MatchOnly {
+		rep.Unmatched++
+		rep.addError(fmt.Sprintf("%s: no matching %s", label, table.Name))
+		return
+	}
+
+	id := sourceID
+	if id == "" {
+		id = uuid.NewString()
+	}
+	values["id"] = id
+	if legacyID != 0 {
+		values["old_id"] = legacyID
+	}
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		return tx.Table(table.Name).Create(values).Error
+	})
+	if err != nil {
+		rep.fail(fmt.Sprintf("%s: %v", label, err))
+		return
+	}
+	rep.Imported++
+	im.mapRow(table.Name, rep, legacyID, sourceID, id)
+	im.addPending(pending, id)
+}
+
+func (im *legacyImporter) mapRow(table string, rep *LegacyTableReport, legacyID int, sourceID, id string) {
+	keys := im.keys[table]
+	if legacyID != 0 {
+		keys[legacyKey(legacyID)] = id
+	}
+	if sourceID != "" {
+		keys["id:"+sourceID] = id
+	}
+	keys["id:"+id] = id
+	rep.mapped[id]++
+}
+
+func hasPending(pending []legacyPending, column string) bool {
+	for _, p := range pending {
+		if p.Column == column {
+			return true
+		}
+	}
+	return false
+}
+
+func (im *legacyImporter) addPending(pending []legacyPending, id string) {
+	for _, p := range pending {
+		p.ID = id
+		im.pending = append(im.pending, p)
+	}
+}
+
+// resolvePending sets the references to tables imported later, then derives the main
+// partition of branches the old system only marked on the partition side.
+func (im *legacyImporter) resolvePending(db *gorm.DB) error {
+	reports := map[string]*LegacyTableReport{}
+	for _, rep := range im.report.Tables {
+		reports[rep.Table] = rep
+	}
+
+	for start := 0; start < len(im.pending); start += legacyBatchSize {
+		end := min(start+legacyBatchSize, len(im.pending))
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, p := range im.pending[start:end] {
+				id, ok := im.keys[p.RefTable][p.Key]
+				if !ok {
+					reports[p.Table].Unresolved[p.Column]++
+					continue
+				}
+				err := tx.Table(p.Table).
+					Where("id = ?", p.ID).
+					Where(clause.Eq{Column: clause.Column{Name: p.Column}, Value: nil}).
+					Update(p.Column, id).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return db.Exec(`UPDATE "Branch" SET "mainPartitionId" = (
+		SELECT p.id FROM "Partition" p WHERE p."branchDefaultId" = "Branch".id AND p."deletedAt" IS NULL
+		ORDER BY p."localId" LIMIT 1
+	) WHERE "mainPartitionId" IS NULL`).Error
+}
+
+// reconcile counts the source rows whose record exists after the run
+func (im *legacyImporter) reconcile(db *gorm.DB) error {
+	for _, rep := range im.report.Tables {
+		ids := make([]string, 0, len(rep.mapped))
+		for id := range rep.mapped {
+			ids = append(ids, id)
+		}
+		rep.Present = 0
+		for start := 0; start < len(ids); start += legacyBatchSize {
+			var present []string
+			end := min(start+legacyBatchSize, len(ids))
+			if err := db.Table(rep.Table).Where("id IN ?", ids[start:end]).Pluck("id", &present).Error; err != nil {
+				return err
+			}
+			for _, id := range present {
+				rep.Present += rep.mapped[id]
+			}
+		}
+		if len(rep.Unresolved) == 0 {
+			rep.Unresolved = nil
+		}
+	}
+	return nil
+}
+
+func (r *LegacyTableReport) fail(message string) {
+	r.Failed++
+	r.addError(message)
+}
+
+func (r *LegacyTableReport) addError(message string) {
+	if len(r.Errors) < legacyMaxErrors {
+		r.Errors = append(r.Errors, message)
+	}
+}
+
+// loadLegacyKeys maps the rows already in table; old_id of MatchOnly tables is not a legacy id
+func loadLegacyKeys(db *gorm.DB, table legacyTable) (map[string]string, error) {
+	var rows []struct {
+		ID    string `gorm:"column:id"`
+		OldID *int   `gorm:"column:old_id"`
+	}
+	if err := db.Table(table.Name).Select("id", "old_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	keys := make(map[string]string, len(rows)*2)
+	for _, row := range rows {
+		keys["id:"+row.ID] = row.ID
+		if row.OldID != nil && *row.OldID != 0 && !table.MatchOnly {
+			keys[legacyKey(*row.OldID)] = row.ID
+		}
+	}
+	return keys, nil
+}
+
+// legacyRowKeys returns the integer id of the old system and the uuid the row already had,
+// if any: an original dump has an integer "id", a converted one a uuid "id" and "old_id".
+func legacyRowKeys(row map[string]any) (int, string) {
+	legacyID, ok := legacyInt(row["id"])
+	if ok {
+		return legacyID, ""
+	}
+	legacyID, _ = legacyInt(row["old_id"])
+	sourceID, _ := legacyString(row["id"])
+	if _, err := uuid.Parse(sourceID); err != nil {
+		sourceID = ""
+	}
+	return legacyID, sourceID
+}
+
+// legacyRefKey returns the key of the row column refers to, preferring old_<column>
+func legacyRefKey(row map[string]any, column string) (string, int) {
+	if oldID, ok := legacyInt(row["old_"+column]); ok && oldID != 0 {
+		return legacyKey(oldID), oldID
+	}
+	if oldID, ok := legacyInt(row[column]); ok {
+		return legacyKey(oldID), oldID
+	}
+	if id, ok := legacyString(row[column]); ok && id != "" {
+		return "id:" + id, 0
+	}
+	return "", 0
+}
+
+func legacyKey(oldID int) string {
+	return "old:" + strconv.Itoa(oldID)
+}
+
+// parentsFirst orders the rows of a tree table so every parent comes before its children;
+// rows whose parent never shows up (or cycles) come last.
+func parentsFirst(rows []map[string]any, column string, existing map[string]string) []map[string]any {
+	if len(rows) == 0 {
+		return rows
+	}
+	inSource := map[string]bool{}
+	for _, row := range rows {
+		legacyID, sourceID := legacyRowKeys(row)
+		if legacyID != 0 {
+			inSource[legacyKey(legacyID)] = true
+		}
+		if sourceID != "" {
+			inSource["id:"+sourceID] = true
+		}
+	}
+
+	ordered := make([]map[string]any, 0, len(rows))
+	placed := map[string]bool{}
+	remaining := rows
+	for len(remaining) > 0 {
+		var next []map[string]any
+		for _, row := range remaining {
+			parent, _ := legacyRefKey(row, column)
+			if parent == "" || placed[parent] || !inSource[parent] || existing[parent] != "" {
+				ordered = append(ordered, row)
+				legacyID, sourceID := legacyRowKeys(row)
+				placed[legacyKey(legacyID)] = true
+				placed["id:"+sourceID] = true
+			} else {
+				next = append(next, row)
+			}
+		}
+		if len(next) == len(remaining) {
+			ordered = append(ordered, next...)
+			break
+		}
+		remaining = next
+	}
+	return ordered
+}
+
+func legacyInt(value any) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int16:
+		return int(v), true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case string:
+		n, err := strconv.Atoi(v)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func legacyString(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case []byte:
+		return string(v), true
+	case [16]byte:
+		return uuid.UUID(v).String(), true
+	}
+	return "", false
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	deletedAtType = reflect.TypeOf(gorm.DeletedAt{})
+)
+
+// convertLegacyValue turns a value of the source (text from a dump, typed from a live
+// connection) into the type of field
+func convertLegacyValue(field *schema.Field, value any) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+	fieldType := field.FieldType
+	if fieldType.Kind() == reflect.Ptr {
+		fieldType = fieldType.Elem()
+	}
+
+	if v, ok := value.([16]byte); ok {
+		value = uuid.UUID(v).String()
+	}
+	if v, ok := value.([]byte); ok {
+		value = string(v)
+	}
+	// آرایه Postgres مثل eventFormat به صورت JSON ذخیره می‌شود
+	if v, ok := value.([]any); ok && fieldType.Kind() == reflect.String {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+	text, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+
+	switch {
+	case fieldType == timeType || fieldType == deletedAtType:
+		return parseLegacyTime(text)
+	case fieldType.Kind() == reflect.Bool:
+		switch strings.ToLower(text) {
+		case "t", "true", "1", "yes", "on":
+			return true, nil
+		case "f", "false", "0", "no", "off":
+			return false, nil
+		}
+		return nil, fmt.Errorf("invalid boolean %q", text)
+	case fieldType.Kind() >= reflect.Int && fieldType.Kind() <= reflect.Uint64:
+		return strconv.ParseInt(text, 10, 64)
+	case fieldType.Kind() == reflect.Float32 || fieldType.Kind() == reflect.Float64:
+		return strconv.ParseFloat(text, 64)
+	case fieldType.Kind() == reflect.String && strings.HasPrefix(text, "{") && field.DBName == "eventFormat":
+		data, err := json.Marshal(parsePostgresArray(text))
+		return string(data), err
+	}
+	return text, nil
+}
+
+// parseLegacyTime reads the text form of timestamp and timestamptz; a time without zone
+// was written by the old system in UTC.
+func parseLegacyTime(text string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999Z07", time.RFC3339Nano} {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, text, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", text)
+}
+
+// parsePostgresArray splits a one-dimensional array literal such as {a,"b c",NULL}
+func parsePostgresArray(text string) []string {
+	inner := strings.TrimSuffix(strings.TrimPrefix(text, "{"), "}")
+	items := []string{}
+	if inner == "" {
+		return items
+	}
+	var b strings.Builder
+	quoted, wasQuoted := false, false
+	for i := 0; i < len(inner); i++ {
+		c := inner[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(inner):
+			i++
+			b.WriteByte(inner[i])
+		case c == '"':
+			quoted = !quoted
+			wasQuoted = true
+		case c == ',' && !quoted:
+			items = append(items, arrayItem(b.String(), wasQuoted))
+			b.Reset()
+			wasQuoted = false
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return append(items, arrayItem(b.String(), wasQuoted))
+}
+
+func arrayItem(item string, quoted bool) string {
+	if !quoted && item == "NULL" {
+		return ""
+	}
+	return item
+}
+
+// RunLegacyImport imports src into db, prints the reconciliation report to out and, when
+// reportPath is set, also writes it there as JSON.
+func RunLegacyImport(db *gorm.DB, src LegacySource, out io.Writer, reportPath string, dryRun bool) error {
+	report, err := ImportLegacy(db, src, dryRun)
+
+	fmt.Fprintf(out, "%-16s %9s %9s %9s %9s %9s %9s %9s\n", "table", "source", "existing", "matched", "imported", "failed", "unmatched", "present")
+	for _, t := range report.Tables {
+		if t.MissingTable {
+			fmt.Fprintf(out, "%-16s not in source\n", t.Table)
+			continue
+		}
+		fmt.Fprintf(out, "%-16s %9d %9d %9d %9d %9d %9d %9d\n", t.Table, t.Source, t.Existing, t.Matched, t.Imported, t.Failed, t.Unmatched, t.Present)
+		for column, count := range t.Unresolved {
+			fmt.Fprintf(out, "    %d unresolved %s references\n", count, column)
+		}
+		if len(t.IgnoredColumns) > 0 {
+			fmt.Fprintf(out, "    ignored columns: %s\n", strings.Join(t.IgnoredColumns, ", "))
+		}
+		for _, message := range t.Errors {
+			fmt.Fprintf(out, "    %s\n", message)
+		}
+	}
+	if dryRun {
+		fmt.Fprintln(out, "dry run, nothing was saved")
+	}
+
+	if reportPath != "" {
+		data, jsonErr := json.MarshalIndent(report, "", "  ")
+		if jsonErr == nil {
+			jsonErr = os.WriteFile(reportPath, data, 0o644)
+		}
+		if jsonErr != nil {
+			return fmt.Errorf("failed to write report: %v", jsonErr)
+		}
+		fmt.Fprintf(out, "report written to %s\n", reportPath)
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package database
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// LegacySource yields the rows of the old Postgres system, one table at a time
+type LegacySource interface {
+	// Rows calls fn for every row of table; ok is false when the source has no such table
+	Rows(table string, fn func(row map[string]any) error) (ok bool, err error)
+	Close() error
+}
+
+// OpenLegacyDump reads the COPY blocks of a plain pg_dump file (pg_dump -Fp, the default)
+func OpenLegacyDump(path string) (LegacySource, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to open dump: %v", err)
+	}
+	return &dumpSource{path: path}, nil
+}
+
+// OpenLegacyPostgres connects to the old database; it is only read from
+func OpenLegacyPostgres(dsn string) (LegacySource, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Warn)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect legacy database: %v", err)
+	}
+	return &postgresSource{db: db}, nil
+}
+
+type dumpSource struct {
+	path string
+}
+
+var copyHeader = regexp.MustCompile(`^COPY (?:"?\w+"?\.)?"?(\w+)"? \((.*)\) FROM stdin;$`)
+
+func (s *dumpSource) Rows(table string, fn func(row map[string]any) error) (bool, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	// هر بار کل فایل خوانده می‌شود تا جدول‌های بزرگ در حافظه نمانند
+	reader := bufio.NewReaderSize(file, 1<<20)
+	var columns []string
+	found := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return found, err
+		}
+		if line == "" && errors.Is(err, io.EOF) {
+			if columns != nil {
+				return found, fmt.Errorf("unterminated COPY block of %s", table)
+			}
+			return found, nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if columns == nil {
+			if m := copyHeader.FindStringSubmatch(line); m != nil && m[1] == table {
+				found = true
+				for _, column := range strings.Split(m[2], ",") {
+					columns = append(columns, strings.Trim(strings.TrimSpace(column), `"`))
+				}
+			}
+			continue
+		}
+		if line == `\.` {
+			columns = nil
+			continue
+		}
+
+		values := strings.Split(line, "\t")
+		if len(values) != len(columns) {
+			return found, fmt.Errorf("%s: expected %d values, got %d", table, len(columns), len(values))
+		}
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[column] = decodeCopyValue(values[i])
+		}
+		if err := fn(row); err != nil {
+			return found, err
+		}
+	}
+}
+
+func (s *dumpSource) Close() error {
+	return nil
+}
+
+// decodeCopyValue undoes the escaping of the COPY text format; \N is NULL
+func decodeCopyValue(value string) any {
+	if value == `\N` {
+		return nil
+	}
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'v':
+			b.WriteByte('\v')
+		case 'x':
+			end := i + 1
+			for end < len(value) && end < i+3 && isHexDigit(value[end]) {
+				end++
+			}
+			n, _ := strconv.ParseUint(value[i+1:end], 16, 8)
+			b.WriteByte(byte(n))
+			i = end - 1
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			end := i
+			for end < len(value) && end < i+3 && value[end] >= '0' && value[end] <= '7' {
+				end++
+			}
+			n, _ := strconv.ParseUint(value[i:end], 8, 8)
+			b.WriteByte(byte(n))
+			i = end - 1
+		default:
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+func isHexDigit(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+type postgresSource struct {
+	db *gorm.DB
+}
+
+func (s *postgresSource) Rows(table string, fn func(row map[string]any) error) (bool, error) {
+	if !s.db.Migrator().HasTable(table) {
+		return false, nil
+	}
+	rows, err := s.db.Table(table).Rows()
+	if err != nil {
+		return true, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		row := map[string]any{}
+		if err := s.db.ScanRows(rows, &row); err != nil {
+			return true, err
+		}
+		if err := fn(row); err != nil {
+			return true, err
+		}
+	}
+	return true, rows.Err()
+}
+
+func (s *postgresSource) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
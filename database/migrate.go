@@ -0,0 +1,401 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one versioned schema step, read from migrations/<dialect>/NNNN_name.{up,down}.sql
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// SchemaMigration is the row recorded for every applied migration
+type SchemaMigration struct {
+	Version   int       `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name"`
+	Checksum  string    `gorm:"column:checksum"`
+	AppliedAt time.Time `gorm:"column:appliedAt"`
+}
+
+func (SchemaMigration) TableName() string {
+	return "SchemaMigration"
+}
+
+const (
+	MigrationApplied  = "applied"
+	MigrationPending  = "pending"
+	MigrationModified = "modified" // applied, but the embedded file no longer matches its checksum
+	MigrationMissing  = "missing"  // applied, but no longer shipped with this build
+)
+
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	State     string
+	AppliedAt *time.Time
+}
+
+// loadMigrations reads the embedded migrations of dialect ordered by version
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for %s: %v", dialect, err)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		prefix, rest, ok := strings.Cut(strings.TrimSuffix(name, "."+direction+".sql"), "_")
+		version, err := strconv.Atoi(prefix)
+		if !ok || err != nil {
+			return nil, fmt.Errorf("invalid migration file name %s", name)
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: rest}
+			byVersion[version] = m
+		} else if m.Name != rest {
+			return nil, fmt.Errorf("migration %04d has two names: %s and %s", version, m.Name, rest)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		sum := sha256.Sum256([]byte(m.Up + "\x00" + m.Down))
+		m.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func appliedMigrations(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create migrations table: %v", err)
+	}
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+// Migrate applies the pending migrations in order, each in its own transaction, and returns
+// them. With dryRun nothing is executed. It refuses to run when an applied migration was
+// modified since, because the database would no longer match the shipped schema.
+func Migrate(db *gorm.DB, dryRun bool) ([]Migration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range migrations {
+		row, ok := applied[m.Version]
+		if !ok {
+			pending = append(pending, m)
+		} else if row.Checksum != m.Checksum {
+			return nil, fmt.Errorf("migration %04d_%s was modified after it was applied", m.Version, m.Name)
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		if preflight := preflights[m.Version]; preflight != nil {
+			if err := preflight(db); err != nil {
+				return nil, fmt.Errorf("migration %04d_%s cannot run: %v", m.Version, m.Name, err)
+			}
+		}
+		err := runMigration(db, m.Up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("migration %04d_%s failed: %v", m.Version, m.Name, err)
+		}
+	}
+	return pending, nil
+}
+
+// Rollback reverts the last steps applied migrations with their down files and returns them
+func Rollback(db *gorm.DB, steps int, dryRun bool) ([]Migration, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var reverted []Migration
+	for i := len(migrations) - 1; i >= 0 && len(reverted) < steps; i-- {
+		m := migrations[i]
+		row, ok := applied[m.Version]
+		if !ok {
+			continue
+		}
+		if row.Checksum != m.Checksum {
+			return reverted, fmt.Errorf("migration %04d_%s was modified after it was applied", m.Version, m.Name)
+		}
+		if !dryRun {
+			err := runMigration(db, m.Down, func(tx *gorm.DB) error {
+				return tx.Delete(&SchemaMigration{}, "version = ?", m.Version).Error
+			})
+			if err != nil {
+				return reverted, fmt.Errorf("rollback of %04d_%s failed: %v", m.Version, m.Name, err)
+			}
+		}
+		reverted = append(reverted, m)
+	}
+	return reverted, nil
+}
+
+// MigrationStatuses lists every shipped migration and any applied one no longer shipped
+func MigrationStatuses(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, err := loadMigrations(db.Dialector.Name())
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name, State: MigrationPending}
+		if row, ok := applied[m.Version]; ok {
+			appliedAt := row.AppliedAt
+			status.AppliedAt = &appliedAt
+			status.State = MigrationApplied
+			if row.Checksum != m.Checksum {
+				status.State = MigrationModified
+			}
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name, State: MigrationMissing, AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// runMigration executes script and record in one transaction. SQLite rebuilds a table to
+// change its keys, which foreign key enforcement would cascade into, so there the checks are
+// switched off on the connection for the duration and every reference is verified before commit.
+func runMigration(db *gorm.DB, script string, record func(tx *gorm.DB) error) error {
+	if db.Dialector.Name() != "sqlite" {
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, script); err != nil {
+				return err
+			}
+			return record(tx)
+		})
+	}
+
+	// PRAGMA foreign_keys داخل تراکنش اثری ندارد و فقط روی همان کانکشن اعمال می‌شود
+	return db.Connection(func(conn *gorm.DB) error {
+		if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+			return err
+		}
+		defer conn.Exec("PRAGMA foreign_keys = ON")
+
+		return conn.Transaction(func(tx *gorm.DB) error {
+			if err := execScript(tx, script); err != nil {
+				return err
+			}
+			if err := checkForeignKeys(tx); err != nil {
+				return err
+			}
+			return record(tx)
+		})
+	})
+}
+
+// checkForeignKeys fails with the number of rows per table and parent that reference a
+// missing record, so they can be fixed before the migration is run again
+func checkForeignKeys(tx *gorm.DB) error {
+	var violations []struct {
+		Table  string
+		Parent string
+	}
+	if err := tx.Raw("PRAGMA foreign_key_check").Scan(&violations).Error; err != nil {
+		return err
+	}
+	if len(violations) == 0 {
+		return nil
+	}
+
+	counts := map[string]int{}
+	var refs []string
+	for _, v := range violations {
+		ref := v.Table + " -> " + v.Parent
+		if counts[ref] == 0 {
+			refs = append(refs, ref)
+		}
+		counts[ref]++
+	}
+	sort.Strings(refs)
+	for i, ref := range refs {
+		refs[i] = fmt.Sprintf("%s: %d rows", ref, counts[ref])
+	}
+	return fmt.Errorf("rows reference missing records, fix or delete them first: %s", strings.Join(refs, ", "))
+}
+
+// preflights are checks run before the migration of the same version, for data it would
+// otherwise fail on with a message that names no rows
+var preflights = map[int]func(db *gorm.DB) error{
+	17: checkBranchDuplicates,
+}
+
+// uniqueKeys are the keys 0017_branch_unique_indexes makes unique among rows not deleted
+var uniqueKeys = []struct {
+	Table, Scope, Value string
+}{
+	{"Branch", `COALESCE("receiverId", '')`, `"panelCode"`},
+	{"Partition", `"branchId"`, `"localId"`},
+	{"Zone", `"partitionId"`, `"localId"`},
+	{"Employee", `"branchId"`, `"localId"`},
+}
+
+// checkBranchDuplicates fails with every key shared by more than one row, so the rows can
+// be renumbered or deleted before the unique indexes are created
+func checkBranchDuplicates(db *gorm.DB) error {
+	var duplicates []string
+	for _, key := range uniqueKeys {
+		var groups []struct {
+			Scope string
+			Value int
+			Total int
+		}
+		err := db.Raw(fmt.Sprintf(`SELECT %s AS scope, %s AS value, COUNT(*) AS total FROM "%s"
+			WHERE "deletedAt" IS NULL GROUP BY 1, 2 HAVING COUNT(*) > 1 ORDER BY 1, 2`, key.Scope, key.Value, key.Table)).Scan(&groups).Error
+		if err != nil {
+			return err
+		}
+		for _, g := range groups {
+			duplicates = append(duplicates, fmt.Sprintf("%s %s=%d in %q: %d rows", key.Table, strings.Trim(key.Value, `"`), g.Value, g.Scope, g.Total))
+		}
+	}
+	if len(duplicates) == 0 {
+		return nil
+	}
+	return fmt.Errorf("rows share a key that must be unique, renumber or delete them first: %s", strings.Join(duplicates, ", "))
+}
+
+// execScript runs a whole migration file in one call; the drivers execute every statement
+// in it, so triggers and literals containing ";" need no splitting.
+func execScript(tx *gorm.DB, script string) error {
+	_, err := tx.Statement.ConnPool.ExecContext(context.Background(), script)
+	return err
+}
+
+// RunMigrationCommand runs one of the -migrate commands against db and reports to out:
+// status, up, or down (reverts steps migrations). With dryRun, up prints the SQL of the
+// pending migrations and down the SQL it would run, without touching the database.
+func RunMigrationCommand(db *gorm.DB, out io.Writer, command string, steps int, dryRun bool) error {
+	switch command {
+	case "status":
+		statuses, err := MigrationStatuses(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			appliedAt := ""
+			if s.AppliedAt != nil {
+				appliedAt = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(out, "%04d  %-30s %-9s %s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return nil
+	case "up":
+		pending, err := Migrate(db, dryRun)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Fprintln(out, "database is up to date")
+		}
+		for _, m := range pending {
+			if dryRun {
+				fmt.Fprintf(out, "-- %04d_%s (up)\n%s\n", m.Version, m.Name, m.Up)
+			} else {
+				fmt.Fprintf(out, "applied %04d_%s\n", m.Version, m.Name)
+			}
+		}
+		return nil
+	case "down":
+		if steps < 1 {
+			return errors.New("steps must be at least 1")
+		}
+		reverted, err := Rollback(db, steps, dryRun)
+		for _, m := range reverted {
+			if dryRun {
+				fmt.Fprintf(out, "-- %04d_%s (down)\n%s\n", m.Version, m.Name, m.Down)
+			} else {
+				fmt.Fprintf(out, "reverted %04d_%s\n", m.Version, m.Name)
+			}
+		}
+		return err
+	default:
+		return fmt.Errorf("unknown migration command %q, use status, up or down", command)
+	}
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_foreign_keys=1"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// sqliteSchema returns the SQL of every table, index and trigger keyed by name
+func sqliteSchema(t *testing.T, db *gorm.DB) map[string]string {
+	t.Helper()
+	var rows []struct {
+		Name string
+		SQL  string
+	}
+	err := db.Raw(`SELECT name, COALESCE(sql, '') AS sql FROM sqlite_master
+		WHERE name NOT LIKE 'sqlite_%' AND name <> 'SchemaMigration' ORDER BY name`).Scan(&rows).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema := make(map[string]string, len(rows))
+	for _, row := range rows {
+		schema[row.Name] = row.SQL
+	}
+	return schema
+}
+
+// TestMigrationRoundTrip applies every migration, reverts them all and applies them again,
+// expecting an empty database in between and the same schema at the end
+func TestMigrationRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Migrate(db, false)
+	if err != nil {
+		t.Fatalf("first up: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Fatalf("first up applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	want := sqliteSchema(t, db)
+
+	reverted, err := Rollback(db, len(migrations), false)
+	if err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if len(reverted) != len(migrations) {
+		t.Fatalf("down reverted %d migrations, want %d", len(reverted), len(migrations))
+	}
+	if left := sqliteSchema(t, db); len(left) > 0 {
+		names := make([]string, 0, len(left))
+		for name := range left {
+			names = append(names, name)
+		}
+		t.Errorf("down left %v behind", names)
+	}
+
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("second up: %v", err)
+	}
+	got := sqliteSchema(t, db)
+	for name, sql := range want {
+		if got[name] != sql {
+			t.Errorf("%s differs after the round trip:\n got: %s\nwant: %s", name, got[name], sql)
+		}
+	}
+	for name := range got {
+		if _, ok := want[name]; !ok {
+			t.Errorf("%s only exists after the round trip", name)
+		}
+	}
+
+	statuses, err := MigrationStatuses(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, status := range statuses {
+		if status.State != MigrationApplied {
+			t.Errorf("%04d_%s is %s after the round trip", status.Version, status.Name, status.State)
+		}
+	}
+}
+
+// migrateTo applies the sqlite migrations up to and including version
+func migrateTo(t *testing.T, db *gorm.DB, version int) {
+	t.Helper()
+	migrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := appliedMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			return
+		}
+		err := runMigration(db, m.Up, func(tx *gorm.DB) error {
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, Checksum: m.Checksum}).Error
+		})
+		if err != nil {
+			t.Fatalf("migration %04d_%s: %v", m.Version, m.Name, err)
+		}
+	}
+}
+
+// TestKeysMigrationReportsOrphans checks that 0009_keys turns empty references into NULL but
+// stops at references to missing rows, naming them, until they are fixed
+func TestKeysMigrationReportsOrphans(t *testing.T) {
+	db := openTestDB(t)
+	migrateTo(t, db, 8)
+
+	statements := []string{
+		`INSERT INTO "Location" (id, label, type) VALUES ('loc', 'Tehran', 'CITY')`,
+		`INSERT INTO "Branch" (id, name, code, "updatedAt", "locationId", "receiverId") VALUES ('b1', 'one', 1, CURRENT_TIMESTAMP, 'loc', '')`,
+		`INSERT INTO "Event" (id, time, date, "branchId") VALUES ('e1', '10:00', '2024-01-01', 'b1')`,
+		`INSERT INTO "Event" (id, time, date, "branchId") VALUES ('e2', '10:00', '2024-01-01', 'gone')`,
+		`INSERT INTO "Event" (id, time, date, "branchId") VALUES ('e3', '10:00', '2024-01-01', 'gone')`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := Migrate(db, false)
+	if err == nil || !strings.Contains(err.Error(), "Event -> Branch: 2 rows") {
+		t.Fatalf("Migrate() = %v, want it to report the 2 events of a missing branch", err)
+	}
+	var branchID *string
+	db.Raw(`SELECT "branchId" FROM "Event" WHERE id = 'e2'`).Scan(&branchID)
+	if branchID == nil || *branchID != "gone" {
+		t.Fatalf("the failed migration changed the orphaned event to %v", branchID)
+	}
+
+	if err := db.Exec(`DELETE FROM "Event" WHERE "branchId" = 'gone'`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("Migrate() after the fix = %v", err)
+	}
+	var receiverID *string
+	db.Raw(`SELECT "receiverId" FROM "Branch" WHERE id = 'b1'`).Scan(&receiverID)
+	if receiverID != nil {
+		t.Errorf("empty receiverId became %q, want NULL", *receiverID)
+	}
+}
+
+// TestDialectsInLockstep checks that both backends ship the same migrations, so a database
+// moved from one to the other is at the same version
+func TestDialectsInLockstep(t *testing.T) {
+	sqliteMigrations, err := loadMigrations("sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	postgresMigrations, err := loadMigrations("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(migrations []Migration) []string {
+		list := make([]string, len(migrations))
+		for i, m := range migrations {
+			list[i] = fmt.Sprintf("%04d_%s", m.Version, m.Name)
+		}
+		return list
+	}
+	if got, want := strings.Join(names(postgresMigrations), " "), strings.Join(names(sqliteMigrations), " "); got != want {
+		t.Errorf("postgres migrations\n%s\ndiffer from sqlite\n%s", got, want)
+	}
+}
+
+// TestUniqueIndexesReportDuplicates checks that 0017_branch_unique_indexes stops before
+// creating its indexes while live rows share a panel code or local id, naming them
+func TestUniqueIndexesReportDuplicates(t *testing.T) {
+	db := openTestDB(t)
+	migrateTo(t, db, 16)
+
+	statements := []string{
+		`INSERT INTO "Location" (id, label, type) VALUES ('loc', 'Tehran', 'CITY')`,
+		`INSERT INTO "Branch" (id, name, code, "panelCode", "updatedAt", "locationId") VALUES ('b1', 'one', 1, 7, CURRENT_TIMESTAMP, 'loc')`,
+		`INSERT INTO "Branch" (id, name, code, "panelCode", "updatedAt", "locationId") VALUES ('b2', 'two', 2, 7, CURRENT_TIMESTAMP, 'loc')`,
+		// ردیف حذف‌شده تکراری حساب نمی‌شود
+		`INSERT INTO "Branch" (id, name, code, "panelCode", "updatedAt", "locationId", "deletedAt") VALUES ('b3', 'three', 3, 8, CURRENT_TIMESTAMP, 'loc', CURRENT_TIMESTAMP)`,
+		`INSERT INTO "Branch" (id, name, code, "panelCode", "updatedAt", "locationId") VALUES ('b4', 'four', 4, 8, CURRENT_TIMESTAMP, 'loc')`,
+		`INSERT INTO "Partition" (id, label, "localId", "updatedAt", "branchId") VALUES ('p1', 'one', 1, CURRENT_TIMESTAMP, 'b1')`,
+		`INSERT INTO "Partition" (id, label, "localId", "updatedAt", "branchId") VALUES ('p2', 'two', 1, CURRENT_TIMESTAMP, 'b1')`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := Migrate(db, false)
+	if err == nil {
+		t.Fatal("Migrate() created the unique indexes over duplicate rows")
+	}
+	for _, want := range []string{`Branch panelCode=7 in "": 2 rows`, `Partition localId=1 in "b1": 2 rows`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Migrate() = %v, want it to report %s", err, want)
+		}
+	}
+	if strings.Contains(err.Error(), "panelCode=8") {
+		t.Errorf("Migrate() = %v, counted a deleted branch", err)
+	}
+
+	fixes := []string{
+		`UPDATE "Branch" SET "panelCode" = 9 WHERE id = 'b2'`,
+		`UPDATE "Partition" SET "localId" = 2 WHERE id = 'p2'`,
+	}
+	for _, fix := range fixes {
+		if err := db.Exec(fix).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := Migrate(db, false); err != nil {
+		t.Fatalf("Migrate() after the fix = %v", err)
+	}
+}
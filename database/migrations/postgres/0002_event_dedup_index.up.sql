@@ -0,0 +1,4 @@
+-- جلوگیری از ثبت تکراری رویدادهای فعال
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_deduphash_active
+ON "Event"("dedupHash")
+WHERE "deletedAt" IS NULL;
@@ -0,0 +1,15 @@
+-- Session Table
+CREATE TABLE IF NOT EXISTS "Session" (
+    id TEXT DEFAULT gen_random_uuid()::text NOT NULL,  -- Auto-generated UUID (TEXT)
+    "tokenHash" TEXT NOT NULL,
+    "userId" TEXT,  -- UUID as TEXT
+    "authLogId" TEXT,  -- UUID as TEXT
+    ip TEXT,
+    "expiresAt" TIMESTAMPTZ NOT NULL,
+    "createdAt" TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_tokenhash
+ON "Session"("tokenHash");
@@ -0,0 +1,15 @@
+ALTER TABLE "User" ADD COLUMN "totpSecret" TEXT;
+ALTER TABLE "User" ADD COLUMN "totpEnabled" BOOLEAN DEFAULT false NOT NULL;
+ALTER TABLE "User" ADD COLUMN "totpLastStep" BIGINT DEFAULT 0 NOT NULL;
+ALTER TABLE "Session" ADD COLUMN stage TEXT;
+
+-- RecoveryCode Table
+CREATE TABLE IF NOT EXISTS "RecoveryCode" (
+    id TEXT DEFAULT gen_random_uuid()::text NOT NULL,  -- Auto-generated UUID (TEXT)
+    "userId" TEXT NOT NULL,  -- UUID as TEXT
+    "codeHash" TEXT NOT NULL,
+    "usedAt" TIMESTAMPTZ,
+    "createdAt" TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMPTZ
+);
@@ -0,0 +1,11 @@
+ALTER TABLE "User" ADD COLUMN "passwordChangedAt" TIMESTAMPTZ;
+
+-- PasswordHistory Table
+CREATE TABLE IF NOT EXISTS "PasswordHistory" (
+    id TEXT DEFAULT gen_random_uuid()::text NOT NULL,  -- Auto-generated UUID (TEXT)
+    "userId" TEXT NOT NULL,  -- UUID as TEXT
+    "passwordHash" TEXT NOT NULL,
+    "createdAt" TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMPTZ
+);
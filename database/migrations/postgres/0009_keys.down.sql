@@ -0,0 +1,62 @@
+ALTER TABLE "ActionLog" DROP CONSTRAINT IF EXISTS "ActionLog_userId_fkey";
+ALTER TABLE "Alarm" DROP CONSTRAINT IF EXISTS "Alarm_categoryId_fkey";
+ALTER TABLE "Alarm" DROP CONSTRAINT IF EXISTS "Alarm_panelTypeId_fkey";
+ALTER TABLE "AuthLog" DROP CONSTRAINT IF EXISTS "AuthLog_userId_fkey";
+ALTER TABLE "Branch" DROP CONSTRAINT IF EXISTS "Branch_locationId_fkey";
+ALTER TABLE "Branch" DROP CONSTRAINT IF EXISTS "Branch_mainPartitionId_fkey";
+ALTER TABLE "Branch" DROP CONSTRAINT IF EXISTS "Branch_panelTypeId_fkey";
+ALTER TABLE "Branch" DROP CONSTRAINT IF EXISTS "Branch_receiverId_fkey";
+ALTER TABLE "Employee" DROP CONSTRAINT IF EXISTS "Employee_branchId_fkey";
+ALTER TABLE "Equipment" DROP CONSTRAINT IF EXISTS "Equipment_branchId_fkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_alarmId_fkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_branchId_fkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_employeeId_fkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_partitionId_fkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_zoneId_fkey";
+ALTER TABLE "Location" DROP CONSTRAINT IF EXISTS "Location_parentId_fkey";
+ALTER TABLE "Partition" DROP CONSTRAINT IF EXISTS "Partition_branchDefaultId_fkey";
+ALTER TABLE "Partition" DROP CONSTRAINT IF EXISTS "Partition_branchId_fkey";
+ALTER TABLE "PasswordHistory" DROP CONSTRAINT IF EXISTS "PasswordHistory_userId_fkey";
+ALTER TABLE "PasswordResetRequest" DROP CONSTRAINT IF EXISTS "PasswordResetRequest_userId_fkey";
+ALTER TABLE "PersonalSetting" DROP CONSTRAINT IF EXISTS "PersonalSetting_userId_fkey";
+ALTER TABLE "RecoveryCode" DROP CONSTRAINT IF EXISTS "RecoveryCode_userId_fkey";
+ALTER TABLE "Session" DROP CONSTRAINT IF EXISTS "Session_userId_fkey";
+ALTER TABLE "User" DROP CONSTRAINT IF EXISTS "User_locationId_fkey";
+ALTER TABLE "UserLocation" DROP CONSTRAINT IF EXISTS "UserLocation_locationId_fkey";
+ALTER TABLE "UserLocation" DROP CONSTRAINT IF EXISTS "UserLocation_userId_fkey";
+ALTER TABLE "UserPermission" DROP CONSTRAINT IF EXISTS "UserPermission_permissionId_fkey";
+ALTER TABLE "UserPermission" DROP CONSTRAINT IF EXISTS "UserPermission_userId_fkey";
+ALTER TABLE "UserSetting" DROP CONSTRAINT IF EXISTS "UserSetting_alarmCategoryId_fkey";
+ALTER TABLE "UserSetting" DROP CONSTRAINT IF EXISTS "UserSetting_userId_fkey";
+ALTER TABLE "Zone" DROP CONSTRAINT IF EXISTS "Zone_partitionId_fkey";
+ALTER TABLE "Zone" DROP CONSTRAINT IF EXISTS "Zone_zoneTypeId_fkey";
+ALTER TABLE "ActionLog" DROP CONSTRAINT IF EXISTS "ActionLog_pkey";
+ALTER TABLE "Alarm" DROP CONSTRAINT IF EXISTS "Alarm_pkey";
+ALTER TABLE "AlarmCategory" DROP CONSTRAINT IF EXISTS "AlarmCategory_pkey";
+ALTER TABLE "AppSetting" DROP CONSTRAINT IF EXISTS "AppSetting_pkey";
+ALTER TABLE "AuthLog" DROP CONSTRAINT IF EXISTS "AuthLog_pkey";
+ALTER TABLE "Branch" DROP CONSTRAINT IF EXISTS "Branch_pkey";
+ALTER TABLE "Employee" DROP CONSTRAINT IF EXISTS "Employee_pkey";
+ALTER TABLE "Equipment" DROP CONSTRAINT IF EXISTS "Equipment_pkey";
+ALTER TABLE "Event" DROP CONSTRAINT IF EXISTS "Event_pkey";
+ALTER TABLE "Location" DROP CONSTRAINT IF EXISTS "Location_pkey";
+ALTER TABLE "Meta" DROP CONSTRAINT IF EXISTS "Meta_pkey";
+ALTER TABLE "PanelType" DROP CONSTRAINT IF EXISTS "PanelType_pkey";
+ALTER TABLE "Partition" DROP CONSTRAINT IF EXISTS "Partition_pkey";
+ALTER TABLE "PasswordHistory" DROP CONSTRAINT IF EXISTS "PasswordHistory_pkey";
+ALTER TABLE "PasswordResetRequest" DROP CONSTRAINT IF EXISTS "PasswordResetRequest_pkey";
+ALTER TABLE "Permission" DROP CONSTRAINT IF EXISTS "Permission_pkey";
+ALTER TABLE "PersonalSetting" DROP CONSTRAINT IF EXISTS "PersonalSetting_pkey";
+ALTER TABLE "Receiver" DROP CONSTRAINT IF EXISTS "Receiver_pkey";
+ALTER TABLE "RecoveryCode" DROP CONSTRAINT IF EXISTS "RecoveryCode_pkey";
+ALTER TABLE "Session" DROP CONSTRAINT IF EXISTS "Session_pkey";
+ALTER TABLE "User" DROP CONSTRAINT IF EXISTS "User_pkey";
+ALTER TABLE "UserLocation" DROP CONSTRAINT IF EXISTS "UserLocation_pkey";
+ALTER TABLE "UserPermission" DROP CONSTRAINT IF EXISTS "UserPermission_pkey";
+ALTER TABLE "UserSetting" DROP CONSTRAINT IF EXISTS "UserSetting_pkey";
+ALTER TABLE "Zone" DROP CONSTRAINT IF EXISTS "Zone_pkey";
+ALTER TABLE "ZoneType" DROP CONSTRAINT IF EXISTS "ZoneType_pkey";
+DROP INDEX IF EXISTS "idx_Receiver_dedupHash";
+ALTER TABLE "Location" DROP COLUMN IF EXISTS "createdAt";
+ALTER TABLE "Location" DROP COLUMN IF EXISTS "updatedAt";
+ALTER TABLE "Receiver" DROP COLUMN IF EXISTS "dedupHash";
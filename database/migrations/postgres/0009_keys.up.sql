@@ -0,0 +1,239 @@
+-- Primary and foreign keys, with the model columns the schema lacked (Location timestamps,
+-- Receiver.dedupHash), the same as sqlite/0009_keys. Empty strings used for "none" become
+-- NULL. References to rows that no longer exist stop the migration with a list of them
+-- instead of being dropped silently.
+DO $$
+DECLARE
+    orphans TEXT;
+BEGIN
+    SELECT string_agg(format('%s.%s -> %s: %s rows', tbl, col, parent, n), ', ')
+    INTO orphans
+    FROM (
+        SELECT 'ActionLog' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "ActionLog" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'Alarm' AS tbl, 'categoryId' AS col, 'AlarmCategory' AS parent, count(*) AS n FROM "Alarm" r
+            WHERE r."categoryId" <> '' AND NOT EXISTS (SELECT 1 FROM "AlarmCategory" p WHERE p.id = r."categoryId")
+        UNION ALL
+        SELECT 'Alarm' AS tbl, 'panelTypeId' AS col, 'PanelType' AS parent, count(*) AS n FROM "Alarm" r
+            WHERE r."panelTypeId" <> '' AND NOT EXISTS (SELECT 1 FROM "PanelType" p WHERE p.id = r."panelTypeId")
+        UNION ALL
+        SELECT 'AuthLog' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "AuthLog" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'Branch' AS tbl, 'locationId' AS col, 'Location' AS parent, count(*) AS n FROM "Branch" r
+            WHERE r."locationId" <> '' AND NOT EXISTS (SELECT 1 FROM "Location" p WHERE p.id = r."locationId")
+        UNION ALL
+        SELECT 'Branch' AS tbl, 'mainPartitionId' AS col, 'Partition' AS parent, count(*) AS n FROM "Branch" r
+            WHERE r."mainPartitionId" <> '' AND NOT EXISTS (SELECT 1 FROM "Partition" p WHERE p.id = r."mainPartitionId")
+        UNION ALL
+        SELECT 'Branch' AS tbl, 'panelTypeId' AS col, 'PanelType' AS parent, count(*) AS n FROM "Branch" r
+            WHERE r."panelTypeId" <> '' AND NOT EXISTS (SELECT 1 FROM "PanelType" p WHERE p.id = r."panelTypeId")
+        UNION ALL
+        SELECT 'Branch' AS tbl, 'receiverId' AS col, 'Receiver' AS parent, count(*) AS n FROM "Branch" r
+            WHERE r."receiverId" <> '' AND NOT EXISTS (SELECT 1 FROM "Receiver" p WHERE p.id = r."receiverId")
+        UNION ALL
+        SELECT 'Employee' AS tbl, 'branchId' AS col, 'Branch' AS parent, count(*) AS n FROM "Employee" r
+            WHERE r."branchId" <> '' AND NOT EXISTS (SELECT 1 FROM "Branch" p WHERE p.id = r."branchId")
+        UNION ALL
+        SELECT 'Equipment' AS tbl, 'branchId' AS col, 'Branch' AS parent, count(*) AS n FROM "Equipment" r
+            WHERE r."branchId" <> '' AND NOT EXISTS (SELECT 1 FROM "Branch" p WHERE p.id = r."branchId")
+        UNION ALL
+        SELECT 'Event' AS tbl, 'alarmId' AS col, 'Alarm' AS parent, count(*) AS n FROM "Event" r
+            WHERE r."alarmId" <> '' AND NOT EXISTS (SELECT 1 FROM "Alarm" p WHERE p.id = r."alarmId")
+        UNION ALL
+        SELECT 'Event' AS tbl, 'branchId' AS col, 'Branch' AS parent, count(*) AS n FROM "Event" r
+            WHERE r."branchId" <> '' AND NOT EXISTS (SELECT 1 FROM "Branch" p WHERE p.id = r."branchId")
+        UNION ALL
+        SELECT 'Event' AS tbl, 'employeeId' AS col, 'Employee' AS parent, count(*) AS n FROM "Event" r
+            WHERE r."employeeId" <> '' AND NOT EXISTS (SELECT 1 FROM "Employee" p WHERE p.id = r."employeeId")
+        UNION ALL
+        SELECT 'Event' AS tbl, 'partitionId' AS col, 'Partition' AS parent, count(*) AS n FROM "Event" r
+            WHERE r."partitionId" <> '' AND NOT EXISTS (SELECT 1 FROM "Partition" p WHERE p.id = r."partitionId")
+        UNION ALL
+        SELECT 'Event' AS tbl, 'zoneId' AS col, 'Zone' AS parent, count(*) AS n FROM "Event" r
+            WHERE r."zoneId" <> '' AND NOT EXISTS (SELECT 1 FROM "Zone" p WHERE p.id = r."zoneId")
+        UNION ALL
+        SELECT 'Location' AS tbl, 'parentId' AS col, 'Location' AS parent, count(*) AS n FROM "Location" r
+            WHERE r."parentId" <> '' AND NOT EXISTS (SELECT 1 FROM "Location" p WHERE p.id = r."parentId")
+        UNION ALL
+        SELECT 'Partition' AS tbl, 'branchDefaultId' AS col, 'Branch' AS parent, count(*) AS n FROM "Partition" r
+            WHERE r."branchDefaultId" <> '' AND NOT EXISTS (SELECT 1 FROM "Branch" p WHERE p.id = r."branchDefaultId")
+        UNION ALL
+        SELECT 'Partition' AS tbl, 'branchId' AS col, 'Branch' AS parent, count(*) AS n FROM "Partition" r
+            WHERE r."branchId" <> '' AND NOT EXISTS (SELECT 1 FROM "Branch" p WHERE p.id = r."branchId")
+        UNION ALL
+        SELECT 'PasswordHistory' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "PasswordHistory" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'PasswordResetRequest' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "PasswordResetRequest" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'PersonalSetting' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "PersonalSetting" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'RecoveryCode' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "RecoveryCode" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'Session' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "Session" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'User' AS tbl, 'locationId' AS col, 'Location' AS parent, count(*) AS n FROM "User" r
+            WHERE r."locationId" <> '' AND NOT EXISTS (SELECT 1 FROM "Location" p WHERE p.id = r."locationId")
+        UNION ALL
+        SELECT 'UserLocation' AS tbl, 'locationId' AS col, 'Location' AS parent, count(*) AS n FROM "UserLocation" r
+            WHERE r."locationId" <> '' AND NOT EXISTS (SELECT 1 FROM "Location" p WHERE p.id = r."locationId")
+        UNION ALL
+        SELECT 'UserLocation' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "UserLocation" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'UserPermission' AS tbl, 'permissionId' AS col, 'Permission' AS parent, count(*) AS n FROM "UserPermission" r
+            WHERE r."permissionId" <> '' AND NOT EXISTS (SELECT 1 FROM "Permission" p WHERE p.id = r."permissionId")
+        UNION ALL
+        SELECT 'UserPermission' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "UserPermission" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'UserSetting' AS tbl, 'alarmCategoryId' AS col, 'AlarmCategory' AS parent, count(*) AS n FROM "UserSetting" r
+            WHERE r."alarmCategoryId" <> '' AND NOT EXISTS (SELECT 1 FROM "AlarmCategory" p WHERE p.id = r."alarmCategoryId")
+        UNION ALL
+        SELECT 'UserSetting' AS tbl, 'userId' AS col, 'User' AS parent, count(*) AS n FROM "UserSetting" r
+            WHERE r."userId" <> '' AND NOT EXISTS (SELECT 1 FROM "User" p WHERE p.id = r."userId")
+        UNION ALL
+        SELECT 'Zone' AS tbl, 'partitionId' AS col, 'Partition' AS parent, count(*) AS n FROM "Zone" r
+            WHERE r."partitionId" <> '' AND NOT EXISTS (SELECT 1 FROM "Partition" p WHERE p.id = r."partitionId")
+        UNION ALL
+        SELECT 'Zone' AS tbl, 'zoneTypeId' AS col, 'ZoneType' AS parent, count(*) AS n FROM "Zone" r
+            WHERE r."zoneTypeId" <> '' AND NOT EXISTS (SELECT 1 FROM "ZoneType" p WHERE p.id = r."zoneTypeId")
+    ) refs
+    WHERE n > 0;
+    IF orphans IS NOT NULL THEN
+        RAISE EXCEPTION 'rows reference missing records, fix or delete them first: %', orphans;
+    END IF;
+END $$;
+ALTER TABLE "Location" ADD COLUMN IF NOT EXISTS "createdAt" TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL;
+ALTER TABLE "Location" ADD COLUMN IF NOT EXISTS "updatedAt" TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP NOT NULL;
+ALTER TABLE "Receiver" ADD COLUMN IF NOT EXISTS "dedupHash" TEXT;
+CREATE INDEX IF NOT EXISTS "idx_Receiver_dedupHash"
+ON "Receiver"("dedupHash");
+ALTER TABLE "ActionLog" ADD CONSTRAINT "ActionLog_pkey" PRIMARY KEY (id);
+ALTER TABLE "Alarm" ADD CONSTRAINT "Alarm_pkey" PRIMARY KEY (id);
+ALTER TABLE "AlarmCategory" ADD CONSTRAINT "AlarmCategory_pkey" PRIMARY KEY (id);
+ALTER TABLE "AppSetting" ADD CONSTRAINT "AppSetting_pkey" PRIMARY KEY (id);
+ALTER TABLE "AuthLog" ADD CONSTRAINT "AuthLog_pkey" PRIMARY KEY (id);
+ALTER TABLE "Branch" ADD CONSTRAINT "Branch_pkey" PRIMARY KEY (id);
+ALTER TABLE "Employee" ADD CONSTRAINT "Employee_pkey" PRIMARY KEY (id);
+ALTER TABLE "Equipment" ADD CONSTRAINT "Equipment_pkey" PRIMARY KEY (id);
+ALTER TABLE "Event" ADD CONSTRAINT "Event_pkey" PRIMARY KEY (id);
+ALTER TABLE "Location" ADD CONSTRAINT "Location_pkey" PRIMARY KEY (id);
+ALTER TABLE "Meta" ADD CONSTRAINT "Meta_pkey" PRIMARY KEY (id);
+ALTER TABLE "PanelType" ADD CONSTRAINT "PanelType_pkey" PRIMARY KEY (id);
+ALTER TABLE "Partition" ADD CONSTRAINT "Partition_pkey" PRIMARY KEY (id);
+ALTER TABLE "PasswordHistory" ADD CONSTRAINT "PasswordHistory_pkey" PRIMARY KEY (id);
+ALTER TABLE "PasswordResetRequest" ADD CONSTRAINT "PasswordResetRequest_pkey" PRIMARY KEY (id);
+ALTER TABLE "Permission" ADD CONSTRAINT "Permission_pkey" PRIMARY KEY (id);
+ALTER TABLE "PersonalSetting" ADD CONSTRAINT "PersonalSetting_pkey" PRIMARY KEY (id);
+ALTER TABLE "Receiver" ADD CONSTRAINT "Receiver_pkey" PRIMARY KEY (id);
+ALTER TABLE "RecoveryCode" ADD CONSTRAINT "RecoveryCode_pkey" PRIMARY KEY (id);
+ALTER TABLE "Session" ADD CONSTRAINT "Session_pkey" PRIMARY KEY (id);
+ALTER TABLE "User" ADD CONSTRAINT "User_pkey" PRIMARY KEY (id);
+ALTER TABLE "UserLocation" ADD CONSTRAINT "UserLocation_pkey" PRIMARY KEY (id);
+ALTER TABLE "UserPermission" ADD CONSTRAINT "UserPermission_pkey" PRIMARY KEY (id);
+ALTER TABLE "UserSetting" ADD CONSTRAINT "UserSetting_pkey" PRIMARY KEY (id);
+ALTER TABLE "Zone" ADD CONSTRAINT "Zone_pkey" PRIMARY KEY (id);
+ALTER TABLE "ZoneType" ADD CONSTRAINT "ZoneType_pkey" PRIMARY KEY (id);
+UPDATE "ActionLog" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "ActionLog" ADD CONSTRAINT "ActionLog_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Alarm" SET "categoryId" = NULL WHERE "categoryId" = '';
+ALTER TABLE "Alarm" ADD CONSTRAINT "Alarm_categoryId_fkey"
+    FOREIGN KEY ("categoryId") REFERENCES "AlarmCategory"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Alarm" SET "panelTypeId" = NULL WHERE "panelTypeId" = '';
+ALTER TABLE "Alarm" ADD CONSTRAINT "Alarm_panelTypeId_fkey"
+    FOREIGN KEY ("panelTypeId") REFERENCES "PanelType"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "AuthLog" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "AuthLog" ADD CONSTRAINT "AuthLog_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Branch" SET "locationId" = NULL WHERE "locationId" = '';
+ALTER TABLE "Branch" ADD CONSTRAINT "Branch_locationId_fkey"
+    FOREIGN KEY ("locationId") REFERENCES "Location"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Branch" SET "mainPartitionId" = NULL WHERE "mainPartitionId" = '';
+ALTER TABLE "Branch" ADD CONSTRAINT "Branch_mainPartitionId_fkey"
+    FOREIGN KEY ("mainPartitionId") REFERENCES "Partition"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Branch" SET "panelTypeId" = NULL WHERE "panelTypeId" = '';
+ALTER TABLE "Branch" ADD CONSTRAINT "Branch_panelTypeId_fkey"
+    FOREIGN KEY ("panelTypeId") REFERENCES "PanelType"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Branch" SET "receiverId" = NULL WHERE "receiverId" = '';
+ALTER TABLE "Branch" ADD CONSTRAINT "Branch_receiverId_fkey"
+    FOREIGN KEY ("receiverId") REFERENCES "Receiver"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Employee" SET "branchId" = NULL WHERE "branchId" = '';
+ALTER TABLE "Employee" ADD CONSTRAINT "Employee_branchId_fkey"
+    FOREIGN KEY ("branchId") REFERENCES "Branch"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "Equipment" SET "branchId" = NULL WHERE "branchId" = '';
+ALTER TABLE "Equipment" ADD CONSTRAINT "Equipment_branchId_fkey"
+    FOREIGN KEY ("branchId") REFERENCES "Branch"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "Event" SET "alarmId" = NULL WHERE "alarmId" = '';
+ALTER TABLE "Event" ADD CONSTRAINT "Event_alarmId_fkey"
+    FOREIGN KEY ("alarmId") REFERENCES "Alarm"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Event" SET "branchId" = NULL WHERE "branchId" = '';
+ALTER TABLE "Event" ADD CONSTRAINT "Event_branchId_fkey"
+    FOREIGN KEY ("branchId") REFERENCES "Branch"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Event" SET "employeeId" = NULL WHERE "employeeId" = '';
+ALTER TABLE "Event" ADD CONSTRAINT "Event_employeeId_fkey"
+    FOREIGN KEY ("employeeId") REFERENCES "Employee"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Event" SET "partitionId" = NULL WHERE "partitionId" = '';
+ALTER TABLE "Event" ADD CONSTRAINT "Event_partitionId_fkey"
+    FOREIGN KEY ("partitionId") REFERENCES "Partition"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Event" SET "zoneId" = NULL WHERE "zoneId" = '';
+ALTER TABLE "Event" ADD CONSTRAINT "Event_zoneId_fkey"
+    FOREIGN KEY ("zoneId") REFERENCES "Zone"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Location" SET "parentId" = NULL WHERE "parentId" = '';
+ALTER TABLE "Location" ADD CONSTRAINT "Location_parentId_fkey"
+    FOREIGN KEY ("parentId") REFERENCES "Location"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "Partition" SET "branchDefaultId" = NULL WHERE "branchDefaultId" = '';
+ALTER TABLE "Partition" ADD CONSTRAINT "Partition_branchDefaultId_fkey"
+    FOREIGN KEY ("branchDefaultId") REFERENCES "Branch"(id) ON UPDATE CASCADE ON DELETE SET NULL;
+UPDATE "Partition" SET "branchId" = NULL WHERE "branchId" = '';
+ALTER TABLE "Partition" ADD CONSTRAINT "Partition_branchId_fkey"
+    FOREIGN KEY ("branchId") REFERENCES "Branch"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "PasswordHistory" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "PasswordHistory" ADD CONSTRAINT "PasswordHistory_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "PasswordResetRequest" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "PasswordResetRequest" ADD CONSTRAINT "PasswordResetRequest_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "PersonalSetting" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "PersonalSetting" ADD CONSTRAINT "PersonalSetting_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "RecoveryCode" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "RecoveryCode" ADD CONSTRAINT "RecoveryCode_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "Session" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "Session" ADD CONSTRAINT "Session_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "User" SET "locationId" = NULL WHERE "locationId" = '';
+ALTER TABLE "User" ADD CONSTRAINT "User_locationId_fkey"
+    FOREIGN KEY ("locationId") REFERENCES "Location"(id) ON UPDATE CASCADE ON DELETE RESTRICT;
+UPDATE "UserLocation" SET "locationId" = NULL WHERE "locationId" = '';
+ALTER TABLE "UserLocation" ADD CONSTRAINT "UserLocation_locationId_fkey"
+    FOREIGN KEY ("locationId") REFERENCES "Location"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "UserLocation" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "UserLocation" ADD CONSTRAINT "UserLocation_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "UserPermission" SET "permissionId" = NULL WHERE "permissionId" = '';
+ALTER TABLE "UserPermission" ADD CONSTRAINT "UserPermission_permissionId_fkey"
+    FOREIGN KEY ("permissionId") REFERENCES "Permission"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "UserPermission" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "UserPermission" ADD CONSTRAINT "UserPermission_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "UserSetting" SET "alarmCategoryId" = NULL WHERE "alarmCategoryId" = '';
+ALTER TABLE "UserSetting" ADD CONSTRAINT "UserSetting_alarmCategoryId_fkey"
+    FOREIGN KEY ("alarmCategoryId") REFERENCES "AlarmCategory"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "UserSetting" SET "userId" = NULL WHERE "userId" = '';
+ALTER TABLE "UserSetting" ADD CONSTRAINT "UserSetting_userId_fkey"
+    FOREIGN KEY ("userId") REFERENCES "User"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "Zone" SET "partitionId" = NULL WHERE "partitionId" = '';
+ALTER TABLE "Zone" ADD CONSTRAINT "Zone_partitionId_fkey"
+    FOREIGN KEY ("partitionId") REFERENCES "Partition"(id) ON UPDATE CASCADE ON DELETE CASCADE;
+UPDATE "Zone" SET "zoneTypeId" = NULL WHERE "zoneTypeId" = '';
+ALTER TABLE "Zone" ADD CONSTRAINT "Zone_zoneTypeId_fkey"
+    FOREIGN KEY ("zoneTypeId") REFERENCES "ZoneType"(id) ON UPDATE CASCADE ON DELETE CASCADE;
@@ -0,0 +1,14 @@
+DROP INDEX IF EXISTS "idx_Location_old_id";
+DROP INDEX IF EXISTS "idx_Receiver_old_id";
+DROP INDEX IF EXISTS "idx_PanelType_old_id";
+DROP INDEX IF EXISTS "idx_ZoneType_old_id";
+DROP INDEX IF EXISTS "idx_AlarmCategory_old_id";
+DROP INDEX IF EXISTS "idx_Alarm_old_id";
+DROP INDEX IF EXISTS "idx_Branch_old_id";
+DROP INDEX IF EXISTS "idx_Partition_old_id";
+DROP INDEX IF EXISTS "idx_Zone_old_id";
+DROP INDEX IF EXISTS "idx_Employee_old_id";
+DROP INDEX IF EXISTS "idx_Equipment_old_id";
+DROP INDEX IF EXISTS "idx_User_old_id";
+DROP INDEX IF EXISTS "idx_UserPermission_old_id";
+DROP INDEX IF EXISTS "idx_Event_old_id";
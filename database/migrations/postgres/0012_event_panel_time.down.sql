@@ -0,0 +1,6 @@
+ALTER TABLE "Branch" DROP COLUMN "timeZone";
+
+DROP INDEX IF EXISTS "idx_Event_panelTime_pending";
+DROP INDEX IF EXISTS "idx_Event_panelTime";
+ALTER TABLE "Event" DROP COLUMN "clockDrift";
+ALTER TABLE "Event" DROP COLUMN "panelTime";
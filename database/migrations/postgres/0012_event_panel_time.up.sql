@@ -0,0 +1,7 @@
+-- زمان گزارش‌شده توسط پنل به صورت timestamp واقعی؛ ردیف‌های قبلی در پس‌زمینه از date و time پر می‌شوند
+ALTER TABLE "Event" ADD COLUMN "panelTime" TIMESTAMPTZ;
+ALTER TABLE "Event" ADD COLUMN "clockDrift" INTEGER;
+CREATE INDEX IF NOT EXISTS "idx_Event_panelTime" ON "Event"("panelTime");
+CREATE INDEX IF NOT EXISTS "idx_Event_panelTime_pending" ON "Event"(id) WHERE "panelTime" IS NULL AND "date" <> '';
+
+ALTER TABLE "Branch" ADD COLUMN "timeZone" TEXT NOT NULL DEFAULT '';
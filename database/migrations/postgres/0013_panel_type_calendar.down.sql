@@ -0,0 +1 @@
+ALTER TABLE "PanelType" DROP COLUMN "calendar";
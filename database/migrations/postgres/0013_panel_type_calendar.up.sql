@@ -0,0 +1,2 @@
+-- تقویمی که پنل تاریخ رویداد را با آن می‌فرستد (gregorian یا jalali)
+ALTER TABLE "PanelType" ADD COLUMN "calendar" TEXT NOT NULL DEFAULT 'gregorian';
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS "idx_Branch_locationId";
+DROP INDEX IF EXISTS "idx_Alarm_categoryId";
+DROP INDEX IF EXISTS "idx_Event_confirmationStatus_createdAt";
+DROP INDEX IF EXISTS "idx_Event_zoneId_createdAt";
+DROP INDEX IF EXISTS "idx_Event_alarmId_createdAt";
+DROP INDEX IF EXISTS "idx_Event_branchId_createdAt";
+DROP INDEX IF EXISTS "idx_Event_createdAt_id";
+CREATE INDEX IF NOT EXISTS "idx_Event_createdAt" ON "Event"("createdAt");
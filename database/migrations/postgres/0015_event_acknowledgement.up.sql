@@ -0,0 +1,8 @@
+-- زمان تایید رویداد و هشدار کاذب بودن آن برای گزارش‌ها؛ تاییدهای قبلی از ActionLog خوانده می‌شوند
+CREATE INDEX IF NOT EXISTS "idx_ActionLog_model_id" ON "ActionLog"(model, model_id);
+ALTER TABLE "Event" ADD COLUMN "confirmedAt" TIMESTAMPTZ;
+ALTER TABLE "Event" ADD COLUMN "falseAlarm" BOOLEAN NOT NULL DEFAULT false;
+UPDATE "Event" SET "confirmedAt" = (
+    SELECT MIN(l."createdAt") FROM "ActionLog" l
+    WHERE l.model = 'Event' AND l.model_id = "Event".id AND l.action = 'CONFIRMED'
+) WHERE "confirmationStatus" <> 'Unconfirmed';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS "idx_Event_panelTime_pending";
+CREATE INDEX IF NOT EXISTS "idx_Event_panelTime_pending" ON "Event"(id) WHERE "panelTime" IS NULL AND "date" <> '';
+ALTER TABLE "Event" DROP COLUMN "panelTimeInvalid";
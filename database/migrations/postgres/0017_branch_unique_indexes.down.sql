@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS "idx_Employee_branchId_localId";
+DROP INDEX IF EXISTS "idx_Zone_partitionId_localId";
+DROP INDEX IF EXISTS "idx_Partition_branchId_localId";
+DROP INDEX IF EXISTS "idx_Branch_receiverId_panelCode";
@@ -0,0 +1,21 @@
+DROP TABLE IF EXISTS "ActionLog";
+DROP TABLE IF EXISTS "Alarm";
+DROP TABLE IF EXISTS "AlarmCategory";
+DROP TABLE IF EXISTS "AppSetting";
+DROP TABLE IF EXISTS "AuthLog";
+DROP TABLE IF EXISTS "Branch";
+DROP TABLE IF EXISTS "Employee";
+DROP TABLE IF EXISTS "Equipment";
+DROP TABLE IF EXISTS "Event";
+DROP TABLE IF EXISTS "Location";
+DROP TABLE IF EXISTS "Meta";
+DROP TABLE IF EXISTS "PanelType";
+DROP TABLE IF EXISTS "Partition";
+DROP TABLE IF EXISTS "Permission";
+DROP TABLE IF EXISTS "PersonalSetting";
+DROP TABLE IF EXISTS "Receiver";
+DROP TABLE IF EXISTS "User";
+DROP TABLE IF EXISTS "UserPermission";
+DROP TABLE IF EXISTS "UserSetting";
+DROP TABLE IF EXISTS "Zone";
+DROP TABLE IF EXISTS "ZoneType";
@@ -151,10 +151,6 @@ CREATE TABLE IF NOT EXISTS Event (
     "dedupHash" TEXT
 );
 
-CREATE UNIQUE INDEX IF NOT EXISTS idx_event_deduphash_active
-ON "Event"("dedupHash")
-WHERE "deletedAt" IS NULL;
-
 -- Location Table
 CREATE TABLE IF NOT EXISTS Location (
     old_id INTEGER,
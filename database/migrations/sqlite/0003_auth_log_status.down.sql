@@ -0,0 +1,3 @@
+ALTER TABLE "AuthLog" DROP COLUMN reason;
+ALTER TABLE "AuthLog" DROP COLUMN status;
+ALTER TABLE "AuthLog" DROP COLUMN username;
@@ -0,0 +1,3 @@
+ALTER TABLE "AuthLog" ADD COLUMN username TEXT;
+ALTER TABLE "AuthLog" ADD COLUMN status TEXT;  -- ENUM replaced with TEXT
+ALTER TABLE "AuthLog" ADD COLUMN reason TEXT;
@@ -0,0 +1,15 @@
+-- Session Table
+CREATE TABLE IF NOT EXISTS Session (
+    id TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,  -- Auto-generated UUID (TEXT)
+    "tokenHash" TEXT NOT NULL,
+    "userId" TEXT,  -- UUID as TEXT
+    "authLogId" TEXT,  -- UUID as TEXT
+    ip TEXT,
+    "expiresAt" TIMESTAMP NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_session_tokenhash
+ON "Session"("tokenHash");
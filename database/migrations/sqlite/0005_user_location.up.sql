@@ -0,0 +1,9 @@
+-- UserLocation Table
+CREATE TABLE IF NOT EXISTS UserLocation (
+    id TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,  -- Auto-generated UUID (TEXT)
+    "userId" TEXT NOT NULL,  -- UUID as TEXT
+    "locationId" TEXT NOT NULL,  -- UUID as TEXT
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
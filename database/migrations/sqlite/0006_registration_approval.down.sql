@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "PasswordResetRequest";
+ALTER TABLE "User" DROP COLUMN "mustChangePassword";
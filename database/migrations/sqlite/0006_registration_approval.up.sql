@@ -0,0 +1,16 @@
+ALTER TABLE "User" ADD COLUMN "mustChangePassword" BOOLEAN DEFAULT false NOT NULL;
+
+-- PasswordResetRequest Table
+CREATE TABLE IF NOT EXISTS PasswordResetRequest (
+    id TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,  -- Auto-generated UUID (TEXT)
+    "userId" TEXT,  -- UUID as TEXT
+    username TEXT NOT NULL,
+    "phoneNumber" TEXT,
+    status TEXT DEFAULT 'PENDING' NOT NULL,  -- ENUM replaced with TEXT
+    "requestedAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "resolvedAt" TIMESTAMP,
+    "resolvedBy" TEXT,  -- UUID as TEXT
+    note TEXT,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
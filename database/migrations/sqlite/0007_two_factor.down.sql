@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS "RecoveryCode";
+ALTER TABLE "Session" DROP COLUMN stage;
+ALTER TABLE "User" DROP COLUMN "totpLastStep";
+ALTER TABLE "User" DROP COLUMN "totpEnabled";
+ALTER TABLE "User" DROP COLUMN "totpSecret";
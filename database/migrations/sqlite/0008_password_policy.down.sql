@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "PasswordHistory";
+ALTER TABLE "User" DROP COLUMN "passwordChangedAt";
@@ -0,0 +1,11 @@
+ALTER TABLE "User" ADD COLUMN "passwordChangedAt" TIMESTAMP;
+
+-- PasswordHistory Table
+CREATE TABLE IF NOT EXISTS PasswordHistory (
+    id TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,  -- Auto-generated UUID (TEXT)
+    "userId" TEXT NOT NULL,  -- UUID as TEXT
+    "passwordHash" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    version INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
@@ -0,0 +1,501 @@
+-- ActionLog Table
+CREATE TABLE "ActionLog_new" (
+    "old_id" INTEGER,
+    "model" TEXT NOT NULL,
+    "action" TEXT NOT NULL,
+    "note" TEXT,
+    "userInfo" JSON,
+    "changedFields" JSON,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "userId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "model_id" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "ActionLog_new" ("old_id", "model", "action", "note", "userInfo", "changedFields", "createdAt", "userId", "id", "model_id", "version", "deletedAt")
+SELECT "old_id", "model", "action", "note", "userInfo", "changedFields", "createdAt", "userId", "id", "model_id", "version", "deletedAt" FROM "ActionLog";
+DROP TABLE "ActionLog";
+ALTER TABLE "ActionLog_new" RENAME TO "ActionLog";
+
+-- Alarm Table
+CREATE TABLE "Alarm_new" (
+    "old_id" INTEGER,
+    "code" INTEGER NOT NULL,
+    "label" TEXT NOT NULL,
+    "type" TEXT NOT NULL,
+    "protocol" TEXT NOT NULL,
+    "description" TEXT,
+    "action" TEXT DEFAULT 'NONE' NOT NULL,
+    "old_panelTypeId" INTEGER,
+    "categoryId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "panelTypeId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Alarm_new" ("old_id", "code", "label", "type", "protocol", "description", "action", "old_panelTypeId", "categoryId", "id", "panelTypeId", "version", "createdAt", "updatedAt", "deletedAt")
+SELECT "old_id", "code", "label", "type", "protocol", "description", "action", "old_panelTypeId", "categoryId", "id", "panelTypeId", "version", "createdAt", "updatedAt", "deletedAt" FROM "Alarm";
+DROP TABLE "Alarm";
+ALTER TABLE "Alarm_new" RENAME TO "Alarm";
+
+-- AlarmCategory Table
+CREATE TABLE "AlarmCategory_new" (
+    "old_id" INTEGER,
+    "label" TEXT NOT NULL,
+    "code" INTEGER NOT NULL,
+    "needsApproval" BOOLEAN DEFAULT false NOT NULL,
+    "priority" TEXT DEFAULT 'NONE' NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "updatedAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+INSERT INTO "AlarmCategory_new" ("old_id", "label", "code", "needsApproval", "priority", "createdAt", "id", "version", "deletedAt", "updatedAt")
+SELECT "old_id", "label", "code", "needsApproval", "priority", "createdAt", "id", "version", "deletedAt", "updatedAt" FROM "AlarmCategory";
+DROP TABLE "AlarmCategory";
+ALTER TABLE "AlarmCategory_new" RENAME TO "AlarmCategory";
+
+-- AppSetting Table
+CREATE TABLE "AppSetting_new" (
+    "old_id" INTEGER,
+    "key" TEXT NOT NULL,
+    "value" TEXT,
+    "isVisible" BOOLEAN DEFAULT true NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "AppSetting_new" ("old_id", "key", "value", "isVisible", "id", "version", "deletedAt")
+SELECT "old_id", "key", "value", "isVisible", "id", "version", "deletedAt" FROM "AppSetting";
+DROP TABLE "AppSetting";
+ALTER TABLE "AppSetting_new" RENAME TO "AppSetting";
+
+-- AuthLog Table
+CREATE TABLE "AuthLog_new" (
+    "old_id" INTEGER,
+    "ip" TEXT,
+    "loginTime" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "logoutTime" TIMESTAMP NOT NULL,
+    "userId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "username" TEXT,
+    "status" TEXT,
+    "reason" TEXT
+);
+INSERT INTO "AuthLog_new" ("old_id", "ip", "loginTime", "logoutTime", "userId", "id", "version", "deletedAt", "username", "status", "reason")
+SELECT "old_id", "ip", "loginTime", "logoutTime", "userId", "id", "version", "deletedAt", "username", "status", "reason" FROM "AuthLog";
+DROP TABLE "AuthLog";
+ALTER TABLE "AuthLog_new" RENAME TO "AuthLog";
+
+-- Branch Table
+CREATE TABLE "Branch_new" (
+    "old_id" INTEGER,
+    "name" TEXT NOT NULL,
+    "old_locationId" INTEGER,
+    "code" INTEGER NOT NULL,
+    "address" TEXT,
+    "phoneNumber" TEXT,
+    "destinationPhoneNumber" TEXT,
+    "imgUrl" TEXT,
+    "panelIp" TEXT,
+    "panelCode" INTEGER,
+    "emergencyCall" TEXT,
+    "old_panelTypeId" INTEGER,
+    "old_receiverId" INTEGER,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "receiverId" TEXT,
+    "panelTypeId" TEXT,
+    "mainPartitionId" TEXT,
+    "locationId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Branch_new" ("old_id", "name", "old_locationId", "code", "address", "phoneNumber", "destinationPhoneNumber", "imgUrl", "panelIp", "panelCode", "emergencyCall", "old_panelTypeId", "old_receiverId", "createdAt", "updatedAt", "id", "receiverId", "panelTypeId", "mainPartitionId", "locationId", "version", "deletedAt")
+SELECT "old_id", "name", "old_locationId", "code", "address", "phoneNumber", "destinationPhoneNumber", "imgUrl", "panelIp", "panelCode", "emergencyCall", "old_panelTypeId", "old_receiverId", "createdAt", "updatedAt", "id", "receiverId", "panelTypeId", "mainPartitionId", "locationId", "version", "deletedAt" FROM "Branch";
+DROP TABLE "Branch";
+ALTER TABLE "Branch_new" RENAME TO "Branch";
+
+-- Employee Table
+CREATE TABLE "Employee_new" (
+    "old_id" INTEGER,
+    "localId" INTEGER NOT NULL,
+    "name" TEXT NOT NULL,
+    "lastName" TEXT NOT NULL,
+    "position" TEXT NOT NULL,
+    "nationalCode" TEXT,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "branchId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Employee_new" ("old_id", "localId", "name", "lastName", "position", "nationalCode", "createdAt", "updatedAt", "branchId", "id", "version", "deletedAt")
+SELECT "old_id", "localId", "name", "lastName", "position", "nationalCode", "createdAt", "updatedAt", "branchId", "id", "version", "deletedAt" FROM "Employee";
+DROP TABLE "Employee";
+ALTER TABLE "Employee_new" RENAME TO "Employee";
+
+-- Equipment Table
+CREATE TABLE "Equipment_new" (
+    "old_id" INTEGER,
+    "name" TEXT NOT NULL,
+    "model" TEXT NOT NULL,
+    "imgUrl" TEXT,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "branchId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Equipment_new" ("old_id", "name", "model", "imgUrl", "createdAt", "updatedAt", "branchId", "id", "version", "deletedAt")
+SELECT "old_id", "name", "model", "imgUrl", "createdAt", "updatedAt", "branchId", "id", "version", "deletedAt" FROM "Equipment";
+DROP TABLE "Equipment";
+ALTER TABLE "Equipment_new" RENAME TO "Equipment";
+
+-- Event Table
+CREATE TABLE "Event_new" (
+    "originalZoneId" TEXT,
+    "originalPartitionId" TEXT,
+    "referenceId" TEXT,
+    "time" TEXT NOT NULL,
+    "date" TEXT NOT NULL,
+    "originalEmployeeId" TEXT,
+    "originalBranchCode" TEXT,
+    "ip" TEXT,
+    "description" TEXT,
+    "confirmationStatus" TEXT,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "alarmId" TEXT,
+    "branchId" TEXT,
+    "zoneId" TEXT,
+    "partitionId" TEXT,
+    "employeeId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "old_id" INTEGER,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "dedupHash" TEXT
+);
+INSERT INTO "Event_new" ("originalZoneId", "originalPartitionId", "referenceId", "time", "date", "originalEmployeeId", "originalBranchCode", "ip", "description", "confirmationStatus", "createdAt", "alarmId", "branchId", "zoneId", "partitionId", "employeeId", "id", "old_id", "version", "deletedAt", "dedupHash")
+SELECT "originalZoneId", "originalPartitionId", "referenceId", "time", "date", "originalEmployeeId", "originalBranchCode", "ip", "description", "confirmationStatus", "createdAt", "alarmId", "branchId", "zoneId", "partitionId", "employeeId", "id", "old_id", "version", "deletedAt", "dedupHash" FROM "Event";
+DROP TABLE "Event";
+ALTER TABLE "Event_new" RENAME TO "Event";
+CREATE UNIQUE INDEX IF NOT EXISTS idx_event_deduphash_active
+ON "Event"("dedupHash")
+WHERE "deletedAt" IS NULL;
+
+-- Location Table
+CREATE TABLE "Location_new" (
+    "old_id" INTEGER,
+    "label" TEXT NOT NULL,
+    "old_parentId" INTEGER,
+    "type" TEXT NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "parentId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "sort" INTEGER
+);
+INSERT INTO "Location_new" ("old_id", "label", "old_parentId", "type", "id", "parentId", "version", "deletedAt", "sort")
+SELECT "old_id", "label", "old_parentId", "type", "id", "parentId", "version", "deletedAt", "sort" FROM "Location";
+DROP TABLE "Location";
+ALTER TABLE "Location_new" RENAME TO "Location";
+
+-- Meta Table
+CREATE TABLE "Meta_new" (
+    "old_id" INTEGER,
+    "key" TEXT NOT NULL,
+    "processId" TEXT NOT NULL,
+    "value" TEXT,
+    "expiresAt" INTEGER NOT NULL,
+    "timeElapsed" INTEGER NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Meta_new" ("old_id", "key", "processId", "value", "expiresAt", "timeElapsed", "createdAt", "updatedAt", "id", "version", "deletedAt")
+SELECT "old_id", "key", "processId", "value", "expiresAt", "timeElapsed", "createdAt", "updatedAt", "id", "version", "deletedAt" FROM "Meta";
+DROP TABLE "Meta";
+ALTER TABLE "Meta_new" RENAME TO "Meta";
+
+-- PanelType Table
+CREATE TABLE "PanelType_new" (
+    "old_id" INTEGER,
+    "name" TEXT NOT NULL,
+    "model" TEXT NOT NULL,
+    "code" INTEGER NOT NULL,
+    "delimiter" TEXT NOT NULL,
+    "eventFormat" TEXT[],
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "PanelType_new" ("old_id", "name", "model", "code", "delimiter", "eventFormat", "createdAt", "updatedAt", "id", "version", "deletedAt")
+SELECT "old_id", "name", "model", "code", "delimiter", "eventFormat", "createdAt", "updatedAt", "id", "version", "deletedAt" FROM "PanelType";
+DROP TABLE "PanelType";
+ALTER TABLE "PanelType_new" RENAME TO "PanelType";
+
+-- Partition Table
+CREATE TABLE "Partition_new" (
+    "old_id" INTEGER,
+    "label" TEXT NOT NULL,
+    "localId" INTEGER NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "old_branchDefaultId" INTEGER,
+    "branchId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "branchDefaultId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Partition_new" ("old_id", "label", "localId", "createdAt", "updatedAt", "old_branchDefaultId", "branchId", "id", "branchDefaultId", "version", "deletedAt")
+SELECT "old_id", "label", "localId", "createdAt", "updatedAt", "old_branchDefaultId", "branchId", "id", "branchDefaultId", "version", "deletedAt" FROM "Partition";
+DROP TABLE "Partition";
+ALTER TABLE "Partition_new" RENAME TO "Partition";
+
+-- PasswordHistory Table
+CREATE TABLE "PasswordHistory_new" (
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "userId" TEXT NOT NULL,
+    "passwordHash" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "PasswordHistory_new" ("id", "userId", "passwordHash", "createdAt", "version", "deletedAt")
+SELECT "id", "userId", "passwordHash", "createdAt", "version", "deletedAt" FROM "PasswordHistory";
+DROP TABLE "PasswordHistory";
+ALTER TABLE "PasswordHistory_new" RENAME TO "PasswordHistory";
+
+-- PasswordResetRequest Table
+CREATE TABLE "PasswordResetRequest_new" (
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "userId" TEXT,
+    "username" TEXT NOT NULL,
+    "phoneNumber" TEXT,
+    "status" TEXT DEFAULT 'PENDING' NOT NULL,
+    "requestedAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "resolvedAt" TIMESTAMP,
+    "resolvedBy" TEXT,
+    "note" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "PasswordResetRequest_new" ("id", "userId", "username", "phoneNumber", "status", "requestedAt", "resolvedAt", "resolvedBy", "note", "version", "deletedAt")
+SELECT "id", "userId", "username", "phoneNumber", "status", "requestedAt", "resolvedAt", "resolvedBy", "note", "version", "deletedAt" FROM "PasswordResetRequest";
+DROP TABLE "PasswordResetRequest";
+ALTER TABLE "PasswordResetRequest_new" RENAME TO "PasswordResetRequest";
+
+-- Permission Table
+CREATE TABLE "Permission_new" (
+    "action" TEXT NOT NULL,
+    "model" TEXT NOT NULL,
+    "field" TEXT,
+    "description" TEXT,
+    "old_id" INTEGER,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Permission_new" ("action", "model", "field", "description", "old_id", "id", "version", "deletedAt")
+SELECT "action", "model", "field", "description", "old_id", "id", "version", "deletedAt" FROM "Permission";
+DROP TABLE "Permission";
+ALTER TABLE "Permission_new" RENAME TO "Permission";
+
+-- PersonalSetting Table
+CREATE TABLE "PersonalSetting_new" (
+    "old_id" INTEGER,
+    "key" TEXT NOT NULL,
+    "value" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "userId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "PersonalSetting_new" ("old_id", "key", "value", "createdAt", "updatedAt", "userId", "id", "version", "deletedAt")
+SELECT "old_id", "key", "value", "createdAt", "updatedAt", "userId", "id", "version", "deletedAt" FROM "PersonalSetting";
+DROP TABLE "PersonalSetting";
+ALTER TABLE "PersonalSetting_new" RENAME TO "PersonalSetting";
+
+-- Receiver Table
+CREATE TABLE "Receiver_new" (
+    "old_id" INTEGER,
+    "token" TEXT NOT NULL,
+    "model" TEXT NOT NULL,
+    "protocol" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Receiver_new" ("old_id", "token", "model", "protocol", "createdAt", "updatedAt", "id", "version", "deletedAt")
+SELECT "old_id", "token", "model", "protocol", "createdAt", "updatedAt", "id", "version", "deletedAt" FROM "Receiver";
+DROP TABLE "Receiver";
+ALTER TABLE "Receiver_new" RENAME TO "Receiver";
+
+-- RecoveryCode Table
+CREATE TABLE "RecoveryCode_new" (
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "userId" TEXT NOT NULL,
+    "codeHash" TEXT NOT NULL,
+    "usedAt" TIMESTAMP,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "RecoveryCode_new" ("id", "userId", "codeHash", "usedAt", "createdAt", "version", "deletedAt")
+SELECT "id", "userId", "codeHash", "usedAt", "createdAt", "version", "deletedAt" FROM "RecoveryCode";
+DROP TABLE "RecoveryCode";
+ALTER TABLE "RecoveryCode_new" RENAME TO "RecoveryCode";
+
+-- Session Table
+CREATE TABLE "Session_new" (
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "tokenHash" TEXT NOT NULL,
+    "userId" TEXT,
+    "authLogId" TEXT,
+    "ip" TEXT,
+    "expiresAt" TIMESTAMP NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "stage" TEXT
+);
+INSERT INTO "Session_new" ("id", "tokenHash", "userId", "authLogId", "ip", "expiresAt", "createdAt", "version", "deletedAt", "stage")
+SELECT "id", "tokenHash", "userId", "authLogId", "ip", "expiresAt", "createdAt", "version", "deletedAt", "stage" FROM "Session";
+DROP TABLE "Session";
+ALTER TABLE "Session_new" RENAME TO "Session";
+CREATE INDEX IF NOT EXISTS idx_session_tokenhash
+ON "Session"("tokenHash");
+
+-- User Table
+CREATE TABLE "User_new" (
+    "old_id" INTEGER,
+    "fullname" TEXT NOT NULL,
+    "username" TEXT NOT NULL,
+    "nationalityCode" TEXT NOT NULL,
+    "password" TEXT NOT NULL,
+    "type" TEXT NOT NULL,
+    "personalCode" TEXT NOT NULL,
+    "avatarUrl" TEXT,
+    "fatherName" TEXT NOT NULL,
+    "phoneNumber" TEXT NOT NULL,
+    "address" TEXT NOT NULL,
+    "ip" TEXT NOT NULL,
+    "status" TEXT DEFAULT 'OFFLINE' NOT NULL,
+    "old_locationId" INTEGER,
+    "ConfirmationTime" TEXT,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "locationId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP,
+    "mustChangePassword" BOOLEAN DEFAULT false NOT NULL,
+    "totpSecret" TEXT,
+    "totpEnabled" BOOLEAN DEFAULT false NOT NULL,
+    "totpLastStep" INTEGER DEFAULT 0 NOT NULL,
+    "passwordChangedAt" TIMESTAMP
+);
+INSERT INTO "User_new" ("old_id", "fullname", "username", "nationalityCode", "password", "type", "personalCode", "avatarUrl", "fatherName", "phoneNumber", "address", "ip", "status", "old_locationId", "ConfirmationTime", "createdAt", "updatedAt", "id", "locationId", "version", "deletedAt", "mustChangePassword", "totpSecret", "totpEnabled", "totpLastStep", "passwordChangedAt")
+SELECT "old_id", "fullname", "username", "nationalityCode", "password", "type", "personalCode", "avatarUrl", "fatherName", "phoneNumber", "address", "ip", "status", "old_locationId", "ConfirmationTime", "createdAt", "updatedAt", "id", "locationId", "version", "deletedAt", "mustChangePassword", "totpSecret", "totpEnabled", "totpLastStep", "passwordChangedAt" FROM "User";
+DROP TABLE "User";
+ALTER TABLE "User_new" RENAME TO "User";
+
+-- UserLocation Table
+CREATE TABLE "UserLocation_new" (
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "userId" TEXT NOT NULL,
+    "locationId" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "UserLocation_new" ("id", "userId", "locationId", "createdAt", "version", "deletedAt")
+SELECT "id", "userId", "locationId", "createdAt", "version", "deletedAt" FROM "UserLocation";
+DROP TABLE "UserLocation";
+ALTER TABLE "UserLocation_new" RENAME TO "UserLocation";
+
+-- UserPermission Table
+CREATE TABLE "UserPermission_new" (
+    "old_id" INTEGER,
+    "modelId" INTEGER,
+    "userId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "old_permissionId" INTEGER,
+    "permissionId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "UserPermission_new" ("old_id", "modelId", "userId", "id", "old_permissionId", "permissionId", "version", "deletedAt")
+SELECT "old_id", "modelId", "userId", "id", "old_permissionId", "permissionId", "version", "deletedAt" FROM "UserPermission";
+DROP TABLE "UserPermission";
+ALTER TABLE "UserPermission_new" RENAME TO "UserPermission";
+
+-- UserSetting Table
+CREATE TABLE "UserSetting_new" (
+    "old_id" INTEGER,
+    "alarmColor" TEXT,
+    "audioUrl" TEXT,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "alarmCategoryId" TEXT,
+    "userId" TEXT,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "UserSetting_new" ("old_id", "alarmColor", "audioUrl", "createdAt", "updatedAt", "alarmCategoryId", "userId", "id", "version", "deletedAt")
+SELECT "old_id", "alarmColor", "audioUrl", "createdAt", "updatedAt", "alarmCategoryId", "userId", "id", "version", "deletedAt" FROM "UserSetting";
+DROP TABLE "UserSetting";
+ALTER TABLE "UserSetting_new" RENAME TO "UserSetting";
+
+-- Zone Table
+CREATE TABLE "Zone_new" (
+    "old_id" INTEGER,
+    "localId" INTEGER NOT NULL,
+    "label" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "zoneTypeId" TEXT,
+    "partitionId" TEXT,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "Zone_new" ("old_id", "localId", "label", "createdAt", "updatedAt", "id", "zoneTypeId", "partitionId", "version", "deletedAt")
+SELECT "old_id", "localId", "label", "createdAt", "updatedAt", "id", "zoneTypeId", "partitionId", "version", "deletedAt" FROM "Zone";
+DROP TABLE "Zone";
+ALTER TABLE "Zone_new" RENAME TO "Zone";
+
+-- ZoneType Table
+CREATE TABLE "ZoneType_new" (
+    "old_id" INTEGER,
+    "label" TEXT NOT NULL,
+    "createdAt" TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    "updatedAt" TIMESTAMP NOT NULL,
+    "id" TEXT DEFAULT (lower(hex(randomblob(16)))) NOT NULL,
+    "version" INTEGER DEFAULT 0 NOT NULL,
+    "deletedAt" TIMESTAMP
+);
+INSERT INTO "ZoneType_new" ("old_id", "label", "createdAt", "updatedAt", "id", "version", "deletedAt")
+SELECT "old_id", "label", "createdAt", "updatedAt", "id", "version", "deletedAt" FROM "ZoneType";
+DROP TABLE "ZoneType";
+ALTER TABLE "ZoneType_new" RENAME TO "ZoneType";
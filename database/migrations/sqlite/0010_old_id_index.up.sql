@@ -0,0 +1,15 @@
+-- ورود داده‌های سیستم قدیم ردیف‌ها را با old_id پیدا می‌کند
+CREATE INDEX IF NOT EXISTS "idx_Location_old_id" ON "Location"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Receiver_old_id" ON "Receiver"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_PanelType_old_id" ON "PanelType"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_ZoneType_old_id" ON "ZoneType"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_AlarmCategory_old_id" ON "AlarmCategory"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Alarm_old_id" ON "Alarm"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Branch_old_id" ON "Branch"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Partition_old_id" ON "Partition"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Zone_old_id" ON "Zone"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Employee_old_id" ON "Employee"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Equipment_old_id" ON "Equipment"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_User_old_id" ON "User"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_UserPermission_old_id" ON "UserPermission"(old_id);
+CREATE INDEX IF NOT EXISTS "idx_Event_old_id" ON "Event"(old_id);
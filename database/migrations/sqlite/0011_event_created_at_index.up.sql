@@ -0,0 +1,2 @@
+-- نگهداری رویدادها رویدادهای قدیمی‌تر از مهلت را با createdAt پیدا می‌کند
+CREATE INDEX IF NOT EXISTS "idx_Event_createdAt" ON "Event"("createdAt");
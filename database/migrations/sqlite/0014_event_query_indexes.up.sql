@@ -0,0 +1,9 @@
+-- صفحه‌بندی cursor روی (createdAt, id) و فیلترهای پرکاربرد جستجوی رویدادها
+DROP INDEX IF EXISTS "idx_Event_createdAt";
+CREATE INDEX IF NOT EXISTS "idx_Event_createdAt_id" ON "Event"("createdAt", id);
+CREATE INDEX IF NOT EXISTS "idx_Event_branchId_createdAt" ON "Event"("branchId", "createdAt", id);
+CREATE INDEX IF NOT EXISTS "idx_Event_alarmId_createdAt" ON "Event"("alarmId", "createdAt", id);
+CREATE INDEX IF NOT EXISTS "idx_Event_zoneId_createdAt" ON "Event"("zoneId", "createdAt", id);
+CREATE INDEX IF NOT EXISTS "idx_Event_confirmationStatus_createdAt" ON "Event"("confirmationStatus", "createdAt", id);
+CREATE INDEX IF NOT EXISTS "idx_Alarm_categoryId" ON "Alarm"("categoryId");
+CREATE INDEX IF NOT EXISTS "idx_Branch_locationId" ON "Branch"("locationId");
@@ -0,0 +1,3 @@
+ALTER TABLE "Event" DROP COLUMN "falseAlarm";
+ALTER TABLE "Event" DROP COLUMN "confirmedAt";
+DROP INDEX IF EXISTS "idx_ActionLog_model_id";
@@ -0,0 +1,4 @@
+-- رویدادهایی که date و time آن‌ها خوانده نمی‌شود علامت می‌خورند تا در هر اجرا دوباره بررسی نشوند
+ALTER TABLE "Event" ADD COLUMN "panelTimeInvalid" BOOLEAN NOT NULL DEFAULT false;
+DROP INDEX IF EXISTS "idx_Event_panelTime_pending";
+CREATE INDEX IF NOT EXISTS "idx_Event_panelTime_pending" ON "Event"(id) WHERE "panelTime" IS NULL AND "date" <> '' AND NOT "panelTimeInvalid";
@@ -0,0 +1,6 @@
+-- کد پنل در هر گیرنده و شماره پارتیشن، زون و کارمند در هر شعبه یکتاست؛ ردیف‌های حذف‌شده حساب نمی‌شوند
+-- شعبه‌های بدون گیرنده با هم مقایسه می‌شوند، پس "receiverId" خالی در ایندکس '' حساب می‌شود
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_Branch_receiverId_panelCode" ON "Branch"(COALESCE("receiverId", ''), "panelCode") WHERE "deletedAt" IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_Partition_branchId_localId" ON "Partition"("branchId", "localId") WHERE "deletedAt" IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_Zone_partitionId_localId" ON "Zone"("partitionId", "localId") WHERE "deletedAt" IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS "idx_Employee_branchId_localId" ON "Employee"("branchId", "localId") WHERE "deletedAt" IS NULL;
@@ -0,0 +1,226 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Kinds of the documents kept in the search index
+const (
+	SearchKindEvent  = "event"
+	SearchKindBranch = "branch"
+	SearchKindZone   = "zone"
+	SearchKindAlarm  = "alarm"
+)
+
+var ErrSearchUnsupported = errors.New("full-text search needs the sqlite database and a build with the sqlite_fts5 tag")
+
+// searchReplacements fold the spellings of Persian text typed on different keyboards into one:
+// Arabic ya and kaf become Persian, Persian and Arabic digits become ASCII, ZWNJ and tatweel
+// are dropped so «می‌شود» and «میشود» match. The same table is used in the index triggers and
+// on the queries.
+var searchReplacements = [][2]string{
+	{"ي", "ی"}, {"ى", "ی"}, {"ك", "ک"}, {"ة", "ه"},
+	{"‌", ""}, {"ـ", ""},
+	{"۰", "0"}, {"۱", "1"}, {"۲", "2"}, {"۳", "3"}, {"۴", "4"},
+	{"۵", "5"}, {"۶", "6"}, {"۷", "7"}, {"۸", "8"}, {"۹", "9"},
+	{"٠", "0"}, {"١", "1"}, {"٢", "2"}, {"٣", "3"}, {"٤", "4"},
+	{"٥", "5"}, {"٦", "6"}, {"٧", "7"}, {"٨", "8"}, {"٩", "9"},
+}
+
+var searchReplacer = func() *strings.Replacer {
+	pairs := make([]string, 0, len(searchReplacements)*2)
+	for _, r := range searchReplacements {
+		pairs = append(pairs, r[0], r[1])
+	}
+	return strings.NewReplacer(pairs...)
+}()
+
+// NormalizeSearchText applies the Persian normalisation of the search index to text
+func NormalizeSearchText(text string) string {
+	return searchReplacer.Replace(text)
+}
+
+// normalizeSQL wraps the SQL expression expr in the replace() calls of searchReplacements
+func normalizeSQL(expr string) string {
+	out := "COALESCE(" + expr + ", '')"
+	for _, r := range searchReplacements {
+		out = fmt.Sprintf("REPLACE(%s, '%s', '%s')", out, r[0], r[1])
+	}
+	return out
+}
+
+// SearchMatchQuery turns what an operator typed into an FTS5 query: every word must appear,
+// as a word or the start of one. It returns "" when nothing searchable is left.
+func SearchMatchQuery(text string) string {
+	words := strings.FieldsFunc(NormalizeSearchText(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unicode.IsMark(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, `"`+word+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// searchSource describes how the rows of a table become documents of the index
+type searchSource struct {
+	kind    string
+	table   string
+	columns []string // تغییر این ستون‌ها سند را به‌روز می‌کند
+	// عبارت‌های SQL عنوان و متن سند؛ {row} با NEW یا نام جدول جایگزین می‌شود
+	title string
+	body  string
+}
+
+// An event document is titled by its alarm and carries the branch and zone it came from, as
+// they were when the event was stored or its references last changed.
+var searchSources = []searchSource{
+	{SearchKindEvent, "Event", []string{"description", "alarmId", "branchId", "zoneId", "originalBranchCode"},
+		`COALESCE((SELECT a."label" FROM "Alarm" a WHERE a.id = {row}."alarmId"), {row}."description")`,
+		`COALESCE({row}."description", '') || ' ' || COALESCE({row}."originalBranchCode", '') || ' ' ||
+		COALESCE((SELECT b."name" || ' ' || b."code" FROM "Branch" b WHERE b.id = {row}."branchId"), '') || ' ' ||
+		COALESCE((SELECT z."label" FROM "Zone" z WHERE z.id = {row}."zoneId"), '')`},
+	{SearchKindBranch, "Branch", []string{"name", "address", "code"}, `{row}."name"`, `COALESCE({row}."address", '') || ' ' || COALESCE({row}."code", '')`},
+	{SearchKindZone, "Zone", []string{"label"}, `{row}."label"`, `''`},
+	{SearchKindAlarm, "Alarm", []string{"label", "description", "code"}, `{row}."label"`, `COALESCE({row}."description", '') || ' ' || COALESCE({row}."code", '')`},
+}
+
+// SearchAvailable reports whether db can hold the FTS5 search index
+func SearchAvailable(db *gorm.DB) bool {
+	if db.Dialector.Name() != "sqlite" {
+		return false
+	}
+	var enabled int
+	db.Raw(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&enabled)
+	return enabled == 1
+}
+
+// EnsureSearchIndex creates the FTS5 search index and the triggers that keep it in sync with
+// Event, Branch, Zone and Alarm, and fills it when the triggers were missing. Without FTS5 the
+// triggers are removed so writes keep working; the index is rebuilt once a build with FTS5
+// opens the database again.
+func EnsureSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+	if !SearchAvailable(db) {
+		for _, source := range searchSources {
+			for _, suffix := range []string{"ai", "au", "ad"} {
+				if err := db.Exec(fmt.Sprintf(`DROP TRIGGER IF EXISTS "trg_%s_search_%s"`, source.table, suffix)).Error; err != nil {
+					return err
+				}
+			}
+		}
+		log.Printf("⚠️ Full-text search is disabled: %v", ErrSearchUnsupported)
+		return nil
+	}
+
+	// نمایه فقط وقتی دوباره ساخته می‌شود که تریگرها نیستند یا با این نسخه فرق دارند
+	var triggers []struct {
+		Name string
+		SQL  string
+	}
+	err := db.Raw(`SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND name LIKE 'trg\_%\_search\_%' ESCAPE '\'`).Scan(&triggers).Error
+	if err != nil {
+		return err
+	}
+	current := map[string]string{}
+	for _, trigger := range triggers {
+		current[trigger.Name] = trigger.SQL
+	}
+	upToDate := len(current) == len(searchSources)*3
+	for _, source := range searchSources {
+		for name, statement := range source.triggerDefinitions() {
+			if current[name] != statement {
+				upToDate = false
+			}
+		}
+	}
+	if upToDate {
+		return nil
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		statements := []string{
+			// docid صریح است تا VACUUM شماره سندها را تغییر ندهد
+			`CREATE TABLE IF NOT EXISTS "SearchDocument" (
+				docid INTEGER PRIMARY KEY,
+				kind TEXT NOT NULL,
+				"refId" TEXT NOT NULL,
+				UNIQUE (kind, "refId")
+			)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS "SearchIndex" USING fts5(title, body, tokenize = 'unicode61 remove_diacritics 2')`,
+			`DELETE FROM "SearchIndex"`,
+			`DELETE FROM "SearchDocument"`,
+		}
+		for _, source := range searchSources {
+			statements = append(statements, source.triggers()...)
+			statements = append(statements, source.fill()...)
+		}
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return fmt.Errorf("failed to build the search index: %v", err)
+			}
+		}
+
+		var documents int64
+		tx.Raw(`SELECT COUNT(*) FROM "SearchDocument"`).Scan(&documents)
+		log.Printf("🔎 Search index built with %d documents", documents)
+		return nil
+	})
+}
+
+// triggerDefinitions returns the insert, update and delete triggers keyed by name, as sqlite
+// keeps them in sqlite_master
+func (s searchSource) triggerDefinitions() map[string]string {
+	document := fmt.Sprintf(`(SELECT docid FROM "SearchDocument" WHERE kind = '%s' AND "refId" = %%s.id)`, s.kind)
+	with := func(row, expr string) string {
+		return normalizeSQL(strings.ReplaceAll(expr, "{row}", row))
+	}
+	insert := fmt.Sprintf(`
+		INSERT OR IGNORE INTO "SearchDocument"(kind, "refId") SELECT '%s', NEW.id WHERE NEW."deletedAt" IS NULL;
+		INSERT INTO "SearchIndex"(rowid, title, body) SELECT %s, %s, %s WHERE NEW."deletedAt" IS NULL;`,
+		s.kind, fmt.Sprintf(document, "NEW"), with("NEW", s.title), with("NEW", s.body))
+	remove := fmt.Sprintf(`
+		DELETE FROM "SearchIndex" WHERE rowid = %s;
+		DELETE FROM "SearchDocument" WHERE kind = '%s' AND "refId" = OLD.id;`,
+		fmt.Sprintf(document, "OLD"), s.kind)
+
+	columns := append([]string{"deletedAt"}, s.columns...)
+	for i, column := range columns {
+		columns[i] = `"` + column + `"`
+	}
+	return map[string]string{
+		"trg_" + s.table + "_search_ai": fmt.Sprintf(`CREATE TRIGGER "trg_%s_search_ai" AFTER INSERT ON "%s" BEGIN %s END`, s.table, s.table, insert),
+		"trg_" + s.table + "_search_au": fmt.Sprintf(`CREATE TRIGGER "trg_%s_search_au" AFTER UPDATE OF %s ON "%s" BEGIN %s %s END`,
+			s.table, strings.Join(columns, ", "), s.table, remove, insert),
+		"trg_" + s.table + "_search_ad": fmt.Sprintf(`CREATE TRIGGER "trg_%s_search_ad" AFTER DELETE ON "%s" BEGIN %s END`, s.table, s.table, remove),
+	}
+}
+
+// triggers returns the statements (re)creating the insert, update and delete triggers
+func (s searchSource) triggers() []string {
+	statements := []string{}
+	for name, statement := range s.triggerDefinitions() {
+		statements = append(statements, fmt.Sprintf(`DROP TRIGGER IF EXISTS "%s"`, name), statement)
+	}
+	return statements
+}
+
+// fill returns the statements indexing the live rows of the table
+func (s searchSource) fill() []string {
+	return []string{
+		fmt.Sprintf(`INSERT INTO "SearchDocument"(kind, "refId") SELECT '%s', id FROM "%s" WHERE "deletedAt" IS NULL`, s.kind, s.table),
+		fmt.Sprintf(`INSERT INTO "SearchIndex"(rowid, title, body)
+			SELECT d.docid, %s, %s FROM "%s" JOIN "SearchDocument" d ON d.kind = '%s' AND d."refId" = "%s".id`,
+			normalizeSQL(strings.ReplaceAll(s.title, "{row}", `"`+s.table+`"`)),
+			normalizeSQL(strings.ReplaceAll(s.body, "{row}", `"`+s.table+`"`)),
+			s.table, s.kind, s.table),
+	}
+}
@@ -0,0 +1,91 @@
+// Package export writes tables to XLSX, CSV and PDF files. Rows are written one at a time
+// and PDF pages as soon as they are full, so exports of any size run in constant memory.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats of an export
+const (
+	FormatXLSX = "xlsx"
+	FormatCSV  = "csv"
+	FormatPDF  = "pdf"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format, use xlsx, csv or pdf")
+
+// Column is one column of an exported table; Width is its share of the page in PDF and its
+// width in characters in XLSX
+type Column struct {
+	Title string
+	Width float64
+}
+
+// Writer writes the rows of one table. Values may be strings, integers, floats, booleans or
+// nil; Close finishes the file and must be called for it to be complete.
+type Writer interface {
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+// NewWriter starts a table with the given columns on w. Title names the XLSX sheet and heads
+// every PDF page.
+func NewWriter(format string, w io.Writer, title string, columns []Column) (Writer, error) {
+	switch format {
+	case FormatXLSX:
+		return newXLSXWriter(w, title, columns)
+	case FormatCSV:
+		return newCSVWriter(w, columns)
+	case FormatPDF:
+		return newPDFWriter(w, title, columns)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ValidFormat reports whether format is one NewWriter knows
+func ValidFormat(format string) bool {
+	return format == FormatXLSX || format == FormatCSV || format == FormatPDF
+}
+
+// text renders a value for the formats that only hold text
+func text(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		if v {
+			return "بله"
+		}
+		return "خیر"
+	case time.Time:
+		return v.Format("2006-01-02 15:04:05")
+	}
+	return fmt.Sprint(value)
+}
+
+// cleanSheetName drops the characters Excel refuses in a sheet name and cuts it to 31 runes
+func cleanSheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(strings.TrimSpace(name)); len(runes) > 31 {
+		name = string(runes[:31])
+	}
+	if strings.TrimSpace(name) == "" {
+		return "Sheet1"
+	}
+	return name
+}
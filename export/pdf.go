@@ -0,0 +1,446 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	_ "embed"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf16"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// vazirmatn is the font of the frontend, embedded so the PDF looks the same on any machine
+//
+//go:embed fonts/Vazirmatn-Regular.ttf
+var vazirmatn []byte
+
+// Sizes are in millimetres, font sizes in points
+const (
+	pdfFontSize   = 9
+	pdfRowHeight  = 7
+	pdfMargin     = 10
+	pdfCellMargin = 1
+	pdfTitleSize  = 13
+	pdfFooterSize = 8
+	pdfLineWidth  = 0.2
+)
+
+// pdfScale converts millimetres to PDF points
+const pdfScale = 72 / 25.4
+
+// Objects written before the pages; the rest are numbered as they are written
+const (
+	pdfCatalogObject = 1
+	pdfPagesObject   = 2
+	pdfFontObject    = 3
+)
+
+// pdfFont is the parsed embedded font. Widths are in thousandths of the font size, as PDF
+// wants them.
+type pdfFont struct {
+	sfnt    *sfnt.Font
+	ascent  int
+	descent int
+	capTop  int
+	bbox    [4]int
+}
+
+var (
+	pdfFontOnce   sync.Once
+	pdfFontParsed *pdfFont
+	pdfFontErr    error
+)
+
+// pdfUnits asks sfnt for sizes in thousandths of an em
+var pdfUnits = fixed.I(1000)
+
+func loadPDFFont() (*pdfFont, error) {
+	pdfFontOnce.Do(func() {
+		f, err := sfnt.Parse(vazirmatn)
+		if err != nil {
+			pdfFontErr = fmt.Errorf("failed to read the PDF font: %v", err)
+			return
+		}
+		var buf sfnt.Buffer
+		metrics, err := f.Metrics(&buf, pdfUnits, font.HintingNone)
+		if err != nil {
+			pdfFontErr = err
+			return
+		}
+		bounds, err := f.Bounds(&buf, pdfUnits, font.HintingNone)
+		if err != nil {
+			pdfFontErr = err
+			return
+		}
+		// محور y در sfnt رو به پایین است و در PDF رو به بالا
+		pdfFontParsed = &pdfFont{
+			sfnt:    f,
+			ascent:  metrics.Ascent.Round(),
+			descent: -metrics.Descent.Round(),
+			capTop:  metrics.CapHeight.Round(),
+			bbox:    [4]int{bounds.Min.X.Floor(), -bounds.Max.Y.Ceil(), bounds.Max.X.Ceil(), -bounds.Min.Y.Floor()},
+		}
+	})
+	return pdfFontParsed, pdfFontErr
+}
+
+// pdfGlyph is a glyph of the font and the character it was drawn for
+type pdfGlyph struct {
+	index sfnt.GlyphIndex
+	width int
+	char  rune
+}
+
+// pdfWriter writes every page to the output as soon as it is full, so a PDF of any size is
+// written in constant memory; only the page numbers and the glyphs used are kept until
+// Close writes the font and the page tree.
+type pdfWriter struct {
+	out     *bufio.Writer
+	offset  int64
+	objects map[int]int64 // object number -> offset
+	next    int
+	pages   []int
+
+	font   *pdfFont
+	buf    sfnt.Buffer
+	glyphs map[rune]pdfGlyph
+	used   map[sfnt.GlyphIndex]pdfGlyph
+
+	title     string
+	generated string
+	columns   []Column
+	widths    []float64
+	pageWidth float64
+	height    float64
+
+	page bytes.Buffer
+	y    float64
+	rows int
+	err  error
+}
+
+// newPDFWriter lays the table out right to left, the first column at the right edge of an
+// A4 page; tables with more than five columns are printed in landscape
+func newPDFWriter(w io.Writer, title string, columns []Column) (*pdfWriter, error) {
+	f, err := loadPDFFont()
+	if err != nil {
+		return nil, err
+	}
+	width, height := 210.0, 297.0
+	if len(columns) > 5 {
+		width, height = height, width
+	}
+
+	var total float64
+	for _, column := range columns {
+		total += pdfColumnWeight(column)
+	}
+	widths := make([]float64, len(columns))
+	for i, column := range columns {
+		widths[i] = (width - 2*pdfMargin) * pdfColumnWeight(column) / total
+	}
+
+	p := &pdfWriter{
+		out:       bufio.NewWriterSize(w, 1<<16),
+		objects:   map[int]int64{},
+		next:      pdfFontObject + 1,
+		font:      f,
+		glyphs:    map[rune]pdfGlyph{},
+		used:      map[sfnt.GlyphIndex]pdfGlyph{},
+		title:     title,
+		generated: time.Now().Format("2006-01-02 15:04"),
+		columns:   columns,
+		widths:    widths,
+		pageWidth: width,
+		height:    height,
+	}
+	// نویسه‌های دودویی به برنامه‌ها می‌گویند فایل متنی نیست
+	p.write("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	p.startPage()
+	return p, p.err
+}
+
+func pdfColumnWeight(column Column) float64 {
+	if column.Width > 0 {
+		return column.Width
+	}
+	return 10
+}
+
+func (p *pdfWriter) WriteRow(values []interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	// شکستن صفحه دستی است تا یک ردیف بین دو صفحه تقسیم نشود
+	if p.y+pdfRowHeight > p.height-pdfMargin-6 {
+		p.finishPage()
+		p.startPage()
+	}
+	p.rows++
+	// ردیف‌ها یک در میان زمینه کم‌رنگ دارند
+	p.cells(values, p.rows%2 == 0, [3]int{245, 247, 250})
+	return p.err
+}
+
+// startPage draws the title, the time the file was made and the column titles
+func (p *pdfWriter) startPage() {
+	p.page.Reset()
+	fmt.Fprintf(&p.page, "%s w\n", pdfNumber(pdfLineWidth*pdfScale))
+	inner := p.pageWidth - 2*pdfMargin
+	p.y = pdfMargin
+	p.text(pdfMargin, p.y, inner, 9, pdfTitleSize, Visual(p.title), "R")
+	p.y += 9
+	p.text(pdfMargin, p.y, inner, 5, pdfFooterSize, p.generated, "L")
+	p.y += 5 + 2
+
+	titles := make([]interface{}, len(p.columns))
+	for i, column := range p.columns {
+		titles[i] = column.Title
+	}
+	p.cells(titles, true, [3]int{221, 228, 238})
+}
+
+// finishPage draws the page number and writes the page to the output
+func (p *pdfWriter) finishPage() {
+	p.text(pdfMargin, p.height-pdfMargin-5, p.pageWidth-2*pdfMargin, 5, pdfFooterSize, Visual("صفحه "+strconv.Itoa(len(p.pages)+1)), "C")
+
+	content := p.newObject()
+	p.writeStream(content, "", p.page.Bytes())
+	page := p.newObject()
+	p.pages = append(p.pages, page)
+	p.writeObject(page, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+		pdfPagesObject, pdfNumber(p.pageWidth*pdfScale), pdfNumber(p.height*pdfScale), pdfFontObject, content))
+}
+
+// cells draws one row, from the right edge leftwards
+func (p *pdfWriter) cells(values []interface{}, fill bool, color [3]int) {
+	x := p.pageWidth - pdfMargin
+	for i, width := range p.widths {
+		x -= width
+		value := ""
+		if i < len(values) {
+			value = text(values[i])
+		}
+		op := "S"
+		if fill {
+			fmt.Fprintf(&p.page, "%s %s %s rg\n", pdfNumber(float64(color[0])/255), pdfNumber(float64(color[1])/255), pdfNumber(float64(color[2])/255))
+			op = "B"
+		}
+		fmt.Fprintf(&p.page, "%s %s %s %s re %s\n", pdfNumber(x*pdfScale), pdfNumber((p.height-p.y-pdfRowHeight)*pdfScale),
+			pdfNumber(width*pdfScale), pdfNumber(pdfRowHeight*pdfScale), op)
+		p.text(x, p.y, width, pdfRowHeight, pdfFontSize, p.fit(value, width-2, pdfFontSize), "R")
+	}
+	p.y += pdfRowHeight
+}
+
+// text writes visual, already in visual order, in the box at x, y aligned to its right (R),
+// left (L) or centre (C), vertically centred like a table cell
+func (p *pdfWriter) text(x, y, width, height, size float64, visual string, align string) {
+	if visual == "" {
+		return
+	}
+	glyphs := p.shape(visual)
+	textWidth := p.width(glyphs, size)
+	switch align {
+	case "R":
+		x += width - pdfCellMargin - textWidth
+	case "C":
+		x += (width - textWidth) / 2
+	default:
+		x += pdfCellMargin
+	}
+	baseline := y + height/2 + 0.3*size/pdfScale
+
+	var hex strings.Builder
+	for _, g := range glyphs {
+		fmt.Fprintf(&hex, "%04X", uint16(g.index))
+		p.used[g.index] = g
+	}
+	fmt.Fprintf(&p.page, "0 g BT /F1 %s Tf %s %s Td <%s> Tj ET\n", pdfNumber(size), pdfNumber(x*pdfScale), pdfNumber((p.height-baseline)*pdfScale), hex.String())
+}
+
+// shape maps the characters of visual to glyphs of the font; missing ones draw as .notdef
+func (p *pdfWriter) shape(visual string) []pdfGlyph {
+	glyphs := make([]pdfGlyph, 0, len(visual))
+	for _, r := range visual {
+		g, ok := p.glyphs[r]
+		if !ok {
+			g.char = r
+			g.index, _ = p.font.sfnt.GlyphIndex(&p.buf, r)
+			if advance, err := p.font.sfnt.GlyphAdvance(&p.buf, g.index, pdfUnits, font.HintingNone); err == nil {
+				g.width = advance.Round()
+			}
+			p.glyphs[r] = g
+		}
+		glyphs = append(glyphs, g)
+	}
+	return glyphs
+}
+
+// width is the width of glyphs at size, in millimetres
+func (p *pdfWriter) width(glyphs []pdfGlyph, size float64) float64 {
+	total := 0
+	for _, g := range glyphs {
+		total += g.width
+	}
+	return float64(total) * size / 1000 / pdfScale
+}
+
+// fit cuts value until it fits in width, marking the cut with an ellipsis
+func (p *pdfWriter) fit(value string, width, size float64) string {
+	visual := Visual(value)
+	full := p.width(p.shape(visual), size)
+	if full <= width {
+		return visual
+	}
+	runes := []rune(value)
+	// از طول تقریبی شروع می‌شود تا متن‌های بلند حرف به حرف کوتاه نشوند
+	start := int(float64(len(runes))*width/full) + 2
+	if start > len(runes)-1 {
+		start = len(runes) - 1
+	}
+	for n := start; n > 0; n-- {
+		visual = Visual(string(runes[:n]) + "…")
+		if p.width(p.shape(visual), size) <= width {
+			return visual
+		}
+	}
+	return ""
+}
+
+// Close writes the last page, the font with the glyphs used, the page tree and the
+// cross-reference table
+func (p *pdfWriter) Close() error {
+	if p.err != nil {
+		return p.err
+	}
+	p.finishPage()
+	p.writeFont()
+
+	kids := make([]string, len(p.pages))
+	for i, page := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", page)
+	}
+	p.writeObject(pdfPagesObject, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	p.writeObject(pdfCatalogObject, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pdfPagesObject))
+	info := p.newObject()
+	p.writeObject(info, fmt.Sprintf("<< /Title %s /Creator %s /CreationDate (D:%s) >>",
+		pdfTextString(p.title), pdfTextString("monitoring-with-go"), time.Now().Format("20060102150405")))
+
+	xref := p.offset
+	p.write(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", p.next))
+	for n := 1; n < p.next; n++ {
+		p.write(fmt.Sprintf("%010d 00000 n \n", p.objects[n]))
+	}
+	p.write(fmt.Sprintf("trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", p.next, pdfCatalogObject, info, xref))
+	if p.err != nil {
+		return p.err
+	}
+	return p.out.Flush()
+}
+
+// writeFont embeds the whole font; the widths and the text mapping cover the glyphs used
+func (p *pdfWriter) writeFont() {
+	f := p.font
+	name := "Vazirmatn"
+	used := make([]pdfGlyph, 0, len(p.used))
+	for _, g := range p.used {
+		used = append(used, g)
+	}
+	sort.Slice(used, func(i, j int) bool { return used[i].index < used[j].index })
+
+	cid, descriptor, file, toUnicode := p.newObject(), p.newObject(), p.newObject(), p.newObject()
+	p.writeObject(pdfFontObject, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		name, cid, toUnicode))
+
+	var widths strings.Builder
+	for _, g := range used {
+		fmt.Fprintf(&widths, "%d [%d] ", g.index, g.width)
+	}
+	p.writeObject(cid, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /CIDToGIDMap /Identity /W [%s] >>",
+		name, descriptor, widths.String()))
+	p.writeObject(descriptor, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		name, f.bbox[0], f.bbox[1], f.bbox[2], f.bbox[3], f.ascent, f.descent, f.capTop, file))
+	p.writeStream(file, fmt.Sprintf("/Length1 %d", len(vazirmatn)), vazirmatn)
+
+	var cmap bytes.Buffer
+	cmap.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n" +
+		"/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n" +
+		"/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n" +
+		"1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+	for start := 0; start < len(used); start += 100 {
+		end := min(start+100, len(used))
+		fmt.Fprintf(&cmap, "%d beginbfchar\n", end-start)
+		for _, g := range used[start:end] {
+			fmt.Fprintf(&cmap, "<%04X> <", uint16(g.index))
+			for _, unit := range utf16.Encode([]rune{g.char}) {
+				fmt.Fprintf(&cmap, "%04X", unit)
+			}
+			cmap.WriteString(">\n")
+		}
+		cmap.WriteString("endbfchar\n")
+	}
+	cmap.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	p.writeStream(toUnicode, "", cmap.Bytes())
+}
+
+func (p *pdfWriter) newObject() int {
+	n := p.next
+	p.next++
+	return n
+}
+
+func (p *pdfWriter) writeObject(n int, body string) {
+	p.objects[n] = p.offset
+	p.write(fmt.Sprintf("%d 0 obj\n%s\nendobj\n", n, body))
+}
+
+// writeStream writes data compressed; extra holds more entries of the stream dictionary
+func (p *pdfWriter) writeStream(n int, extra string, data []byte) {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(data)
+	zw.Close()
+	p.objects[n] = p.offset
+	if extra != "" {
+		extra = " " + extra
+	}
+	p.write(fmt.Sprintf("%d 0 obj\n<< /Length %d /Filter /FlateDecode%s >>\nstream\n", n, compressed.Len(), extra))
+	p.write(compressed.String())
+	p.write("\nendstream\nendobj\n")
+}
+
+func (p *pdfWriter) write(s string) {
+	if p.err != nil {
+		return
+	}
+	n, err := p.out.WriteString(s)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// pdfNumber writes f with two decimals; PDF numbers have no exponent form
+func pdfNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', 2, 64)
+}
+
+// pdfTextString writes s as a UTF-16 string, which PDF readers show in any script
+func pdfTextString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, unit := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", unit)
+	}
+	b.WriteString(">")
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+)
+
+func TestPDFWriterStreamsPages(t *testing.T) {
+	var out bytes.Buffer
+	columns := []Column{{Title: "شعبه", Width: 20}, {Title: "Code"}, {Title: "توضیحات", Width: 30}}
+	w, err := NewWriter(FormatPDF, &out, "گزارش رویدادها", columns)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	// صفحه‌های پر شده پیش از بستن فایل نوشته می‌شوند
+	const rows = 3000
+	for i := 0; i < rows; i++ {
+		if err := w.WriteRow([]interface{}{"شعبه ونک", i, "درب ورودی باز شد و آژیر به صدا درآمد، پیام بلندتر از ستون"}); err != nil {
+			t.Fatalf("row %d: %v", i, err)
+		}
+		if i == rows/2 && out.Len() == 0 {
+			t.Fatalf("nothing written after %d rows, pages are kept in memory", i)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data := out.Bytes()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n")) {
+		t.Fatal("not a complete PDF file")
+	}
+	// جدول ارجاع باید دقیقاً به شروع هر شیء اشاره کند
+	start := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(data)
+	if start == nil {
+		t.Fatal("no startxref")
+	}
+	xref, _ := strconv.Atoi(string(start[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(data[xref:], -1)
+	if len(entries) == 0 {
+		t.Fatal("empty cross-reference table")
+	}
+	for i, entry := range entries {
+		offset, _ := strconv.Atoi(string(entry[1]))
+		if want := fmt.Sprintf("%d 0 obj", i+1); !bytes.HasPrefix(data[offset:], []byte(want)) {
+			t.Fatalf("object %d is not at offset %d", i+1, offset)
+		}
+	}
+
+	pages := regexp.MustCompile(`/Type /Pages /Kids \[[^\]]*\] /Count (\d+)`).FindSubmatch(data)
+	if pages == nil {
+		t.Fatal("no page tree")
+	}
+	// سی و پنج ردیف در هر صفحه عمودی جا می‌شود
+	if count, _ := strconv.Atoi(string(pages[1])); count != (rows+34)/35 {
+		t.Errorf("%s pages for %d rows, want %d", pages[1], rows, (rows+34)/35)
+	}
+}
@@ -0,0 +1,262 @@
+package export
+
+import (
+	"strings"
+	"unicode"
+)
+
+// PDF fonts only draw what they are given, so Persian text has to be shaped (every letter
+// replaced by its joined form) and put in visual order before it reaches the page.
+
+// letterForms holds the isolated, final, initial and medial presentation forms of a letter;
+// letters joining only to the previous one have no initial or medial form.
+var letterForms = map[rune][4]rune{
+	'ء': {0xFE80, 0, 0, 0},
+	'آ': {0xFE81, 0xFE82, 0, 0},
+	'أ': {0xFE83, 0xFE84, 0, 0},
+	'ؤ': {0xFE85, 0xFE86, 0, 0},
+	'إ': {0xFE87, 0xFE88, 0, 0},
+	'ئ': {0xFE89, 0xFE8A, 0xFE8B, 0xFE8C},
+	'ا': {0xFE8D, 0xFE8E, 0, 0},
+	'ب': {0xFE8F, 0xFE90, 0xFE91, 0xFE92},
+	'ة': {0xFE93, 0xFE94, 0, 0},
+	'ت': {0xFE95, 0xFE96, 0xFE97, 0xFE98},
+	'ث': {0xFE99, 0xFE9A, 0xFE9B, 0xFE9C},
+	'ج': {0xFE9D, 0xFE9E, 0xFE9F, 0xFEA0},
+	'ح': {0xFEA1, 0xFEA2, 0xFEA3, 0xFEA4},
+	'خ': {0xFEA5, 0xFEA6, 0xFEA7, 0xFEA8},
+	'د': {0xFEA9, 0xFEAA, 0, 0},
+	'ذ': {0xFEAB, 0xFEAC, 0, 0},
+	'ر': {0xFEAD, 0xFEAE, 0, 0},
+	'ز': {0xFEAF, 0xFEB0, 0, 0},
+	'س': {0xFEB1, 0xFEB2, 0xFEB3, 0xFEB4},
+	'ش': {0xFEB5, 0xFEB6, 0xFEB7, 0xFEB8},
+	'ص': {0xFEB9, 0xFEBA, 0xFEBB, 0xFEBC},
+	'ض': {0xFEBD, 0xFEBE, 0xFEBF, 0xFEC0},
+	'ط': {0xFEC1, 0xFEC2, 0xFEC3, 0xFEC4},
+	'ظ': {0xFEC5, 0xFEC6, 0xFEC7, 0xFEC8},
+	'ع': {0xFEC9, 0xFECA, 0xFECB, 0xFECC},
+	'غ': {0xFECD, 0xFECE, 0xFECF, 0xFED0},
+	'ـ': {0x0640, 0x0640, 0x0640, 0x0640},
+	'ف': {0xFED1, 0xFED2, 0xFED3, 0xFED4},
+	'ق': {0xFED5, 0xFED6, 0xFED7, 0xFED8},
+	'ك': {0xFED9, 0xFEDA, 0xFEDB, 0xFEDC},
+	'ل': {0xFEDD, 0xFEDE, 0xFEDF, 0xFEE0},
+	'م': {0xFEE1, 0xFEE2, 0xFEE3, 0xFEE4},
+	'ن': {0xFEE5, 0xFEE6, 0xFEE7, 0xFEE8},
+	'ه': {0xFEE9, 0xFEEA, 0xFEEB, 0xFEEC},
+	'و': {0xFEED, 0xFEEE, 0, 0},
+	'ى': {0xFEEF, 0xFEF0, 0, 0},
+	'ي': {0xFEF1, 0xFEF2, 0xFEF3, 0xFEF4},
+	'پ': {0xFB56, 0xFB57, 0xFB58, 0xFB59},
+	'چ': {0xFB7A, 0xFB7B, 0xFB7C, 0xFB7D},
+	'ژ': {0xFB8A, 0xFB8B, 0, 0},
+	'ک': {0xFB8E, 0xFB8F, 0xFB90, 0xFB91},
+	'گ': {0xFB92, 0xFB93, 0xFB94, 0xFB95},
+	'ی': {0xFBFC, 0xFBFD, 0xFBFE, 0xFBFF},
+}
+
+// lamAlef holds the isolated and final forms of lam followed by each alef
+var lamAlef = map[rune][2]rune{
+	'آ': {0xFEF5, 0xFEF6},
+	'أ': {0xFEF7, 0xFEF8},
+	'إ': {0xFEF9, 0xFEFA},
+	'ا': {0xFEFB, 0xFEFC},
+}
+
+// mirrored brackets are swapped when they end up in right-to-left text
+var mirrored = map[rune]rune{
+	'(': ')', ')': '(', '[': ']', ']': '[', '{': '}', '}': '{',
+	'<': '>', '>': '<', '«': '»', '»': '«',
+}
+
+// transparent marks (harakat) do not break the joining of the letters around them
+func transparent(r rune) bool {
+	return (r >= 0x064B && r <= 0x065F) || r == 0x0670
+}
+
+// shape replaces the Persian and Arabic letters of text with their joined forms
+func shape(text []rune) []rune {
+	neighbour := func(i, step int) rune {
+		for i += step; i >= 0 && i < len(text); i += step {
+			if !transparent(text[i]) {
+				return text[i]
+			}
+		}
+		return 0
+	}
+	joinsForward := func(r rune) bool {
+		forms, ok := letterForms[r]
+		return ok && forms[2] != 0
+	}
+	joinsBackward := func(r rune) bool {
+		forms, ok := letterForms[r]
+		return ok && forms[1] != 0
+	}
+
+	out := make([]rune, 0, len(text))
+	for i := 0; i < len(text); i++ {
+		r := text[i]
+		forms, ok := letterForms[r]
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+		prev := joinsForward(neighbour(i, -1)) && joinsBackward(r)
+		if r == 'ل' && i+1 < len(text) {
+			if ligature, ok := lamAlef[text[i+1]]; ok {
+				if prev {
+					out = append(out, ligature[1])
+				} else {
+					out = append(out, ligature[0])
+				}
+				i++
+				continue
+			}
+		}
+		next := joinsForward(r) && joinsBackward(neighbour(i, 1))
+		switch {
+		case prev && next:
+			out = append(out, forms[3])
+		case prev:
+			out = append(out, forms[1])
+		case next:
+			out = append(out, forms[2])
+		default:
+			out = append(out, forms[0])
+		}
+	}
+	return out
+}
+
+// bidi classes, reduced to what a single right-to-left line of a table cell needs
+const (
+	classR  = iota // حروف راست‌به‌چپ
+	classL         // حروف چپ‌به‌راست
+	classEN        // رقم
+	classCS        // جداکننده داخل عدد مثل : و /
+	classET        // علامت کنار عدد مثل %
+	classN         // فاصله و علامت‌ها
+)
+
+func bidiClass(r rune) int {
+	switch {
+	case r >= '0' && r <= '9', r >= '۰' && r <= '۹', r >= '٠' && r <= '٩':
+		return classEN
+	case (r >= 0x0590 && r <= 0x08FF) || (r >= 0xFB1D && r <= 0xFDFF) || (r >= 0xFE70 && r <= 0xFEFF):
+		return classR
+	case unicode.IsLetter(r):
+		return classL
+	case strings.ContainsRune(".,:/-+", r):
+		return classCS
+	case strings.ContainsRune("%٪#$°", r):
+		return classET
+	}
+	return classN
+}
+
+// Visual shapes text and orders it for drawing left to right on a right-to-left line:
+// Persian runs are reversed, while Latin words and numbers (with their separators, such as
+// 1403/01/05 or 12:30) keep their order.
+func Visual(text string) string {
+	runes := shape([]rune(strings.Join(strings.Fields(text), " ")))
+	if len(runes) == 0 {
+		return ""
+	}
+	classes := make([]int, len(runes))
+	for i, r := range runes {
+		classes[i] = bidiClass(r)
+	}
+
+	// جداکننده تنها بین دو رقم و علامت کنار رقم جزو عدد می‌شوند
+	for i := 1; i+1 < len(classes); i++ {
+		if classes[i] == classCS && classes[i-1] == classEN && classes[i+1] == classEN {
+			classes[i] = classEN
+		}
+	}
+	for i := range classes {
+		if classes[i] != classET {
+			continue
+		}
+		j := i
+		for j < len(classes) && classes[j] == classET {
+			j++
+		}
+		if (i > 0 && classes[i-1] == classEN) || (j < len(classes) && classes[j] == classEN) {
+			for k := i; k < j; k++ {
+				classes[k] = classEN
+			}
+		}
+		i = j - 1
+	}
+	// عدد بعد از متن لاتین جزو همان متن است
+	strong := classR
+	for i, class := range classes {
+		switch class {
+		case classR, classL:
+			strong = class
+		case classEN:
+			if strong == classL {
+				classes[i] = classL
+			}
+		}
+	}
+	// علامت‌ها بین دو متن چپ‌به‌راست چپ‌به‌راست می‌مانند و بقیه راست‌به‌چپ
+	direction := func(class int) int {
+		if class == classL {
+			return classL
+		}
+		return classR
+	}
+	for i := 0; i < len(classes); i++ {
+		if classes[i] != classN && classes[i] != classCS && classes[i] != classET {
+			continue
+		}
+		j := i
+		for j < len(classes) && (classes[j] == classN || classes[j] == classCS || classes[j] == classET) {
+			j++
+		}
+		before, after := classR, classR
+		if i > 0 {
+			before = direction(classes[i-1])
+		}
+		if j < len(classes) {
+			after = direction(classes[j])
+		}
+		resolved := classR
+		if before == classL && after == classL {
+			resolved = classL
+		}
+		for k := i; k < j; k++ {
+			classes[k] = resolved
+		}
+		i = j - 1
+	}
+
+	// کل خط برعکس می‌شود و بخش‌های چپ‌به‌راست دوباره به ترتیب اصلی برمی‌گردند
+	ltr := func(class int) bool { return class == classL || class == classEN }
+	out := make([]rune, len(runes))
+	for i := range runes {
+		j := len(runes) - 1 - i
+		out[i] = runes[j]
+		if !ltr(classes[j]) {
+			if m, ok := mirrored[out[i]]; ok {
+				out[i] = m
+			}
+		}
+	}
+	for i := 0; i < len(out); i++ {
+		if !ltr(classes[len(runes)-1-i]) {
+			continue
+		}
+		j := i
+		for j < len(out) && ltr(classes[len(runes)-1-j]) {
+			j++
+		}
+		for a, b := i, j-1; a < b; a, b = a+1, b-1 {
+			out[a], out[b] = out[b], out[a]
+		}
+		i = j - 1
+	}
+	return string(out)
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"io"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type xlsxWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+// newXLSXWriter streams the rows into a right-to-left sheet with a frozen, bold header
+func newXLSXWriter(w io.Writer, title string, columns []Column) (*xlsxWriter, error) {
+	file := excelize.NewFile()
+	sheet := cleanSheetName(title)
+	if err := file.SetSheetName("Sheet1", sheet); err != nil {
+		file.Close()
+		return nil, err
+	}
+	rightToLeft := true
+	if err := file.SetSheetView(sheet, -1, &excelize.ViewOptions{RightToLeft: &rightToLeft}); err != nil {
+		file.Close()
+		return nil, err
+	}
+	stream, err := file.NewStreamWriter(sheet)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	out := &xlsxWriter{out: w, file: file, stream: stream}
+	if err := out.header(columns); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return out, nil
+}
+
+func (x *xlsxWriter) header(columns []Column) error {
+	bold, err := x.file.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true},
+		Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDE4EE"}},
+	})
+	if err != nil {
+		return err
+	}
+	// عرض ستون‌ها و قفل ردیف عنوان باید قبل از اولین ردیف نوشته شوند
+	for i, column := range columns {
+		if column.Width > 0 {
+			if err := x.stream.SetColWidth(i+1, i+1, column.Width); err != nil {
+				return err
+			}
+		}
+	}
+	err = x.stream.SetPanes(&excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+	if err != nil {
+		return err
+	}
+	titles := make([]interface{}, len(columns))
+	for i, column := range columns {
+		titles[i] = column.Title
+	}
+	return x.write(titles, excelize.RowOpts{StyleID: bold})
+}
+
+func (x *xlsxWriter) WriteRow(values []interface{}) error {
+	row := make([]interface{}, len(values))
+	for i, value := range values {
+		// بله/خیر مثل بقیه قالب‌ها به جای TRUE/FALSE
+		if b, ok := value.(bool); ok {
+			value = text(b)
+		}
+		row[i] = value
+	}
+	return x.write(row)
+}
+
+func (x *xlsxWriter) write(values []interface{}, opts ...excelize.RowOpts) error {
+	x.row++
+	cell, err := excelize.CoordinatesToCellName(1, x.row)
+	if err != nil {
+		return err
+	}
+	return x.stream.SetRow(cell, values, opts...)
+}
+
+func (x *xlsxWriter) Close() error {
+	defer x.file.Close()
+	if err := x.stream.Flush(); err != nil {
+		return err
+	}
+	return x.file.Write(x.out)
+}
@@ -24,7 +24,7 @@ interface RegisterRequest {
   personalCode: string;
   fatherName: string;
   phoneNumber: string;
-  locationId: number;
+  locationId: string;
   address: string;
   province?: string;
   city?: string;
@@ -37,6 +37,7 @@ interface RegisterResponse {
 
 export interface User {
   id: string;
+  token: string;
   username: string;
   fullname: string;
   nationalityCode: string;
@@ -63,6 +64,7 @@ export const login = async (values: LoginRequest): Promise<User> => {
 
     // ذخیره user در localStorage یا sessionStorage (می‌تونی context هم استفاده کنی)
     localStorage.setItem("user", JSON.stringify(user));
+    localStorage.setItem("access_token", user.token);
 
     return user;
   } catch (error: any) {
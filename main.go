@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
 	"embed"
+	"flag"
+	"io"
 	"log"
+	"monitoring-with-go/config"
 	"monitoring-with-go/database"
 	"monitoring-with-go/services"
+	"os"
+	"path/filepath"
+	"time"
+	// ویندوز پایگاه منطقه‌های زمانی ندارد؛ برای ساعت پنل‌ها داخل برنامه قرار می‌گیرد
+	_ "time/tzdata"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/wailsapp/wails/v2"
@@ -16,16 +25,170 @@ import (
 var assets embed.FS // Embed all frontend files
 
 func main() {
+	migrateCommand := flag.String("migrate", "", "run a migration command and exit: status, up or down")
+	migrateSteps := flag.Int("steps", 1, "number of migrations reverted by -migrate down")
+	dryRun := flag.Bool("dry-run", false, "with -migrate up/down, print the SQL instead of running it; with a legacy import, roll it back")
+	legacyDump := flag.String("legacy-dump", "", "import the old system's data from a plain pg_dump file and exit")
+	legacyDSN := flag.String("legacy-dsn", "", "import the old system's data from a live PostgreSQL database and exit")
+	config.RegisterFlags(flag.CommandLine)
+	flag.Parse()
+
+	// مسیرها از فایل تنظیمات، متغیرهای محیطی و flag ها خوانده می‌شوند
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("❌ Error loading config: %s", err)
+	}
+	journal, err := cfg.OpenJournal()
+	if err != nil {
+		log.Fatalf("❌ Error opening journal: %s", err)
+	}
+	defer journal.Close()
+	log.SetOutput(io.MultiWriter(os.Stderr, journal))
+
+	// اجرای دستور migration بدون بالا آوردن برنامه
+	if *migrateCommand != "" {
+		db, err := database.Open(cfg)
+		if err != nil {
+			log.Fatalf("❌ Error opening database: %s", err)
+		}
+		if err := database.RunMigrationCommand(db, os.Stdout, *migrateCommand, *migrateSteps, *dryRun); err != nil {
+			log.Fatalf("❌ Migration failed: %s", err)
+		}
+		return
+	}
+
 	// Initialize database first
-	db, err := database.Init()
+	db, err := database.Init(cfg)
 	if err != nil {
 		log.Fatalf("❌ Error initializing database: %s", err)
 	}
 
-	auth := &services.AuthService{
+	// ورود داده‌های سیستم قدیم؛ بعد از migration و seed تا ردیف‌های پایه شناخته شوند
+	if *legacyDump != "" || *legacyDSN != "" {
+		var source database.LegacySource
+		if *legacyDump != "" {
+			source, err = database.OpenLegacyDump(*legacyDump)
+		} else {
+			source, err = database.OpenLegacyPostgres(*legacyDSN)
+		}
+		if err != nil {
+			log.Fatalf("❌ Error opening legacy data: %s", err)
+		}
+		defer source.Close()
+		name := "legacy-import-" + time.Now().Format("20060102-150405")
+		if *dryRun {
+			name += "-dry-run"
+		}
+		report := filepath.Join(cfg.JournalDir, name+".json")
+		if err := database.RunLegacyImport(db, source, os.Stdout, report, *dryRun); err != nil {
+			log.Fatalf("❌ Legacy import failed: %s", err)
+		}
+		return
+	}
+
+	// ثبت خودکار تغییرات در ActionLog
+	if err := services.RegisterAuditCallbacks(db); err != nil {
+		log.Fatalf("❌ Error registering audit callbacks: %s", err)
+	}
+
+	authz := &services.Authorizer{
 		DB: db,
 	}
 
+	auth := &services.AuthService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	users := &services.UserService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	registrations := &services.RegistrationService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	live := &services.LiveEventService{
+		DB:    db,
+		Authz: authz,
+	}
+	services.RegisterLiveEvents(live)
+
+	actionLogs := &services.ActionLogService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	// پشتیبان‌گیری زمان‌بندی‌شده هم‌زمان با دریافت رویدادها اجرا می‌شود
+	backups := &services.BackupService{
+		DB:    db,
+		Authz: authz,
+		Dir:   cfg.BackupDir,
+	}
+	services.StartBackupScheduler(backups)
+
+	// رویدادهای قدیمی‌تر از event.retentionDays به فایل‌های ماهانه منتقل می‌شوند
+	archives := &services.EventArchiveService{
+		DB:    db,
+		Authz: authz,
+		Dir:   cfg.ArchiveDir,
+	}
+	services.StartArchiveScheduler(archives)
+
+	recycleBin := &services.RecycleBinService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	panelClocks := &services.PanelClockService{
+		DB:    db,
+		Authz: authz,
+	}
+	// رویدادهای قبل از ستون panelTime در پس‌زمینه تبدیل می‌شوند
+	go services.BackfillPanelTimes(db)
+
+	events := &services.EventService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	reports := &services.EventReportService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	// خروجی‌های بزرگ در پس‌زمینه نوشته می‌شوند
+	exports := &services.ExportService{
+		DB:      db,
+		Authz:   authz,
+		Reports: reports,
+		Dir:     cfg.ExportDir,
+	}
+
+	panelTypes := &services.PanelTypeService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	branches := &services.BranchService{
+		DB:    db,
+		Authz: authz,
+	}
+
+	// گزارش خطای ورود شعبه‌ها کنار خروجی‌ها نوشته می‌شود
+	branchImports := &services.BranchImportService{
+		DB:    db,
+		Authz: authz,
+		Dir:   cfg.ExportDir,
+	}
+
+	search := &services.SearchService{
+		DB:    db,
+		Authz: authz,
+	}
+
 	app := &App{
 		DB:          db,
 		AuthService: auth,
@@ -45,9 +208,30 @@ func main() {
 		Width:  1200,
 		Height: 750,
 		Assets: assets,
+		OnStartup: func(ctx context.Context) {
+			services.StartLiveEvents(ctx, live)
+			services.StartExports(ctx, exports)
+		},
+		// خطاها به صورت {statusCode, message} به فرانت برمی‌گردند
+		ErrorFormatter: services.FormatError,
 		Bind: []interface{}{
 			app,
 			auth,
+			users,
+			registrations,
+			live,
+			actionLogs,
+			backups,
+			archives,
+			recycleBin,
+			panelClocks,
+			search,
+			events,
+			reports,
+			exports,
+			panelTypes,
+			branches,
+			branchImports,
 		},
 	}); err != nil {
 		log.Fatalf("❌ Failed to start Wails app: %s", err)
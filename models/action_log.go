@@ -15,7 +15,7 @@ type ActionLog struct {
 	UserInfo      datatypes.JSON `gorm:"column:userInfo" json:"userInfo"`
 	ChangedFields datatypes.JSON `gorm:"column:changedFields" json:"changedFields"`
 	CreatedAt     time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
-	UserID        string         `gorm:"column:userId" json:"userId"`
+	UserID        string         `gorm:"column:userId;default:null" json:"userId"`
 	ModelID       string         `gorm:"column:model_id" json:"model_id"`
 	Version       int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt     gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
@@ -31,7 +31,7 @@ type Alarm struct {
 	Action         UserAction     `gorm:"column:action;default:'NONE'" json:"action"`
 	OldPanelTypeID int            `gorm:"column:old_panelTypeId" json:"old_panelTypeId"`
 	CategoryID     *string        `gorm:"column:categoryId" json:"categoryId"`
-	PanelTypeID    string         `gorm:"column:panelTypeId" json:"panelTypeId"`
+	PanelTypeID    string         `gorm:"column:panelTypeId;default:null" json:"panelTypeId"`
 	Version        int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt      gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 	CreatedAt      time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
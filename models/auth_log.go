@@ -5,13 +5,26 @@ import (
 	"time"
 )
 
+type AuthStatus string
+
+const (
+	AuthStatusSuccess        AuthStatus = "SUCCESS"
+	AuthStatusFailed         AuthStatus = "FAILED"
+	AuthStatusLocked         AuthStatus = "LOCKED"
+	AuthStatusUnlocked       AuthStatus = "UNLOCKED"
+	AuthStatusSourceUnlocked AuthStatus = "SOURCE_UNLOCKED"
+)
+
 type AuthLog struct {
 	ID         string         `gorm:"primaryKey;type:text;column:id" json:"id"`
 	OldID      int            `gorm:"column:old_id" json:"old_id"`
 	IP         string         `gorm:"column:ip" json:"ip"`
+	Username   string         `gorm:"column:username;index" json:"username"`
+	Status     AuthStatus     `gorm:"column:status" json:"status"`
+	Reason     string         `gorm:"column:reason" json:"reason"`
 	LoginTime  time.Time      `gorm:"autoCreateTime;column:loginTime" json:"loginTime"`
 	LogoutTime time.Time      `gorm:"column:logoutTime" json:"logoutTime"` // nullable
-	UserID     string         `gorm:"column:userId;index" json:"userId"`
+	UserID     string         `gorm:"column:userId;default:null;index" json:"userId"`
 	Version    int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt  gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 }
@@ -22,10 +22,11 @@ type Branch struct {
 	OldReceiverID          int            `gorm:"column:old_receiverId" json:"old_receiverId"`
 	CreatedAt              time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt              time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
-	ReceiverID             string         `gorm:"column:receiverId" json:"receiverId"`
-	PanelTypeID            string         `gorm:"column:panelTypeId" json:"panelTypeId"`
-	MainPartitionID        string         `gorm:"column:mainPartitionId" json:"mainPartitionId"`
-	LocationID             string         `gorm:"column:locationId" json:"locationId"`
+	ReceiverID             string         `gorm:"column:receiverId;default:null" json:"receiverId"`
+	PanelTypeID            string         `gorm:"column:panelTypeId;default:null" json:"panelTypeId"`
+	MainPartitionID        string         `gorm:"column:mainPartitionId;default:null" json:"mainPartitionId"`
+	LocationID             string         `gorm:"column:locationId;default:null" json:"locationId"`
+	TimeZone               string         `gorm:"column:timeZone" json:"timeZone"` // منطقه زمانی ساعت پنل؛ خالی یعنی event.timeZone
 	Version                int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt              gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 }
@@ -13,7 +13,7 @@ type Employee struct {
 	LastName     string         `gorm:"column:lastName" json:"lastName"`
 	Position     string         `gorm:"column:position" json:"position"`
 	NationalCode string         `gorm:"column:nationalCode" json:"nationalCode"`
-	BranchID     string         `gorm:"column:branchId;index" json:"branchId"` // ایندکس برای کوئری سریع
+	BranchID     string         `gorm:"column:branchId;default:null;index" json:"branchId"` // ایندکس برای کوئری سریع
 	CreatedAt    time.Time      `gorm:"autoCreateTime;column:createdAt" json:"createdAt"`
 	UpdatedAt    time.Time      `gorm:"autoUpdateTime;column:updatedAt" json:"updatedAt"`
 	Version      int            `gorm:"column:version;default:0" json:"version"`
@@ -11,7 +11,7 @@ type Equipment struct {
 	Name      string         `gorm:"column:name" json:"name"`
 	Model     string         `gorm:"column:model" json:"model"`
 	ImgUrl    string         `gorm:"column:imgUrl" json:"imgUrl"`
-	BranchID  string         `gorm:"column:branchId;index" json:"branchId"` // ایندکس برای جستجو
+	BranchID  string         `gorm:"column:branchId;default:null;index" json:"branchId"` // ایندکس برای جستجو
 	CreatedAt time.Time      `gorm:"autoCreateTime;column:createdAt" json:"createdAt"`
 	UpdatedAt time.Time      `gorm:"autoUpdateTime;column:updatedAt" json:"updatedAt"`
 	Version   int            `gorm:"column:version;default:0" json:"version"`
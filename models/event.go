@@ -18,12 +18,17 @@ type Event struct {
 	IP                  string         `gorm:"column:ip" json:"ip"`
 	Description         string         `gorm:"column:description" json:"description"`
 	ConfirmationStatus  string         `gorm:"column:confirmationStatus" json:"confirmationStatus"`
-	AlarmID             string         `gorm:"column:alarmId" json:"alarmId"`
-	BranchID            string         `gorm:"column:branchId" json:"branchId"`
-	ZoneID              string         `gorm:"column:zoneId" json:"zoneId"`
-	PartitionID         string         `gorm:"column:partitionId" json:"partitionId"`
-	EmployeeID          string         `gorm:"column:employeeId" json:"employeeId"`
+	AlarmID             string         `gorm:"column:alarmId;default:null" json:"alarmId"`
+	BranchID            string         `gorm:"column:branchId;default:null" json:"branchId"`
+	ZoneID              string         `gorm:"column:zoneId;default:null" json:"zoneId"`
+	PartitionID         string         `gorm:"column:partitionId;default:null" json:"partitionId"`
+	EmployeeID          string         `gorm:"column:employeeId;default:null" json:"employeeId"`
 	DedupHash           string         `gorm:"column:dedupHash;index:idx_event_deduphash_active,unique" json:"dedupHash"`
+	PanelTime           *time.Time     `gorm:"column:panelTime" json:"panelTime"`                             // زمان گزارش‌شده توسط پنل در منطقه زمانی شعبه
+	ClockDrift          *int           `gorm:"column:clockDrift" json:"clockDrift"`                           // اختلاف زمان دریافت با زمان پنل، به ثانیه
+	PanelTimeInvalid    bool           `gorm:"column:panelTimeInvalid;default:false" json:"panelTimeInvalid"` // date و time پنل خوانده نشد
+	ConfirmedAt         *time.Time     `gorm:"column:confirmedAt" json:"confirmedAt"`                         // زمان تایید رویداد توسط اپراتور
+	FalseAlarm          bool           `gorm:"column:falseAlarm;default:false" json:"falseAlarm"`             // اپراتور رویداد را هشدار کاذب اعلام کرده
 	CreatedAt           time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	Version             int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt           gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
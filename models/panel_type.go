@@ -15,6 +15,7 @@ type PanelType struct {
 	Delimiter     string         `gorm:"column:delimiter" json:"delimiter"`
 	EventFormat   []string       `gorm:"-" json:"eventFormat"`       // به عنوان []string در ساختار
 	EventFormatJSON string       `gorm:"column:eventFormat" json:"-"` // ذخیره به صورت JSON string
+	Calendar      string         `gorm:"column:calendar;default:gregorian" json:"calendar"` // تقویم تاریخ پیام‌های پنل: gregorian یا jalali
 	CreatedAt     time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt     time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
 	Version       int            `gorm:"column:version;default:0" json:"version"`
@@ -13,8 +13,8 @@ type Partition struct {
 	CreatedAt         time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt         time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
 	OldBranchDefaultID int           `gorm:"column:old_branchDefaultId" json:"old_branchDefaultId"`
-	BranchID          string         `gorm:"column:branchId" json:"branchId"`
-	BranchDefaultID   string         `gorm:"column:branchDefaultId" json:"branchDefaultId"`
+	BranchID          string         `gorm:"column:branchId;default:null" json:"branchId"`
+	BranchDefaultID   string         `gorm:"column:branchDefaultId;default:null" json:"branchDefaultId"`
 	Version           int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt         gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 }
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PasswordHistory keeps previous password hashes so they cannot be reused
+type PasswordHistory struct {
+	ID           string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	UserID       string         `gorm:"column:userId;default:null;index" json:"userId"`
+	PasswordHash string         `gorm:"column:passwordHash" json:"-"`
+	CreatedAt    time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
+	Version      int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt    gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+}
+
+func (PasswordHistory) TableName() string {
+	return "PasswordHistory"
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ResetStatus string
+
+const (
+	ResetStatusPending  ResetStatus = "PENDING"
+	ResetStatusApproved ResetStatus = "APPROVED"
+	ResetStatusRejected ResetStatus = "REJECTED"
+)
+
+type PasswordResetRequest struct {
+	ID          string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	UserID      string         `gorm:"column:userId;default:null;index" json:"userId"`
+	Username    string         `gorm:"column:username" json:"username"`
+	PhoneNumber string         `gorm:"column:phoneNumber" json:"phoneNumber"`
+	Status      ResetStatus    `gorm:"column:status;default:PENDING" json:"status"`
+	RequestedAt time.Time      `gorm:"column:requestedAt;autoCreateTime" json:"requestedAt"`
+	ResolvedAt  *time.Time     `gorm:"column:resolvedAt" json:"resolvedAt"`
+	ResolvedBy  string         `gorm:"column:resolvedBy" json:"resolvedBy"`
+	Note        string         `gorm:"column:note" json:"note"`
+	Version     int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt   gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+}
+
+func (PasswordResetRequest) TableName() string {
+	return "PasswordResetRequest"
+}
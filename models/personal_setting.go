@@ -10,7 +10,7 @@ type PersonalSetting struct {
 	OldID     int            `gorm:"column:old_id" json:"old_id"`
 	Key       string         `gorm:"column:key" json:"key"`
 	Value     string         `gorm:"column:value" json:"value"`
-	UserID    string         `gorm:"column:userId" json:"userId"`
+	UserID    string         `gorm:"column:userId;default:null" json:"userId"`
 	Version   int            `gorm:"column:version;default:0" json:"version"`
 	CreatedAt time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
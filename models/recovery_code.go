@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time code that replaces a TOTP code when the authenticator is lost
+type RecoveryCode struct {
+	ID        string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	UserID    string         `gorm:"column:userId;default:null;index" json:"userId"`
+	CodeHash  string         `gorm:"column:codeHash" json:"-"`
+	UsedAt    *time.Time     `gorm:"column:usedAt" json:"usedAt"`
+	CreatedAt time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
+	Version   int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "RecoveryCode"
+}
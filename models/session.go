@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type Session struct {
+	ID        string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	TokenHash string         `gorm:"column:tokenHash;index" json:"-"`
+	UserID    string         `gorm:"column:userId;default:null;index" json:"userId"`
+	AuthLogID string         `gorm:"column:authLogId" json:"authLogId"`
+	IP        string         `gorm:"column:ip" json:"ip"`
+	Stage     string         `gorm:"column:stage" json:"stage"` // empty for a full session, TOTP while waiting for the second factor
+	ExpiresAt time.Time      `gorm:"column:expiresAt" json:"expiresAt"`
+	CreatedAt time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
+	Version   int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+}
+
+func (Session) TableName() string {
+	return "Session"
+}
@@ -1,32 +1,43 @@
 package models
 
 import (
-	"time"
 	"gorm.io/gorm"
+	"time"
+)
+
+const (
+	UserStatusPending  = "PENDING"
+	UserStatusRejected = "REJECTED"
+	UserStatusOffline  = "OFFLINE"
 )
 
 type User struct {
-	ID               string         `gorm:"primaryKey;type:text;column:id" json:"id"`
-	OldID            int            `gorm:"column:old_id" json:"old_id"`
-	Fullname         string         `gorm:"column:fullname" json:"fullname"`
-	Username         string         `gorm:"column:username" json:"username"`
-	NationalityCode  string         `gorm:"column:nationalityCode" json:"nationalityCode"`
-	Password         string         `gorm:"column:password" json:"password"`
-	Type             string         `gorm:"column:type" json:"type"`
-	PersonalCode     string         `gorm:"column:personalCode" json:"personalCode"`
-	AvatarUrl        string         `gorm:"column:avatarUrl" json:"avatarUrl"`
-	FatherName       string         `gorm:"column:fatherName" json:"fatherName"`
-	PhoneNumber      string         `gorm:"column:phoneNumber" json:"phoneNumber"`
-	Address          string         `gorm:"column:address" json:"address"`
-	IP               string         `gorm:"column:ip" json:"ip"`
-	Status           string         `gorm:"column:status;default:OFFLINE" json:"status"`
-	OldLocationID    int            `gorm:"column:old_locationId" json:"old_locationId"`
-	ConfirmationTime string         `gorm:"column:confirmationTime" json:"confirmationTime"`
-	LocationID       string         `gorm:"column:locationId" json:"locationId"`
-	CreatedAt        time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
-	UpdatedAt        time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
-	Version          int            `gorm:"column:version;default:0" json:"version"`
-	DeletedAt        gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+	ID                 string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	OldID              int            `gorm:"column:old_id" json:"old_id"`
+	Fullname           string         `gorm:"column:fullname" json:"fullname"`
+	Username           string         `gorm:"column:username" json:"username"`
+	NationalityCode    string         `gorm:"column:nationalityCode" json:"nationalityCode"`
+	Password           string         `gorm:"column:password" json:"password"`
+	Type               string         `gorm:"column:type" json:"type"`
+	PersonalCode       string         `gorm:"column:personalCode" json:"personalCode"`
+	AvatarUrl          string         `gorm:"column:avatarUrl" json:"avatarUrl"`
+	FatherName         string         `gorm:"column:fatherName" json:"fatherName"`
+	PhoneNumber        string         `gorm:"column:phoneNumber" json:"phoneNumber"`
+	Address            string         `gorm:"column:address" json:"address"`
+	IP                 string         `gorm:"column:ip" json:"ip"`
+	Status             string         `gorm:"column:status;default:OFFLINE" json:"status"`
+	OldLocationID      int            `gorm:"column:old_locationId" json:"old_locationId"`
+	ConfirmationTime   string         `gorm:"column:confirmationTime" json:"confirmationTime"`
+	MustChangePassword bool           `gorm:"column:mustChangePassword;default:false" json:"mustChangePassword"`
+	PasswordChangedAt  *time.Time     `gorm:"column:passwordChangedAt" json:"passwordChangedAt"`
+	TotpSecret         string         `gorm:"column:totpSecret" json:"-"`
+	TotpEnabled        bool           `gorm:"column:totpEnabled;default:false" json:"totpEnabled"`
+	TotpLastStep       int64          `gorm:"column:totpLastStep;default:0" json:"-"`
+	LocationID         string         `gorm:"column:locationId;default:null" json:"locationId"`
+	CreatedAt          time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
+	UpdatedAt          time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
+	Version            int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt          gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 }
 
 func (User) TableName() string {
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// UserLocation gives a user access to a location subtree in addition to User.LocationID
+type UserLocation struct {
+	ID         string         `gorm:"primaryKey;type:text;column:id" json:"id"`
+	UserID     string         `gorm:"column:userId;default:null;index" json:"userId"`
+	LocationID string         `gorm:"column:locationId;default:null" json:"locationId"`
+	CreatedAt  time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
+	Version    int            `gorm:"column:version;default:0" json:"version"`
+	DeletedAt  gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
+}
+
+func (UserLocation) TableName() string {
+	return "UserLocation"
+}
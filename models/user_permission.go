@@ -8,9 +8,9 @@ type UserPermission struct {
 	ID             string         `gorm:"primaryKey;type:text;column:id" json:"id"`
 	OldID          int            `gorm:"column:old_id" json:"old_id"`
 	ModelID        int            `gorm:"column:modelId" json:"modelId"`
-	UserID         string         `gorm:"column:userId" json:"userId"`
+	UserID         string         `gorm:"column:userId;default:null" json:"userId"`
 	OldPermissionID int           `gorm:"column:old_permissionId" json:"old_permissionId"`
-	PermissionID   string         `gorm:"column:permissionId" json:"permissionId"`
+	PermissionID   string         `gorm:"column:permissionId;default:null" json:"permissionId"`
 	Version        int            `gorm:"column:version;default:0" json:"version"`
 	DeletedAt      gorm.DeletedAt `gorm:"column:deletedAt;index" json:"deletedAt"`
 }
@@ -10,8 +10,8 @@ type UserSetting struct {
 	OldID           int            `gorm:"column:old_id" json:"old_id"`
 	AlarmColor      string         `gorm:"column:alarmColor" json:"alarmColor"`
 	AudioUrl        string         `gorm:"column:audioUrl" json:"audioUrl"`
-	AlarmCategoryID string         `gorm:"column:alarmCategoryId" json:"alarmCategoryId"`
-	UserID          string         `gorm:"column:userId" json:"userId"`
+	AlarmCategoryID string         `gorm:"column:alarmCategoryId;default:null" json:"alarmCategoryId"`
+	UserID          string         `gorm:"column:userId;default:null" json:"userId"`
 	CreatedAt       time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt       time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
 	Version         int            `gorm:"column:version;default:0" json:"version"`
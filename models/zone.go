@@ -10,8 +10,8 @@ type Zone struct {
 	OldID       int            `gorm:"column:old_id" json:"old_id"`
 	LocalID     int            `gorm:"column:localId" json:"localId"`
 	Label       string         `gorm:"column:label" json:"label"`
-	ZoneTypeID  string         `gorm:"column:zoneTypeId" json:"zoneTypeId"`
-	PartitionID string         `gorm:"column:partitionId" json:"partitionId"`
+	ZoneTypeID  string         `gorm:"column:zoneTypeId;default:null" json:"zoneTypeId"`
+	PartitionID string         `gorm:"column:partitionId;default:null" json:"partitionId"`
 	CreatedAt   time.Time      `gorm:"column:createdAt;autoCreateTime" json:"createdAt"`
 	UpdatedAt   time.Time      `gorm:"column:updatedAt;autoUpdateTime" json:"updatedAt"`
 	Version     int            `gorm:"column:version;default:0" json:"version"`
@@ -0,0 +1,54 @@
+package seeders
+
+import (
+	"log"
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+func SeedAppSettings(db *gorm.DB) {
+	// تنظیمات پیش‌فرض؛ مقادیری که ادمین تغییر داده دست نمی‌خورند
+	settings := []models.AppSetting{
+		{Key: "auth.maxFailedAttempts", Value: "5", IsVisible: true},
+		{Key: "auth.maxFailedAttemptsPerSource", Value: "50", IsVisible: true},
+		{Key: "auth.failureWindowMinutes", Value: "15", IsVisible: true},
+		{Key: "auth.lockoutMinutes", Value: "15", IsVisible: true},
+		{Key: "auth.delayBaseMs", Value: "500", IsVisible: true},
+		{Key: "auth.delayMaxMs", Value: "8000", IsVisible: true},
+		{Key: "auth.sessionHours", Value: "12", IsVisible: true},
+		{Key: "auth.totpIssuer", Value: "Monitoring", IsVisible: true},
+		{Key: "auth.require2faTypes", Value: "", IsVisible: true},
+		{Key: "password.minLength", Value: "8", IsVisible: true},
+		{Key: "password.requireUpper", Value: "true", IsVisible: true},
+		{Key: "password.requireLower", Value: "true", IsVisible: true},
+		{Key: "password.requireDigit", Value: "true", IsVisible: true},
+		{Key: "password.requireSymbol", Value: "true", IsVisible: true},
+		{Key: "password.historySize", Value: "5", IsVisible: true},
+		{Key: "password.maxAgeDays", Value: "90", IsVisible: true},
+		{Key: "backup.intervalHours", Value: "24", IsVisible: true},
+		{Key: "backup.keepCount", Value: "14", IsVisible: true},
+		{Key: "backup.keepDays", Value: "30", IsVisible: true},
+		{Key: "event.retentionDays", Value: "90", IsVisible: true},
+		{Key: "event.timeZone", Value: "Asia/Tehran", IsVisible: true},
+		{Key: "event.clockDriftToleranceSeconds", Value: "300", IsVisible: true},
+		{Key: "udp.receiverId", Value: "", IsVisible: true},
+		{Key: "report.cacheSeconds", Value: "300", IsVisible: true},
+	}
+
+	for _, setting := range settings {
+		var existing models.AppSetting
+		tx := db.Where("key = ?", setting.Key).First(&existing)
+		if tx.Error == gorm.ErrRecordNotFound {
+			setting.ID = uuid.NewString()
+			if err := db.Create(&setting).Error; err != nil {
+				log.Printf("Failed to insert app setting %s: %v", setting.Key, err)
+			}
+		} else if tx.Error != nil {
+			log.Printf("Error checking app setting %s: %v", setting.Key, tx.Error)
+		}
+	}
+
+	log.Println("✅ App settings seeded successfully.")
+}
@@ -1,7 +1,6 @@
 package seeders
 
 import (
-	"context"
 	"log"
 	"monitoring-with-go/models"
 	"github.com/google/uuid"
@@ -23,23 +22,17 @@ func SeedLocations(db *gorm.DB) {
 		log.Fatalf("count Location: %v", err)
 	}
 
-	// اگر جدول دارای داده باشد، از seeding و migration جلوگیری کنیم
+	// اگر جدول دارای داده باشد، از seeding جلوگیری کنیم
 	if cnt > 0 {
 		log.Println("ℹ️ Location already seeded; skipping.")
 		return
 	}
 
-	ctx := context.Background()
-
-	if err := db.WithContext(ctx).AutoMigrate(&models.Location{}); err != nil {
-		log.Fatalf("migrate Location: %v", err)
-	}
-
 	// 1) کشور
 	iran := models.Location{ID: uuid.NewString(), Type: "COUNTRY", Label: "ایران"}
 	{
 		var existed models.Location
-		tx := db.Where("label = ? AND type = ? AND parentId IS NULL", iran.Label, iran.Type).First(&existed)
+		tx := db.Where(`label = ? AND type = ? AND "parentId" IS NULL`, iran.Label, iran.Type).First(&existed)
 		if tx.Error == gorm.ErrRecordNotFound {
 			if err := db.Create(&iran).Error; err != nil {
 				log.Fatalf("create country: %v", err)
@@ -92,7 +85,7 @@ func SeedLocations(db *gorm.DB) {
 		prov := models.Location{ID: uuid.NewString(), Type: "STATE", Label: pname, ParentID: &iran.ID}
 
 		var existed models.Location
-		tx := db.Where("label = ? AND type = ? AND parentId = ?", prov.Label, prov.Type, iran.ID).First(&existed)
+		tx := db.Where(`label = ? AND type = ? AND "parentId" = ?`, prov.Label, prov.Type, iran.ID).First(&existed)
 		if tx.Error == gorm.ErrRecordNotFound {
 			if err := db.Create(&prov).Error; err != nil {
 				log.Fatalf("create province %s: %v", pname, err)
@@ -113,7 +106,7 @@ func SeedLocations(db *gorm.DB) {
 			city := models.Location{ID: uuid.NewString(), Type: "CITY", Label: cname, ParentID: &parent}
 
 			var existed models.Location
-			tx := db.Where("label = ? AND type = ? AND parentId = ?", city.Label, city.Type, parent).First(&existed)
+			tx := db.Where(`label = ? AND type = ? AND "parentId" = ?`, city.Label, city.Type, parent).First(&existed)
 			if tx.Error == gorm.ErrRecordNotFound {
 				if err := db.Create(&city).Error; err != nil {
 					log.Fatalf("create city %s/%s: %v", pname, cname, err)
@@ -8,18 +8,10 @@ import (
 )
 
 func SeedPermissions(db *gorm.DB) {
-	// Check if Permissions already exist
-	var count int64
-	tx := db.Model(&models.Permission{}).Count(&count)
-	if tx.Error != nil {
-		log.Fatalf("Failed to check Permissions count: %v", tx.Error)
-	}
-	if count > 0 {
-		log.Println("Permissions already exist, skipping seeding.")
-		return
-	}
+	field := func(name string) *string { return &name }
 
-	// Define the permissions
+	// Define the permissions; missing ones are inserted on every start so new
+	// permissions reach existing databases too
 	permissions := []models.Permission{
 		{
 			ID:          uuid.NewString(),
@@ -57,20 +49,396 @@ func SeedPermissions(db *gorm.DB) {
 			Description: "مشاهده کاربر",
 			Version:     0,
 		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       5,
+			Action:      models.PermissionRead,
+			Model:       "User",
+			Field:       field("nationalityCode"),
+			Description: "مشاهده کد ملی کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       6,
+			Action:      models.PermissionUpdate,
+			Model:       "User",
+			Field:       field("nationalityCode"),
+			Description: "ویرایش کد ملی کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       7,
+			Action:      models.PermissionRead,
+			Model:       "User",
+			Field:       field("phoneNumber"),
+			Description: "مشاهده شماره تماس کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       8,
+			Action:      models.PermissionUpdate,
+			Model:       "User",
+			Field:       field("phoneNumber"),
+			Description: "ویرایش شماره تماس کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       9,
+			Action:      models.PermissionRead,
+			Model:       "Branch",
+			Field:       field("panelIp"),
+			Description: "مشاهده آی‌پی پنل شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       10,
+			Action:      models.PermissionUpdate,
+			Model:       "Branch",
+			Field:       field("panelIp"),
+			Description: "ویرایش آی‌پی پنل شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       11,
+			Action:      models.PermissionRead,
+			Model:       "Branch",
+			Field:       field("emergencyCall"),
+			Description: "مشاهده شماره اضطراری شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       12,
+			Action:      models.PermissionUpdate,
+			Model:       "Branch",
+			Field:       field("emergencyCall"),
+			Description: "ویرایش شماره اضطراری شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       13,
+			Action:      models.PermissionRead,
+			Model:       "Employee",
+			Field:       field("nationalCode"),
+			Description: "مشاهده کد ملی کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       14,
+			Action:      models.PermissionUpdate,
+			Model:       "Employee",
+			Field:       field("nationalCode"),
+			Description: "ویرایش کد ملی کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       15,
+			Action:      models.PermissionRead,
+			Model:       "Event",
+			Field:       nil,
+			Description: "مشاهده رویدادها",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       16,
+			Action:      models.PermissionAssign,
+			Model:       "Location",
+			Field:       nil,
+			Description: "تخصیص محدوده مکانی به کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       17,
+			Action:      models.PermissionAssign,
+			Model:       "Permission",
+			Field:       nil,
+			Description: "تخصیص دسترسی به کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       18,
+			Action:      models.PermissionRead,
+			Model:       "ActionLog",
+			Field:       nil,
+			Description: "مشاهده گزارش تغییرات",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       19,
+			Action:      models.PermissionRead,
+			Model:       "Backup",
+			Field:       nil,
+			Description: "مشاهده نسخه‌های پشتیبان",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       20,
+			Action:      models.PermissionCreate,
+			Model:       "Backup",
+			Field:       nil,
+			Description: "تهیه نسخه پشتیبان",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       21,
+			Action:      models.PermissionUpdate,
+			Model:       "Backup",
+			Field:       nil,
+			Description: "بازگردانی نسخه پشتیبان",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       22,
+			Action:      models.PermissionDelete,
+			Model:       "Event",
+			Field:       nil,
+			Description: "بایگانی رویدادهای قدیمی",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       23,
+			Action:      models.PermissionUpdate,
+			Model:       "PanelType",
+			Field:       nil,
+			Description: "ویرایش نوع پنل",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       24,
+			Action:      models.PermissionCreate,
+			Model:       "Branch",
+			Field:       nil,
+			Description: "ایجاد شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       25,
+			Action:      models.PermissionRead,
+			Model:       "Branch",
+			Field:       nil,
+			Description: "مشاهده شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       26,
+			Action:      models.PermissionUpdate,
+			Model:       "Branch",
+			Field:       nil,
+			Description: "ویرایش شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       27,
+			Action:      models.PermissionCreate,
+			Model:       "Partition",
+			Field:       nil,
+			Description: "ایجاد پارتیشن",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       28,
+			Action:      models.PermissionUpdate,
+			Model:       "Partition",
+			Field:       nil,
+			Description: "ویرایش پارتیشن",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       29,
+			Action:      models.PermissionDelete,
+			Model:       "Partition",
+			Field:       nil,
+			Description: "حذف پارتیشن",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       30,
+			Action:      models.PermissionCreate,
+			Model:       "Zone",
+			Field:       nil,
+			Description: "ایجاد زون",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       31,
+			Action:      models.PermissionUpdate,
+			Model:       "Zone",
+			Field:       nil,
+			Description: "ویرایش زون",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       32,
+			Action:      models.PermissionDelete,
+			Model:       "Zone",
+			Field:       nil,
+			Description: "حذف زون",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       33,
+			Action:      models.PermissionCreate,
+			Model:       "Employee",
+			Field:       nil,
+			Description: "ایجاد کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       34,
+			Action:      models.PermissionUpdate,
+			Model:       "Employee",
+			Field:       nil,
+			Description: "ویرایش کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       35,
+			Action:      models.PermissionDelete,
+			Model:       "Employee",
+			Field:       nil,
+			Description: "حذف کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       36,
+			Action:      models.PermissionCreate,
+			Model:       "PanelType",
+			Field:       nil,
+			Description: "ایجاد نوع پنل",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       37,
+			Action:      models.PermissionUpdate,
+			Model:       "AppSetting",
+			Field:       nil,
+			Description: "ویرایش تنظیمات برنامه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       38,
+			Action:      models.PermissionDelete,
+			Model:       "Branch",
+			Field:       nil,
+			Description: "حذف شعبه",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       39,
+			Action:      models.PermissionDelete,
+			Model:       "User",
+			Field:       nil,
+			Description: "حذف کاربر",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       40,
+			Action:      models.PermissionRead,
+			Model:       "PanelType",
+			Field:       nil,
+			Description: "مشاهده نوع پنل",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       41,
+			Action:      models.PermissionDelete,
+			Model:       "PanelType",
+			Field:       nil,
+			Description: "حذف نوع پنل",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       42,
+			Action:      models.PermissionRead,
+			Model:       "Partition",
+			Field:       nil,
+			Description: "مشاهده پارتیشن",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       43,
+			Action:      models.PermissionRead,
+			Model:       "Zone",
+			Field:       nil,
+			Description: "مشاهده زون",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       44,
+			Action:      models.PermissionRead,
+			Model:       "Employee",
+			Field:       nil,
+			Description: "مشاهده کارمند",
+			Version:     0,
+		},
+		{
+			ID:          uuid.NewString(),
+			OldID:       45,
+			Action:      models.PermissionUpdate,
+			Model:       "Event",
+			Field:       nil,
+			Description: "تایید و ویرایش رویداد",
+			Version:     0,
+		},
 		// Add other permissions as needed
 	}
 
 	// Insert permissions if not already present
 	for _, permission := range permissions {
 		var existing models.Permission
-		// Check if the permission already exists based on `action` and `model`
-		tx := db.Where("action = ? AND model = ?", permission.Action, permission.Model).First(&existing)
+		// Check if the permission already exists based on `action`, `model` and `field`
+		query := db.Where("action = ? AND model = ?", permission.Action, permission.Model)
+		if permission.Field == nil {
+			query = query.Where("field IS NULL")
+		} else {
+			query = query.Where("field = ?", *permission.Field)
+		}
+		tx := query.First(&existing)
 		if tx.Error == gorm.ErrRecordNotFound {
 			// Insert the permission if it doesn't exist
 			if err := db.Create(&permission).Error; err != nil {
 				log.Printf("Failed to insert permission: %v", err)
 			} else {
 				log.Printf("Seeded permission: %v", permission.Description)
+				grantImplied(db, permission)
 			}
 		} else if tx.Error != nil {
 			log.Printf("Error checking permission: %v", tx.Error)
@@ -79,3 +447,34 @@ func SeedPermissions(db *gorm.DB) {
 
 	log.Println("✅ Permissions seeded successfully.")
 }
+
+// impliedPermissions maps a permission that took over a method to the one that guarded it
+// before, so the users who could call the method keep it after upgrading
+var impliedPermissions = map[[2]string][2]string{
+	// تایید رویداد قبلاً با CREATE Event کنترل می‌شد
+	{string(models.PermissionUpdate), "Event"}: {string(models.PermissionCreate), "Event"},
+}
+
+// grantImplied gives a newly seeded permission to the users holding the one it replaces
+func grantImplied(db *gorm.DB, permission models.Permission) {
+	from, ok := impliedPermissions[[2]string{string(permission.Action), permission.Model}]
+	if !ok {
+		return
+	}
+	var userIDs []string
+	err := db.Model(&models.UserPermission{}).
+		Joins(`JOIN "Permission" ON "Permission".id = "UserPermission"."permissionId"`).
+		Where(`"Permission".action = ? AND "Permission".model = ? AND "Permission".field IS NULL`, from[0], from[1]).
+		Distinct(`"UserPermission"."userId"`).
+		Pluck(`"UserPermission"."userId"`, &userIDs).Error
+	if err != nil {
+		log.Printf("Failed to read holders of %s %s: %v", from[0], from[1], err)
+		return
+	}
+	for _, userID := range userIDs {
+		grant := models.UserPermission{ID: uuid.NewString(), UserID: userID, PermissionID: permission.ID}
+		if err := db.Create(&grant).Error; err != nil {
+			log.Printf("Failed to grant %s to user %s: %v", permission.Description, userID, err)
+		}
+	}
+}
@@ -4,13 +4,17 @@ import (
 	"log"
 	"monitoring-with-go/models"
 
+	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 )
 
+// defaultOwnerPassword is only good for the first login; the owner must change it right away
+const defaultOwnerPassword = "@Aa123456"
+
 func SeedUsers(db *gorm.DB) {
 	// هش کردن پسوردها
-	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("@Aa123456"), 10)
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte(defaultOwnerPassword), 10)
 
 	users := []models.User{
 		{
@@ -24,15 +28,16 @@ func SeedUsers(db *gorm.DB) {
 			PhoneNumber:   "0000000000",
 			Address:       "آدرس کاربر اصلاح شود",
 			IP:            "127.0.0.1",
-			LocationID:    "33",
+			MustChangePassword: true,
 		},
 	}
 
 	for _, u := range users {
 		// اگر یوزری با همین Username یا NationalityCode وجود داشته باشه، ایجاد نکن
 		var user models.User
-		result := db.Where("username = ? OR nationalityCode = ?", u.Username, u.NationalityCode).First(&user)
+		result := db.Where(`username = ? OR "nationalityCode" = ?`, u.Username, u.NationalityCode).First(&user)
 		if result.Error == gorm.ErrRecordNotFound {
+			u.ID = uuid.NewString()
 			if err := db.Create(&u).Error; err != nil {
 				log.Println("Error seeding user:", err)
 			} else {
@@ -40,6 +45,14 @@ func SeedUsers(db *gorm.DB) {
 			}
 		} else {
 			log.Println("User already exists:", u.Username)
+			// نسخه‌های قبلی کاربر را بدون id ساخته بودند؛ نشست‌ها به id نیاز دارند
+			if user.ID == "" {
+				db.Exec(`UPDATE "User" SET id = ? WHERE id = '' AND username = ?`, uuid.NewString(), user.Username)
+			}
+			// هنوز با رمز پیش‌فرض است؛ در ورود بعدی باید عوض شود
+			if !user.MustChangePassword && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(defaultOwnerPassword)) == nil {
+				db.Model(&models.User{}).Where("username = ?", user.Username).Update("mustChangePassword", true)
+			}
 		}
 	}
 }
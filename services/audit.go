@@ -0,0 +1,321 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	AuditCreated   = "CREATED"
+	AuditUpdated   = "UPDATED"
+	AuditDeleted   = "DELETED"
+	AuditRestored  = "RESTORED"
+	AuditPurged    = "PURGED"
+	AuditConfirmed = "CONFIRMED"
+)
+
+const auditBeforeKey = "audit:before"
+
+// auditedModels are the domain tables whose changes land in ActionLog.
+// Logs, sessions and other bookkeeping tables are deliberately left out.
+var auditedModels = map[string]bool{
+	"Alarm":           true,
+	"AlarmCategory":   true,
+	"AppSetting":      true,
+	"Branch":          true,
+	"Employee":        true,
+	"Equipment":       true,
+	"Event":           true,
+	"Location":        true,
+	"PanelType":       true,
+	"Partition":       true,
+	"Permission":      true,
+	"PersonalSetting": true,
+	"Receiver":        true,
+	"User":            true,
+	"UserLocation":    true,
+	"UserPermission":  true,
+	"UserSetting":     true,
+	"Zone":            true,
+	"ZoneType":        true,
+}
+
+// رویدادهای دریافتی از پنل خودشان سابقه هستند؛ فقط تغییراتشان ثبت می‌شود
+var auditSkipCreate = map[string]bool{
+	"Event": true,
+}
+
+// auditOmittedFields never appear in a snapshot or diff
+var auditOmittedFields = []string{"password", "updatedAt"}
+
+// auditActor is the snapshot of the acting user stored in ActionLog.userInfo
+type auditActor struct {
+	ID              string `json:"id,omitempty"`
+	Fullname        string `json:"fullName"`
+	Username        string `json:"username"`
+	NationalityCode string `json:"nationalityCode"`
+	PhoneNumber     string `json:"phoneNumber"`
+	AvatarUrl       string `json:"avatarUrl"`
+}
+
+// RegisterAuditCallbacks hooks ActionLog auditing into every create, update and delete of db.
+// The acting user is read from the statement context, see Caller.Context; writes without a
+// caller are recorded as done by the system.
+func RegisterAuditCallbacks(db *gorm.DB) error {
+	callbacks := db.Callback()
+	if err := callbacks.Create().After("gorm:create").Register("audit:after_create", auditAfterCreate); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("audit:before_update", auditBeforeChange); err != nil {
+		return err
+	}
+	if err := callbacks.Update().After("gorm:update").Register("audit:after_update", auditAfterUpdate); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("audit:before_delete", auditBeforeChange); err != nil {
+		return err
+	}
+	return callbacks.Delete().After("gorm:delete").Register("audit:after_delete", auditAfterDelete)
+}
+
+func isAudited(db *gorm.DB) bool {
+	return db.Statement.Schema != nil && auditedModels[db.Statement.Schema.Table]
+}
+
+func auditAfterCreate(db *gorm.DB) {
+	if db.Error != nil || !isAudited(db) || auditSkipCreate[db.Statement.Schema.Table] {
+		return
+	}
+
+	var logs []models.ActionLog
+	eachRecord(db.Statement.ReflectValue, func(record reflect.Value) {
+		after := auditSnapshot(record)
+		id, _ := after["id"].(string)
+		logs = append(logs, newActionLog(db, AuditCreated, id, map[string]any{"after": after}))
+	})
+	writeActionLogs(db, logs)
+}
+
+// auditBeforeChange loads the rows an update or delete is about to touch
+func auditBeforeChange(db *gorm.DB) {
+	if db.Error != nil || !isAudited(db) {
+		return
+	}
+	before, ok := auditLoad(db, auditConditions(db))
+	if ok {
+		db.InstanceSet(auditBeforeKey, before)
+	}
+}
+
+func auditAfterUpdate(db *gorm.DB) {
+	before, ok := auditBefore(db)
+	if !ok || len(before) == 0 {
+		return
+	}
+
+	ids := make([]string, 0, len(before))
+	for id := range before {
+		ids = append(ids, id)
+	}
+	after, ok := auditLoad(db, []clause.Expression{clause.IN{Column: clause.PrimaryColumn, Values: toAny(ids)}})
+	if !ok {
+		return
+	}
+
+	var logs []models.ActionLog
+	for _, id := range ids {
+		old, current := before[id], after[id]
+		if current == nil {
+			continue
+		}
+		changedBefore, changedAfter := diffSnapshots(old, current)
+		if len(changedAfter) == 0 {
+			continue
+		}
+
+		action := AuditUpdated
+		switch {
+		case old["deletedAt"] != nil && current["deletedAt"] == nil:
+			action = AuditRestored
+		case db.Statement.Schema.Table == "Event" && changedAfter["confirmationStatus"] != nil:
+			action = AuditConfirmed
+		}
+		logs = append(logs, newActionLog(db, action, id, map[string]any{"before": changedBefore, "after": changedAfter}))
+	}
+	writeActionLogs(db, logs)
+}
+
+func auditAfterDelete(db *gorm.DB) {
+	before, ok := auditBefore(db)
+	if !ok || len(before) == 0 {
+		return
+	}
+
+	// حذف نرم قابل بازگشت است؛ حذف دائمی جداگانه ثبت می‌شود
+	action := AuditDeleted
+	if db.Statement.Schema.LookUpField("DeletedAt") != nil && db.Statement.Unscoped {
+		action = AuditPurged
+	}
+
+	var logs []models.ActionLog
+	for id, snapshot := range before {
+		logs = append(logs, newActionLog(db, action, id, map[string]any{"before": snapshot}))
+	}
+	writeActionLogs(db, logs)
+}
+
+func auditBefore(db *gorm.DB) (map[string]map[string]any, bool) {
+	if db.Error != nil || !isAudited(db) || db.Statement.RowsAffected == 0 {
+		return nil, false
+	}
+	value, ok := db.InstanceGet(auditBeforeKey)
+	if !ok {
+		return nil, false
+	}
+	before, ok := value.(map[string]map[string]any)
+	return before, ok
+}
+
+// auditConditions returns the WHERE of the running statement, plus the primary keys of the
+// records it was given; gorm only adds the latter inside its own update/delete callback.
+func auditConditions(db *gorm.DB) []clause.Expression {
+	var exprs []clause.Expression
+	if where, ok := db.Statement.Clauses["WHERE"].Expression.(clause.Where); ok && len(where.Exprs) > 0 {
+		exprs = append(exprs, clause.And(where.Exprs...))
+	}
+
+	var ids []any
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field != nil {
+		eachRecord(db.Statement.ReflectValue, func(record reflect.Value) {
+			if value, zero := field.ValueOf(db.Statement.Context, record); !zero {
+				ids = append(ids, value)
+			}
+		})
+	}
+	if len(ids) > 0 {
+		exprs = append(exprs, clause.IN{Column: clause.PrimaryColumn, Values: ids})
+	}
+	return exprs
+}
+
+// auditLoad reads the matching rows, deleted ones included, keyed by id
+func auditLoad(db *gorm.DB, exprs []clause.Expression) (map[string]map[string]any, bool) {
+	// بدون شرط کل جدول خوانده می‌شود؛ gorm هم چنین تغییری را رد می‌کند
+	if len(exprs) == 0 {
+		return nil, false
+	}
+
+	rows := reflect.New(reflect.SliceOf(db.Statement.Schema.ModelType))
+	err := db.Session(&gorm.Session{NewDB: true}).
+		Unscoped().
+		Clauses(clause.Where{Exprs: exprs}).
+		Find(rows.Interface()).Error
+	if err != nil {
+		db.AddError(err)
+		return nil, false
+	}
+
+	result := make(map[string]map[string]any)
+	eachRecord(rows.Elem(), func(record reflect.Value) {
+		snapshot := auditSnapshot(record)
+		if id, ok := snapshot["id"].(string); ok {
+			result[id] = snapshot
+		}
+	})
+	return result, true
+}
+
+// auditSnapshot turns a record into its json form, without omitted fields
+func auditSnapshot(record reflect.Value) map[string]any {
+	snapshot := map[string]any{}
+	data, err := json.Marshal(record.Interface())
+	if err != nil {
+		return snapshot
+	}
+	json.Unmarshal(data, &snapshot)
+	for _, field := range auditOmittedFields {
+		delete(snapshot, field)
+	}
+	return snapshot
+}
+
+// diffSnapshots returns the old and new values of every field that changed
+func diffSnapshots(before, after map[string]any) (map[string]any, map[string]any) {
+	changedBefore, changedAfter := map[string]any{}, map[string]any{}
+	for field, value := range after {
+		if old, ok := before[field]; !ok || !reflect.DeepEqual(old, value) {
+			changedBefore[field] = before[field]
+			changedAfter[field] = value
+		}
+	}
+	return changedBefore, changedAfter
+}
+
+func newActionLog(db *gorm.DB, action string, modelID string, changes map[string]any) models.ActionLog {
+	actor := auditActor{Username: "system"}
+	var userID string
+	if caller := CallerFromContext(db.Statement.Context); caller != nil {
+		user := caller.User
+		userID = user.ID
+		actor = auditActor{
+			ID:              user.ID,
+			Fullname:        user.Fullname,
+			Username:        user.Username,
+			NationalityCode: user.NationalityCode,
+			PhoneNumber:     user.PhoneNumber,
+			AvatarUrl:       user.AvatarUrl,
+		}
+	}
+	userInfo, _ := json.Marshal(actor)
+	changedFields, _ := json.Marshal(changes)
+
+	return models.ActionLog{
+		ID:            uuid.NewString(),
+		Model:         db.Statement.Schema.Table,
+		Action:        action,
+		UserInfo:      userInfo,
+		ChangedFields: changedFields,
+		UserID:        userID,
+		ModelID:       modelID,
+	}
+}
+
+// writeActionLogs stores logs in the same transaction as the change; a failure rolls it back
+func writeActionLogs(db *gorm.DB, logs []models.ActionLog) {
+	if len(logs) == 0 {
+		return
+	}
+	if err := db.Session(&gorm.Session{NewDB: true}).Create(&logs).Error; err != nil {
+		db.AddError(err)
+	}
+}
+
+// eachRecord calls fn for the struct, or every struct of the slice, held in value
+func eachRecord(value reflect.Value, fn func(reflect.Value)) {
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < value.Len(); i++ {
+			if record := reflect.Indirect(value.Index(i)); record.Kind() == reflect.Struct {
+				fn(record)
+			}
+		}
+	case reflect.Struct:
+		fn(value)
+	}
+}
+
+func toAny(values []string) []any {
+	result := make([]any, len(values))
+	for i, v := range values {
+		result[i] = v
+	}
+	return result
+}
@@ -0,0 +1,171 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// auditEntry is an ActionLog row with its json columns decoded
+type auditEntry struct {
+	Action  string
+	ModelID string
+	UserID  string
+	Actor   auditActor
+	Changes map[string]map[string]any
+}
+
+func auditEntries(t *testing.T, db *gorm.DB, model string) []auditEntry {
+	t.Helper()
+	var logs []models.ActionLog
+	if err := db.Where("model = ?", model).Order(`"createdAt"`).Order("rowid").Find(&logs).Error; err != nil {
+		t.Fatal(err)
+	}
+	entries := make([]auditEntry, 0, len(logs))
+	for _, log := range logs {
+		entry := auditEntry{Action: log.Action, ModelID: log.ModelID, UserID: log.UserID}
+		if err := json.Unmarshal(log.UserInfo, &entry.Actor); err != nil {
+			t.Fatalf("user info of %s: %v", log.ID, err)
+		}
+		if err := json.Unmarshal(log.ChangedFields, &entry.Changes); err != nil {
+			t.Fatalf("changed fields of %s: %v", log.ID, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestAuditCallbacks(t *testing.T) {
+	db := newTestDB(t)
+	if err := RegisterAuditCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	token := newTestUser(t, db, models.User{ID: "admin", Type: "OWNER", Fullname: "Admin", PhoneNumber: "09120000000"})
+	caller, err := (&Authorizer{DB: db}).Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.WithContext(caller.Context())
+
+	location := models.Location{ID: "tehran", Label: "Tehran"}
+	if err := tx.Create(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Model(&location).Update("label", "Tehran North").Error; err != nil {
+		t.Fatal(err)
+	}
+	// تغییری که مقداری را عوض نمی‌کند ثبت نمی‌شود
+	if err := tx.Model(&location).Update("label", "Tehran North").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Delete(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Unscoped().Model(&location).Update("deletedAt", nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Unscoped().Delete(&location).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	entries := auditEntries(t, db, "Location")
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		if entry.ModelID != "tehran" || entry.UserID != "admin" || entry.Actor.Username != "admin" || entry.Actor.PhoneNumber != "09120000000" {
+			t.Errorf("%s logged for %s by %s (%+v)", entry.Action, entry.ModelID, entry.UserID, entry.Actor)
+		}
+	}
+	if !sameIDs(actions, []string{AuditCreated, AuditUpdated, AuditDeleted, AuditRestored, AuditPurged}) {
+		t.Fatalf("location actions %v", actions)
+	}
+	if after := entries[0].Changes["after"]; after["label"] != "Tehran" || after["id"] != "tehran" {
+		t.Errorf("created snapshot %v", after)
+	}
+	// فقط فیلدهای تغییرکرده در تفاوت می‌آیند
+	update := entries[1].Changes
+	if len(update["after"]) != 1 || update["before"]["label"] != "Tehran" || update["after"]["label"] != "Tehran North" {
+		t.Errorf("update diff %v", update)
+	}
+	if before := entries[4].Changes["before"]; before["label"] != "Tehran North" {
+		t.Errorf("purged snapshot %v", before)
+	}
+
+	// رمز عبور هیچ‌وقت در سابقه نمی‌آید و تغییر بدون فراخواننده به نام سیستم ثبت می‌شود
+	if err := db.Create(&models.User{ID: "operator", Username: "operator", Password: "secret-hash"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	users := auditEntries(t, db, "User")
+	operator := users[len(users)-1]
+	if operator.ModelID != "operator" || operator.Actor.Username != "system" || operator.UserID != "" {
+		t.Fatalf("operator logged as %+v", operator)
+	}
+	if _, ok := operator.Changes["after"]["password"]; ok {
+		t.Error("the password is stored in the action log")
+	}
+
+	// رویداد دریافتی ثبت نمی‌شود ولی تأیید آن ثبت می‌شود
+	event := models.Event{ID: "event", DedupHash: "event", ConfirmationStatus: "Unconfirmed"}
+	if err := tx.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Model(&event).Update("confirmationStatus", "Confirmed").Error; err != nil {
+		t.Fatal(err)
+	}
+	events := auditEntries(t, db, "Event")
+	if len(events) != 1 || events[0].Action != AuditConfirmed {
+		t.Errorf("event logs %+v", events)
+	}
+
+	// جدول‌های دفتری ثبت نمی‌شوند
+	if _, err := newSession(db, "operator", "", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if sessions := auditEntries(t, db, "Session"); len(sessions) != 0 {
+		t.Errorf("sessions are audited: %+v", sessions)
+	}
+}
+
+func TestActionLogServiceMasksHiddenFields(t *testing.T) {
+	db := newTestDB(t)
+	if err := RegisterAuditCallbacks(db); err != nil {
+		t.Fatal(err)
+	}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER", PhoneNumber: "09120000000"})
+	auditor := newTestUser(t, db, models.User{ID: "auditor"}, requirement{Action: models.PermissionRead, Model: "ActionLog"})
+	caller, err := (&Authorizer{DB: db}).Authenticate(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{ID: "operator", Username: "operator", Password: "-", PhoneNumber: "09350000000"}
+	if err := db.WithContext(caller.Context()).Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	s := &ActionLogService{DB: db, Authz: &Authorizer{DB: db}}
+	res, err := s.FindAll(auditor, ActionLogFilter{Model: "User", ModelID: "operator"})
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if res.Data.TotalRecords != 1 || len(res.Data.Logs) != 1 || len(res.Data.Logs[0].Logs) != 1 {
+		t.Fatalf("filtered logs %+v", res.Data)
+	}
+	entry := res.Data.Logs[0].Logs[0]
+	var changes map[string]map[string]any
+	json.Unmarshal(entry.ChangedFields, &changes)
+	if entry.User.PhoneNumber != redactedValue || changes["after"]["phoneNumber"] != redactedValue || changes["after"]["username"] != "operator" {
+		t.Errorf("auditor read actor %+v and changes %v", entry.User, changes)
+	}
+
+	res, err = s.FindAll(owner, ActionLogFilter{Model: "User", ModelID: "operator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry = res.Data.Logs[0].Logs[0]
+	if entry.User.PhoneNumber != "09120000000" {
+		t.Errorf("owner read actor %+v", entry.User)
+	}
+}
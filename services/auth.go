@@ -3,6 +3,7 @@ package services
 import (
 	"errors"
 	"monitoring-with-go/models"
+	"time"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
@@ -10,7 +11,8 @@ import (
 )
 
 type AuthService struct {
-	DB *gorm.DB
+	DB    *gorm.DB
+	Authz *Authorizer
 }
 
 type LoginRequest struct {
@@ -19,18 +21,25 @@ type LoginRequest struct {
 }
 
 type LoginResponse struct {
-	ID              string `json:"id"`
-	Username        string `json:"username"`
-	Fullname        string `json:"fullname"`
-	NationalityCode string `json:"nationalityCode"`
-	PersonalCode    string `json:"personalCode"`
-	FatherName      string `json:"fatherName"`
-	PhoneNumber     string `json:"phoneNumber"`
-	LocationID      string `json:"locationId"`
-	Address         string `json:"address"`
-	Status          string `json:"status"`
-	AvatarUrl       string `json:"avatarUrl,omitempty"`
-	Version         int    `json:"version"`
+	ID                 string `json:"id"`
+	Token              string `json:"token"`
+	Username           string `json:"username"`
+	Type               string `json:"type"`
+	Fullname           string `json:"fullname"`
+	NationalityCode    string `json:"nationalityCode"`
+	PersonalCode       string `json:"personalCode"`
+	FatherName         string `json:"fatherName"`
+	PhoneNumber        string `json:"phoneNumber"`
+	LocationID         string `json:"locationId"`
+	Address            string `json:"address"`
+	Status             string `json:"status"`
+	AvatarUrl          string `json:"avatarUrl,omitempty"`
+	MustChangePassword bool   `json:"mustChangePassword"`
+	Version            int    `json:"version"`
+	// TwoFactorRequired means no session was opened yet; send the code with ChallengeToken to VerifyTwoFactor
+	TwoFactorRequired           bool   `json:"twoFactorRequired"`
+	ChallengeToken              string `json:"challengeToken,omitempty"`
+	TwoFactorEnrollmentRequired bool   `json:"twoFactorEnrollmentRequired"`
 }
 
 type LogoutResponse struct {
@@ -47,7 +56,7 @@ type RegisterRequest struct {
 	PersonalCode    string `json:"personalCode"`
 	FatherName      string `json:"fatherName"`
 	PhoneNumber     string `json:"phoneNumber"`
-	LocationID      int    `json:"locationId"`
+	LocationID      string `json:"locationId"`
 	Address         string `json:"address"`
 }
 
@@ -56,49 +65,167 @@ type RegisterResponse struct {
 	Message    string `json:"message"`
 }
 
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword"`
+	NewPassword     string `json:"newPassword"`
+	ConfirmPassword string `json:"confirmPassword"`
+}
 
+type UnlockResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+var (
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	ErrTooManyAttempts    = errors.New("too many failed login attempts, try again later")
+)
+
+// dummyPasswordHash keeps the response time of unknown usernames close to a wrong password
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password-for-timing"), 10)
 
 // Login method exposed to frontend
 func (s *AuthService) Login(username, password string) (*LoginResponse, error) {
+	source := clientSource()
+	guard := &loginGuard{db: s.DB, policy: loadLoginPolicy(s.DB)}
+
+	if _, locked := guard.lockedUntil(username, source); locked {
+		guard.record(username, "", source, models.AuthStatusLocked, "login attempt while locked")
+		return nil, ErrTooManyAttempts
+	}
+
 	var user models.User
 
-	// پیدا کردن کاربر
+	// پیدا کردن کاربر؛ در هر دو حالت خطا پیام یکسان برمی‌گردد
 	result := s.DB.Where("username = ?", username).First(&user)
 	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-		return nil, errors.New("user not found")
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, s.loginFailed(guard, username, "", source, "user not found")
+	}
+	if result.Error != nil {
+		return nil, result.Error
 	}
 
 	// چک کردن پسورد
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
-		return nil, errors.New("invalid password")
+		return nil, s.loginFailed(guard, username, user.ID, source, "invalid password")
+	}
+
+	// وضعیت ثبت‌نام فقط بعد از رمز صحیح اعلام می‌شود
+	switch user.Status {
+	case models.UserStatusPending:
+		return nil, &ServiceError{StatusCode: 403, Message: "registration is awaiting approval"}
+	case models.UserStatusRejected:
+		return nil, &ServiceError{StatusCode: 403, Message: "registration was rejected"}
 	}
 
-	// برگرداندن اطلاعات کاربر (بدون token)
+	// رمز منقضی شده باید بعد از ورود تغییر کند
+	if !user.MustChangePassword && loadPasswordPolicy(s.DB).expired(&user) {
+		if err := s.DB.Model(&user).Update("mustChangePassword", true).Error; err != nil {
+			return nil, err
+		}
+		user.MustChangePassword = true
+	}
+
+	// با رمز دوم فعال، نشست بعد از VerifyTwoFactor ساخته می‌شود
+	if user.TotpEnabled {
+		challenge, err := newChallenge(s.DB, user.ID, source)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResponse{
+			ID:                user.ID,
+			Username:          user.Username,
+			TwoFactorRequired: true,
+			ChallengeToken:    challenge,
+		}, nil
+	}
+
+	return s.startSession(guard, &user, source)
+}
+
+// startSession records the successful login and opens a session for user
+func (s *AuthService) startSession(guard *loginGuard, user *models.User, source string) (*LoginResponse, error) {
+	authLogID := guard.record(user.Username, user.ID, source, models.AuthStatusSuccess, "")
+	token, err := newSession(s.DB, user.ID, authLogID, source)
+	if err != nil {
+		return nil, err
+	}
+
+	// برگرداندن اطلاعات کاربر به همراه token نشست
 	return &LoginResponse{
-		ID:              user.ID,
-		Username:        user.Username,
-		Fullname:        user.Fullname,
-		NationalityCode: user.NationalityCode,
-		PersonalCode:    user.PersonalCode,
-		FatherName:      user.FatherName,
-		PhoneNumber:     user.PhoneNumber,
-		LocationID:      user.LocationID,
-		Address:         user.Address,
-		Status:          user.Status,
-		AvatarUrl:       user.AvatarUrl,
-		Version:         user.Version,
+		ID:                          user.ID,
+		Token:                       token,
+		Username:                    user.Username,
+		Type:                        user.Type,
+		Fullname:                    user.Fullname,
+		NationalityCode:             user.NationalityCode,
+		PersonalCode:                user.PersonalCode,
+		FatherName:                  user.FatherName,
+		PhoneNumber:                 user.PhoneNumber,
+		LocationID:                  user.LocationID,
+		Address:                     user.Address,
+		Status:                      user.Status,
+		AvatarUrl:                   user.AvatarUrl,
+		MustChangePassword:          user.MustChangePassword,
+		TwoFactorEnrollmentRequired: twoFactorRequiredFor(s.DB, user) && !user.TotpEnabled,
+		Version:                     user.Version,
+	}, nil
+}
+
+// loginFailed records the failure, applies the progressive delay and returns the uniform error
+func (s *AuthService) loginFailed(guard *loginGuard, username, userID, source, reason string) error {
+	guard.record(username, userID, source, models.AuthStatusFailed, reason)
+	time.Sleep(guard.policy.delay(guard.userFailures(username)))
+	return ErrInvalidCredentials
+}
+
+// UnlockAccount clears the failed login counter of username
+func (s *AuthService) UnlockAccount(token string, username string) (*UnlockResponse, error) {
+	if _, err := s.Authz.Authorize(token, "AuthService.UnlockAccount"); err != nil {
+		return nil, err
+	}
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	guard := &loginGuard{db: s.DB}
+	guard.record(username, "", clientSource(), models.AuthStatusUnlocked, "unlocked by admin")
+
+	return &UnlockResponse{
+		StatusCode: 200,
+		Message:    "Account unlocked successfully",
+	}, nil
+}
+
+// UnlockSource clears the failed login counter of a source address
+func (s *AuthService) UnlockSource(token string, ip string) (*UnlockResponse, error) {
+	if _, err := s.Authz.Authorize(token, "AuthService.UnlockSource"); err != nil {
+		return nil, err
+	}
+	if ip == "" {
+		return nil, errors.New("ip is required")
+	}
+	guard := &loginGuard{db: s.DB}
+	guard.record("", "", ip, models.AuthStatusSourceUnlocked, "unlocked by admin")
+
+	return &UnlockResponse{
+		StatusCode: 200,
+		Message:    "Source unlocked successfully",
 	}, nil
 }
 
 func (s *AuthService) Logout(token string) (*LogoutResponse, error) {
-	var user models.User
-	result := s.DB.Where("ip = ?", token).First(&user)
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+	session, err := findSession(s.DB, token)
+	if err != nil {
 		return nil, errors.New("invalid token")
 	}
 
-	user.IP = ""
-	s.DB.Save(&user)
+	if session.AuthLogID != "" {
+		s.DB.Model(&models.AuthLog{}).Where("id = ?", session.AuthLogID).Update("logoutTime", time.Now())
+	}
+	if err := s.DB.Delete(session).Error; err != nil {
+		return nil, err
+	}
 
 	return &LogoutResponse{
 		StatusCode: 200,
@@ -106,46 +233,95 @@ func (s *AuthService) Logout(token string) (*LogoutResponse, error) {
 	}, nil
 }
 
+// Register is the public self-registration; the account stays PENDING until an admin approves it
 func (s *AuthService) Register(req RegisterRequest) (*RegisterResponse, error) {
-	if req.Password != req.ConfirmPassword {
+	if err := createUser(s.DB, req, "USER", models.UserStatusPending); err != nil {
+		return nil, err
+	}
+
+	return &RegisterResponse{
+		StatusCode: 201,
+		Message:    "Registration submitted and awaiting approval",
+	}, nil
+}
+
+// ChangePassword replaces the caller's password; it is the only call allowed while
+// mustChangePassword is set.
+func (s *AuthService) ChangePassword(token string, req ChangePasswordRequest) (*RegisterResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.ChangePassword")
+	if err != nil {
+		return nil, err
+	}
+	if req.NewPassword != req.ConfirmPassword {
 		return nil, errors.New("passwords do not match")
 	}
+	if err := bcrypt.CompareHashAndPassword([]byte(caller.User.Password), []byte(req.CurrentPassword)); err != nil {
+		return nil, errors.New("current password is incorrect")
+	}
 
-	// چک اگر یوزرنیم تکراریه
-	var existing models.User
-	if err := s.DB.Where("username = ?", req.Username).First(&existing).Error; err == nil {
-		return nil, errors.New("username already exists")
+	policy := loadPasswordPolicy(s.DB)
+	if err := policy.Validate(req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := policy.checkReuse(s.DB, &caller.User, req.NewPassword); err != nil {
+		return nil, err
 	}
 
-	// هش کردن پسورد
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), 10)
 	if err != nil {
 		return nil, err
 	}
-
-	user := models.User{
-		ID:              uuid.New().String(),
-		Fullname:        req.Fullname,
-		Username:        req.Username,
-		Password:        string(hashedPassword),
-		NationalityCode: req.NationalityCode,
-		PersonalCode:    req.PersonalCode,
-		FatherName:      req.FatherName,
-		PhoneNumber:     req.PhoneNumber,
-		LocationID:      string(req.LocationID),
-		Address:         req.Address,
-		Status:          "OFFLINE",
-		Version:         0,
-	}
-
-	if err := s.DB.Create(&user).Error; err != nil {
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		return policy.setPassword(tx, &caller.User, string(hashedPassword), false)
+	})
+	if err != nil {
 		return nil, err
 	}
 
 	return &RegisterResponse{
-		StatusCode: 201,
-		Message:    "User registered successfully",
+		StatusCode: 200,
+		Message:    "Password changed successfully",
 	}, nil
 }
 
+// createUser validates req and stores a new user with the given type and status
+func createUser(db *gorm.DB, req RegisterRequest, userType string, status string) error {
+	if req.Password != req.ConfirmPassword {
+		return errors.New("passwords do not match")
+	}
+	if err := loadPasswordPolicy(db).Validate(req.Password); err != nil {
+		return err
+	}
+
+	// چک اگر یوزرنیم تکراریه
+	var existing models.User
+	if err := db.Where("username = ?", req.Username).First(&existing).Error; err == nil {
+		return errors.New("username already exists")
+	}
+
+	// هش کردن پسورد
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), 10)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	user := models.User{
+		ID:                uuid.New().String(),
+		Fullname:          req.Fullname,
+		Username:          req.Username,
+		Password:          string(hashedPassword),
+		Type:              userType,
+		NationalityCode:   req.NationalityCode,
+		PersonalCode:      req.PersonalCode,
+		FatherName:        req.FatherName,
+		PhoneNumber:       req.PhoneNumber,
+		LocationID:        req.LocationID,
+		Address:           req.Address,
+		Status:            status,
+		PasswordChangedAt: &now,
+		Version:           0,
+	}
 
+	return db.Create(&user).Error
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+	"monitoring-with-go/seeders"
+)
+
+// TestPoliciesAreSeeded checks that every permission a bound method or the recycle bin asks
+// for can be granted, so no method is left to owners by accident
+func TestPoliciesAreSeeded(t *testing.T) {
+	db := newTestDB(t)
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+	seeders.SeedPermissions(db)
+
+	seeded := func(action models.PermissionAction, model, field string) bool {
+		query := db.Model(&models.Permission{}).Where("action = ? AND model = ?", action, model)
+		if field == "" {
+			query = query.Where("field IS NULL")
+		} else {
+			query = query.Where("field = ?", field)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		return count > 0
+	}
+
+	for method, req := range methodPolicies {
+		if req.Model != "" && !seeded(req.Action, req.Model, req.Field) {
+			t.Errorf("%s needs %s %s, which is never seeded", method, req.Action, req.Model)
+		}
+	}
+
+	// مدلی که در سطل بازیافت دسترسی دارد باید هر سه دسترسی آن قابل اعطا باشد
+	actions := []models.PermissionAction{models.PermissionRead, models.PermissionUpdate, models.PermissionDelete}
+	for name := range recycleModels {
+		var granted bool
+		for _, action := range actions {
+			granted = granted || seeded(action, name, "")
+		}
+		if !granted {
+			continue
+		}
+		for _, action := range actions {
+			if !seeded(action, name, "") {
+				t.Errorf("recycle bin model %s needs %s, which is never seeded", name, action)
+			}
+		}
+	}
+}
+
+// TestConfirmGrantCarriesOver checks that the users who could confirm events with CREATE Event
+// keep confirming after the UPDATE Event permission is seeded into their database
+func TestConfirmGrantCarriesOver(t *testing.T) {
+	db := newTestDB(t)
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(out)
+
+	create := models.Permission{ID: "create-event", Action: models.PermissionCreate, Model: "Event"}
+	operator := models.User{ID: "operator", Username: "operator", Password: "-", Type: "USER", Status: models.UserStatusOffline}
+	for _, row := range []any{&create, &operator, &models.UserPermission{ID: "grant", UserID: operator.ID, PermissionID: create.ID}} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	seeders.SeedPermissions(db)
+
+	var granted int64
+	err := db.Model(&models.UserPermission{}).
+		Joins(`JOIN "Permission" ON "Permission".id = "UserPermission"."permissionId"`).
+		Where(`"UserPermission"."userId" = ? AND "Permission".action = ? AND "Permission".model = ?`, operator.ID, models.PermissionUpdate, "Event").
+		Count(&granted).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if granted != 1 {
+		t.Errorf("operator holds UPDATE Event %d times after seeding, want once", granted)
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	db := newTestDB(t)
+	authz := &Authorizer{DB: db}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	reader := newTestUser(t, db, models.User{ID: "reader"}, requirement{Action: models.PermissionRead, Model: "Event"})
+	nobody := newTestUser(t, db, models.User{ID: "nobody"})
+	temporary := newTestUser(t, db, models.User{ID: "temporary", MustChangePassword: true}, requirement{Action: models.PermissionRead, Model: "Event"})
+
+	cases := []struct {
+		name   string
+		token  string
+		method string
+		err    error
+	}{
+		{"no token", "", "EventService.FindAll", ErrUnauthorized},
+		{"unknown token", "not-a-session", "EventService.FindAll", ErrUnauthorized},
+		{"method without a policy", owner, "EventService.DropEverything", ErrForbidden},
+		{"owner bypasses grants", owner, "EventService.Confirm", nil},
+		{"granted", reader, "EventService.FindAll", nil},
+		{"not granted", reader, "EventService.Confirm", ErrForbidden},
+		{"no grants", nobody, "EventService.FindAll", ErrForbidden},
+		{"temporary password", temporary, "EventService.FindAll", ErrPasswordChangeRequired},
+		{"temporary password may change it", temporary, "AuthService.ChangePassword", nil},
+	}
+	for _, c := range cases {
+		caller, err := authz.Authorize(c.token, c.method)
+		if err != c.err {
+			t.Errorf("%s: Authorize(%s) = %v, want %v", c.name, c.method, err, c.err)
+		}
+		if err == nil && caller == nil {
+			t.Errorf("%s: no caller returned", c.name)
+		}
+	}
+
+	// گرفتن دسترسی در نشست بعدی اثر می‌کند
+	if err := db.Where(`"userId" = ?`, "reader").Delete(&models.UserPermission{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authz.Authorize(reader, "EventService.FindAll"); err != ErrForbidden {
+		t.Errorf("revoked grant: %v, want ErrForbidden", err)
+	}
+
+	// نشست منقضی پذیرفته نمی‌شود
+	if err := db.Model(&models.Session{}).Where(`"userId" = ?`, "owner").Update("expiresAt", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authz.Authorize(owner, "EventService.FindAll"); err != ErrUnauthorized {
+		t.Errorf("expired session: %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestCallerCanField(t *testing.T) {
+	db := newTestDB(t)
+	token := newTestUser(t, db, models.User{ID: "clerk"},
+		requirement{Action: models.PermissionRead, Model: "User"},
+		requirement{Action: models.PermissionRead, Model: "Branch", Field: "panelIp"})
+	caller, err := (&Authorizer{DB: db}).Authenticate(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		model, field string
+		can          bool
+	}{
+		// دسترسی به کل مدل همه فیلدهای آن را می‌دهد
+		{"User", "", true},
+		{"User", "phoneNumber", true},
+		{"Branch", "panelIp", true},
+		{"Branch", "emergencyCall", false},
+		{"Branch", "", false},
+	}
+	for _, c := range cases {
+		if got := caller.Can(models.PermissionRead, c.model, c.field); got != c.can {
+			t.Errorf("Can(READ, %s, %q) = %v, want %v", c.model, c.field, got, c.can)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/models"
+)
+
+func TestBackupRoundTrip(t *testing.T) {
+	db := newTestDB(t)
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	if err := db.Create(&models.Location{ID: "tehran", Label: "Tehran"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	s := &BackupService{DB: db, Authz: &Authorizer{DB: db}, Dir: t.TempDir()}
+
+	created, err := s.Create(owner)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	name := created.Data.Name
+	verified, err := s.Verify(owner, name)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !verified.Data.OK() || !verified.Data.ChecksumOK || !verified.Data.IntegrityOK || verified.Data.MigrationVersion == 0 {
+		t.Fatalf("verified %+v", verified.Data)
+	}
+
+	// تغییرات بعد از پشتیبان با بازگردانی از بین می‌روند و حالت قبلی نگه داشته می‌شود
+	if err := db.Create(&models.Location{ID: "shiraz", Label: "Shiraz"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	restored, err := s.Restore(owner, name)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	var locations []string
+	db.Model(&models.Location{}).Order("id").Pluck("id", &locations)
+	if !sameIDs(locations, []string{"tehran"}) {
+		t.Errorf("locations after the restore %v, want [tehran]", locations)
+	}
+	if restored.Data.Reason != database.BackupPreRestore {
+		t.Errorf("restore kept the previous state as %+v", restored.Data)
+	}
+	list, err := s.FindAll(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 2 {
+		t.Errorf("%d backups listed, want the manual and the pre-restore one", len(list.Data))
+	}
+
+	// نسخه‌ای که بعد از نوشتن تغییر کرده رد می‌شود
+	path := filepath.Join(s.Dir, name)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(data)-1] ^= 0xff
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	verified, err = s.Verify(owner, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if verified.Data.OK() || verified.Data.ChecksumOK {
+		t.Errorf("a changed backup passed verification: %+v", verified.Data)
+	}
+	if _, err := s.Restore(owner, name); err == nil {
+		t.Error("a changed backup was restored")
+	}
+	if _, err := s.Verify(owner, "../test.db"); err == nil {
+		t.Error("a file outside the backups was accepted")
+	}
+}
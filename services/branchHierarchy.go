@@ -0,0 +1,650 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BranchChildrenRequest asks for a page of the partitions, zones or employees of a branch
+type BranchChildrenRequest struct {
+	BranchID string `json:"branchId"`
+	Page     int    `json:"page"`
+	Limit    int    `json:"limit"`
+}
+
+type PartitionPage struct {
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	Limit      int                `json:"limit"`
+	TotalPages int                `json:"totalPages"`
+	Data       []models.Partition `json:"data"`
+}
+
+type PartitionListResponse struct {
+	StatusCode int           `json:"statusCode"`
+	Message    string        `json:"message"`
+	Data       PartitionPage `json:"data"`
+}
+
+// BranchZone is a zone with the labels of its partition and zone type
+type BranchZone struct {
+	models.Zone
+	PartitionLocalID int    `gorm:"column:partitionLocalId" json:"partitionLocalId"`
+	PartitionLabel   string `gorm:"column:partitionLabel" json:"partitionLabel"`
+	ZoneTypeLabel    string `gorm:"column:zoneTypeLabel" json:"zoneTypeLabel"`
+}
+
+type ZonePage struct {
+	Total      int64        `json:"total"`
+	Page       int          `json:"page"`
+	Limit      int          `json:"limit"`
+	TotalPages int          `json:"totalPages"`
+	Data       []BranchZone `json:"data"`
+}
+
+type ZoneListResponse struct {
+	StatusCode int      `json:"statusCode"`
+	Message    string   `json:"message"`
+	Data       ZonePage `json:"data"`
+}
+
+type EmployeePage struct {
+	Total      int64             `json:"total"`
+	Page       int               `json:"page"`
+	Limit      int               `json:"limit"`
+	TotalPages int               `json:"totalPages"`
+	Data       []models.Employee `json:"data"`
+}
+
+type EmployeeListResponse struct {
+	StatusCode     int          `json:"statusCode"`
+	Message        string       `json:"message"`
+	Data           EmployeePage `json:"data"`
+	RedactedFields []string     `json:"redactedFields"`
+}
+
+type PartitionResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Message    string            `json:"message"`
+	Data       *models.Partition `json:"data"`
+}
+
+type ZoneResponse struct {
+	StatusCode int          `json:"statusCode"`
+	Message    string       `json:"message"`
+	Data       *models.Zone `json:"data"`
+}
+
+type EmployeeResponse struct {
+	StatusCode     int              `json:"statusCode"`
+	Message        string           `json:"message"`
+	Data           *models.Employee `json:"data"`
+	RedactedFields []string         `json:"redactedFields"`
+}
+
+// DeleteResponse reports a soft delete; the rows can be brought back from the recycle bin
+type DeleteResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+type CreatePartitionRequest struct {
+	BranchID string `json:"branchId"`
+	LocalID  int    `json:"localId"`
+	Label    string `json:"label"`
+}
+
+// UpdatePartitionRequest carries only the fields to change; nil fields are left untouched
+type UpdatePartitionRequest struct {
+	LocalID *int    `json:"localId"`
+	Label   *string `json:"label"`
+	Version *int    `json:"version"`
+}
+
+type CreateZoneRequest struct {
+	PartitionID string `json:"partitionId"`
+	LocalID     int    `json:"localId"`
+	Label       string `json:"label"`
+	ZoneTypeID  string `json:"zoneTypeId"`
+}
+
+// UpdateZoneRequest carries only the fields to change; PartitionID can only move the zone to
+// another partition of the same branch
+type UpdateZoneRequest struct {
+	PartitionID *string `json:"partitionId"`
+	LocalID     *int    `json:"localId"`
+	Label       *string `json:"label"`
+	ZoneTypeID  *string `json:"zoneTypeId"`
+	Version     *int    `json:"version"`
+}
+
+type CreateEmployeeRequest struct {
+	BranchID     string `json:"branchId"`
+	LocalID      int    `json:"localId"`
+	Name         string `json:"name"`
+	LastName     string `json:"lastName"`
+	Position     string `json:"position"`
+	NationalCode string `json:"nationalCode"`
+}
+
+// UpdateEmployeeRequest carries only the fields to change; nil fields are left untouched
+type UpdateEmployeeRequest struct {
+	LocalID      *int    `json:"localId"`
+	Name         *string `json:"name"`
+	LastName     *string `json:"lastName"`
+	Position     *string `json:"position"`
+	NationalCode *string `json:"nationalCode"`
+	Version      *int    `json:"version"`
+}
+
+// FindPartitions returns a page of the partitions of a branch, ordered by local id
+func (s *BranchService) FindPartitions(token string, req BranchChildrenRequest) (*PartitionListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.FindPartitions")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.scopedBranch(s.DB, caller, req.BranchID); err != nil {
+		return nil, err
+	}
+	page, limit := normalizePage(req.Page, req.Limit)
+
+	query := s.DB.Model(&models.Partition{}).Where(`"branchId" = ?`, req.BranchID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	partitions := []models.Partition{}
+	if err := query.Order(`"localId"`).Offset((page - 1) * limit).Limit(limit).Find(&partitions).Error; err != nil {
+		return nil, err
+	}
+	return &PartitionListResponse{
+		StatusCode: 200,
+		Message:    "Partitions fetched successfully",
+		Data:       PartitionPage{Total: total, Page: page, Limit: limit, TotalPages: totalPages(total, limit), Data: partitions},
+	}, nil
+}
+
+// FindZones returns a page of the zones of every partition of a branch, ordered by
+// partition and local id
+func (s *BranchService) FindZones(token string, req BranchChildrenRequest) (*ZoneListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.FindZones")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.scopedBranch(s.DB, caller, req.BranchID); err != nil {
+		return nil, err
+	}
+	page, limit := normalizePage(req.Page, req.Limit)
+
+	query := s.DB.Model(&models.Zone{}).
+		Joins(`JOIN "Partition" ON "Partition".id = "Zone"."partitionId" AND "Partition"."deletedAt" IS NULL`).
+		Where(`"Partition"."branchId" = ?`, req.BranchID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	zones := []BranchZone{}
+	err = query.
+		Select(`"Zone".*, "Partition"."localId" AS "partitionLocalId", "Partition".label AS "partitionLabel", "ZoneType".label AS "zoneTypeLabel"`).
+		Joins(`LEFT JOIN "ZoneType" ON "ZoneType".id = "Zone"."zoneTypeId"`).
+		Order(`"Partition"."localId", "Zone"."localId"`).
+		Offset((page - 1) * limit).Limit(limit).
+		Scan(&zones).Error
+	if err != nil {
+		return nil, err
+	}
+	return &ZoneListResponse{
+		StatusCode: 200,
+		Message:    "Zones fetched successfully",
+		Data:       ZonePage{Total: total, Page: page, Limit: limit, TotalPages: totalPages(total, limit), Data: zones},
+	}, nil
+}
+
+// FindEmployees returns a page of the employees of a branch with the fields the caller may
+// not read masked
+func (s *BranchService) FindEmployees(token string, req BranchChildrenRequest) (*EmployeeListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.FindEmployees")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.scopedBranch(s.DB, caller, req.BranchID); err != nil {
+		return nil, err
+	}
+	page, limit := normalizePage(req.Page, req.Limit)
+
+	query := s.DB.Model(&models.Employee{}).Where(`"branchId" = ?`, req.BranchID)
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	employees := []models.Employee{}
+	if err := query.Order(`"localId"`).Offset((page - 1) * limit).Limit(limit).Find(&employees).Error; err != nil {
+		return nil, err
+	}
+	return &EmployeeListResponse{
+		StatusCode:     200,
+		Message:        "Employees fetched successfully",
+		Data:           EmployeePage{Total: total, Page: page, Limit: limit, TotalPages: totalPages(total, limit), Data: employees},
+		RedactedFields: caller.redact("Employee", &employees),
+	}, nil
+}
+
+// CreatePartition adds a partition to a branch; the first partition of a branch becomes its
+// main partition
+func (s *BranchService) CreatePartition(token string, req CreatePartitionRequest) (*PartitionResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.CreatePartition")
+	if err != nil {
+		return nil, err
+	}
+	partition := models.Partition{ID: uuid.NewString(), BranchID: req.BranchID, LocalID: req.LocalID, Label: strings.TrimSpace(req.Label)}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		branch, err := s.scopedBranch(tx, caller, req.BranchID)
+		if err != nil {
+			return err
+		}
+		if err := checkLocalID(tx, &models.Partition{}, "branchId", req.BranchID, partition.ID, partition.LocalID, "partition"); err != nil {
+			return err
+		}
+		if err := tx.Create(&partition).Error; err != nil {
+			return err
+		}
+		if branch.MainPartitionID == "" {
+			return tx.Model(branch).Update("mainPartitionId", partition.ID).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &PartitionResponse{StatusCode: 200, Message: "Partition created successfully", Data: &partition}, nil
+}
+
+// UpdatePartition changes the local id or label of a partition
+func (s *BranchService) UpdatePartition(token string, id string, req UpdatePartitionRequest) (*PartitionResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.UpdatePartition")
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if req.LocalID != nil {
+		updates["localId"] = *req.LocalID
+	}
+	if req.Label != nil {
+		updates["label"] = strings.TrimSpace(*req.Label)
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+
+	var partition models.Partition
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		current, err := s.scopedPartition(tx, caller, id)
+		if err != nil {
+			return err
+		}
+		if req.LocalID != nil {
+			if err := checkLocalID(tx, &models.Partition{}, "branchId", current.BranchID, id, *req.LocalID, "partition"); err != nil {
+				return err
+			}
+		}
+		return updateVersioned(tx, &partition, id, req.Version, updates)
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &PartitionResponse{StatusCode: 200, Message: "Partition updated successfully", Data: &partition}, nil
+}
+
+// DeletePartition soft-deletes a partition with its zones. When it was the main partition
+// of its branch, the remaining partition with the lowest local id takes its place.
+func (s *BranchService) DeletePartition(token string, id string) (*DeleteResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.DeletePartition")
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		partition, err := s.scopedPartition(tx, caller, id)
+		if err != nil {
+			return err
+		}
+		var zones int64
+		if err := tx.Model(&models.Zone{}).Where(`"partitionId" = ?`, id).Count(&zones).Error; err != nil {
+			return err
+		}
+		if zones > 0 && !caller.Can(models.PermissionDelete, "Zone", "") {
+			return ErrForbidden
+		}
+		// زون‌ها پیش از پارتیشن حذف می‌شوند تا سطل بازیافت آن‌ها را همراه پارتیشن برگرداند
+		if err := tx.Where(`"partitionId" = ?`, id).Delete(&models.Zone{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(partition).Error; err != nil {
+			return err
+		}
+
+		var branch models.Branch
+		if err := tx.Where("id = ?", partition.BranchID).First(&branch).Error; err != nil {
+			return err
+		}
+		if branch.MainPartitionID != id {
+			return nil
+		}
+		var next models.Partition
+		err = tx.Where(`"branchId" = ?`, branch.ID).Order(`"localId"`).First(&next).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Model(&branch).Update("mainPartitionId", nil).Error
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&branch).Update("mainPartitionId", next.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{StatusCode: 200, Message: "Partition deleted successfully"}, nil
+}
+
+// CreateZone adds a zone to a partition
+func (s *BranchService) CreateZone(token string, req CreateZoneRequest) (*ZoneResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.CreateZone")
+	if err != nil {
+		return nil, err
+	}
+	zone := models.Zone{
+		ID: uuid.NewString(), PartitionID: req.PartitionID, LocalID: req.LocalID,
+		Label: strings.TrimSpace(req.Label), ZoneTypeID: strings.TrimSpace(req.ZoneTypeID),
+	}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if _, err := s.scopedPartition(tx, caller, req.PartitionID); err != nil {
+			return err
+		}
+		if err := checkZoneType(tx, zone.ZoneTypeID); err != nil {
+			return err
+		}
+		if err := checkLocalID(tx, &models.Zone{}, "partitionId", req.PartitionID, zone.ID, zone.LocalID, "zone"); err != nil {
+			return err
+		}
+		return tx.Create(&zone).Error
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &ZoneResponse{StatusCode: 200, Message: "Zone created successfully", Data: &zone}, nil
+}
+
+// UpdateZone changes the given fields of a zone
+func (s *BranchService) UpdateZone(token string, id string, req UpdateZoneRequest) (*ZoneResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.UpdateZone")
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if req.PartitionID != nil {
+		updates["partitionId"] = strings.TrimSpace(*req.PartitionID)
+	}
+	if req.LocalID != nil {
+		updates["localId"] = *req.LocalID
+	}
+	if req.Label != nil {
+		updates["label"] = strings.TrimSpace(*req.Label)
+	}
+	if req.ZoneTypeID != nil {
+		updates["zoneTypeId"] = strings.TrimSpace(*req.ZoneTypeID)
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+
+	var zone models.Zone
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		var current models.Zone
+		if err := tx.Where("id = ?", id).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{StatusCode: 404, Message: "zone not found"}
+			}
+			return err
+		}
+		partition, err := s.scopedPartition(tx, caller, current.PartitionID)
+		if err != nil {
+			return &ServiceError{StatusCode: 404, Message: "zone not found"}
+		}
+		partitionID, localID := current.PartitionID, current.LocalID
+		if req.PartitionID != nil && *req.PartitionID != current.PartitionID {
+			target, err := s.scopedPartition(tx, caller, strings.TrimSpace(*req.PartitionID))
+			if err != nil {
+				return err
+			}
+			if target.BranchID != partition.BranchID {
+				return &ServiceError{StatusCode: 400, Message: "a zone can only move to a partition of the same branch"}
+			}
+			partitionID = target.ID
+		}
+		if req.LocalID != nil {
+			localID = *req.LocalID
+		}
+		if req.PartitionID != nil || req.LocalID != nil {
+			if err := checkLocalID(tx, &models.Zone{}, "partitionId", partitionID, id, localID, "zone"); err != nil {
+				return err
+			}
+		}
+		if req.ZoneTypeID != nil {
+			if err := checkZoneType(tx, strings.TrimSpace(*req.ZoneTypeID)); err != nil {
+				return err
+			}
+			if updates["zoneTypeId"] == "" {
+				updates["zoneTypeId"] = nil
+			}
+		}
+		return updateVersioned(tx, &zone, id, req.Version, updates)
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &ZoneResponse{StatusCode: 200, Message: "Zone updated successfully", Data: &zone}, nil
+}
+
+// DeleteZone soft-deletes a zone
+func (s *BranchService) DeleteZone(token string, id string) (*DeleteResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.DeleteZone")
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		var zone models.Zone
+		if err := tx.Where("id = ?", id).First(&zone).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{StatusCode: 404, Message: "zone not found"}
+			}
+			return err
+		}
+		if _, err := s.scopedPartition(tx, caller, zone.PartitionID); err != nil {
+			return &ServiceError{StatusCode: 404, Message: "zone not found"}
+		}
+		return tx.Delete(&zone).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{StatusCode: 200, Message: "Zone deleted successfully"}, nil
+}
+
+// CreateEmployee adds an employee to a branch
+func (s *BranchService) CreateEmployee(token string, req CreateEmployeeRequest) (*EmployeeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.CreateEmployee")
+	if err != nil {
+		return nil, err
+	}
+	if req.NationalCode != "" {
+		if err := caller.checkFieldUpdates("Employee", []string{"nationalCode"}); err != nil {
+			return nil, err
+		}
+	}
+	employee := models.Employee{
+		ID: uuid.NewString(), BranchID: req.BranchID, LocalID: req.LocalID, Name: strings.TrimSpace(req.Name),
+		LastName: strings.TrimSpace(req.LastName), Position: strings.TrimSpace(req.Position), NationalCode: strings.TrimSpace(req.NationalCode),
+	}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if _, err := s.scopedBranch(tx, caller, req.BranchID); err != nil {
+			return err
+		}
+		if err := checkLocalID(tx, &models.Employee{}, "branchId", req.BranchID, employee.ID, employee.LocalID, "employee"); err != nil {
+			return err
+		}
+		return tx.Create(&employee).Error
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &EmployeeResponse{
+		StatusCode:     200,
+		Message:        "Employee created successfully",
+		Data:           &employee,
+		RedactedFields: caller.redact("Employee", &employee),
+	}, nil
+}
+
+// UpdateEmployee changes the given fields of an employee; the national code needs a grant on it
+func (s *BranchService) UpdateEmployee(token string, id string, req UpdateEmployeeRequest) (*EmployeeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.UpdateEmployee")
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	var fields []string
+	set := func(field string, value *string) {
+		if value != nil {
+			updates[field] = strings.TrimSpace(*value)
+			fields = append(fields, field)
+		}
+	}
+	set("name", req.Name)
+	set("lastName", req.LastName)
+	set("position", req.Position)
+	set("nationalCode", req.NationalCode)
+	if req.LocalID != nil {
+		updates["localId"] = *req.LocalID
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+	if err := caller.checkFieldUpdates("Employee", fields); err != nil {
+		return nil, err
+	}
+
+	var employee models.Employee
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		current, err := s.scopedEmployee(tx, caller, id)
+		if err != nil {
+			return err
+		}
+		if req.LocalID != nil {
+			if err := checkLocalID(tx, &models.Employee{}, "branchId", current.BranchID, id, *req.LocalID, "employee"); err != nil {
+				return err
+			}
+		}
+		return updateVersioned(tx, &employee, id, req.Version, updates)
+	})
+	if err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			caller.redact("Employee", &employee)
+		}
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &EmployeeResponse{
+		StatusCode:     200,
+		Message:        "Employee updated successfully",
+		Data:           &employee,
+		RedactedFields: caller.redact("Employee", &employee),
+	}, nil
+}
+
+// DeleteEmployee soft-deletes an employee
+func (s *BranchService) DeleteEmployee(token string, id string) (*DeleteResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.DeleteEmployee")
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		employee, err := s.scopedEmployee(tx, caller, id)
+		if err != nil {
+			return err
+		}
+		return tx.Delete(employee).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &DeleteResponse{StatusCode: 200, Message: "Employee deleted successfully"}, nil
+}
+
+// scopedPartition loads the live partition id of a branch inside the caller's locations
+func (s *BranchService) scopedPartition(db *gorm.DB, caller *Caller, id string) (*models.Partition, error) {
+	var partition models.Partition
+	if err := db.Where("id = ?", id).First(&partition).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "partition not found"}
+		}
+		return nil, err
+	}
+	if _, err := s.scopedBranch(db, caller, partition.BranchID); err != nil {
+		return nil, &ServiceError{StatusCode: 404, Message: "partition not found"}
+	}
+	return &partition, nil
+}
+
+// scopedEmployee loads the live employee id of a branch inside the caller's locations
+func (s *BranchService) scopedEmployee(db *gorm.DB, caller *Caller, id string) (*models.Employee, error) {
+	var employee models.Employee
+	if err := db.Where("id = ?", id).First(&employee).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "employee not found"}
+		}
+		return nil, err
+	}
+	if _, err := s.scopedBranch(db, caller, employee.BranchID); err != nil {
+		return nil, &ServiceError{StatusCode: 404, Message: "employee not found"}
+	}
+	return &employee, nil
+}
+
+// checkLocalID refuses a negative local id, or one another live row under the same parent
+// already has
+func checkLocalID(tx *gorm.DB, model any, parentColumn string, parentID string, id string, localID int, what string) error {
+	if localID < 0 {
+		return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("the %s number must not be negative", what)}
+	}
+	var count int64
+	err := tx.Model(model).
+		Where(fmt.Sprintf(`"%s" = ? AND "localId" = ? AND id <> ?`, parentColumn), parentID, localID, id).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("%s %d already exists", what, localID)}
+	}
+	return nil
+}
+
+// checkZoneType makes sure a zone type id, when given, exists
+func checkZoneType(tx *gorm.DB, zoneTypeID string) error {
+	if zoneTypeID == "" {
+		return nil
+	}
+	var count int64
+	if err := tx.Model(&models.ZoneType{}).Where("id = ?", zoneTypeID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ServiceError{StatusCode: 400, Message: "zone type not found"}
+	}
+	return nil
+}
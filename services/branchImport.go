@@ -0,0 +1,756 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/export"
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"github.com/xuri/excelize/v2"
+	"gorm.io/gorm"
+)
+
+// branchImportMaxSize keeps a wrong file from being read into memory
+const branchImportMaxSize = 20 << 20
+
+// BranchImportService creates branches with their partitions, zones and employees from an
+// XLSX or CSV file. Each row of the file belongs to the branch of its branch code: the first
+// row of a branch gives its fields, and every row may add a partition, a zone of that
+// partition and an employee. All rows are checked before anything is written, and the
+// import is written in one transaction or not at all.
+type BranchImportService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+	Dir   string // پوشه پیش‌فرض گزارش خطا
+
+	mu      sync.Mutex
+	results map[string]branchImportEntry
+}
+
+// branchImportField is a value the importer reads; Aliases are the column titles it is
+// found under when the request does not map it
+type branchImportField struct {
+	Key      string
+	Required bool
+	Aliases  []string
+}
+
+var branchImportFields = []branchImportField{
+	{"branchCode", true, []string{"کد شعبه", "code"}},
+	{"branchName", true, []string{"نام شعبه", "name"}},
+	{"location", true, []string{"مکان", "شهر", "locationId"}},
+	{"panelType", true, []string{"نوع پنل", "مدل پنل", "panelTypeId"}},
+	{"panelCode", true, []string{"کد پنل"}},
+	{"panelIp", false, []string{"آیپی", "آی‌پی", "آی‌پی پنل", "ip"}},
+	{"receiver", false, []string{"رسیور", "گیرنده", "receiverId"}},
+	{"address", false, []string{"آدرس"}},
+	{"phoneNumber", false, []string{"شماره تماس", "تلفن"}},
+	{"destinationPhoneNumber", false, []string{"شماره مقصد"}},
+	{"emergencyCall", false, []string{"شماره اضطراری"}},
+	{"partitionLocalId", false, []string{"شماره پارتیشن"}},
+	{"partitionLabel", false, []string{"پارتیشن", "نام پارتیشن"}},
+	{"zoneLocalId", false, []string{"شماره زون", "شماره ورودی"}},
+	{"zoneLabel", false, []string{"زون", "ورودی", "نام زون"}},
+	{"zoneType", false, []string{"نوع زون", "zoneTypeId"}},
+	{"employeeLocalId", false, []string{"شماره کارمند", "کد کاربر"}},
+	{"employeeName", false, []string{"نام کارمند", "نام کاربر"}},
+	{"employeeLastName", false, []string{"نام خانوادگی کارمند", "نام خانوادگی"}},
+	{"employeePosition", false, []string{"سمت", "سمت کارمند"}},
+	{"employeeNationalCode", false, []string{"کد ملی", "کد ملی کارمند"}},
+}
+
+// branchFields are the fields every row of a branch has to agree on
+var branchFields = []string{"branchName", "location", "panelType", "panelCode", "panelIp", "receiver",
+	"address", "phoneNumber", "destinationPhoneNumber", "emergencyCall"}
+
+// BranchImportRequest is an import of the first sheet of an XLSX file or of a CSV file,
+// told apart by the extension of FileName. Mapping maps a field to the column title it is
+// read from; fields left out are found by their usual titles. DryRun only checks the file.
+type BranchImportRequest struct {
+	FileName string            `json:"fileName"`
+	Content  []byte            `json:"content"`
+	Mapping  map[string]string `json:"mapping"`
+	DryRun   bool              `json:"dryRun"`
+}
+
+// BranchImportError is a problem of one row; Row counts the title row as 1, like Excel
+type BranchImportError struct {
+	Row     int    `json:"row"`
+	Column  string `json:"column"`
+	Message string `json:"message"`
+}
+
+// BranchImportPreview is a branch the import creates
+type BranchImportPreview struct {
+	Row        int    `json:"row"`
+	Code       int    `json:"code"`
+	Name       string `json:"name"`
+	PanelCode  int    `json:"panelCode"`
+	Location   string `json:"location"`
+	PanelType  string `json:"panelType"`
+	Partitions int    `json:"partitions"`
+	Zones      int    `json:"zones"`
+	Employees  int    `json:"employees"`
+}
+
+// BranchImportResult describes an import. Nothing is written unless Committed is true,
+// which needs a request without DryRun and a file without errors.
+type BranchImportResult struct {
+	ID         string                `json:"id"`
+	Headers    []string              `json:"headers"`
+	Mapping    map[string]string     `json:"mapping"`
+	Rows       int                   `json:"rows"`
+	Branches   []BranchImportPreview `json:"branches"`
+	Partitions int                   `json:"partitions"`
+	Zones      int                   `json:"zones"`
+	Employees  int                   `json:"employees"`
+	Errors     []BranchImportError   `json:"errors"`
+	Committed  bool                  `json:"committed"`
+}
+
+type BranchImportResponse struct {
+	StatusCode int                `json:"statusCode"`
+	Message    string             `json:"message"`
+	Data       BranchImportResult `json:"data"`
+}
+
+// BranchImportReportRequest writes the errors of the import ImportID to a file; an empty
+// Directory writes to the default export directory
+type BranchImportReportRequest struct {
+	ImportID  string `json:"importId"`
+	Format    string `json:"format"`
+	Directory string `json:"directory"`
+}
+
+type BranchImportReportResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Data       string `json:"data"`
+}
+
+// branchImportEntry keeps the errors of an import for its report
+type branchImportEntry struct {
+	userID  string
+	errors  []BranchImportError
+	expires time.Time
+}
+
+// importedBranch is a branch of the file with what it holds
+type importedBranch struct {
+	branch     models.Branch
+	raw        map[string]string // مقدارهای ردیف اول برای مقایسه با ردیف‌های بعدی
+	preview    BranchImportPreview
+	partitions []*importedPartition
+	employees  []models.Employee
+}
+
+type importedPartition struct {
+	partition models.Partition
+	zones     []models.Zone
+}
+
+// Import checks the file and, unless the request is a dry run or a row has an error,
+// creates its branches in one transaction
+func (s *BranchImportService) Import(token string, req BranchImportRequest) (*BranchImportResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchImportService.Import")
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Content) == 0 {
+		return nil, &ServiceError{StatusCode: 400, Message: "the file is empty"}
+	}
+	if len(req.Content) > branchImportMaxSize {
+		return nil, &ServiceError{StatusCode: 400, Message: "the file is larger than 20 MB"}
+	}
+	rows, err := readImportTable(req.FileName, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, &ServiceError{StatusCode: 400, Message: "the file has no title row"}
+	}
+
+	result := BranchImportResult{
+		ID:       uuid.NewString(),
+		Headers:  rows[0],
+		Branches: []BranchImportPreview{},
+		Errors:   []BranchImportError{},
+	}
+	columns, err := importMapping(rows[0], req.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	result.Mapping = map[string]string{}
+	for field, column := range columns {
+		result.Mapping[field] = rows[0][column]
+	}
+	if err := checkImportGrants(caller, columns); err != nil {
+		return nil, err
+	}
+
+	branches, err := s.check(caller, rows, columns, &result)
+	if err != nil {
+		return nil, err
+	}
+	message := "Import checked successfully"
+	if len(result.Errors) > 0 {
+		message = "The file has errors; nothing was imported"
+	} else if !req.DryRun {
+		if err := s.write(caller, branches); err != nil {
+			return nil, uniqueConflict(s.DB, err)
+		}
+		result.Committed = true
+		message = "Branches imported successfully"
+	}
+	s.keep(caller.User.ID, result)
+
+	return &BranchImportResponse{StatusCode: 200, Message: message, Data: result}, nil
+}
+
+// WriteErrorReport writes the row numbers and reasons of an import's errors to an XLSX, CSV
+// or PDF file and returns its path
+func (s *BranchImportService) WriteErrorReport(token string, req BranchImportReportRequest) (*BranchImportReportResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchImportService.WriteErrorReport")
+	if err != nil {
+		return nil, err
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if !export.ValidFormat(format) {
+		return nil, &ServiceError{StatusCode: 400, Message: export.ErrUnknownFormat.Error()}
+	}
+	s.mu.Lock()
+	entry, ok := s.results[req.ImportID]
+	s.mu.Unlock()
+	if !ok || entry.userID != caller.User.ID || time.Now().After(entry.expires) {
+		return nil, &ServiceError{StatusCode: 404, Message: "import not found, check the file again"}
+	}
+
+	path, err := exportPath(req.Directory, s.Dir, "", "branch-import-errors", format)
+	if err != nil {
+		return nil, err
+	}
+	err = writeTableFile(path, format, "خطاهای ورود شعبه‌ها", []export.Column{
+		{Title: "ردیف", Width: 8}, {Title: "ستون", Width: 20}, {Title: "خطا", Width: 60},
+	}, func(w export.Writer) error {
+		for _, e := range entry.errors {
+			if err := w.WriteRow([]interface{}{e.Row, e.Column, e.Message}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &BranchImportReportResponse{StatusCode: 200, Message: "Error report written", Data: path}, nil
+}
+
+// keep stores the errors of an import for an hour, for WriteErrorReport
+func (s *BranchImportService) keep(userID string, result BranchImportResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.results == nil {
+		s.results = map[string]branchImportEntry{}
+	}
+	now := time.Now()
+	for id, entry := range s.results {
+		if now.After(entry.expires) {
+			delete(s.results, id)
+		}
+	}
+	s.results[result.ID] = branchImportEntry{userID: userID, errors: result.Errors, expires: now.Add(time.Hour)}
+}
+
+// readImportTable returns the cells of the first sheet of an XLSX file or of a CSV file
+func readImportTable(fileName string, content []byte) ([][]string, error) {
+	var rows [][]string
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".xlsx":
+		file, err := excelize.OpenReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, &ServiceError{StatusCode: 400, Message: "the file is not a valid xlsx file"}
+		}
+		defer file.Close()
+		rows, err = file.GetRows(file.GetSheetName(0), excelize.Options{RawCellValue: true})
+		if err != nil {
+			return nil, err
+		}
+	case ".csv":
+		content = bytes.TrimPrefix(content, []byte("\ufeff"))
+		reader := csv.NewReader(bytes.NewReader(content))
+		reader.FieldsPerRecord = -1
+		// اکسل با تنظیمات فارسی گاهی ; را جداکننده می‌گذارد
+		header, _, _ := bytes.Cut(content, []byte("\n"))
+		if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+			reader.Comma = ';'
+		}
+		var err error
+		if rows, err = reader.ReadAll(); err != nil {
+			return nil, &ServiceError{StatusCode: 400, Message: "the file is not a valid csv file: " + err.Error()}
+		}
+	default:
+		return nil, &ServiceError{StatusCode: 400, Message: "only xlsx and csv files can be imported"}
+	}
+	for i, row := range rows {
+		for j := range row {
+			rows[i][j] = strings.TrimSpace(row[j])
+		}
+	}
+	return rows, nil
+}
+
+// importTitle folds a column title for matching: case, spaces and Persian spellings
+func importTitle(title string) string {
+	title = strings.ToLower(database.NormalizeSearchText(title))
+	return strings.Join(strings.FieldsFunc(title, func(r rune) bool { return r == ' ' || r == '_' || r == '-' }), "")
+}
+
+// importMapping resolves every field to the index of its column; unmapped fields are left out
+func importMapping(headers []string, mapping map[string]string) (map[string]int, error) {
+	byTitle := map[string]int{}
+	for i, header := range headers {
+		if _, ok := byTitle[importTitle(header)]; !ok && header != "" {
+			byTitle[importTitle(header)] = i
+		}
+	}
+
+	columns := map[string]int{}
+	var missing []string
+	for _, field := range branchImportFields {
+		if title, ok := mapping[field.Key]; ok {
+			if strings.TrimSpace(title) == "" {
+				// عنوان خالی یعنی این فیلد خوانده نشود
+				if field.Required {
+					missing = append(missing, field.Key)
+				}
+				continue
+			}
+			column, ok := byTitle[importTitle(title)]
+			if !ok {
+				return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("column %q of %s is not in the file", title, field.Key)}
+			}
+			columns[field.Key] = column
+			continue
+		}
+		for _, title := range append([]string{field.Key}, field.Aliases...) {
+			if column, ok := byTitle[importTitle(title)]; ok {
+				columns[field.Key] = column
+				break
+			}
+		}
+		if _, ok := columns[field.Key]; !ok && field.Required {
+			missing = append(missing, field.Key)
+		}
+	}
+	for key := range mapping {
+		known := false
+		for _, field := range branchImportFields {
+			known = known || field.Key == key
+		}
+		if !known {
+			return nil, &ServiceError{StatusCode: 400, Message: "unknown import field " + key}
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &ServiceError{StatusCode: 400, Message: "no column for the required fields: " + strings.Join(missing, ", ")}
+	}
+	return columns, nil
+}
+
+// checkImportGrants refuses an import whose columns create rows or set fields the caller may not
+func checkImportGrants(caller *Caller, columns map[string]int) error {
+	mapped := func(fields ...string) bool {
+		for _, field := range fields {
+			if _, ok := columns[field]; ok {
+				return true
+			}
+		}
+		return false
+	}
+	if mapped("partitionLocalId", "partitionLabel", "zoneLocalId", "zoneLabel", "zoneType") && !caller.Can(models.PermissionCreate, "Partition", "") {
+		return ErrForbidden
+	}
+	if mapped("zoneLocalId", "zoneLabel", "zoneType") && !caller.Can(models.PermissionCreate, "Zone", "") {
+		return ErrForbidden
+	}
+	if mapped("employeeLocalId", "employeeName", "employeeLastName", "employeePosition", "employeeNationalCode") && !caller.Can(models.PermissionCreate, "Employee", "") {
+		return ErrForbidden
+	}
+	var fields []string
+	for _, field := range []string{"panelIp", "emergencyCall"} {
+		if mapped(field) {
+			fields = append(fields, field)
+		}
+	}
+	if err := caller.checkFieldUpdates("Branch", fields); err != nil {
+		return err
+	}
+	if mapped("employeeNationalCode") {
+		return caller.checkFieldUpdates("Employee", []string{"nationalCode"})
+	}
+	return nil
+}
+
+// importLookup finds rows by id or by a name; a name shared by several rows is ambiguous
+type importLookup struct {
+	byID   map[string]string
+	byName map[string][]string
+}
+
+func newImportLookup() *importLookup {
+	return &importLookup{byID: map[string]string{}, byName: map[string][]string{}}
+}
+
+func (l *importLookup) add(id string, label string, names ...string) {
+	l.byID[id] = label
+	seen := map[string]bool{}
+	for _, name := range names {
+		key := importTitle(name)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		l.byName[key] = append(l.byName[key], id)
+	}
+}
+
+// find returns the id of value, or a reason it was not found
+func (l *importLookup) find(value string, what string) (string, string) {
+	if _, ok := l.byID[value]; ok {
+		return value, ""
+	}
+	ids := l.byName[importTitle(value)]
+	switch len(ids) {
+	case 0:
+		return "", fmt.Sprintf("unknown %s %q", what, value)
+	case 1:
+		return ids[0], ""
+	}
+	return "", fmt.Sprintf("%s %q is ambiguous, use its id or its full name", what, value)
+}
+
+// check reads every row into branches and records the problems in result
+func (s *BranchImportService) check(caller *Caller, rows [][]string, columns map[string]int, result *BranchImportResult) ([]*importedBranch, error) {
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	locations, panelTypes, receivers, zoneTypes := newImportLookup(), newImportLookup(), newImportLookup(), newImportLookup()
+	var locationRows []models.Location
+	if err := s.DB.Find(&locationRows).Error; err != nil {
+		return nil, err
+	}
+	labels := map[string]string{}
+	for _, l := range locationRows {
+		labels[l.ID] = l.Label
+	}
+	for _, l := range locationRows {
+		// شهرهای هم‌نام با «استان/شهر» از هم جدا می‌شوند
+		names := []string{l.Label}
+		if l.ParentID != nil && labels[*l.ParentID] != "" {
+			names = append(names, labels[*l.ParentID]+"/"+l.Label)
+		}
+		locations.add(l.ID, l.Label, names...)
+	}
+	var panelTypeRows []models.PanelType
+	if err := s.DB.Find(&panelTypeRows).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range panelTypeRows {
+		panelTypes.add(p.ID, p.Name, p.Name, p.Model, p.Name+" "+p.Model)
+	}
+	var receiverRows []models.Receiver
+	if err := s.DB.Find(&receiverRows).Error; err != nil {
+		return nil, err
+	}
+	for _, r := range receiverRows {
+		receivers.add(r.ID, r.Token, r.Token)
+	}
+	var zoneTypeRows []models.ZoneType
+	if err := s.DB.Find(&zoneTypeRows).Error; err != nil {
+		return nil, err
+	}
+	for _, z := range zoneTypeRows {
+		zoneTypes.add(z.ID, z.Label, z.Label)
+	}
+
+	// کد شعبه و کد پنل (برای هر گیرنده) باید یکتا باشند
+	type panelKey struct {
+		receiverID string
+		panelCode  int
+	}
+	var existing []models.Branch
+	if err := s.DB.Select("id", "code", `"panelCode"`, `"receiverId"`).Find(&existing).Error; err != nil {
+		return nil, err
+	}
+	usedCodes := map[int]bool{}
+	usedPanels := map[panelKey]string{}
+	for _, b := range existing {
+		usedCodes[b.Code] = true
+		usedPanels[panelKey{b.ReceiverID, b.PanelCode}] = "an existing branch"
+	}
+
+	var branches []*importedBranch
+	byCode := map[int]*importedBranch{}
+	for i, cells := range rows[1:] {
+		line := i + 2
+		value := func(field string) string {
+			if column, ok := columns[field]; ok && column < len(cells) {
+				return cells[column]
+			}
+			return ""
+		}
+		empty := true
+		for _, cell := range cells {
+			empty = empty && cell == ""
+		}
+		if empty {
+			continue
+		}
+		result.Rows++
+		fail := func(field string, format string, args ...interface{}) {
+			column := field
+			if index, ok := columns[field]; ok {
+				column = rows[0][index]
+			}
+			result.Errors = append(result.Errors, BranchImportError{Row: line, Column: column, Message: fmt.Sprintf(format, args...)})
+		}
+		number := func(field string, required bool) (int, bool) {
+			raw := persianDigits.Replace(value(field))
+			if raw == "" {
+				if required {
+					fail(field, "%s is required", field)
+				}
+				return 0, false
+			}
+			n, err := strconv.Atoi(strings.TrimSuffix(raw, ".0"))
+			if err != nil || n < 0 {
+				fail(field, "%q is not a valid number", value(field))
+				return 0, false
+			}
+			return n, true
+		}
+
+		code, ok := number("branchCode", true)
+		if !ok {
+			continue
+		}
+		current := byCode[code]
+		if current == nil {
+			if usedCodes[code] {
+				fail("branchCode", "a branch with code %d already exists", code)
+			}
+			current = s.newBranch(line, code, value, fail, number, scope, locations, panelTypes, receivers)
+			if current == nil {
+				// ردیف‌های بعدی این شعبه هم بررسی می‌شوند ولی شعبه ساخته نمی‌شود
+				current = &importedBranch{raw: map[string]string{}}
+			} else {
+				key := panelKey{current.branch.ReceiverID, current.branch.PanelCode}
+				if other, ok := usedPanels[key]; ok {
+					fail("panelCode", "panel code %d is already used by %s on the same receiver", key.panelCode, other)
+				}
+				usedPanels[key] = fmt.Sprintf("the branch of row %d", line)
+				branches = append(branches, current)
+			}
+			byCode[code] = current
+		} else {
+			for _, field := range branchFields {
+				if v := value(field); v != "" && current.raw[field] != "" && importTitle(v) != importTitle(current.raw[field]) {
+					fail(field, "%q differs from %q given for this branch on an earlier row", v, current.raw[field])
+				}
+			}
+		}
+
+		// پارتیشن، زون و کارمند همین ردیف
+		var partition *importedPartition
+		if value("partitionLocalId") != "" || value("partitionLabel") != "" {
+			if localID, ok := number("partitionLocalId", true); ok {
+				for _, p := range current.partitions {
+					if p.partition.LocalID == localID {
+						partition = p
+					}
+				}
+				if partition == nil {
+					partition = &importedPartition{partition: models.Partition{ID: uuid.NewString(), LocalID: localID, Label: value("partitionLabel"), BranchID: current.branch.ID}}
+					current.partitions = append(current.partitions, partition)
+				} else if label := value("partitionLabel"); label != "" && partition.partition.Label != "" && importTitle(label) != importTitle(partition.partition.Label) {
+					fail("partitionLabel", "partition %d is already named %q", localID, partition.partition.Label)
+				}
+			}
+		}
+		if value("zoneLocalId") != "" || value("zoneLabel") != "" || value("zoneType") != "" {
+			localID, ok := number("zoneLocalId", true)
+			switch {
+			case partition == nil:
+				fail("zoneLocalId", "a zone needs the partition it belongs to on the same row")
+			case ok:
+				zone := models.Zone{ID: uuid.NewString(), LocalID: localID, Label: value("zoneLabel"), PartitionID: partition.partition.ID}
+				if raw := value("zoneType"); raw != "" {
+					id, problem := zoneTypes.find(raw, "zone type")
+					if problem != "" {
+						fail("zoneType", "%s", problem)
+					}
+					zone.ZoneTypeID = id
+				}
+				for _, z := range partition.zones {
+					if z.LocalID == localID {
+						fail("zoneLocalId", "zone %d is repeated in partition %d", localID, partition.partition.LocalID)
+						ok = false
+					}
+				}
+				if ok {
+					partition.zones = append(partition.zones, zone)
+				}
+			}
+		}
+		if value("employeeLocalId") != "" || value("employeeName") != "" || value("employeeLastName") != "" {
+			if localID, ok := number("employeeLocalId", true); ok {
+				repeated := false
+				for _, e := range current.employees {
+					repeated = repeated || e.LocalID == localID
+				}
+				if repeated {
+					fail("employeeLocalId", "employee %d is repeated in this branch", localID)
+				} else {
+					current.employees = append(current.employees, models.Employee{
+						ID: uuid.NewString(), LocalID: localID, Name: value("employeeName"), LastName: value("employeeLastName"),
+						Position: value("employeePosition"), NationalCode: persianDigits.Replace(value("employeeNationalCode")),
+						BranchID: current.branch.ID,
+					})
+				}
+			}
+		}
+	}
+
+	for _, b := range branches {
+		b.preview.Partitions = len(b.partitions)
+		b.preview.Employees = len(b.employees)
+		for _, p := range b.partitions {
+			b.preview.Zones += len(p.zones)
+		}
+		result.Partitions += b.preview.Partitions
+		result.Zones += b.preview.Zones
+		result.Employees += b.preview.Employees
+		result.Branches = append(result.Branches, b.preview)
+	}
+	sort.SliceStable(result.Errors, func(i, j int) bool { return result.Errors[i].Row < result.Errors[j].Row })
+	return branches, nil
+}
+
+// newBranch reads the branch fields of its first row; nil when one of them is wrong
+func (s *BranchImportService) newBranch(line int, code int, value func(string) string, fail func(string, string, ...interface{}),
+	number func(string, bool) (int, bool), scope *LocationScope, locations, panelTypes, receivers *importLookup) *importedBranch {
+	valid := true
+	b := &importedBranch{raw: map[string]string{}}
+	for _, field := range branchFields {
+		b.raw[field] = value(field)
+	}
+
+	name := value("branchName")
+	if name == "" {
+		fail("branchName", "branchName is required")
+		valid = false
+	}
+	locationID, problem := locations.find(value("location"), "location")
+	if value("location") == "" {
+		fail("location", "location is required")
+		valid = false
+	} else if problem != "" {
+		fail("location", "%s", problem)
+		valid = false
+	} else if !scope.AllowsLocation(locationID) {
+		fail("location", "location %q is outside your locations", value("location"))
+		valid = false
+	}
+	panelTypeID, problem := panelTypes.find(value("panelType"), "panel type")
+	if value("panelType") == "" {
+		fail("panelType", "panelType is required")
+		valid = false
+	} else if problem != "" {
+		fail("panelType", "%s", problem)
+		valid = false
+	}
+	panelCode, ok := number("panelCode", true)
+	valid = valid && ok
+	var receiverID string
+	if raw := value("receiver"); raw != "" {
+		if receiverID, problem = receivers.find(raw, "receiver"); problem != "" {
+			fail("receiver", "%s", problem)
+			valid = false
+		}
+	}
+	ip := value("panelIp")
+	if ip != "" && net.ParseIP(ip) == nil {
+		fail("panelIp", "%q is not a valid IP address", ip)
+		valid = false
+	}
+	if !valid {
+		return nil
+	}
+
+	b.branch = models.Branch{
+		ID:                     uuid.NewString(),
+		Name:                   name,
+		Code:                   code,
+		Address:                value("address"),
+		PhoneNumber:            persianDigits.Replace(value("phoneNumber")),
+		DestinationPhoneNumber: persianDigits.Replace(value("destinationPhoneNumber")),
+		PanelIp:                ip,
+		PanelCode:              panelCode,
+		EmergencyCall:          persianDigits.Replace(value("emergencyCall")),
+		ReceiverID:             receiverID,
+		PanelTypeID:            panelTypeID,
+		LocationID:             locationID,
+	}
+	b.preview = BranchImportPreview{
+		Row: line, Code: code, Name: name, PanelCode: panelCode,
+		Location: locations.byID[locationID], PanelType: panelTypes.byID[panelTypeID],
+	}
+	return b
+}
+
+// write creates the checked branches in one transaction; the first partition of a branch
+// becomes its main partition
+func (s *BranchImportService) write(caller *Caller, branches []*importedBranch) error {
+	return s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		for _, b := range branches {
+			if err := tx.Create(&b.branch).Error; err != nil {
+				return err
+			}
+			for _, p := range b.partitions {
+				if err := tx.Create(&p.partition).Error; err != nil {
+					return err
+				}
+				for i := range p.zones {
+					if err := tx.Create(&p.zones[i]).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if len(b.partitions) > 0 {
+				err := tx.Model(&b.branch).Update("mainPartitionId", b.partitions[0].partition.ID).Error
+				if err != nil {
+					return err
+				}
+			}
+			for i := range b.employees {
+				if err := tx.Create(&b.employees[i]).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
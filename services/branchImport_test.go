@@ -0,0 +1,134 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"monitoring-with-go/models"
+)
+
+const branchImportHeader = "کد شعبه,نام شعبه,مکان,نوع پنل,کد پنل,شماره پارتیشن,شماره زون,نام زون,شماره کارمند,نام کارمند\n"
+
+func TestBranchImportDryRunAndCommit(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	if err := db.Create(&models.PanelType{ID: "mcu", Name: "PZH-MCU", Model: "MCU"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: "tehran"},
+		requirement{Action: models.PermissionCreate, Model: "Branch"},
+		requirement{Action: models.PermissionCreate, Model: "Partition"},
+		requirement{Action: models.PermissionCreate, Model: "Zone"},
+		requirement{Action: models.PermissionCreate, Model: "Employee"})
+	s := &BranchImportService{DB: db, Authz: &Authorizer{DB: db}, Dir: t.TempDir()}
+
+	// ردیف‌های یک شعبه پارتیشن، زون و کارمند آن را اضافه می‌کنند؛ رقم فارسی هم خوانده می‌شود
+	content := []byte(branchImportHeader +
+		"101,Vanak,North,PZH-MCU,۱۷,1,1,Door,1,Ali\n" +
+		"101,Vanak,North,PZH-MCU,17,1,2,Window,,\n" +
+		"102,Pasdaran,North,PZH-MCU,18,1,,,,\n")
+	req := BranchImportRequest{FileName: "branches.csv", Content: content, DryRun: true}
+	res, err := s.Import(admin, req)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	data := res.Data
+	if data.Committed || len(data.Errors) != 0 || data.Rows != 3 || len(data.Branches) != 2 || data.Partitions != 2 || data.Zones != 2 || data.Employees != 1 {
+		t.Fatalf("dry run result %+v", data)
+	}
+	var branches int64
+	db.Model(&models.Branch{}).Where("code IN ?", []int{101, 102}).Count(&branches)
+	if branches != 0 {
+		t.Fatalf("the dry run wrote %d branches", branches)
+	}
+
+	req.DryRun = false
+	if res, err = s.Import(admin, req); err != nil || !res.Data.Committed {
+		t.Fatalf("import: %v, %+v", err, res)
+	}
+	var vanak models.Branch
+	if err := db.Where("code = ?", 101).Take(&vanak).Error; err != nil {
+		t.Fatal(err)
+	}
+	var partition models.Partition
+	db.Where(`"branchId" = ?`, vanak.ID).Take(&partition)
+	var zones, employees int64
+	db.Model(&models.Zone{}).Where(`"partitionId" = ?`, partition.ID).Count(&zones)
+	db.Model(&models.Employee{}).Where(`"branchId" = ?`, vanak.ID).Count(&employees)
+	if vanak.PanelCode != 17 || vanak.MainPartitionID != partition.ID || zones != 2 || employees != 1 {
+		t.Errorf("imported %+v with partition %s, %d zones and %d employees", vanak, partition.ID, zones, employees)
+	}
+}
+
+func TestBranchImportErrorReport(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	if err := db.Create(&models.PanelType{ID: "mcu", Name: "PZH-MCU", Model: "MCU"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: "tehran"},
+		requirement{Action: models.PermissionCreate, Model: "Branch"},
+		requirement{Action: models.PermissionCreate, Model: "Partition"},
+		requirement{Action: models.PermissionCreate, Model: "Zone"},
+		requirement{Action: models.PermissionCreate, Model: "Employee"})
+	other := newTestUser(t, db, models.User{ID: "other", LocationID: "tehran"}, requirement{Action: models.PermissionCreate, Model: "Branch"})
+	s := &BranchImportService{DB: db, Authz: &Authorizer{DB: db}, Dir: t.TempDir()}
+
+	content := []byte(branchImportHeader +
+		"103,Valid,North,PZH-MCU,30,,,,,\n" +
+		// کد شعبه ۱ و کد پنل ۱ از قبل هست
+		"1,Taken,North,PZH-MCU,1,,,,,\n" +
+		"104,Far,Shiraz,PZH-MCU,31,,,,,\n" +
+		"105,Nowhere,Tabriz,PZH-MCU,32,,,,,\n" +
+		"106,Zone only,North,PZH-MCU,33,,4,Door,,\n" +
+		"103,Renamed,North,PZH-MCU,30,,,,,\n")
+	res, err := s.Import(admin, BranchImportRequest{FileName: "branches.csv", Content: content})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if res.Data.Committed {
+		t.Fatal("a file with errors was imported")
+	}
+	rows := map[int]bool{}
+	for _, e := range res.Data.Errors {
+		rows[e.Row] = true
+	}
+	for _, row := range []int{3, 4, 5, 6, 7} {
+		if !rows[row] {
+			t.Errorf("no error on row %d: %+v", row, res.Data.Errors)
+		}
+	}
+	if rows[2] {
+		t.Errorf("the valid row has an error: %+v", res.Data.Errors)
+	}
+	var count int64
+	db.Model(&models.Branch{}).Where("code = ?", 103).Count(&count)
+	if count != 0 {
+		t.Error("the valid branch of a file with errors was written")
+	}
+
+	report, err := s.WriteErrorReport(admin, BranchImportReportRequest{ImportID: res.Data.ID, Format: "csv"})
+	if err != nil {
+		t.Fatalf("WriteErrorReport: %v", err)
+	}
+	written, err := os.ReadFile(report.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(written), "\n"); lines != len(res.Data.Errors)+1 {
+		t.Errorf("report has %d lines for %d errors", lines, len(res.Data.Errors))
+	}
+	// گزارش هر ورود فقط برای همان کاربر است
+	var serviceErr *ServiceError
+	_, err = s.WriteErrorReport(other, BranchImportReportRequest{ImportID: res.Data.ID, Format: "csv"})
+	if !errors.As(err, &serviceErr) || serviceErr.StatusCode != 404 {
+		t.Errorf("another user's report: %v, want 404", err)
+	}
+	// ستون زون بدون دسترسی ساخت زون رد می‌شود
+	_, err = s.Import(other, BranchImportRequest{FileName: "branches.csv", Content: content, DryRun: true})
+	if !errors.As(err, &serviceErr) || serviceErr.StatusCode != 403 {
+		t.Errorf("zones without the grant: %v, want 403", err)
+	}
+}
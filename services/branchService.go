@@ -0,0 +1,713 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BranchService creates, edits and clones branches with their partitions, zones and
+// employees. A branch outside the caller's locations is reported as not found. Two live
+// branches may not share a code, nor a panel code on the same receiver; local ids are unique
+// per branch for partitions and employees and per partition for zones. MainPartitionID
+// always points at a live partition of the branch while it has any.
+type BranchService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+// PartitionTree is a partition with its zones
+type PartitionTree struct {
+	Partition models.Partition `json:"partition"`
+	Zones     []models.Zone    `json:"zones"`
+}
+
+// BranchTree is a branch with everything below it, ordered by local id
+type BranchTree struct {
+	Branch     models.Branch      `json:"branch"`
+	Partitions []PartitionTree    `json:"partitions"`
+	Employees  []models.Employee  `json:"employees"`
+	Equipment  []models.Equipment `json:"equipment"`
+}
+
+type BranchTreeResponse struct {
+	StatusCode     int         `json:"statusCode"`
+	Message        string      `json:"message"`
+	Data           *BranchTree `json:"data"`
+	RedactedFields []string    `json:"redactedFields"`
+}
+
+// BranchInput holds the fields of a new branch; an empty ReceiverID or PanelTypeID leaves
+// the branch without one
+type BranchInput struct {
+	Name                   string `json:"name"`
+	Code                   int    `json:"code"`
+	LocationID             string `json:"locationId"`
+	PanelTypeID            string `json:"panelTypeId"`
+	ReceiverID             string `json:"receiverId"`
+	PanelCode              int    `json:"panelCode"`
+	PanelIp                string `json:"panelIp"`
+	EmergencyCall          string `json:"emergencyCall"`
+	Address                string `json:"address"`
+	PhoneNumber            string `json:"phoneNumber"`
+	DestinationPhoneNumber string `json:"destinationPhoneNumber"`
+	TimeZone               string `json:"timeZone"`
+}
+
+type ZoneInput struct {
+	LocalID    int    `json:"localId"`
+	Label      string `json:"label"`
+	ZoneTypeID string `json:"zoneTypeId"`
+}
+
+type PartitionInput struct {
+	LocalID int         `json:"localId"`
+	Label   string      `json:"label"`
+	Zones   []ZoneInput `json:"zones"`
+}
+
+type EmployeeInput struct {
+	LocalID      int    `json:"localId"`
+	Name         string `json:"name"`
+	LastName     string `json:"lastName"`
+	Position     string `json:"position"`
+	NationalCode string `json:"nationalCode"`
+}
+
+// CreateBranchRequest creates a branch with its hierarchy. MainPartitionLocalID picks the
+// main partition; 0 takes the first partition.
+type CreateBranchRequest struct {
+	Branch               BranchInput      `json:"branch"`
+	Partitions           []PartitionInput `json:"partitions"`
+	Employees            []EmployeeInput  `json:"employees"`
+	MainPartitionLocalID int              `json:"mainPartitionLocalId"`
+}
+
+// UpdateBranchRequest carries only the fields to change; nil fields are left untouched
+type UpdateBranchRequest struct {
+	Name                   *string `json:"name"`
+	Code                   *int    `json:"code"`
+	LocationID             *string `json:"locationId"`
+	PanelTypeID            *string `json:"panelTypeId"`
+	ReceiverID             *string `json:"receiverId"`
+	PanelCode              *int    `json:"panelCode"`
+	PanelIp                *string `json:"panelIp"`
+	EmergencyCall          *string `json:"emergencyCall"`
+	Address                *string `json:"address"`
+	PhoneNumber            *string `json:"phoneNumber"`
+	DestinationPhoneNumber *string `json:"destinationPhoneNumber"`
+	MainPartitionID        *string `json:"mainPartitionId"`
+	// Version is the version the client read; the update fails with a conflict if it is stale
+	Version *int `json:"version"`
+}
+
+// CloneBranchRequest copies a branch with its partitions and zones under a new code and panel
+// code. Nil ReceiverID and an empty LocationID keep those of the source. The panel IP is not
+// copied, since the new branch has its own panel; employees are copied only when asked.
+type CloneBranchRequest struct {
+	Name       string  `json:"name"`
+	Code       int     `json:"code"`
+	PanelCode  int     `json:"panelCode"`
+	ReceiverID *string `json:"receiverId"`
+	LocationID string  `json:"locationId"`
+	PanelIp    string  `json:"panelIp"`
+	Employees  bool    `json:"employees"`
+}
+
+// FindOne returns a branch with its partitions, zones, employees and equipment
+func (s *BranchService) FindOne(token string, id string) (*BranchTreeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.FindOne")
+	if err != nil {
+		return nil, err
+	}
+	branch, err := s.scopedBranch(s.DB, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.treeResponse(caller, branch, "Branch fetched successfully")
+}
+
+// Create adds a branch with its partitions, zones and employees in one transaction
+func (s *BranchService) Create(token string, req CreateBranchRequest) (*BranchTreeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.Create")
+	if err != nil {
+		return nil, err
+	}
+	if err := checkHierarchyGrants(caller, req.Partitions, len(req.Employees) > 0); err != nil {
+		return nil, err
+	}
+	var sensitive []string
+	if req.Branch.PanelIp != "" {
+		sensitive = append(sensitive, "panelIp")
+	}
+	if req.Branch.EmergencyCall != "" {
+		sensitive = append(sensitive, "emergencyCall")
+	}
+	if err := caller.checkFieldUpdates("Branch", sensitive); err != nil {
+		return nil, err
+	}
+	for _, e := range req.Employees {
+		if e.NationalCode != "" {
+			if err := caller.checkFieldUpdates("Employee", []string{"nationalCode"}); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if zone := strings.TrimSpace(req.Branch.TimeZone); zone != "" {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown time zone %q", zone)}
+		}
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	in := req.Branch
+	branch := models.Branch{
+		ID:                     uuid.NewString(),
+		Name:                   strings.TrimSpace(in.Name),
+		Code:                   in.Code,
+		LocationID:             in.LocationID,
+		PanelTypeID:            in.PanelTypeID,
+		ReceiverID:             in.ReceiverID,
+		PanelCode:              in.PanelCode,
+		PanelIp:                strings.TrimSpace(in.PanelIp),
+		EmergencyCall:          in.EmergencyCall,
+		Address:                in.Address,
+		PhoneNumber:            in.PhoneNumber,
+		DestinationPhoneNumber: in.DestinationPhoneNumber,
+		TimeZone:               strings.TrimSpace(in.TimeZone),
+	}
+	tree := BranchTree{Branch: branch}
+	for _, p := range req.Partitions {
+		partition := PartitionTree{Partition: models.Partition{ID: uuid.NewString(), LocalID: p.LocalID, Label: p.Label, BranchID: branch.ID}}
+		for _, z := range p.Zones {
+			partition.Zones = append(partition.Zones, models.Zone{
+				ID: uuid.NewString(), LocalID: z.LocalID, Label: z.Label, ZoneTypeID: z.ZoneTypeID, PartitionID: partition.Partition.ID,
+			})
+		}
+		tree.Partitions = append(tree.Partitions, partition)
+	}
+	for _, e := range req.Employees {
+		tree.Employees = append(tree.Employees, models.Employee{
+			ID: uuid.NewString(), LocalID: e.LocalID, Name: e.Name, LastName: e.LastName,
+			Position: e.Position, NationalCode: e.NationalCode, BranchID: branch.ID,
+		})
+	}
+	mainIndex := 0
+	if req.MainPartitionLocalID != 0 {
+		mainIndex = -1
+		for i, p := range tree.Partitions {
+			if p.Partition.LocalID == req.MainPartitionLocalID {
+				mainIndex = i
+			}
+		}
+		if mainIndex < 0 {
+			return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("main partition %d is not one of the partitions", req.MainPartitionLocalID)}
+		}
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := checkBranch(tx, scope, &branch, nil); err != nil {
+			return err
+		}
+		return createTree(tx, &tree, mainIndex)
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+
+	created, err := s.scopedBranch(s.DB, caller, branch.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.treeResponse(caller, created, "Branch created successfully")
+}
+
+// Update changes the given fields of a branch; touching a sensitive field needs a grant on it
+func (s *BranchService) Update(token string, id string, req UpdateBranchRequest) (*BranchTreeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.Update")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	var fields []string
+	setString := func(field string, value *string) {
+		if value != nil {
+			updates[field] = strings.TrimSpace(*value)
+			fields = append(fields, field)
+		}
+	}
+	setInt := func(field string, value *int) {
+		if value != nil {
+			updates[field] = *value
+			fields = append(fields, field)
+		}
+	}
+	setString("name", req.Name)
+	setInt("code", req.Code)
+	setString("locationId", req.LocationID)
+	setString("panelTypeId", req.PanelTypeID)
+	setString("receiverId", req.ReceiverID)
+	setInt("panelCode", req.PanelCode)
+	setString("panelIp", req.PanelIp)
+	setString("emergencyCall", req.EmergencyCall)
+	setString("address", req.Address)
+	setString("phoneNumber", req.PhoneNumber)
+	setString("destinationPhoneNumber", req.DestinationPhoneNumber)
+	setString("mainPartitionId", req.MainPartitionID)
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+	if err := caller.checkFieldUpdates("Branch", fields); err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var branch models.Branch
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		current, err := s.scopedBranch(tx, caller, id)
+		if err != nil {
+			return err
+		}
+		// شعبه با مقدارهای جدید بررسی می‌شود، ولی فقط قیدهای فیلدهای تغییرکرده
+		changed := map[string]bool{}
+		for _, field := range fields {
+			changed[field] = true
+		}
+		merged := *current
+		if req.Name != nil {
+			merged.Name = strings.TrimSpace(*req.Name)
+		}
+		if req.Code != nil {
+			merged.Code = *req.Code
+		}
+		if req.LocationID != nil {
+			merged.LocationID = strings.TrimSpace(*req.LocationID)
+		}
+		if req.PanelTypeID != nil {
+			merged.PanelTypeID = strings.TrimSpace(*req.PanelTypeID)
+		}
+		if req.ReceiverID != nil {
+			merged.ReceiverID = strings.TrimSpace(*req.ReceiverID)
+		}
+		if req.PanelCode != nil {
+			merged.PanelCode = *req.PanelCode
+		}
+		if req.PanelIp != nil {
+			merged.PanelIp = strings.TrimSpace(*req.PanelIp)
+		}
+		if err := checkBranch(tx, scope, &merged, changed); err != nil {
+			return err
+		}
+		if req.MainPartitionID != nil {
+			if err := checkMainPartition(tx, id, strings.TrimSpace(*req.MainPartitionID)); err != nil {
+				return err
+			}
+		}
+		// شناسه خالی یعنی بدون گیرنده یا نوع پنل؛ رشته خالی کلید خارجی را نقض می‌کند
+		for _, field := range []string{"panelTypeId", "receiverId", "mainPartitionId"} {
+			if value, ok := updates[field]; ok && value == "" {
+				updates[field] = nil
+			}
+		}
+		return updateVersioned(tx, &branch, id, req.Version, updates)
+	})
+	if err != nil {
+		var conflict *ConflictError
+		if errors.As(err, &conflict) {
+			caller.redact("Branch", &branch)
+		}
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return s.treeResponse(caller, &branch, "Branch updated successfully")
+}
+
+// Clone creates a new branch with the fields, partitions and zones of the branch id, and
+// its employees when asked
+func (s *BranchService) Clone(token string, id string, req CloneBranchRequest) (*BranchTreeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "BranchService.Clone")
+	if err != nil {
+		return nil, err
+	}
+	if req.PanelIp != "" {
+		if err := caller.checkFieldUpdates("Branch", []string{"panelIp"}); err != nil {
+			return nil, err
+		}
+	}
+	source, err := s.scopedBranch(s.DB, caller, id)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := s.loadTree(source)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCloneGrants(caller, tree, req.Employees); err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	branch := *source
+	branch.ID = uuid.NewString()
+	branch.OldID, branch.OldLocationID, branch.OldPanelTypeID, branch.OldReceiverID = 0, 0, 0, 0
+	branch.Name = strings.TrimSpace(req.Name)
+	branch.Code = req.Code
+	branch.PanelCode = req.PanelCode
+	branch.PanelIp = strings.TrimSpace(req.PanelIp)
+	branch.MainPartitionID = ""
+	branch.Version = 0
+	branch.CreatedAt, branch.UpdatedAt = time.Time{}, time.Time{}
+	if req.ReceiverID != nil {
+		branch.ReceiverID = strings.TrimSpace(*req.ReceiverID)
+	}
+	if req.LocationID != "" {
+		branch.LocationID = req.LocationID
+	}
+	// شماره اضطراری فقط برای کسی که اجازه تغییر آن را دارد کپی می‌شود
+	if !caller.CanField(models.PermissionUpdate, "Branch", "emergencyCall") {
+		branch.EmergencyCall = ""
+	}
+
+	clone := BranchTree{Branch: branch}
+	mainIndex := 0
+	for i, p := range tree.Partitions {
+		partition := PartitionTree{Partition: models.Partition{ID: uuid.NewString(), LocalID: p.Partition.LocalID, Label: p.Partition.Label, BranchID: branch.ID}}
+		for _, z := range p.Zones {
+			partition.Zones = append(partition.Zones, models.Zone{
+				ID: uuid.NewString(), LocalID: z.LocalID, Label: z.Label, ZoneTypeID: z.ZoneTypeID, PartitionID: partition.Partition.ID,
+			})
+		}
+		if p.Partition.ID == source.MainPartitionID {
+			mainIndex = i
+		}
+		clone.Partitions = append(clone.Partitions, partition)
+	}
+	if req.Employees {
+		for _, e := range tree.Employees {
+			clone.Employees = append(clone.Employees, models.Employee{
+				ID: uuid.NewString(), LocalID: e.LocalID, Name: e.Name, LastName: e.LastName,
+				Position: e.Position, NationalCode: e.NationalCode, BranchID: branch.ID,
+			})
+		}
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := checkBranch(tx, scope, &clone.Branch, nil); err != nil {
+			return err
+		}
+		return createTree(tx, &clone, mainIndex)
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+
+	created, err := s.scopedBranch(s.DB, caller, branch.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s.treeResponse(caller, created, "Branch cloned successfully")
+}
+
+// scopedBranch loads the live branch id, reporting a branch outside the caller's locations
+// as not found
+func (s *BranchService) scopedBranch(db *gorm.DB, caller *Caller, id string) (*models.Branch, error) {
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	var branch models.Branch
+	if err := scope.Branches(db.Where("id = ?", id)).First(&branch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "branch not found"}
+		}
+		return nil, err
+	}
+	return &branch, nil
+}
+
+// loadTree reads the partitions, zones, employees and equipment of branch
+func (s *BranchService) loadTree(branch *models.Branch) (*BranchTree, error) {
+	tree := &BranchTree{
+		Branch:     *branch,
+		Partitions: []PartitionTree{},
+		Employees:  []models.Employee{},
+		Equipment:  []models.Equipment{},
+	}
+	var partitions []models.Partition
+	if err := s.DB.Where(`"branchId" = ?`, branch.ID).Order(`"localId"`).Find(&partitions).Error; err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	ids := []string{}
+	for i, p := range partitions {
+		index[p.ID] = i
+		ids = append(ids, p.ID)
+		tree.Partitions = append(tree.Partitions, PartitionTree{Partition: p, Zones: []models.Zone{}})
+	}
+	if len(ids) > 0 {
+		var zones []models.Zone
+		if err := s.DB.Where(`"partitionId" IN ?`, ids).Order(`"localId"`).Find(&zones).Error; err != nil {
+			return nil, err
+		}
+		for _, z := range zones {
+			p := &tree.Partitions[index[z.PartitionID]]
+			p.Zones = append(p.Zones, z)
+		}
+	}
+	if err := s.DB.Where(`"branchId" = ?`, branch.ID).Order(`"localId"`).Find(&tree.Employees).Error; err != nil {
+		return nil, err
+	}
+	if err := s.DB.Where(`"branchId" = ?`, branch.ID).Order("name").Find(&tree.Equipment).Error; err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// treeResponse loads the tree of branch with the fields the caller may not read masked
+func (s *BranchService) treeResponse(caller *Caller, branch *models.Branch, message string) (*BranchTreeResponse, error) {
+	tree, err := s.loadTree(branch)
+	if err != nil {
+		return nil, err
+	}
+	redacted := caller.redact("Branch", &tree.Branch)
+	redacted = append(redacted, caller.redact("Employee", &tree.Employees)...)
+	return &BranchTreeResponse{
+		StatusCode:     200,
+		Message:        message,
+		Data:           tree,
+		RedactedFields: redacted,
+	}, nil
+}
+
+// checkHierarchyGrants refuses a new branch whose partitions, zones or employees the caller
+// may not create
+func checkHierarchyGrants(caller *Caller, partitions []PartitionInput, employees bool) error {
+	zones := false
+	for _, p := range partitions {
+		zones = zones || len(p.Zones) > 0
+	}
+	if len(partitions) > 0 && !caller.Can(models.PermissionCreate, "Partition", "") {
+		return ErrForbidden
+	}
+	if zones && !caller.Can(models.PermissionCreate, "Zone", "") {
+		return ErrForbidden
+	}
+	if employees && !caller.Can(models.PermissionCreate, "Employee", "") {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func checkCloneGrants(caller *Caller, tree *BranchTree, employees bool) error {
+	partitions := make([]PartitionInput, len(tree.Partitions))
+	for i, p := range tree.Partitions {
+		partitions[i].Zones = make([]ZoneInput, len(p.Zones))
+	}
+	return checkHierarchyGrants(caller, partitions, employees && len(tree.Employees) > 0)
+}
+
+// checkBranch validates what branch points at and the codes it may not share with another
+// live branch. changed limits the checks to the fields being changed; nil checks them all.
+func checkBranch(tx *gorm.DB, scope *LocationScope, branch *models.Branch, changed map[string]bool) error {
+	check := func(fields ...string) bool {
+		if changed == nil {
+			return true
+		}
+		for _, field := range fields {
+			if changed[field] {
+				return true
+			}
+		}
+		return false
+	}
+	exists := func(model any, id string) (bool, error) {
+		var count int64
+		err := tx.Model(model).Where("id = ?", id).Count(&count).Error
+		return count > 0, err
+	}
+
+	if check("name") && branch.Name == "" {
+		return &ServiceError{StatusCode: 400, Message: "name is required"}
+	}
+	if check("code") && branch.Code <= 0 {
+		return &ServiceError{StatusCode: 400, Message: "code must be a positive number"}
+	}
+	if check("panelCode") && branch.PanelCode <= 0 {
+		return &ServiceError{StatusCode: 400, Message: "panel code must be a positive number"}
+	}
+	if check("panelIp") && branch.PanelIp != "" && net.ParseIP(branch.PanelIp) == nil {
+		return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("%q is not a valid IP address", branch.PanelIp)}
+	}
+	if check("locationId") {
+		if branch.LocationID == "" {
+			return &ServiceError{StatusCode: 400, Message: "location is required"}
+		}
+		found, err := exists(&models.Location{}, branch.LocationID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &ServiceError{StatusCode: 400, Message: "location not found"}
+		}
+		if !scope.AllowsLocation(branch.LocationID) {
+			return &ServiceError{StatusCode: 403, Message: "the location is outside your locations"}
+		}
+	}
+	if check("panelTypeId") && branch.PanelTypeID != "" {
+		found, err := exists(&models.PanelType{}, branch.PanelTypeID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &ServiceError{StatusCode: 400, Message: "panel type not found"}
+		}
+	}
+	if check("receiverId") && branch.ReceiverID != "" {
+		found, err := exists(&models.Receiver{}, branch.ReceiverID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &ServiceError{StatusCode: 400, Message: "receiver not found"}
+		}
+	}
+
+	if check("code") {
+		var count int64
+		if err := tx.Model(&models.Branch{}).Where("code = ? AND id <> ?", branch.Code, branch.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("a branch with code %d already exists", branch.Code)}
+		}
+	}
+	if check("panelCode", "receiverId") {
+		query := tx.Model(&models.Branch{}).Where(`"panelCode" = ? AND id <> ?`, branch.PanelCode, branch.ID)
+		if branch.ReceiverID == "" {
+			query = query.Where(`"receiverId" IS NULL`)
+		} else {
+			query = query.Where(`"receiverId" = ?`, branch.ReceiverID)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("panel code %d is already used on this receiver", branch.PanelCode)}
+		}
+	}
+	return nil
+}
+
+// checkMainPartition makes sure partitionID is a live partition of the branch; it may only be
+// empty while the branch has no partitions
+func checkMainPartition(tx *gorm.DB, branchID string, partitionID string) error {
+	var count int64
+	if partitionID == "" {
+		if err := tx.Model(&models.Partition{}).Where(`"branchId" = ?`, branchID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return &ServiceError{StatusCode: 400, Message: "a branch with partitions needs a main partition"}
+		}
+		return nil
+	}
+	if err := tx.Model(&models.Partition{}).Where(`id = ? AND "branchId" = ?`, partitionID, branchID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ServiceError{StatusCode: 400, Message: "the main partition must be a partition of this branch"}
+	}
+	return nil
+}
+
+// createTree writes a new branch with its hierarchy; the partition at mainIndex becomes the
+// main partition. Rows are created one by one, since batch inserts do not leave the
+// default:null columns out on SQLite.
+func createTree(tx *gorm.DB, tree *BranchTree, mainIndex int) error {
+	if err := checkLocalIDs(tree); err != nil {
+		return err
+	}
+	zoneTypes := map[string]bool{}
+	for _, p := range tree.Partitions {
+		for _, z := range p.Zones {
+			if zoneTypes[z.ZoneTypeID] {
+				continue
+			}
+			if err := checkZoneType(tx, z.ZoneTypeID); err != nil {
+				return err
+			}
+			zoneTypes[z.ZoneTypeID] = true
+		}
+	}
+
+	if err := tx.Create(&tree.Branch).Error; err != nil {
+		return err
+	}
+	for i := range tree.Partitions {
+		p := &tree.Partitions[i]
+		if err := tx.Create(&p.Partition).Error; err != nil {
+			return err
+		}
+		for j := range p.Zones {
+			if err := tx.Create(&p.Zones[j]).Error; err != nil {
+				return err
+			}
+		}
+	}
+	if len(tree.Partitions) > 0 {
+		tree.Branch.MainPartitionID = tree.Partitions[mainIndex].Partition.ID
+		if err := tx.Model(&tree.Branch).Update("mainPartitionId", tree.Branch.MainPartitionID).Error; err != nil {
+			return err
+		}
+	}
+	for i := range tree.Employees {
+		if err := tx.Create(&tree.Employees[i]).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLocalIDs refuses negative and repeated local ids inside a new hierarchy
+func checkLocalIDs(tree *BranchTree) error {
+	partitions := map[int]bool{}
+	for _, p := range tree.Partitions {
+		if p.Partition.LocalID < 0 || partitions[p.Partition.LocalID] {
+			return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("partition %d is invalid or repeated", p.Partition.LocalID)}
+		}
+		partitions[p.Partition.LocalID] = true
+		zones := map[int]bool{}
+		for _, z := range p.Zones {
+			if z.LocalID < 0 || zones[z.LocalID] {
+				return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("zone %d of partition %d is invalid or repeated", z.LocalID, p.Partition.LocalID)}
+			}
+			zones[z.LocalID] = true
+		}
+	}
+	employees := map[int]bool{}
+	for _, e := range tree.Employees {
+		if e.LocalID < 0 || employees[e.LocalID] {
+			return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("employee %d is invalid or repeated", e.LocalID)}
+		}
+		employees[e.LocalID] = true
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"monitoring-with-go/models"
+)
+
+// wantStatus fails unless err is a ServiceError with status
+func wantStatus(t *testing.T, what string, err error, status int) {
+	t.Helper()
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.StatusCode != status {
+		t.Errorf("%s: %v, want %d", what, err, status)
+	}
+}
+
+func TestBranchClone(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	outsider := newTestUser(t, db, models.User{ID: "outsider", LocationID: "shiraz"},
+		requirement{Action: models.PermissionCreate, Model: "Branch"},
+		requirement{Action: models.PermissionCreate, Model: "Partition"},
+		requirement{Action: models.PermissionCreate, Model: "Zone"})
+	s := &BranchService{DB: db, Authz: &Authorizer{DB: db}}
+
+	source, err := s.Create(owner, CreateBranchRequest{
+		Branch: BranchInput{Name: "Vanak", Code: 10, PanelCode: 10, LocationID: "north", PanelIp: "10.0.0.10"},
+		Partitions: []PartitionInput{
+			{LocalID: 1, Label: "Hall", Zones: []ZoneInput{{LocalID: 1, Label: "Door"}}},
+			{LocalID: 2, Label: "Vault", Zones: []ZoneInput{{LocalID: 1, Label: "Safe"}, {LocalID: 2, Label: "Window"}}},
+		},
+		Employees:            []EmployeeInput{{LocalID: 1, Name: "Ali"}},
+		MainPartitionLocalID: 2,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	id := source.Data.Branch.ID
+
+	res, err := s.Clone(owner, id, CloneBranchRequest{Name: "Vanak 2", Code: 11, PanelCode: 11})
+	if err != nil {
+		t.Fatalf("Clone: %v", err)
+	}
+	clone := res.Data
+	if clone.Branch.ID == id || clone.Branch.LocationID != "north" || clone.Branch.PanelIp != "" {
+		t.Errorf("clone %+v", clone.Branch)
+	}
+	if len(clone.Partitions) != 2 || len(clone.Partitions[1].Zones) != 2 || len(clone.Employees) != 0 {
+		t.Fatalf("cloned %d partitions and %d employees", len(clone.Partitions), len(clone.Employees))
+	}
+	// پارتیشن اصلی نسخه جدید همان شماره پارتیشن اصلی مبدأ را دارد
+	vault := clone.Partitions[1].Partition
+	if vault.LocalID != 2 || vault.BranchID != clone.Branch.ID || clone.Branch.MainPartitionID != vault.ID {
+		t.Errorf("main partition %q, want the clone's partition 2 %q", clone.Branch.MainPartitionID, vault.ID)
+	}
+
+	res, err = s.Clone(owner, id, CloneBranchRequest{Name: "Vanak 3", Code: 12, PanelCode: 12, Employees: true})
+	if err != nil {
+		t.Fatalf("Clone with employees: %v", err)
+	}
+	if len(res.Data.Employees) != 1 || res.Data.Employees[0].BranchID != res.Data.Branch.ID {
+		t.Errorf("cloned employees %+v", res.Data.Employees)
+	}
+
+	_, err = s.Clone(owner, id, CloneBranchRequest{Name: "Taken", Code: 10, PanelCode: 13})
+	wantStatus(t, "clone with a taken code", err, 409)
+	_, err = s.Clone(owner, id, CloneBranchRequest{Name: "Taken", Code: 13, PanelCode: 11})
+	wantStatus(t, "clone with a taken panel code", err, 409)
+	_, err = s.Clone(outsider, id, CloneBranchRequest{Name: "Far", Code: 14, PanelCode: 14})
+	wantStatus(t, "clone outside the locations", err, 404)
+	_, err = s.Clone(owner, id, CloneBranchRequest{Name: "Far", Code: 14, PanelCode: 14, LocationID: "nowhere"})
+	wantStatus(t, "clone to an unknown location", err, 400)
+
+	var count int64
+	db.Model(&models.Branch{}).Where("code IN ?", []int{13, 14}).Count(&count)
+	if count != 0 {
+		t.Errorf("refused clones left %d branches", count)
+	}
+}
+
+func TestBranchPanelCodePerReceiver(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	for _, id := range []string{"r1", "r2"} {
+		if err := db.Create(&models.Receiver{ID: id, Token: id}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	s := &BranchService{DB: db, Authz: &Authorizer{DB: db}}
+	create := func(code int, receiver string) (*BranchTreeResponse, error) {
+		return s.Create(owner, CreateBranchRequest{Branch: BranchInput{Name: "Branch", Code: code, PanelCode: 5, LocationID: "north", ReceiverID: receiver}})
+	}
+
+	first, err := create(20, "r1")
+	if err != nil {
+		t.Fatalf("first branch: %v", err)
+	}
+	// همان کد پنل روی گیرنده دیگر و بدون گیرنده آزاد است
+	if _, err := create(21, "r2"); err != nil {
+		t.Errorf("same panel code on another receiver: %v", err)
+	}
+	other, err := create(22, "")
+	if err != nil {
+		t.Errorf("same panel code without a receiver: %v", err)
+	}
+	_, err = create(23, "r1")
+	wantStatus(t, "same panel code on the same receiver", err, 409)
+	_, err = create(23, "")
+	wantStatus(t, "same panel code on no receiver", err, 409)
+
+	receiver := "r1"
+	_, err = s.Update(owner, other.Data.Branch.ID, UpdateBranchRequest{ReceiverID: &receiver, Version: &other.Data.Branch.Version})
+	wantStatus(t, "moving onto a receiver that uses the panel code", err, 409)
+
+	// ایندکس یکتا حتی بدون بررسی سرویس جلوی تکرار را می‌گیرد
+	err = db.Create(&models.Branch{ID: "raw", Name: "Raw", Code: 24, PanelCode: 5, LocationID: "north", ReceiverID: "r1"}).Error
+	wantStatus(t, "insert past the service", uniqueConflict(db, err), 409)
+
+	// کد پنل شعبه حذف‌شده دوباره قابل استفاده است
+	if err := db.Delete(&models.Branch{}, "id = ?", first.Data.Branch.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create(25, "r1"); err != nil {
+		t.Errorf("panel code of a deleted branch: %v", err)
+	}
+}
+
+func TestBranchMainPartitionReassignment(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	s := &BranchService{DB: db, Authz: &Authorizer{DB: db}}
+
+	res, err := s.Create(owner, CreateBranchRequest{
+		Branch:     BranchInput{Name: "Vanak", Code: 30, PanelCode: 30, LocationID: "north"},
+		Partitions: []PartitionInput{{LocalID: 3, Label: "Three"}, {LocalID: 1, Label: "One"}, {LocalID: 2, Label: "Two"}},
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	branchID := res.Data.Branch.ID
+	version := res.Data.Branch.Version
+	partitions := map[int]string{}
+	for _, p := range res.Data.Partitions {
+		partitions[p.Partition.LocalID] = p.Partition.ID
+	}
+	// بدون انتخاب، اولین پارتیشن درخواست اصلی است
+	if res.Data.Branch.MainPartitionID != partitions[3] {
+		t.Fatalf("main partition %q, want partition 3", res.Data.Branch.MainPartitionID)
+	}
+	mainPartition := func() string {
+		t.Helper()
+		var branch models.Branch
+		if err := db.Where("id = ?", branchID).Take(&branch).Error; err != nil {
+			t.Fatal(err)
+		}
+		return branch.MainPartitionID
+	}
+
+	if err := db.Create(&models.Partition{ID: "other-partition", BranchID: "north-branch", LocalID: 1, Label: "Other"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	other := "other-partition"
+	_, err = s.Update(owner, branchID, UpdateBranchRequest{MainPartitionID: &other, Version: &version})
+	wantStatus(t, "main partition of another branch", err, 400)
+	empty := ""
+	_, err = s.Update(owner, branchID, UpdateBranchRequest{MainPartitionID: &empty, Version: &version})
+	wantStatus(t, "no main partition while there are partitions", err, 400)
+	second := partitions[2]
+	if _, err := s.Update(owner, branchID, UpdateBranchRequest{MainPartitionID: &second, Version: &version}); err != nil {
+		t.Fatalf("choosing partition 2: %v", err)
+	}
+
+	// با حذف پارتیشن اصلی، پارتیشن با کمترین شماره جای آن را می‌گیرد
+	if _, err := s.DeletePartition(owner, partitions[2]); err != nil {
+		t.Fatalf("DeletePartition: %v", err)
+	}
+	if got := mainPartition(); got != partitions[1] {
+		t.Errorf("main partition %q after deleting it, want partition 1 %q", got, partitions[1])
+	}
+	// حذف پارتیشن دیگر پارتیشن اصلی را تغییر نمی‌دهد
+	if _, err := s.DeletePartition(owner, partitions[3]); err != nil {
+		t.Fatalf("DeletePartition: %v", err)
+	}
+	if got := mainPartition(); got != partitions[1] {
+		t.Errorf("main partition %q after deleting another one, want %q", got, partitions[1])
+	}
+	if _, err := s.DeletePartition(owner, partitions[1]); err != nil {
+		t.Fatalf("DeletePartition: %v", err)
+	}
+	if got := mainPartition(); got != "" {
+		t.Errorf("main partition %q without partitions, want none", got)
+	}
+}
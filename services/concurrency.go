@@ -0,0 +1,40 @@
+package services
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// updateVersioned is the compare-and-swap update used by every edit coming from the UI.
+// updates are applied to the record with id only while its version still equals version,
+// and the version is incremented in the same statement. record (a pointer to the model) is
+// reloaded afterwards; on a version mismatch it holds the stored row and a ConflictError
+// is returned.
+func updateVersioned(db *gorm.DB, record any, id string, version *int, updates map[string]interface{}) error {
+	if version == nil {
+		return ErrVersionRequired
+	}
+
+	updates["version"] = gorm.Expr("version + 1")
+	result := db.Model(record).Where("id = ? AND version = ?", id, *version).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if err := db.Where("id = ?", id).First(record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &ServiceError{StatusCode: 404, Message: "record not found"}
+		}
+		return err
+	}
+	// رکورد پس از خواندن کلاینت تغییر کرده است
+	if result.RowsAffected == 0 {
+		return &ConflictError{
+			StatusCode: 409,
+			Message:    "the record was changed by someone else, reload and try again",
+			Current:    record,
+		}
+	}
+	return nil
+}
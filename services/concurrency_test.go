@@ -0,0 +1,71 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+)
+
+func TestUpdateVersioned(t *testing.T) {
+	db := newTestDB(t)
+	stored := models.AlarmCategory{ID: uuid.NewString(), Code: 1, Label: "first"}
+	if err := db.Create(&stored).Error; err != nil {
+		t.Fatal(err)
+	}
+	version := func(v int) *int { return &v }
+
+	tests := []struct {
+		name        string
+		id          string
+		version     *int
+		label       string
+		wantErr     error
+		wantStatus  int
+		wantLabel   string
+		wantVersion int
+	}{
+		{name: "current version", id: stored.ID, version: version(0), label: "second", wantLabel: "second", wantVersion: 1},
+		{name: "stale version", id: stored.ID, version: version(0), label: "stale", wantStatus: 409, wantLabel: "second", wantVersion: 1},
+		{name: "next version", id: stored.ID, version: version(1), label: "third", wantLabel: "third", wantVersion: 2},
+		{name: "no version", id: stored.ID, label: "none", wantErr: ErrVersionRequired},
+		{name: "unknown record", id: uuid.NewString(), version: version(0), label: "missing", wantStatus: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var record models.AlarmCategory
+			err := updateVersioned(db, &record, tt.id, tt.version, map[string]interface{}{"label": tt.label})
+
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			case tt.wantStatus == 409:
+				var conflict *ConflictError
+				if !errors.As(err, &conflict) {
+					t.Fatalf("err = %v, want a ConflictError", err)
+				}
+				current, ok := conflict.Current.(*models.AlarmCategory)
+				if !ok || current.Label != tt.wantLabel || current.Version != tt.wantVersion {
+					t.Errorf("conflict carries %+v, want the stored record", conflict.Current)
+				}
+				return
+			case tt.wantStatus != 0:
+				var serviceErr *ServiceError
+				if !errors.As(err, &serviceErr) || serviceErr.StatusCode != tt.wantStatus {
+					t.Fatalf("err = %v, want status %d", err, tt.wantStatus)
+				}
+				return
+			case err != nil:
+				t.Fatalf("err = %v", err)
+			}
+			if record.Label != tt.wantLabel || record.Version != tt.wantVersion {
+				t.Errorf("record = %q v%d, want %q v%d", record.Label, record.Version, tt.wantLabel, tt.wantVersion)
+			}
+		})
+	}
+}
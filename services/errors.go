@@ -0,0 +1,91 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ServiceError is an error with an HTTP-like status code, returned to the frontend as
+// {statusCode, message} through FormatError.
+type ServiceError struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+func (e *ServiceError) Error() string {
+	return e.Message
+}
+
+// ConflictError is returned when a record changed after the client read it; Current holds
+// the stored record so the UI can reconcile.
+type ConflictError struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Current    any    `json:"current"`
+}
+
+func (e *ConflictError) Error() string {
+	return e.Message
+}
+
+var (
+	ErrUnauthorized = &ServiceError{StatusCode: 401, Message: "authentication required"}
+	ErrForbidden    = &ServiceError{StatusCode: 403, Message: "you do not have permission to perform this action"}
+
+	ErrPasswordChangeRequired      = &ServiceError{StatusCode: 403, Message: "password change required"}
+	ErrTwoFactorEnrollmentRequired = &ServiceError{StatusCode: 403, Message: "two-factor enrollment required"}
+
+	ErrVersionRequired = &ServiceError{StatusCode: 400, Message: "version is required"}
+)
+
+// FormatError is used as the Wails ErrorFormatter so every bound method rejects with the same shape
+func FormatError(err error) any {
+	var serviceErr *ServiceError
+	if errors.As(err, &serviceErr) {
+		return serviceErr
+	}
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return conflictErr
+	}
+	return &ServiceError{StatusCode: 400, Message: err.Error()}
+}
+
+// uniqueConflicts are the unique indexes of the branch hierarchy and the 409 message their
+// violation is reported with. PostgreSQL names the index in its error, SQLite the columns of
+// an index on plain columns.
+var uniqueConflicts = []struct {
+	matches []string
+	message string
+}{
+	{[]string{"idx_Branch_receiverId_panelCode"}, "the panel code is already used on this receiver"},
+	{[]string{"idx_Partition_branchId_localId", "Partition.branchId, Partition.localId"}, "the partition number already exists in this branch"},
+	{[]string{"idx_Zone_partitionId_localId", "Zone.partitionId, Zone.localId"}, "the zone number already exists in this partition"},
+	{[]string{"idx_Employee_branchId_localId", "Employee.branchId, Employee.localId"}, "the employee number already exists in this branch"},
+}
+
+// uniqueConflict returns err as a 409 when it is a unique index violation of db, so a write
+// that passed the checks at the same time as another one is refused like the checks would
+// have; other errors are returned as they are.
+func uniqueConflict(db *gorm.DB, err error) error {
+	translator, ok := db.Dialector.(gorm.ErrorTranslator)
+	if err == nil || !ok {
+		return err
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if !errors.Is(translator.Translate(e), gorm.ErrDuplicatedKey) {
+			continue
+		}
+		for _, conflict := range uniqueConflicts {
+			for _, match := range conflict.matches {
+				if strings.Contains(e.Error(), match) {
+					return &ServiceError{StatusCode: 409, Message: conflict.message}
+				}
+			}
+		}
+		return &ServiceError{StatusCode: 409, Message: "the record already exists"}
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"monitoring-with-go/models"
+)
+
+func TestUniqueConflict(t *testing.T) {
+	db := newTestDB(t)
+	rows := []any{
+		&models.Branch{ID: "branch", Code: 1, PanelCode: 7},
+		&models.Partition{ID: "partition", BranchID: "branch", LocalID: 1},
+		&models.Zone{ID: "zone", PartitionID: "partition", LocalID: 1},
+		&models.Employee{ID: "employee", BranchID: "branch", LocalID: 1},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+
+	cases := []struct {
+		name    string
+		row     any
+		message string
+	}{
+		{"panel code without receiver", &models.Branch{ID: "branch-2", Code: 2, PanelCode: 7}, "the panel code is already used on this receiver"},
+		{"partition", &models.Partition{ID: "partition-2", BranchID: "branch", LocalID: 1}, "the partition number already exists in this branch"},
+		{"zone", &models.Zone{ID: "zone-2", PartitionID: "partition", LocalID: 1}, "the zone number already exists in this partition"},
+		{"employee", &models.Employee{ID: "employee-2", BranchID: "branch", LocalID: 1}, "the employee number already exists in this branch"},
+	}
+	for _, c := range cases {
+		err := uniqueConflict(db, db.Create(c.row).Error)
+		var serviceErr *ServiceError
+		if !errors.As(err, &serviceErr) || serviceErr.StatusCode != 409 || serviceErr.Message != c.message {
+			t.Errorf("%s: %v, want 409 %q", c.name, err, c.message)
+		}
+	}
+
+	// شماره‌های ردیف حذف‌شده دوباره قابل استفاده‌اند
+	if err := db.Delete(&models.Employee{}, "id = ?", "employee").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&models.Employee{ID: "employee-3", BranchID: "branch", LocalID: 1}).Error; err != nil {
+		t.Errorf("reuse the number of a deleted employee: %v", err)
+	}
+
+	other := errors.New("other")
+	if err := uniqueConflict(db, other); err != other {
+		t.Errorf("uniqueConflict changed an unrelated error to %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// EventArchiveService moves events older than event.retentionDays into the monthly archive
+// files in Dir and lets them be browsed there without bringing them back.
+type EventArchiveService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+	Dir   string
+
+	mu sync.Mutex
+}
+
+// EventArchiveRequest browses the archive FileName with the filter and cursor paging of the
+// live event list
+type EventArchiveRequest struct {
+	FileName string `json:"fileName"`
+	EventFilter
+}
+
+type EventArchiveListResponse struct {
+	StatusCode int      `json:"statusCode"`
+	Message    string   `json:"message"`
+	Data       []string `json:"data"`
+}
+
+type EventArchiveRunResponse struct {
+	StatusCode int            `json:"statusCode"`
+	Message    string         `json:"message"`
+	Data       map[string]int `json:"data"`
+}
+
+// StartArchiveScheduler archives the expired events once an hour; event.retentionDays of zero
+// keeps every event in the live database. It is not a method so the frontend cannot call it.
+func StartArchiveScheduler(s *EventArchiveService) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			if _, err := s.archive(); err != nil {
+				log.Printf("❌ Event archiving failed: %v", err)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (s *EventArchiveService) archive() (map[string]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	days := readIntSetting(s.DB, "event.retentionDays", 90)
+	if days <= 0 {
+		return map[string]int{}, nil
+	}
+	// مهلت از ابتدای روز حساب می‌شود تا رویدادهای یک روز با هم بایگانی شوند
+	now := time.Now()
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, -days)
+
+	moved, err := database.ArchiveEvents(s.DB, s.Dir, cutoff)
+	for name, count := range moved {
+		log.Printf("📦 %d events archived to %s", count, name)
+	}
+	return moved, err
+}
+
+// Archive runs the retention now instead of waiting for the schedule
+func (s *EventArchiveService) Archive(token string) (*EventArchiveRunResponse, error) {
+	if _, err := s.Authz.Authorize(token, "EventArchiveService.Archive"); err != nil {
+		return nil, err
+	}
+
+	moved, err := s.archive()
+	if err != nil {
+		return nil, err
+	}
+	return &EventArchiveRunResponse{
+		StatusCode: 200,
+		Message:    "Events archived successfully",
+		Data:       moved,
+	}, nil
+}
+
+// FindAll lists the archive files, newest month first
+func (s *EventArchiveService) FindAll(token string) (*EventArchiveListResponse, error) {
+	if _, err := s.Authz.Authorize(token, "EventArchiveService.FindAll"); err != nil {
+		return nil, err
+	}
+
+	names, err := database.ListEventArchives(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return &EventArchiveListResponse{
+		StatusCode: 200,
+		Message:    "Archives fetched successfully",
+		Data:       names,
+	}, nil
+}
+
+// FindEvents returns a page of the events inside an archive that match the filter, limited to
+// the caller's scope. Branches, alarms and the other rows are shown as they were archived.
+func (s *EventArchiveService) FindEvents(token string, req EventArchiveRequest) (*EventPageResponse, error) {
+	caller, err := s.Authz.Authorize(token, "EventArchiveService.FindEvents")
+	if err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	archive, err := database.OpenEventArchive(s.Dir, strings.TrimSpace(req.FileName))
+	if err != nil {
+		return nil, &ServiceError{StatusCode: 404, Message: err.Error()}
+	}
+	defer database.CloseEventArchive(archive)
+
+	page, err := findEventPage(s.DB, scope.Events(archive.Model(&models.Event{})), req.EventFilter)
+	if err != nil {
+		return nil, err
+	}
+	return &EventPageResponse{
+		StatusCode: 200,
+		Message:    "Archived events fetched successfully",
+		Data:       *page,
+	}, nil
+}
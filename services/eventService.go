@@ -0,0 +1,372 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// EventService queries the stored events, newest first, a page at a time. Pages follow a
+// cursor on (createdAt, id) instead of an offset, so paging stays fast on millions of rows
+// and does not skip or repeat events while new ones arrive.
+type EventService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+// EventFilter narrows the events; empty fields are ignored. StartDate and EndDate are
+// Gregorian or Jalali dates and both ends are inclusive; StartTime and EndTime (HH:MM) narrow
+// the first and last day. LocationID includes every location below it. Cursor is the
+// NextCursor of the previous page and must be used with the same filter and Order.
+type EventFilter struct {
+	StartDate          string `json:"startDate"`
+	EndDate            string `json:"endDate"`
+	StartTime          string `json:"startTime"`
+	EndTime            string `json:"endTime"`
+	BranchID           string `json:"branchId"`
+	LocationID         string `json:"locationId"`
+	AlarmCategoryID    string `json:"alarmCategoryId"`
+	Priority           string `json:"priority"`
+	ConfirmationStatus string `json:"confirmationStatus"`
+	ZoneID             string `json:"zoneId"`
+	Order              string `json:"order"` // desc (پیش‌فرض) یا asc
+	Cursor             string `json:"cursor"`
+	Limit              int    `json:"limit"`
+	WithTotal          bool   `json:"withTotal"`
+}
+
+// EventRow is an event with the labels of the rows it points to, read in the same query
+type EventRow struct {
+	models.Event
+	BranchName      string               `gorm:"column:branchName" json:"branchName"`
+	BranchCode      int                  `gorm:"column:branchCode" json:"branchCode"`
+	LocationID      string               `gorm:"column:locationId" json:"locationId"`
+	LocationLabel   string               `gorm:"column:locationLabel" json:"locationLabel"`
+	AlarmLabel      string               `gorm:"column:alarmLabel" json:"alarmLabel"`
+	AlarmCode       int                  `gorm:"column:alarmCode" json:"alarmCode"`
+	AlarmCategoryID string               `gorm:"column:alarmCategoryId" json:"alarmCategoryId"`
+	CategoryLabel   string               `gorm:"column:categoryLabel" json:"categoryLabel"`
+	Priority        models.PriorityLevel `gorm:"column:priority" json:"priority"`
+	ZoneLabel       string               `gorm:"column:zoneLabel" json:"zoneLabel"`
+	PartitionLabel  string               `gorm:"column:partitionLabel" json:"partitionLabel"`
+	EmployeeName    string               `gorm:"column:employeeName" json:"employeeName"`
+	EmployeeLast    string               `gorm:"column:employeeLastName" json:"employeeLastName"`
+}
+
+// EventPage is one page of events; Total is only counted when the filter asks for it
+type EventPage struct {
+	Events     []EventRow `json:"events"`
+	NextCursor string     `json:"nextCursor"`
+	HasMore    bool       `json:"hasMore"`
+	Total      *int64     `json:"total,omitempty"`
+}
+
+type EventPageResponse struct {
+	StatusCode int       `json:"statusCode"`
+	Message    string    `json:"message"`
+	Data       EventPage `json:"data"`
+}
+
+// eventRowColumns are read next to the event; deleted rows still give their labels
+const eventRowColumns = `"Event".*,
+	"Branch".name AS "branchName", "Branch".code AS "branchCode",
+	"Location".id AS "locationId", "Location".label AS "locationLabel",
+	"Alarm".label AS "alarmLabel", "Alarm".code AS "alarmCode",
+	"AlarmCategory".id AS "alarmCategoryId", "AlarmCategory".label AS "categoryLabel", "AlarmCategory".priority AS priority,
+	"Zone".label AS "zoneLabel", "Partition".label AS "partitionLabel",
+	"Employee".name AS "employeeName", "Employee"."lastName" AS "employeeLastName"`
+
+// FindAll returns a page of the events inside the caller's scope that match filter
+func (s *EventService) FindAll(token string, filter EventFilter) (*EventPageResponse, error) {
+	caller, err := s.Authz.Authorize(token, "EventService.FindAll")
+	if err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	page, err := findEventPage(s.DB, scope.Events(s.DB.Model(&models.Event{})), filter)
+	if err != nil {
+		return nil, err
+	}
+	return &EventPageResponse{
+		StatusCode: 200,
+		Message:    "Events fetched successfully",
+		Data:       *page,
+	}, nil
+}
+
+// findEventPage reads the page of filter from base, a query on "Event" already limited to the
+// caller's scope. The live events and the archives are browsed with it; db is the live
+// database, which resolves the location filter.
+func findEventPage(db *gorm.DB, base *gorm.DB, filter EventFilter) (*EventPage, error) {
+	_, limit := normalizePage(1, filter.Limit)
+
+	descending, err := eventOrder(filter.Order)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := filterEvents(db, base, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := EventPage{Events: []EventRow{}}
+	if filter.WithTotal {
+		var total int64
+		if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	if filter.Cursor != "" {
+		createdAt, id, err := decodeEventCursor(filter.Cursor, descending)
+		if err != nil {
+			return nil, err
+		}
+		query = afterEventCursor(query, createdAt, id, descending)
+	}
+
+	// یک ردیف بیشتر خوانده می‌شود تا وجود صفحه بعد معلوم شود
+	err = withEventLabels(query, descending).
+		Limit(limit + 1).
+		Scan(&page.Events).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page.Events) > limit {
+		page.Events = page.Events[:limit]
+		page.HasMore = true
+		last := page.Events[limit-1]
+		page.NextCursor = encodeEventCursor(last.CreatedAt, last.ID, descending)
+	}
+	return &page, nil
+}
+
+// eventOrder reads the order of a filter; newest first unless it is asc
+func eventOrder(order string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(order)) {
+	case "", "desc":
+		return true, nil
+	case "asc":
+		return false, nil
+	}
+	return false, &ServiceError{StatusCode: 400, Message: "order must be asc or desc"}
+}
+
+// withEventLabels selects eventRowColumns, ordered on (createdAt, id)
+func withEventLabels(query *gorm.DB, descending bool) *gorm.DB {
+	direction := "DESC"
+	if !descending {
+		direction = "ASC"
+	}
+	return query.
+		Select(eventRowColumns).
+		Joins(`LEFT JOIN "Branch" ON "Branch".id = "Event"."branchId"`).
+		Joins(`LEFT JOIN "Location" ON "Location".id = "Branch"."locationId"`).
+		Joins(`LEFT JOIN "Alarm" ON "Alarm".id = "Event"."alarmId"`).
+		Joins(`LEFT JOIN "AlarmCategory" ON "AlarmCategory".id = "Alarm"."categoryId"`).
+		Joins(`LEFT JOIN "Zone" ON "Zone".id = "Event"."zoneId"`).
+		Joins(`LEFT JOIN "Partition" ON "Partition".id = "Event"."partitionId"`).
+		Joins(`LEFT JOIN "Employee" ON "Employee".id = "Event"."employeeId"`).
+		Order(fmt.Sprintf(`"Event"."createdAt" %s, "Event".id %s`, direction, direction))
+}
+
+// afterEventCursor keeps the events that come after (createdAt, id) in the order
+func afterEventCursor(query *gorm.DB, createdAt time.Time, id string, descending bool) *gorm.DB {
+	if descending {
+		return query.Where(`("Event"."createdAt" < ? OR ("Event"."createdAt" = ? AND "Event".id < ?))`, createdAt, createdAt, id)
+	}
+	return query.Where(`("Event"."createdAt" > ? OR ("Event"."createdAt" = ? AND "Event".id > ?))`, createdAt, createdAt, id)
+}
+
+// filterEvents applies the fields of filter to a query on "Event"
+func filterEvents(db *gorm.DB, query *gorm.DB, filter EventFilter) (*gorm.DB, error) {
+	start, end, err := parseDayRange(filter.StartDate, filter.EndDate, time.Local)
+	if err != nil {
+		return nil, err
+	}
+	if start != nil {
+		if filter.StartTime != "" {
+			hour, minute, err := parseClock(filter.StartTime)
+			if err != nil {
+				return nil, &ServiceError{StatusCode: 400, Message: "invalid start time"}
+			}
+			*start = time.Date(start.Year(), start.Month(), start.Day(), hour, minute, 0, 0, time.Local)
+		}
+		query = query.Where(`"Event"."createdAt" >= ?`, *start)
+	}
+	if end != nil {
+		if filter.EndTime != "" {
+			hour, minute, err := parseClock(filter.EndTime)
+			if err != nil {
+				return nil, &ServiceError{StatusCode: 400, Message: "invalid end time"}
+			}
+			// end روز بعد است؛ دقیقه پایانی هم جزو بازه حساب می‌شود
+			*end = time.Date(end.Year(), end.Month(), end.Day()-1, hour, minute+1, 0, 0, time.Local)
+		}
+		query = query.Where(`"Event"."createdAt" < ?`, *end)
+	}
+
+	if filter.BranchID != "" {
+		query = query.Where(`"Event"."branchId" = ?`, filter.BranchID)
+	}
+	if filter.LocationID != "" {
+		locations, err := locationSubtree(db, []string{filter.LocationID})
+		if err != nil {
+			return nil, err
+		}
+		if len(locations) == 0 {
+			locations = []string{""}
+		}
+		query = query.Where(`"Event"."branchId" IN (SELECT id FROM "Branch" WHERE "locationId" IN ?)`, locations)
+	}
+	if filter.AlarmCategoryID != "" {
+		query = query.Where(`"Event"."alarmId" IN (SELECT id FROM "Alarm" WHERE "categoryId" = ?)`, filter.AlarmCategoryID)
+	}
+	if filter.Priority != "" {
+		query = query.Where(`"Event"."alarmId" IN (SELECT a.id FROM "Alarm" a JOIN "AlarmCategory" c ON c.id = a."categoryId" WHERE c.priority = ?)`,
+			strings.ToUpper(filter.Priority))
+	}
+	if filter.ConfirmationStatus != "" {
+		query = query.Where(`"Event"."confirmationStatus" = ?`, filter.ConfirmationStatus)
+	}
+	if filter.ZoneID != "" {
+		query = query.Where(`"Event"."zoneId" = ?`, filter.ZoneID)
+	}
+	return query, nil
+}
+
+// EventVersion names an event and the version the client read it at
+type EventVersion struct {
+	ID      string `json:"id"`
+	Version *int   `json:"version"`
+}
+
+// EventConfirmRequest confirms events; FalseAlarm records that the operator found them to
+// be false alarms
+type EventConfirmRequest struct {
+	Events     []EventVersion `json:"events"`
+	FalseAlarm bool           `json:"falseAlarm"`
+}
+
+type EventConfirmResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Data       int64  `json:"data"`
+}
+
+// Confirm marks the given events as confirmed now, all or none. An event outside the caller's
+// scope is not found; one that changed since the client read it, or was already confirmed,
+// fails with a ConflictError holding the stored event.
+func (s *EventService) Confirm(token string, req EventConfirmRequest) (*EventConfirmResponse, error) {
+	caller, err := s.Authz.Authorize(token, "EventService.Confirm")
+	if err != nil {
+		return nil, err
+	}
+	if len(req.Events) == 0 {
+		return nil, &ServiceError{StatusCode: 400, Message: "no events given"}
+	}
+	for _, event := range req.Events {
+		if event.Version == nil {
+			return nil, ErrVersionRequired
+		}
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		for _, confirm := range req.Events {
+			var event models.Event
+			if err := scope.Events(tx.Model(&models.Event{})).Where(`"Event".id = ?`, confirm.ID).First(&event).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return &ServiceError{StatusCode: 404, Message: fmt.Sprintf("event %s not found", confirm.ID)}
+				}
+				return err
+			}
+			// رویداد تاییدشده دوباره تایید نمی‌شود تا زمان اولین تایید بماند
+			if event.ConfirmationStatus != "Unconfirmed" {
+				return &ConflictError{StatusCode: 409, Message: "the event is already confirmed", Current: &event}
+			}
+			err := updateVersioned(tx, &event, confirm.ID, confirm.Version, map[string]interface{}{
+				"confirmationStatus": "Confirmed",
+				"confirmedAt":        now,
+				"falseAlarm":         req.FalseAlarm,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &EventConfirmResponse{
+		StatusCode: 200,
+		Message:    "Events confirmed successfully",
+		Data:       int64(len(req.Events)),
+	}, nil
+}
+
+// parseClock reads an HH:MM time of day
+func parseClock(value string) (int, int, error) {
+	parts := strings.Split(persianDigits.Replace(strings.TrimSpace(value)), ":")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	hour, err := strconv.Atoi(parts[0])
+	if err != nil || hour < 0 || hour > 23 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	minute, err := strconv.Atoi(parts[1])
+	if err != nil || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q", value)
+	}
+	return hour, minute, nil
+}
+
+// encodeEventCursor writes the position after an event. The time keeps its offset so it is
+// compared exactly as stored.
+func encodeEventCursor(createdAt time.Time, id string, descending bool) string {
+	raw := cursorOrder(descending) + "|" + createdAt.Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func cursorOrder(descending bool) string {
+	if descending {
+		return "desc"
+	}
+	return "asc"
+}
+
+func decodeEventCursor(cursor string, descending bool) (time.Time, string, error) {
+	invalid := &ServiceError{StatusCode: 400, Message: "invalid cursor"}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 || parts[0] != cursorOrder(descending) {
+		return time.Time{}, "", invalid
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return time.Time{}, "", invalid
+	}
+	return createdAt, parts[2], nil
+}
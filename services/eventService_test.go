@@ -0,0 +1,154 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+func TestEventCursor(t *testing.T) {
+	createdAt := time.Date(2024, 3, 20, 8, 15, 30, 123456789, time.UTC)
+	for _, descending := range []bool{true, false} {
+		cursor := encodeEventCursor(createdAt, "event|1", descending)
+		gotAt, gotID, err := decodeEventCursor(cursor, descending)
+		if err != nil {
+			t.Fatalf("decode %s cursor: %v", cursorOrder(descending), err)
+		}
+		if !gotAt.Equal(createdAt) || gotID != "event|1" {
+			t.Errorf("%s cursor = (%s, %q), want (%s, %q)", cursorOrder(descending), gotAt, gotID, createdAt, "event|1")
+		}
+		// مکان‌نما فقط با همان ترتیبی که ساخته شده پذیرفته می‌شود
+		if _, _, err := decodeEventCursor(cursor, !descending); err == nil {
+			t.Errorf("%s cursor was accepted for the other order", cursorOrder(descending))
+		}
+	}
+
+	for _, cursor := range []string{"not base64!", "ZGVzYw", "ZGVzY3x5ZXN0ZXJkYXl8YQ"} {
+		if _, _, err := decodeEventCursor(cursor, true); err == nil {
+			t.Errorf("decodeEventCursor(%q) was accepted", cursor)
+		}
+	}
+}
+
+func TestFindEventPageFollowsCursor(t *testing.T) {
+	db := newTestDB(t)
+	base := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var events []models.Event
+	for i := 0; i < 7; i++ {
+		// رویدادهای هم‌زمان با id از هم جدا می‌شوند
+		events = append(events, models.Event{
+			ID:                 fmt.Sprintf("event-%d", i),
+			DedupHash:          fmt.Sprintf("event-%d", i),
+			ConfirmationStatus: "Unconfirmed",
+			CreatedAt:          base.Add(time.Duration(i/3) * time.Minute),
+		})
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("create events: %v", err)
+	}
+
+	for _, order := range []string{"desc", "asc"} {
+		filter := EventFilter{Order: order, Limit: 2}
+		var seen []string
+		for pages := 0; ; pages++ {
+			if pages > len(events) {
+				t.Fatalf("%s paging did not end", order)
+			}
+			page, err := findEventPage(db, db.Model(&models.Event{}), filter)
+			if err != nil {
+				t.Fatalf("%s page: %v", order, err)
+			}
+			for _, row := range page.Events {
+				seen = append(seen, row.ID)
+			}
+			if !page.HasMore {
+				break
+			}
+			filter.Cursor = page.NextCursor
+		}
+
+		if len(seen) != len(events) {
+			t.Fatalf("%s paging returned %v", order, seen)
+		}
+		for i, id := range seen {
+			want := events[i].ID
+			if order == "desc" {
+				want = events[len(events)-1-i].ID
+			}
+			if id != want {
+				t.Fatalf("%s paging returned %v", order, seen)
+			}
+		}
+	}
+}
+
+func TestConfirmChecksVersions(t *testing.T) {
+	db := newTestDB(t)
+	owner := models.User{ID: "owner", Username: "owner", Password: "-", Type: "OWNER", Status: models.UserStatusOffline}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatalf("create owner: %v", err)
+	}
+	token, err := newSession(db, owner.ID, "", "test")
+	if err != nil {
+		t.Fatalf("open session: %v", err)
+	}
+	events := []models.Event{
+		{ID: "first", DedupHash: "first", ConfirmationStatus: "Unconfirmed", Version: 2},
+		{ID: "second", DedupHash: "second", ConfirmationStatus: "Unconfirmed", Version: 0},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("create events: %v", err)
+	}
+	s := &EventService{DB: db, Authz: &Authorizer{DB: db}}
+	version := func(v int) *int { return &v }
+	confirmed := func() int64 {
+		var count int64
+		db.Model(&models.Event{}).Where(`"confirmationStatus" = ?`, "Confirmed").Count(&count)
+		return count
+	}
+
+	_, err = s.Confirm(token, EventConfirmRequest{Events: []EventVersion{{ID: "first"}}})
+	if !errors.Is(err, ErrVersionRequired) {
+		t.Errorf("confirm without a version: %v, want ErrVersionRequired", err)
+	}
+
+	// یک نسخه قدیمی کل درخواست را رد می‌کند
+	_, err = s.Confirm(token, EventConfirmRequest{Events: []EventVersion{{ID: "second", Version: version(0)}, {ID: "first", Version: version(1)}}})
+	var conflict *ConflictError
+	if !errors.As(err, &conflict) {
+		t.Fatalf("confirm with a stale version: %v, want a ConflictError", err)
+	}
+	if current, ok := conflict.Current.(*models.Event); !ok || current.ID != "first" || current.Version != 2 {
+		t.Errorf("conflict holds %+v, want event first at version 2", conflict.Current)
+	}
+	if n := confirmed(); n != 0 {
+		t.Errorf("%d events confirmed by a refused request", n)
+	}
+
+	res, err := s.Confirm(token, EventConfirmRequest{Events: []EventVersion{{ID: "second", Version: version(0)}, {ID: "first", Version: version(2)}}, FalseAlarm: true})
+	if err != nil {
+		t.Fatalf("confirm: %v", err)
+	}
+	if res.Data != 2 || confirmed() != 2 {
+		t.Errorf("confirmed %d events, %d stored, want 2", res.Data, confirmed())
+	}
+	var first models.Event
+	db.Where("id = ?", "first").Take(&first)
+	if first.Version != 3 || first.ConfirmedAt == nil || !first.FalseAlarm {
+		t.Errorf("confirmed event = version %d, confirmedAt %v, falseAlarm %v", first.Version, first.ConfirmedAt, first.FalseAlarm)
+	}
+
+	_, err = s.Confirm(token, EventConfirmRequest{Events: []EventVersion{{ID: "first", Version: version(3)}}})
+	if !errors.As(err, &conflict) {
+		t.Errorf("confirming a confirmed event: %v, want a ConflictError", err)
+	}
+
+	_, err = s.Confirm(token, EventConfirmRequest{Events: []EventVersion{{ID: "missing", Version: version(0)}}})
+	var notFound *ServiceError
+	if !errors.As(err, &notFound) || notFound.StatusCode != 404 {
+		t.Errorf("confirming a missing event: %v, want 404", err)
+	}
+}
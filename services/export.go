@@ -0,0 +1,601 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-with-go/export"
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// ExportProgressName is the frontend event carrying the state of a running export
+const ExportProgressName = "exports:progress"
+
+// What an export writes
+const (
+	ExportEvents   = "events"
+	ExportReport   = "report"
+	ExportBranches = "branches"
+)
+
+// States of an export job
+const (
+	ExportRunning   = "running"
+	ExportDone      = "done"
+	ExportFailed    = "failed"
+	ExportCancelled = "cancelled"
+)
+
+// exportBatch is the number of rows read per query; short reads keep the sqlite database
+// writable for the UDP listener while a large export runs
+const exportBatch = 1000
+
+// ExportService writes event queries, event reports and branch lists to XLSX, CSV or PDF
+// files. Exports run in the background; their progress is pushed to the window as
+// ExportProgressName events and can be polled with FindJobs.
+type ExportService struct {
+	DB      *gorm.DB
+	Authz   *Authorizer
+	Reports *EventReportService
+	Dir     string // پوشه خروجی وقتی کاربر پوشه‌ای انتخاب نکرده
+
+	ctx  context.Context
+	mu   sync.Mutex
+	jobs map[string]*ExportJob
+}
+
+// ExportRequest selects what to export; only the filter of Source is used. An empty
+// Directory writes to the default export directory, an empty FileName names the file after
+// the source and the time.
+type ExportRequest struct {
+	Source    string             `json:"source"`
+	Format    string             `json:"format"`
+	Directory string             `json:"directory"`
+	FileName  string             `json:"fileName"`
+	Events    EventFilter        `json:"events"`
+	Report    EventReportRequest `json:"report"`
+	Branches  BranchExportFilter `json:"branches"`
+}
+
+// BranchExportFilter limits a branch export to a location and the locations under it
+type BranchExportFilter struct {
+	LocationID string `json:"locationId"`
+}
+
+// ExportJob is one export. Total is the number of rows to write, Written how many are done.
+type ExportJob struct {
+	ID         string     `json:"id"`
+	Source     string     `json:"source"`
+	Format     string     `json:"format"`
+	Path       string     `json:"path"`
+	Status     string     `json:"status"`
+	Written    int64      `json:"written"`
+	Total      int64      `json:"total"`
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"startedAt"`
+	FinishedAt *time.Time `json:"finishedAt"`
+
+	userID string
+	cancel context.CancelFunc
+}
+
+type ExportJobResponse struct {
+	StatusCode int       `json:"statusCode"`
+	Message    string    `json:"message"`
+	Data       ExportJob `json:"data"`
+}
+
+type ExportJobListResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Message    string      `json:"message"`
+	Data       []ExportJob `json:"data"`
+}
+
+type ExportDirectoryResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Data       string `json:"data"`
+}
+
+// exportTable is a prepared export: the rows are produced by write once the job runs
+type exportTable struct {
+	title   string
+	columns []export.Column
+	total   int64
+	write   func(ctx context.Context, w export.Writer, progress func(int)) error
+}
+
+// StartExports keeps the Wails context used for the directory dialog and the progress events.
+// Like StartLiveEvents it is a function so the frontend cannot replace the context.
+func StartExports(ctx context.Context, s *ExportService) {
+	s.ctx = ctx
+}
+
+// ChooseDirectory asks the user for the directory of the next export; an empty Data means
+// the dialog was cancelled
+func (s *ExportService) ChooseDirectory(token string) (*ExportDirectoryResponse, error) {
+	if _, err := s.Authz.Authorize(token, "ExportService.ChooseDirectory"); err != nil {
+		return nil, err
+	}
+	if s.ctx == nil {
+		return nil, &ServiceError{StatusCode: 503, Message: "the window is not ready"}
+	}
+	dir, err := runtime.OpenDirectoryDialog(s.ctx, runtime.OpenDialogOptions{
+		Title:            "انتخاب پوشه خروجی",
+		DefaultDirectory: s.Dir,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &ExportDirectoryResponse{StatusCode: 200, Message: "Directory chosen", Data: dir}, nil
+}
+
+// Start checks the request, then writes the export in the background and returns its job
+func (s *ExportService) Start(token string, req ExportRequest) (*ExportJobResponse, error) {
+	caller, err := s.Authz.Authorize(token, "ExportService.Start")
+	if err != nil {
+		return nil, err
+	}
+	format := strings.ToLower(strings.TrimSpace(req.Format))
+	if !export.ValidFormat(format) {
+		return nil, &ServiceError{StatusCode: 400, Message: export.ErrUnknownFormat.Error()}
+	}
+
+	var table *exportTable
+	switch req.Source {
+	case ExportEvents:
+		if !caller.Can(models.PermissionRead, "Event", "") {
+			return nil, ErrForbidden
+		}
+		table, err = s.events(caller, req.Events)
+	case ExportReport:
+		if !caller.Can(models.PermissionRead, "Event", "") {
+			return nil, ErrForbidden
+		}
+		table, err = s.report(caller, req.Report)
+	case ExportBranches:
+		if !caller.Can(models.PermissionRead, "Branch", "") {
+			return nil, ErrForbidden
+		}
+		table, err = s.branches(caller, req.Branches)
+	default:
+		return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown export source %q", req.Source)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path, err := s.outputPath(req, format)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &ExportJob{
+		ID:        uuid.NewString(),
+		Source:    req.Source,
+		Format:    format,
+		Path:      path,
+		Status:    ExportRunning,
+		Total:     table.total,
+		StartedAt: time.Now(),
+		userID:    caller.User.ID,
+		cancel:    cancel,
+	}
+	s.mu.Lock()
+	if s.jobs == nil {
+		s.jobs = map[string]*ExportJob{}
+	}
+	s.pruneJobs()
+	s.jobs[job.ID] = job
+	snapshot := *job
+	s.mu.Unlock()
+
+	go s.run(ctx, job, table)
+	return &ExportJobResponse{StatusCode: 200, Message: "Export started", Data: snapshot}, nil
+}
+
+// FindJobs lists the caller's exports, newest first
+func (s *ExportService) FindJobs(token string) (*ExportJobListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "ExportService.FindJobs")
+	if err != nil {
+		return nil, err
+	}
+	jobs := []ExportJob{}
+	s.mu.Lock()
+	for _, job := range s.jobs {
+		if job.userID == caller.User.ID {
+			jobs = append(jobs, *job)
+		}
+	}
+	s.mu.Unlock()
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].StartedAt.After(jobs[j].StartedAt) })
+	return &ExportJobListResponse{StatusCode: 200, Message: "Exports fetched successfully", Data: jobs}, nil
+}
+
+// Cancel stops a running export of the caller; the partial file is removed
+func (s *ExportService) Cancel(token string, id string) (*ExportJobResponse, error) {
+	caller, err := s.Authz.Authorize(token, "ExportService.Cancel")
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	job, ok := s.jobs[id]
+	if !ok || job.userID != caller.User.ID {
+		s.mu.Unlock()
+		return nil, &ServiceError{StatusCode: 404, Message: "export not found"}
+	}
+	if job.Status == ExportRunning {
+		job.cancel()
+	}
+	snapshot := *job
+	s.mu.Unlock()
+	return &ExportJobResponse{StatusCode: 200, Message: "Export cancelled", Data: snapshot}, nil
+}
+
+// pruneJobs forgets the exports finished more than a day ago; s.mu must be held
+func (s *ExportService) pruneJobs() {
+	for id, job := range s.jobs {
+		if job.FinishedAt != nil && time.Since(*job.FinishedAt) > 24*time.Hour {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// outputPath picks a file in the requested directory that does not exist yet
+func (s *ExportService) outputPath(req ExportRequest, format string) (string, error) {
+	return exportPath(req.Directory, s.Dir, req.FileName, req.Source, format)
+}
+
+// exportPath picks a new file for format in dir, or in defaultDir when dir is empty. The file
+// is named fileName, or prefix and the time when fileName is empty; a number is added when
+// that file exists.
+func exportPath(dir, defaultDir, fileName, prefix, format string) (string, error) {
+	dir = strings.TrimSpace(dir)
+	if dir == "" {
+		dir = defaultDir
+	}
+	if !filepath.IsAbs(dir) {
+		return "", &ServiceError{StatusCode: 400, Message: "the export directory must be an absolute path"}
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", &ServiceError{StatusCode: 400, Message: "the export directory does not exist"}
+	}
+
+	// نام فایل فقط نام است و نمی‌تواند به پوشه دیگری اشاره کند
+	name := strings.TrimSpace(fileName)
+	name = strings.TrimSuffix(filepath.Base(strings.ReplaceAll(name, `\`, "/")), "."+format)
+	if name == "" || name == "." || name == "/" {
+		name = prefix + "-" + time.Now().Format("20060102-150405")
+	}
+	path := filepath.Join(dir, name+"."+format)
+	for i := 2; ; i++ {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if _, err := os.Stat(path + ".part"); errors.Is(err, os.ErrNotExist) {
+				return path, nil
+			}
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s-%d.%s", name, i, format))
+	}
+}
+
+// run writes the table next to its final path and moves it there once it is complete
+func (s *ExportService) run(ctx context.Context, job *ExportJob, table *exportTable) {
+	var reported time.Time
+	err := writeTableFile(job.Path, job.Format, table.title, table.columns, func(writer export.Writer) error {
+		return table.write(ctx, writer, func(rows int) {
+			s.mu.Lock()
+			job.Written += int64(rows)
+			s.mu.Unlock()
+			// پیشرفت حداکثر دو بار در ثانیه به پنجره فرستاده می‌شود
+			if time.Since(reported) > 500*time.Millisecond {
+				reported = time.Now()
+				s.emit(job)
+			}
+		})
+	})
+
+	s.mu.Lock()
+	now := time.Now()
+	job.FinishedAt = &now
+	switch {
+	case err == nil:
+		job.Status = ExportDone
+	case ctx.Err() != nil:
+		job.Status = ExportCancelled
+	default:
+		job.Status = ExportFailed
+		job.Error = err.Error()
+	}
+	job.cancel()
+	s.mu.Unlock()
+
+	if err != nil {
+		if job.Status == ExportFailed {
+			log.Printf("❌ Export %s failed: %v", job.Path, err)
+		}
+	} else {
+		log.Printf("📤 Export %s written (%d rows)", job.Path, job.Written)
+	}
+	s.emit(job)
+}
+
+// writeTableFile writes a table of format to path through fill. The file is written as
+// path.part and only renamed to path once it is complete; on an error it is removed.
+func writeTableFile(path, format, title string, columns []export.Column, fill func(export.Writer) error) error {
+	partial := path + ".part"
+	err := func() error {
+		file, err := os.Create(partial)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		writer, err := export.NewWriter(format, file, title, columns)
+		if err != nil {
+			return err
+		}
+		if err := fill(writer); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+		return os.Rename(partial, path)
+	}()
+	if err != nil {
+		os.Remove(partial)
+	}
+	return err
+}
+
+// emit sends the state of job to the window
+func (s *ExportService) emit(job *ExportJob) {
+	if s.ctx == nil {
+		return
+	}
+	s.mu.Lock()
+	snapshot := *job
+	s.mu.Unlock()
+	runtime.EventsEmit(s.ctx, ExportProgressName, snapshot)
+}
+
+var priorityLabels = map[models.PriorityLevel]string{
+	models.PriorityVeryHigh: "بسیار بالا",
+	models.PriorityHigh:     "بالا",
+	models.PriorityMedium:   "متوسط",
+	models.PriorityLow:      "کم",
+	"VERY_LOW":              "بسیار کم",
+	"NONE":                  "بدون اولویت",
+}
+
+// jalaliDateTime writes t in local time as a Jalali date and a clock
+func jalaliDateTime(t time.Time) string {
+	t = t.Local()
+	return formatJalali(t) + " " + t.Format("15:04:05")
+}
+
+// events prepares the export of the events matching filter, in its order
+func (s *ExportService) events(caller *Caller, filter EventFilter) (*exportTable, error) {
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	descending, err := eventOrder(filter.Order)
+	if err != nil {
+		return nil, err
+	}
+	query, err := filterEvents(s.DB, scope.Events(s.DB.Model(&models.Event{})), filter)
+	if err != nil {
+		return nil, err
+	}
+	table := &exportTable{
+		title: "رویدادها",
+		columns: []export.Column{
+			{Title: "تاریخ", Width: 12}, {Title: "زمان", Width: 10}, {Title: "نام شعبه", Width: 18},
+			{Title: "کد شعبه", Width: 9}, {Title: "مکان", Width: 14}, {Title: "رویداد", Width: 18},
+			{Title: "دسته", Width: 12}, {Title: "اولویت", Width: 10}, {Title: "پارتیشن", Width: 12},
+			{Title: "ورودی", Width: 12}, {Title: "کاربر شعبه", Width: 14}, {Title: "توضیحات", Width: 24},
+			{Title: "تاییدیه", Width: 10}, {Title: "زمان تایید", Width: 18}, {Title: "هشدار کاذب", Width: 10},
+		},
+	}
+	if err := query.Session(&gorm.Session{}).Count(&table.total).Error; err != nil {
+		return nil, err
+	}
+
+	table.write = func(ctx context.Context, w export.Writer, progress func(int)) error {
+		var last *EventRow
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			batch := query.Session(&gorm.Session{})
+			if last != nil {
+				batch = afterEventCursor(batch, last.CreatedAt, last.ID, descending)
+			}
+			var rows []EventRow
+			if err := withEventLabels(batch, descending).Limit(exportBatch).Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				status := "تایید شده"
+				if row.ConfirmationStatus == "Unconfirmed" {
+					status = "تایید نشده"
+				}
+				confirmedAt := ""
+				if row.ConfirmedAt != nil {
+					confirmedAt = jalaliDateTime(*row.ConfirmedAt)
+				}
+				createdAt := row.CreatedAt.Local()
+				err := w.WriteRow([]interface{}{
+					formatJalali(createdAt), createdAt.Format("15:04:05"), row.BranchName,
+					row.BranchCode, row.LocationLabel, row.AlarmLabel,
+					row.CategoryLabel, priorityLabels[row.Priority], row.PartitionLabel,
+					row.ZoneLabel, strings.TrimSpace(row.EmployeeName + " " + row.EmployeeLast), row.Description,
+					status, confirmedAt, row.FalseAlarm,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			progress(len(rows))
+			if len(rows) < exportBatch {
+				return nil
+			}
+			last = &rows[len(rows)-1]
+		}
+	}
+	return table, nil
+}
+
+var reportTitles = map[string]string{
+	ReportByBranch:   "گزارش رویدادها بر اساس شعبه",
+	ReportByLocation: "گزارش رویدادها بر اساس مکان",
+	ReportByCategory: "گزارش رویدادها بر اساس دسته هشدار",
+	ReportByHour:     "گزارش رویدادها بر اساس ساعت",
+	ReportByDay:      "گزارش رویدادها بر اساس روز",
+}
+
+// report prepares the export of an event report with its total as the last row
+func (s *ExportService) report(caller *Caller, req EventReportRequest) (*exportTable, error) {
+	report, err := s.Reports.report(caller, req)
+	if err != nil {
+		return nil, err
+	}
+	rows := append(append([]EventReportRow{}, report.Rows...), report.Total)
+	rows[len(rows)-1].Label = "جمع"
+
+	table := &exportTable{
+		title: reportTitles[req.GroupBy],
+		columns: []export.Column{
+			{Title: "گروه", Width: 24}, {Title: "کل رویدادها", Width: 12}, {Title: "تایید شده", Width: 12},
+			{Title: "تایید نشده", Width: 12}, {Title: "هشدار کاذب", Width: 12},
+			{Title: "درصد هشدار کاذب", Width: 14}, {Title: "میانگین زمان تایید (ثانیه)", Width: 20},
+		},
+		total: int64(len(rows)),
+	}
+	table.write = func(ctx context.Context, w export.Writer, progress func(int)) error {
+		for _, row := range rows {
+			label := row.Label
+			if label == "" {
+				label = "نامشخص"
+			}
+			var meanAck interface{}
+			if row.MeanAckSeconds != nil {
+				meanAck = math.Round(*row.MeanAckSeconds)
+			}
+			err := w.WriteRow([]interface{}{
+				label, row.Total, row.Confirmed, row.Unconfirmed, row.FalseAlarms,
+				math.Round(row.FalseAlarmRatio*1000) / 10, meanAck,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		progress(len(rows))
+		return nil
+	}
+	return table, nil
+}
+
+// exportBranchRow is a branch with the labels of its location and panel type
+type exportBranchRow struct {
+	ID          string `gorm:"column:id"`
+	Name        string `gorm:"column:name"`
+	Code        int    `gorm:"column:code"`
+	Location    string `gorm:"column:locationLabel"`
+	Address     string `gorm:"column:address"`
+	PhoneNumber string `gorm:"column:phoneNumber"`
+	PanelBrand  string `gorm:"column:panelTypeName"`
+	PanelModel  string `gorm:"column:panelTypeModel"`
+	PanelIP     string `gorm:"column:panelIp"`
+	PanelCode   int    `gorm:"column:panelCode"`
+}
+
+// branches prepares the export of the branches in scope, by name
+func (s *ExportService) branches(caller *Caller, filter BranchExportFilter) (*exportTable, error) {
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	query := scope.Branches(s.DB.Model(&models.Branch{}))
+	if filter.LocationID != "" {
+		locations, err := locationSubtree(s.DB, []string{filter.LocationID})
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(`"Branch"."locationId" IN ?`, locations)
+	}
+	table := &exportTable{
+		title: "شعبه‌ها",
+		columns: []export.Column{
+			{Title: "نام شعبه", Width: 20}, {Title: "کد شعبه", Width: 9}, {Title: "مکان", Width: 14},
+			{Title: "آدرس", Width: 30}, {Title: "شماره تماس", Width: 13}, {Title: "برند پنل", Width: 12},
+			{Title: "مدل پنل", Width: 12}, {Title: "آیپی", Width: 14}, {Title: "کد پنل", Width: 9},
+		},
+	}
+	if err := query.Session(&gorm.Session{}).Count(&table.total).Error; err != nil {
+		return nil, err
+	}
+
+	// آی‌پی پنل فقط برای کسی که اجازه دیدن آن را دارد نوشته می‌شود
+	showPanelIP := caller.CanField(models.PermissionRead, "Branch", "panelIp")
+	table.write = func(ctx context.Context, w export.Writer, progress func(int)) error {
+		var last *exportBranchRow
+		for {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			batch := query.Session(&gorm.Session{})
+			if last != nil {
+				batch = batch.Where(`(COALESCE("Branch".name, '') > ? OR (COALESCE("Branch".name, '') = ? AND "Branch".id > ?))`, last.Name, last.Name, last.ID)
+			}
+			var rows []exportBranchRow
+			err := batch.
+				Select(`"Branch".id, COALESCE("Branch".name, '') AS name, "Branch".code, "Location".label AS "locationLabel", "Branch".address,
+					"Branch"."phoneNumber", "PanelType".name AS "panelTypeName", "PanelType".model AS "panelTypeModel",
+					"Branch"."panelIp", "Branch"."panelCode"`).
+				Joins(`LEFT JOIN "Location" ON "Location".id = "Branch"."locationId"`).
+				Joins(`LEFT JOIN "PanelType" ON "PanelType".id = "Branch"."panelTypeId"`).
+				Order(`COALESCE("Branch".name, ''), "Branch".id`).
+				Limit(exportBatch).
+				Scan(&rows).Error
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				if !showPanelIP && row.PanelIP != "" {
+					row.PanelIP = redactedValue
+				}
+				err := w.WriteRow([]interface{}{
+					row.Name, row.Code, row.Location, row.Address, row.PhoneNumber,
+					row.PanelBrand, row.PanelModel, row.PanelIP, row.PanelCode,
+				})
+				if err != nil {
+					return err
+				}
+			}
+			progress(len(rows))
+			if len(rows) < exportBatch {
+				return nil
+			}
+			last = &rows[len(rows)-1]
+		}
+	}
+	return table, nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+// waitForExport polls the caller's jobs until id is no longer running
+func waitForExport(t *testing.T, s *ExportService, token, id string) ExportJob {
+	t.Helper()
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		res, err := s.FindJobs(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, job := range res.Data {
+			if job.ID == id && job.Status != ExportRunning {
+				return job
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("export %s did not finish", id)
+	return ExportJob{}
+}
+
+func TestExportLargePDF(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.Create(&models.Location{ID: "tehran", Label: "تهران"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	// بیشتر از سقف قبلی PDF، که حالا صفحه به صفحه نوشته می‌شود
+	branches := make([]models.Branch, 6000)
+	for i := range branches {
+		branches[i] = models.Branch{ID: fmt.Sprintf("branch-%05d", i), Name: fmt.Sprintf("شعبه %d", i), Code: i + 1, PanelCode: i + 1, LocationID: "tehran"}
+	}
+	if err := db.CreateInBatches(branches, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	reader := newTestUser(t, db, models.User{ID: "reader"}, requirement{Action: models.PermissionRead, Model: "Event"})
+	dir := t.TempDir()
+	s := &ExportService{DB: db, Authz: &Authorizer{DB: db}, Dir: dir}
+
+	res, err := s.Start(owner, ExportRequest{Source: ExportBranches, Format: "PDF", FileName: "branches"})
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	job := waitForExport(t, s, owner, res.Data.ID)
+	if job.Status != ExportDone || job.Written != 6000 || job.Total != 6000 {
+		t.Fatalf("export finished as %+v", job)
+	}
+	if job.Path != filepath.Join(dir, "branches.pdf") {
+		t.Errorf("written to %s", job.Path)
+	}
+	if _, err := os.Stat(job.Path); err != nil {
+		t.Errorf("the export is missing: %v", err)
+	}
+	if _, err := os.Stat(job.Path + ".part"); err == nil {
+		t.Error("the partial file was left behind")
+	}
+
+	// فایل موجود بازنویسی نمی‌شود
+	res, err = s.Start(owner, ExportRequest{Source: ExportBranches, Format: "pdf", FileName: "branches.pdf"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job := waitForExport(t, s, owner, res.Data.ID); job.Path != filepath.Join(dir, "branches-2.pdf") {
+		t.Errorf("second export written to %s", job.Path)
+	}
+
+	var serviceErr *ServiceError
+	cases := []struct {
+		name  string
+		token string
+		req   ExportRequest
+		want  int
+	}{
+		{"unknown format", owner, ExportRequest{Source: ExportBranches, Format: "doc"}, 400},
+		{"relative directory", owner, ExportRequest{Source: ExportBranches, Format: "csv", Directory: "exports"}, 400},
+		{"branches without read", reader, ExportRequest{Source: ExportBranches, Format: "csv"}, 403},
+	}
+	for _, c := range cases {
+		_, err := s.Start(c.token, c.req)
+		if !errors.As(err, &serviceErr) || serviceErr.StatusCode != c.want {
+			t.Errorf("%s: %v, want %d", c.name, err, c.want)
+		}
+	}
+	// کار دیگران دیده نمی‌شود
+	if jobs, err := s.FindJobs(reader); err != nil || len(jobs.Data) != 0 {
+		t.Errorf("reader sees %v (%v)", jobs, err)
+	}
+}
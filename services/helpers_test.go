@@ -0,0 +1,80 @@
+package services
+
+import (
+	"path/filepath"
+	"testing"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB returns a migrated SQLite database in a temporary directory
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := gorm.Open(sqlite.Open(path+"?_foreign_keys=1"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if _, err := database.Migrate(db, false); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return db
+}
+
+// newTestUser stores user with the permissions in grants and returns a session token for it
+func newTestUser(t *testing.T, db *gorm.DB, user models.User, grants ...requirement) string {
+	t.Helper()
+	if user.Username == "" {
+		user.Username = user.ID
+	}
+	if user.Password == "" {
+		user.Password = "-"
+	}
+	if user.Type == "" {
+		user.Type = "USER"
+	}
+	if user.Status == "" {
+		user.Status = models.UserStatusOffline
+	}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user %s: %v", user.ID, err)
+	}
+	for _, g := range grants {
+		query := db.Where("action = ? AND model = ?", g.Action, g.Model)
+		if g.Field == "" {
+			query = query.Where("field IS NULL")
+		} else {
+			query = query.Where("field = ?", g.Field)
+		}
+		var permission models.Permission
+		if err := query.Take(&permission).Error; err != nil {
+			permission = models.Permission{ID: uuid.NewString(), Action: g.Action, Model: g.Model}
+			if g.Field != "" {
+				field := g.Field
+				permission.Field = &field
+			}
+			if err := db.Create(&permission).Error; err != nil {
+				t.Fatalf("create permission: %v", err)
+			}
+		}
+		if err := db.Create(&models.UserPermission{ID: uuid.NewString(), UserID: user.ID, PermissionID: permission.ID}).Error; err != nil {
+			t.Fatalf("grant %s %s to %s: %v", g.Action, g.Model, user.ID, err)
+		}
+	}
+	token, err := newSession(db, user.ID, "", "test")
+	if err != nil {
+		t.Fatalf("open session: %v", err)
+	}
+	return token
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Calendars a panel type can report its dates in
+const (
+	CalendarGregorian = "gregorian"
+	CalendarJalali    = "jalali"
+)
+
+// jalaliBreaks are the years of the 2820 year cycle where the pattern of Jalali leap years
+// restarts (Borkowski's arithmetic, exact for 1 to 3177 SH)
+var jalaliBreaks = []int{
+	-61, 9, 38, 199, 426, 686, 756, 818, 1111, 1181, 1210,
+	1635, 2060, 2097, 2192, 2262, 2324, 2394, 2456, 3178,
+}
+
+// jalaliYear returns whether jy is a leap year and the Gregorian day of March on which its
+// Farvardin 1 falls
+func jalaliYear(jy int) (leap bool, march int) {
+	gy := jy + 621
+	leapJ := -14
+	jp := jalaliBreaks[0]
+	jump := 0
+	for i := 1; i < len(jalaliBreaks); i++ {
+		jm := jalaliBreaks[i]
+		jump = jm - jp
+		if jy < jm {
+			break
+		}
+		leapJ += jump/33*8 + (jump%33)/4
+		jp = jm
+	}
+	n := jy - jp
+	leapJ += n/33*8 + (n%33+3)/4
+	if jump%33 == 4 && jump-n == 4 {
+		leapJ++
+	}
+	leapG := gy/4 - (gy/100+1)*3/4 - 150
+	march = 20 + leapJ - leapG
+
+	if jump-n < 6 {
+		n = n - jump + (jump+4)/33*33
+	}
+	return ((n+1)%33-1)%4 == 0, march
+}
+
+// jalaliMonthDays is the length of month jm of year jy; Esfand has 30 days in leap years
+func jalaliMonthDays(jy, jm int) int {
+	switch {
+	case jm <= 6:
+		return 31
+	case jm <= 11:
+		return 30
+	}
+	if leap, _ := jalaliYear(jy); leap {
+		return 30
+	}
+	return 29
+}
+
+// jalaliToGregorian converts a valid Jalali date to the Gregorian one
+func jalaliToGregorian(jy, jm, jd int) (int, time.Month, int) {
+	_, march := jalaliYear(jy)
+	offset := (jm-1)*31 + jd - 1
+	if jm > 7 {
+		offset = 186 + (jm-7)*30 + jd - 1
+	}
+	// time.Date روزهای اضافه را به ماه‌های بعد می‌برد
+	g := time.Date(jy+621, time.March, march+offset, 0, 0, 0, 0, time.UTC)
+	return g.Year(), g.Month(), g.Day()
+}
+
+// gregorianToJalali converts a Gregorian date to the Jalali one
+func gregorianToJalali(year int, month time.Month, day int) (int, int, int) {
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	jy := year - 621
+	_, march := jalaliYear(jy)
+	nowruz := time.Date(year, time.March, march, 0, 0, 0, 0, time.UTC)
+	if date.Before(nowruz) {
+		jy--
+		_, march = jalaliYear(jy)
+		nowruz = time.Date(year-1, time.March, march, 0, 0, 0, 0, time.UTC)
+	}
+	offset := int(date.Sub(nowruz).Hours() / 24)
+	if offset < 186 {
+		return jy, offset/31 + 1, offset%31 + 1
+	}
+	offset -= 186
+	return jy, offset/30 + 7, offset%30 + 1
+}
+
+// formatJalali writes the date of t, in t's zone, as a Jalali YYYY-MM-DD
+func formatJalali(t time.Time) string {
+	jy, jm, jd := gregorianToJalali(t.Year(), t.Month(), t.Day())
+	return fmt.Sprintf("%04d-%02d-%02d", jy, jm, jd)
+}
+
+// validCalendar reports whether name is one of the supported calendars
+func validCalendar(name string) bool {
+	return name == CalendarGregorian || name == CalendarJalali
+}
+
+// persianDigits turns Persian and Arabic-Indic digits into ASCII ones
+var persianDigits = strings.NewReplacer(
+	"۰", "0", "۱", "1", "۲", "2", "۳", "3", "۴", "4", "۵", "5", "۶", "6", "۷", "7", "۸", "8", "۹", "9",
+	"٠", "0", "١", "1", "٢", "2", "٣", "3", "٤", "4", "٥", "5", "٦", "6", "٧", "7", "٨", "8", "٩", "9",
+)
+
+// parseDay reads a YYYY-MM-DD (or YYYY/MM/DD) date and returns the start of that day in loc.
+// Years before 1700 are taken as Jalali, so operators can enter 1403-01-01 as well as
+// 2024-03-20; Persian digits are accepted.
+func parseDay(value string, loc *time.Location) (time.Time, error) {
+	normalized := persianDigits.Replace(strings.TrimSpace(value))
+	parts := strings.FieldsFunc(normalized, func(r rune) bool { return r == '-' || r == '/' })
+	if len(parts) != 3 {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	values := make([]int, 3)
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		values[i] = n
+	}
+
+	year, month, day := values[0], time.Month(values[1]), values[2]
+	if year < 1700 {
+		if values[1] > 12 || day > jalaliMonthDays(year, values[1]) {
+			return time.Time{}, fmt.Errorf("invalid date %q", value)
+		}
+		year, month, day = jalaliToGregorian(year, values[1], day)
+	}
+	t := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	if t.Year() != year || t.Month() != month || t.Day() != day {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+// parseDayRange turns inclusive from and to dates, either of which may be empty, into the
+// half-open range [start, end) of instants in loc. The end is the start of the day after to,
+// found on the calendar rather than by adding 24 hours, so days changed by DST are whole.
+func parseDayRange(from, to string, loc *time.Location) (start, end *time.Time, err error) {
+	if strings.TrimSpace(from) != "" {
+		day, err := parseDay(from, loc)
+		if err != nil {
+			return nil, nil, &ServiceError{StatusCode: 400, Message: "invalid from date"}
+		}
+		start = &day
+	}
+	if strings.TrimSpace(to) != "" {
+		day, err := parseDay(to, loc)
+		if err != nil {
+			return nil, nil, &ServiceError{StatusCode: 400, Message: "invalid to date"}
+		}
+		next := time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+		end = &next
+	}
+	return start, end, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJalaliToGregorian(t *testing.T) {
+	cases := []struct {
+		jy, jm, jd int
+		want       string
+	}{
+		{1350, 1, 1, "1971-03-21"},
+		{1375, 1, 1, "1996-03-20"},
+		{1399, 12, 30, "2021-03-20"},
+		{1400, 1, 1, "2021-03-21"},
+		{1402, 1, 1, "2023-03-21"},
+		{1403, 1, 1, "2024-03-20"},
+		{1403, 10, 11, "2024-12-31"},
+		{1403, 12, 30, "2025-03-20"},
+	}
+	for _, c := range cases {
+		gy, gm, gd := jalaliToGregorian(c.jy, c.jm, c.jd)
+		got := time.Date(gy, gm, gd, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+		if got != c.want {
+			t.Errorf("jalaliToGregorian(%d, %d, %d) = %s, want %s", c.jy, c.jm, c.jd, got, c.want)
+		}
+	}
+}
+
+func TestJalaliRoundTrip(t *testing.T) {
+	day := time.Date(1921, 3, 21, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2121, 3, 21, 0, 0, 0, 0, time.UTC)
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		jy, jm, jd := gregorianToJalali(day.Year(), day.Month(), day.Day())
+		if jd < 1 || jd > jalaliMonthDays(jy, jm) {
+			t.Fatalf("%s converts to %d-%d-%d, outside the month", day.Format("2006-01-02"), jy, jm, jd)
+		}
+		gy, gm, gd := jalaliToGregorian(jy, jm, jd)
+		if gy != day.Year() || gm != day.Month() || gd != day.Day() {
+			t.Fatalf("%s -> %d-%d-%d -> %d-%d-%d", day.Format("2006-01-02"), jy, jm, jd, gy, gm, gd)
+		}
+	}
+}
+
+func TestJalaliMonthDays(t *testing.T) {
+	cases := []struct{ jy, jm, want int }{
+		{1403, 1, 31},
+		{1403, 6, 31},
+		{1403, 7, 30},
+		{1403, 11, 30},
+		{1399, 12, 30},
+		{1402, 12, 29},
+		{1403, 12, 30},
+		{1404, 12, 29},
+	}
+	for _, c := range cases {
+		if got := jalaliMonthDays(c.jy, c.jm); got != c.want {
+			t.Errorf("jalaliMonthDays(%d, %d) = %d, want %d", c.jy, c.jm, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
+	"gorm.io/gorm"
+)
+
+// LiveEventName is the frontend event carrying newly received events
+const LiveEventName = "events:new"
+
+const (
+	// liveQueueSize is how many received events may wait for the window before new ones are dropped
+	liveQueueSize = 1000
+	// liveRefreshInterval is how often the feed checks that its session still exists and reloads
+	// its location scope
+	liveRefreshInterval = time.Minute
+)
+
+// LiveEventService pushes newly stored events to the window, limited to the location scope
+// of the session that started the feed. The app has one window, so it has one feed: a Start
+// from another login replaces it. publish only queues the event; the session, scope and
+// branch locations are checked from a cache on a goroutine of its own, so the UDP workers
+// never wait on the database for the window.
+type LiveEventService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+
+	mu    sync.RWMutex
+	queue chan models.Event
+	feed  *liveFeed
+}
+
+// liveFeed is what Start cached about the session that receives the events
+type liveFeed struct {
+	token     string
+	expiresAt time.Time
+	scope     *LocationScope
+	checkedAt time.Time
+	// locations maps a branch id to its location id; it is cleared on every refresh
+	locations map[string]string
+}
+
+type LiveEventResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+// RegisterLiveEvents queues every event the UDP listener stores for the live feed of s; call
+// it before starting the listener. It and StartLiveEvents are functions rather than methods so
+// the frontend cannot reach them through the bound service.
+func RegisterLiveEvents(s *LiveEventService) {
+	OnEventSaved(s.publish)
+}
+
+// StartLiveEvents keeps the Wails context used to emit events and starts the emitting goroutine
+func StartLiveEvents(ctx context.Context, s *LiveEventService) {
+	queue := make(chan models.Event, liveQueueSize)
+	s.mu.Lock()
+	s.queue = queue
+	s.mu.Unlock()
+	go s.emitLoop(ctx, queue)
+}
+
+// Start binds the live feed to the caller's location scope
+func (s *LiveEventService) Start(token string) (*LiveEventResponse, error) {
+	caller, err := s.Authz.Authorize(token, "LiveEventService.Start")
+	if err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.feed = &liveFeed{
+		token:     token,
+		expiresAt: caller.Session.ExpiresAt,
+		scope:     scope,
+		checkedAt: time.Now(),
+		locations: map[string]string{},
+	}
+	s.mu.Unlock()
+
+	return &LiveEventResponse{StatusCode: 200, Message: "Live events started"}, nil
+}
+
+// Stop detaches the live feed; nothing is pushed until Start is called again
+func (s *LiveEventService) Stop(token string) (*LiveEventResponse, error) {
+	if _, err := s.Authz.Authorize(token, "LiveEventService.Stop"); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.feed = nil
+	s.mu.Unlock()
+
+	return &LiveEventResponse{StatusCode: 200, Message: "Live events stopped"}, nil
+}
+
+// publish queues event for the window without blocking; when the queue is full the event is
+// dropped from the live feed (it is already stored and shows up in the event list)
+func (s *LiveEventService) publish(event models.Event) {
+	s.mu.RLock()
+	queue, started := s.queue, s.feed != nil
+	s.mu.RUnlock()
+	if queue == nil || !started {
+		return
+	}
+
+	select {
+	case queue <- event:
+	default:
+		log.Printf("live event queue is full, event %s is not pushed to the window", event.ID)
+	}
+}
+
+func (s *LiveEventService) emitLoop(ctx context.Context, queue chan models.Event) {
+	for event := range queue {
+		if s.allows(event) {
+			runtime.EventsEmit(ctx, LiveEventName, event)
+		}
+	}
+}
+
+// allows reports whether event falls inside the feed's scope. Only the emitting goroutine
+// changes a feed after Start, so the lock is held just to read and end it and publish never
+// waits on the queries made here.
+func (s *LiveEventService) allows(event models.Event) bool {
+	s.mu.RLock()
+	feed := s.feed
+	s.mu.RUnlock()
+	if feed == nil {
+		return false
+	}
+
+	now := time.Now()
+	// نشست منقضی یا خارج شده دیگر رویدادی دریافت نمی‌کند
+	if !now.Before(feed.expiresAt) || (now.Sub(feed.checkedAt) >= liveRefreshInterval && !s.refresh(feed, now)) {
+		s.mu.Lock()
+		if s.feed == feed {
+			s.feed = nil
+		}
+		s.mu.Unlock()
+		return false
+	}
+	if feed.scope.Unrestricted {
+		return true
+	}
+
+	locationID, ok := feed.locations[event.BranchID]
+	if !ok {
+		var branch models.Branch
+		if err := s.DB.Select("id", `"locationId"`).Where("id = ?", event.BranchID).First(&branch).Error; err != nil {
+			return false
+		}
+		locationID = branch.LocationID
+		feed.locations[event.BranchID] = locationID
+	}
+	return feed.scope.AllowsLocation(locationID)
+}
+
+// refresh checks that the feed's session still exists and reloads its scope; false ends the feed
+func (s *LiveEventService) refresh(feed *liveFeed, now time.Time) bool {
+	caller, err := s.Authz.Authorize(feed.token, "LiveEventService.Start")
+	if err != nil {
+		return false
+	}
+	feed.checkedAt = now
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		log.Printf("failed to reload the live event scope: %v", err)
+		return true
+	}
+	feed.expiresAt = caller.Session.ExpiresAt
+	feed.scope = scope
+	feed.locations = map[string]string{}
+	return true
+}
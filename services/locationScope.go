@@ -0,0 +1,169 @@
+package services
+
+import (
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// LocationScope is the set of locations a caller may see: the subtree under User.LocationID
+// plus the subtrees of the caller's UserLocation rows. Every branch and event query, report,
+// export and live push goes through it.
+type LocationScope struct {
+	Unrestricted bool
+	LocationIDs  []string
+	allowed      map[string]bool
+}
+
+// locationScope loads (once per call) the location scope of the caller
+func (c *Caller) locationScope(db *gorm.DB) (*LocationScope, error) {
+	if c.scope != nil {
+		return c.scope, nil
+	}
+	scope, err := loadLocationScope(db, &c.User)
+	if err != nil {
+		return nil, err
+	}
+	c.scope = scope
+	return scope, nil
+}
+
+// checkManaged refuses an owner account to every caller but an owner and reports a user
+// outside the caller's locations as not found
+func (c *Caller) checkManaged(db *gorm.DB, user *models.User, notFound string) error {
+	if c.IsOwner() {
+		return nil
+	}
+	if user.Type == "OWNER" {
+		return ErrForbidden
+	}
+	scope, err := c.locationScope(db)
+	if err != nil {
+		return err
+	}
+	allowed, err := scope.AllowsUser(db, user)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return &ServiceError{StatusCode: 404, Message: notFound}
+	}
+	return nil
+}
+
+func loadLocationScope(db *gorm.DB, user *models.User) (*LocationScope, error) {
+	if user.Type == "OWNER" {
+		return &LocationScope{Unrestricted: true}, nil
+	}
+
+	var roots []string
+	if user.LocationID != "" {
+		roots = append(roots, user.LocationID)
+	}
+	var assigned []string
+	if err := db.Model(&models.UserLocation{}).Where(`"userId" = ?`, user.ID).Pluck("locationId", &assigned).Error; err != nil {
+		return nil, err
+	}
+	roots = append(roots, assigned...)
+
+	scope := &LocationScope{LocationIDs: []string{}, allowed: map[string]bool{}}
+	if len(roots) == 0 {
+		return scope, nil
+	}
+
+	ids, err := locationSubtree(db, roots)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		scope.allowed[id] = true
+	}
+	scope.LocationIDs = ids
+	return scope, nil
+}
+
+// locationSubtree returns roots and every location below them
+func locationSubtree(db *gorm.DB, roots []string) ([]string, error) {
+	var ids []string
+	err := db.Raw(`
+		WITH RECURSIVE subtree(id) AS (
+			SELECT id FROM "Location" WHERE id IN ? AND "deletedAt" IS NULL
+			UNION
+			SELECT l.id FROM "Location" l JOIN subtree s ON l."parentId" = s.id WHERE l."deletedAt" IS NULL
+		)
+		SELECT id FROM subtree`, roots).Scan(&ids).Error
+	return ids, err
+}
+
+// AllowsLocation reports whether locationID is inside the scope
+func (l *LocationScope) AllowsLocation(locationID string) bool {
+	return l.Unrestricted || l.allowed[locationID]
+}
+
+// AllowsBranch reports whether the branch with branchID is inside the scope
+func (l *LocationScope) AllowsBranch(db *gorm.DB, branchID string) bool {
+	if l.Unrestricted {
+		return true
+	}
+	var branch models.Branch
+	if err := db.Select("id", `"locationId"`).Where("id = ?", branchID).First(&branch).Error; err != nil {
+		return false
+	}
+	return l.allowed[branch.LocationID]
+}
+
+// AllowsUser reports whether user and every location assigned to it are inside the scope; a
+// user without a location is only inside an unrestricted scope
+func (l *LocationScope) AllowsUser(db *gorm.DB, user *models.User) (bool, error) {
+	if l.Unrestricted {
+		return true, nil
+	}
+	if !l.allowed[user.LocationID] {
+		return false, nil
+	}
+	var assigned []string
+	if err := db.Model(&models.UserLocation{}).Where(`"userId" = ?`, user.ID).Pluck("locationId", &assigned).Error; err != nil {
+		return false, err
+	}
+	for _, id := range assigned {
+		if !l.allowed[id] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Users limits a query on "User" to the users AllowsUser accepts
+func (l *LocationScope) Users(db *gorm.DB) *gorm.DB {
+	if l.Unrestricted {
+		return db
+	}
+	ids := l.nonEmptyIDs()
+	return db.Where(`"User"."locationId" IN ? AND NOT EXISTS (
+		SELECT 1 FROM "UserLocation" WHERE "UserLocation"."userId" = "User".id
+			AND "UserLocation"."deletedAt" IS NULL AND "UserLocation"."locationId" NOT IN ?)`, ids, ids)
+}
+
+// Branches limits a query on "Branch" to the scope
+func (l *LocationScope) Branches(db *gorm.DB) *gorm.DB {
+	if l.Unrestricted {
+		return db
+	}
+	return db.Where(`"Branch"."locationId" IN ?`, l.nonEmptyIDs())
+}
+
+// Events limits a query on "Event" to events of branches inside the scope
+func (l *LocationScope) Events(db *gorm.DB) *gorm.DB {
+	if l.Unrestricted {
+		return db
+	}
+	return db.Where(`"Event"."branchId" IN (SELECT id FROM "Branch" WHERE "locationId" IN ?)`, l.nonEmptyIDs())
+}
+
+// nonEmptyIDs keeps "IN ?" valid SQL when the scope is empty
+func (l *LocationScope) nonEmptyIDs() []string {
+	if len(l.LocationIDs) == 0 {
+		return []string{""}
+	}
+	return l.LocationIDs
+}
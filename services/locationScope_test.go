@@ -0,0 +1,142 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// seedScopeTree stores Tehran with North below it, Shiraz beside them and a branch with one
+// event in North and in Shiraz
+func seedScopeTree(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	tehran := "tehran"
+	for _, row := range []any{
+		&models.Location{ID: tehran, Label: "Tehran"},
+		&models.Location{ID: "north", Label: "North", ParentID: &tehran},
+		&models.Location{ID: "shiraz", Label: "Shiraz"},
+		&models.Branch{ID: "north-branch", Code: 1, PanelCode: 1, LocationID: "north"},
+		&models.Branch{ID: "shiraz-branch", Code: 2, PanelCode: 2, LocationID: "shiraz"},
+		&models.Event{ID: "north-event", DedupHash: "north-event", BranchID: "north-branch", ConfirmationStatus: "Unconfirmed"},
+		&models.Event{ID: "shiraz-event", DedupHash: "shiraz-event", BranchID: "shiraz-branch", ConfirmationStatus: "Unconfirmed"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+}
+
+func TestLocationScope(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	for _, row := range []any{
+		&models.User{ID: "tehran-admin", Username: "tehran-admin", Password: "-", LocationID: "tehran"},
+		&models.User{ID: "assigned", Username: "assigned", Password: "-", LocationID: "north"},
+		&models.UserLocation{ID: "assigned-shiraz", UserID: "assigned", LocationID: "shiraz"},
+		&models.User{ID: "nowhere", Username: "nowhere", Password: "-"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+
+	cases := []struct {
+		user     models.User
+		branches []string
+		events   []string
+	}{
+		// زیرشاخه‌های مکان کاربر هم در محدوده‌اند
+		{models.User{ID: "tehran-admin", LocationID: "tehran"}, []string{"north-branch"}, []string{"north-event"}},
+		// مکان‌های واگذارشده به محدوده اضافه می‌شوند
+		{models.User{ID: "assigned", LocationID: "north"}, []string{"north-branch", "shiraz-branch"}, []string{"north-event", "shiraz-event"}},
+		{models.User{ID: "nowhere"}, nil, nil},
+		{models.User{ID: "owner", Type: "OWNER"}, []string{"north-branch", "shiraz-branch"}, []string{"north-event", "shiraz-event"}},
+	}
+	for _, c := range cases {
+		scope, err := loadLocationScope(db, &c.user)
+		if err != nil {
+			t.Fatalf("%s: %v", c.user.ID, err)
+		}
+		var branches, events []string
+		if err := scope.Branches(db.Model(&models.Branch{})).Order("id").Pluck("id", &branches).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := scope.Events(db.Model(&models.Event{})).Order("id").Pluck("id", &events).Error; err != nil {
+			t.Fatal(err)
+		}
+		if !sameIDs(branches, c.branches) || !sameIDs(events, c.events) {
+			t.Errorf("%s sees branches %v and events %v, want %v and %v", c.user.ID, branches, events, c.branches, c.events)
+		}
+		for _, branch := range []string{"north-branch", "shiraz-branch"} {
+			if got, want := scope.AllowsBranch(db, branch), contains(c.branches, branch); got != want {
+				t.Errorf("%s: AllowsBranch(%s) = %v, want %v", c.user.ID, branch, got, want)
+			}
+		}
+	}
+
+	// کاربری که یکی از مکان‌هایش بیرون محدوده است در محدوده نیست
+	scope, err := loadLocationScope(db, &models.User{ID: "tehran-admin", LocationID: "tehran"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var users []string
+	if err := scope.Users(db.Model(&models.User{})).Pluck("id", &users).Error; err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(users)
+	if !sameIDs(users, []string{"tehran-admin"}) {
+		t.Errorf("tehran admin sees users %v, want [tehran-admin]", users)
+	}
+}
+
+func TestLiveEventsFollowTheFeedScope(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	token := newTestUser(t, db, models.User{ID: "operator", LocationID: "tehran"}, requirement{Action: models.PermissionRead, Model: "Event"})
+	s := &LiveEventService{DB: db, Authz: &Authorizer{DB: db}}
+
+	north := models.Event{ID: "north-event", BranchID: "north-branch"}
+	shiraz := models.Event{ID: "shiraz-event", BranchID: "shiraz-branch"}
+	if s.allows(north) {
+		t.Error("an event is pushed before the feed is started")
+	}
+	if _, err := s.Start(token); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if !s.allows(north) || s.allows(shiraz) {
+		t.Errorf("feed allows north %v and shiraz %v, want only north", s.allows(north), s.allows(shiraz))
+	}
+
+	// نشست خارج‌شده در بازخوانی بعدی فید را می‌بندد
+	if err := db.Where(`"userId" = ?`, "operator").Delete(&models.Session{}).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.feed.checkedAt = s.feed.checkedAt.Add(-liveRefreshInterval)
+	if s.allows(north) || s.feed != nil {
+		t.Error("the feed of a closed session still pushes events")
+	}
+}
+
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(ids []string, id string) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
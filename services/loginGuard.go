@@ -0,0 +1,160 @@
+package services
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// loginPolicy holds the brute-force settings read from AppSetting
+type loginPolicy struct {
+	MaxUserFailures   int
+	MaxSourceFailures int
+	Window            time.Duration
+	Lockout           time.Duration
+	BaseDelay         time.Duration
+	MaxDelay          time.Duration
+}
+
+func loadLoginPolicy(db *gorm.DB) loginPolicy {
+	return loginPolicy{
+		MaxUserFailures:   readIntSetting(db, "auth.maxFailedAttempts", 5),
+		MaxSourceFailures: readIntSetting(db, "auth.maxFailedAttemptsPerSource", 50),
+		Window:            time.Duration(readIntSetting(db, "auth.failureWindowMinutes", 15)) * time.Minute,
+		Lockout:           time.Duration(readIntSetting(db, "auth.lockoutMinutes", 15)) * time.Minute,
+		BaseDelay:         time.Duration(readIntSetting(db, "auth.delayBaseMs", 500)) * time.Millisecond,
+		MaxDelay:          time.Duration(readIntSetting(db, "auth.delayMaxMs", 8000)) * time.Millisecond,
+	}
+}
+
+// delay doubles the base delay for every failure after the first, capped at MaxDelay
+func (p loginPolicy) delay(failures int) time.Duration {
+	if failures <= 0 || p.BaseDelay <= 0 {
+		return 0
+	}
+	d := p.BaseDelay
+	for i := 1; i < failures && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	return d
+}
+
+// loginGuard counts failures from AuthLog per username and per source. A success or an admin
+// unlock resets the user counter; only an admin unlock of the source resets the source counter.
+// Every operator of a console logs in from the same address, so the source limit is kept well
+// above the user limit: it stops password spraying across usernames without letting a few
+// mistyped passwords lock out the whole console.
+type loginGuard struct {
+	db     *gorm.DB
+	policy loginPolicy
+}
+
+// lockedUntil reports whether username or source is locked and until when
+func (g *loginGuard) lockedUntil(username, source string) (time.Time, bool) {
+	resets := []models.AuthStatus{models.AuthStatusSuccess, models.AuthStatusUnlocked}
+	if until, ok := g.lockFor("username", username, resets, g.policy.MaxUserFailures); ok {
+		return until, true
+	}
+	return g.lockFor("ip", source, []models.AuthStatus{models.AuthStatusSourceUnlocked}, g.policy.MaxSourceFailures)
+}
+
+// lockFor reports whether the failures logged with column = key lock it. The lock starts at
+// the failure that brought max within one window and lasts the whole Lockout, even when that
+// is longer than the window; a row with one of the resets statuses clears the earlier failures.
+func (g *loginGuard) lockFor(column, key string, resets []models.AuthStatus, max int) (time.Time, bool) {
+	if max <= 0 || key == "" {
+		return time.Time{}, false
+	}
+	now := time.Now()
+	since := now.Add(-g.policy.Window - g.policy.Lockout)
+	if reset := g.lastReset(column, key, resets); reset.After(since) {
+		since = reset
+	}
+
+	var failures []models.AuthLog
+	g.db.Where(column+` = ? AND status = ? AND "loginTime" > ?`, key, models.AuthStatusFailed, since).
+		Order(`"loginTime" DESC`).
+		Limit(max).
+		Find(&failures)
+	if len(failures) < max {
+		return time.Time{}, false
+	}
+	last := failures[0].LoginTime
+	if failures[max-1].LoginTime.Before(last.Add(-g.policy.Window)) {
+		return time.Time{}, false
+	}
+
+	until := last.Add(g.policy.Lockout)
+	if now.Before(until) {
+		return until, true
+	}
+	return time.Time{}, false
+}
+
+// userFailures returns the failures counted against username in the current window
+func (g *loginGuard) userFailures(username string) int {
+	since := time.Now().Add(-g.policy.Window)
+	resets := []models.AuthStatus{models.AuthStatusSuccess, models.AuthStatusUnlocked}
+	if reset := g.lastReset("username", username, resets); reset.After(since) {
+		since = reset
+	}
+
+	var count int64
+	g.db.Model(&models.AuthLog{}).
+		Where("username = ? AND status = ? AND \"loginTime\" > ?", username, models.AuthStatusFailed, since).
+		Count(&count)
+	return int(count)
+}
+
+// lastReset is the time of the last row with column = key and one of statuses; zero if there
+// is none
+func (g *loginGuard) lastReset(column, key string, statuses []models.AuthStatus) time.Time {
+	var last models.AuthLog
+	err := g.db.Where(column+" = ? AND status IN ?", key, statuses).
+		Order(`"loginTime" DESC`).First(&last).Error
+	if err != nil {
+		return time.Time{}
+	}
+	return last.LoginTime
+}
+
+// record writes one attempt to AuthLog and returns its id
+func (g *loginGuard) record(username, userID, source string, status models.AuthStatus, reason string) string {
+	entry := models.AuthLog{
+		ID:        uuid.New().String(),
+		IP:        source,
+		Username:  username,
+		UserID:    userID,
+		Status:    status,
+		Reason:    reason,
+		LoginTime: time.Now(),
+	}
+	if err := g.db.Create(&entry).Error; err != nil {
+		log.Printf("failed to write auth log: %v", err)
+	}
+	return entry.ID
+}
+
+// clientSource returns the address this console connects from, used as the per-source key
+func clientSource() string {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return "127.0.0.1"
+	}
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		return ipNet.IP.String()
+	}
+	return "127.0.0.1"
+}
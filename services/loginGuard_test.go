@@ -0,0 +1,145 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// logAttempt writes an AuthLog row that happened ago before now
+func logAttempt(t *testing.T, db *gorm.DB, username, source string, status models.AuthStatus, ago time.Duration) {
+	t.Helper()
+	entry := models.AuthLog{
+		ID:        fmt.Sprintf("log-%d", time.Now().UnixNano()),
+		IP:        source,
+		Username:  username,
+		Status:    status,
+		LoginTime: time.Now().Add(-ago),
+	}
+	if err := db.Create(&entry).Error; err != nil {
+		t.Fatalf("write auth log: %v", err)
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	policy := loginPolicy{MaxUserFailures: 3, MaxSourceFailures: 5, Window: 10 * time.Minute, Lockout: 30 * time.Minute}
+	cases := []struct {
+		name     string
+		failures []time.Duration
+		locked   bool
+	}{
+		{"below the limit", []time.Duration{time.Minute, 2 * time.Minute}, false},
+		{"limit within one window", []time.Duration{time.Minute, 3 * time.Minute, 5 * time.Minute}, true},
+		{"limit spread over more than a window", []time.Duration{time.Minute, 6 * time.Minute, 12 * time.Minute}, false},
+		// قفل تا پایان مدت قفل می‌ماند، حتی وقتی از پنجره طولانی‌تر است
+		{"lock outlives the window", []time.Duration{20 * time.Minute, 22 * time.Minute, 25 * time.Minute}, true},
+		{"lock expired", []time.Duration{31 * time.Minute, 32 * time.Minute, 33 * time.Minute}, false},
+	}
+	for _, c := range cases {
+		db := newTestDB(t)
+		for _, ago := range c.failures {
+			logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, ago)
+		}
+		guard := &loginGuard{db: db, policy: policy}
+		until, locked := guard.lockedUntil("operator", "10.0.0.1")
+		if locked != c.locked {
+			t.Errorf("%s: locked = %v, want %v", c.name, locked, c.locked)
+		}
+		if locked {
+			want := time.Now().Add(-c.failures[0] + policy.Lockout)
+			if until.Sub(want) > time.Second || want.Sub(until) > time.Second {
+				t.Errorf("%s: locked until %s, want %s", c.name, until, want)
+			}
+		}
+		// قفل کاربر دیگری از همان آدرس را قفل نمی‌کند
+		if _, locked := guard.lockedUntil("other", "10.0.0.1"); locked {
+			t.Errorf("%s: another user of the source is locked", c.name)
+		}
+	}
+}
+
+func TestLoginGuardResets(t *testing.T) {
+	policy := loginPolicy{MaxUserFailures: 3, MaxSourceFailures: 5, Window: 10 * time.Minute, Lockout: 30 * time.Minute}
+	for _, status := range []models.AuthStatus{models.AuthStatusSuccess, models.AuthStatusUnlocked} {
+		db := newTestDB(t)
+		guard := &loginGuard{db: db, policy: policy}
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, 5*time.Minute)
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, 4*time.Minute)
+		logAttempt(t, db, "operator", "", status, 3*time.Minute)
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, 2*time.Minute)
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, time.Minute)
+		if _, locked := guard.lockedUntil("operator", "10.0.0.1"); locked {
+			t.Errorf("%s did not reset the failures before it", status)
+		}
+		if n := guard.userFailures("operator"); n != 2 {
+			t.Errorf("after %s %d failures are counted, want 2", status, n)
+		}
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, 0)
+		if _, locked := guard.lockedUntil("operator", "10.0.0.1"); !locked {
+			t.Errorf("failures after %s do not lock", status)
+		}
+	}
+}
+
+func TestLoginGuardSourceLockout(t *testing.T) {
+	db := newTestDB(t)
+	guard := &loginGuard{db: db, policy: loginPolicy{MaxUserFailures: 3, MaxSourceFailures: 5, Window: 10 * time.Minute, Lockout: 30 * time.Minute}}
+	// هر نام کاربری زیر حد خودش است ولی آدرس به حد می‌رسد
+	for i := 0; i < 5; i++ {
+		logAttempt(t, db, fmt.Sprintf("user-%d", i), "10.0.0.1", models.AuthStatusFailed, time.Duration(i)*time.Minute)
+	}
+	// ورود موفق از همان آدرس شمارنده آدرس را صفر نمی‌کند
+	logAttempt(t, db, "user-0", "10.0.0.1", models.AuthStatusSuccess, 0)
+	if _, locked := guard.lockedUntil("someone", "10.0.0.1"); !locked {
+		t.Error("a source with failures across usernames is not locked")
+	}
+	if _, locked := guard.lockedUntil("someone", "10.0.0.2"); locked {
+		t.Error("another source is locked")
+	}
+	logAttempt(t, db, "", "10.0.0.1", models.AuthStatusSourceUnlocked, 0)
+	if _, locked := guard.lockedUntil("someone", "10.0.0.1"); locked {
+		t.Error("an unlocked source is still locked")
+	}
+}
+
+func TestUnlockAccount(t *testing.T) {
+	db := newTestDB(t)
+	owner := models.User{ID: "owner", Username: "owner", Password: "-", Type: "OWNER", Status: models.UserStatusOffline}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := newSession(db, owner.ID, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		logAttempt(t, db, "operator", "10.0.0.1", models.AuthStatusFailed, time.Duration(i)*time.Second)
+	}
+	guard := &loginGuard{db: db, policy: loadLoginPolicy(db)}
+	if _, locked := guard.lockedUntil("operator", "10.0.0.1"); !locked {
+		t.Fatal("five failures do not lock with the default policy")
+	}
+
+	s := &AuthService{DB: db, Authz: &Authorizer{DB: db}}
+	if _, err := s.UnlockAccount("", "operator"); err == nil {
+		t.Error("UnlockAccount without a session was accepted")
+	}
+	if _, err := s.UnlockAccount(token, ""); err == nil {
+		t.Error("UnlockAccount without a username was accepted")
+	}
+	if _, err := s.UnlockAccount(token, "operator"); err != nil {
+		t.Fatalf("UnlockAccount: %v", err)
+	}
+	if _, locked := guard.lockedUntil("operator", "10.0.0.1"); locked {
+		t.Error("an unlocked account is still locked")
+	}
+	var unlocks int64
+	db.Model(&models.AuthLog{}).Where("username = ? AND status = ?", "operator", models.AuthStatusUnlocked).Count(&unlocks)
+	if unlocks != 1 {
+		t.Errorf("%d unlocks in the auth log, want 1", unlocks)
+	}
+}
@@ -0,0 +1,24 @@
+package services
+
+const maxPageLimit = 500
+
+// normalizePage clamps page and limit coming from the frontend
+func normalizePage(page, limit int) (int, int) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 {
+		limit = 10
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+	return page, limit
+}
+
+func totalPages(total int64, limit int) int {
+	if limit < 1 {
+		return 0
+	}
+	return int((total + int64(limit) - 1) / int64(limit))
+}
@@ -0,0 +1,432 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// PanelClockService reports the panels whose clock drifts away from the receive time and sets
+// the time zone the clock of a branch's panel runs in and the calendar a panel type reports in.
+type PanelClockService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+const defaultPanelTimeZone = "Asia/Tehran"
+
+// panelZones caches the time zones loaded by name
+var panelZones sync.Map
+
+// backfillMu keeps a single panel time backfill running at a time
+var backfillMu sync.Mutex
+
+// driftWarnings holds, per branch, when its clock drift was last written to the journal
+var driftWarnings sync.Map
+
+// panelLocation returns the zone a panel clock runs in: the branch's own time zone, else
+// the event.timeZone setting. An unknown name falls back to the zone of this machine.
+func panelLocation(db *gorm.DB, branchZone string) *time.Location {
+	name := branchZone
+	if name == "" {
+		name = readSetting(db, "event.timeZone", defaultPanelTimeZone)
+	}
+	if loc, ok := panelZones.Load(name); ok {
+		return loc.(*time.Location)
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("⚠️ Unknown panel time zone %q, using %s", name, time.Local)
+		return time.Local
+	}
+	panelZones.Store(name, loc)
+	return loc
+}
+
+// panelDateStrings zero-pads the date and time fields of a panel message so the stored
+// strings sort in time order; fields that are not numbers are kept as they came.
+func panelDateStrings(year, month, day, hour, minute string) (string, string) {
+	return padNumber(year, 4) + "-" + padNumber(month, 2) + "-" + padNumber(day, 2), padNumber(hour, 2) + ":" + padNumber(minute, 2)
+}
+
+// padNumber zero-pads value to width digits when it is a number
+func padNumber(value string, width int) string {
+	n, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		return value
+	}
+	return fmt.Sprintf("%0*d", width, n)
+}
+
+// parsePanelTime reads the date (Y-M-D) and time (H:M or H:M:S) strings of an event in loc,
+// the date being in the given calendar. Two digit years are taken as 20YY; Jalali ones as 14YY
+// below 50 and 13YY from 50 up, so panels still set to the past century read correctly.
+// Years that cannot belong to the calendar (a Jalali date read as Gregorian) are refused.
+func parsePanelTime(date, clock, calendar string, loc *time.Location) (time.Time, error) {
+	dateParts := strings.Split(strings.TrimSpace(date), "-")
+	clockParts := strings.Split(strings.TrimSpace(clock), ":")
+	if len(dateParts) != 3 || len(clockParts) < 2 || len(clockParts) > 3 {
+		return time.Time{}, fmt.Errorf("invalid panel time %q %q", date, clock)
+	}
+
+	values := make([]int, 6)
+	for i, part := range append(dateParts, clockParts...) {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil || n < 0 {
+			return time.Time{}, fmt.Errorf("invalid panel time %q %q", date, clock)
+		}
+		values[i] = n
+	}
+	year, month, day, hour, minute, second := values[0], values[1], values[2], values[3], values[4], values[5]
+	if month < 1 || month > 12 || day < 1 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, fmt.Errorf("invalid panel time %q %q", date, clock)
+	}
+
+	gYear, gMonth, gDay := year, time.Month(month), day
+	if calendar == CalendarJalali {
+		switch {
+		case year < 50:
+			year += 1400
+		case year < 100:
+			year += 1300
+		}
+		if year < 1300 || year >= 1700 || day > jalaliMonthDays(year, month) {
+			return time.Time{}, fmt.Errorf("invalid jalali panel time %q %q", date, clock)
+		}
+		gYear, gMonth, gDay = jalaliToGregorian(year, month, day)
+	} else {
+		if year < 100 {
+			year += 2000
+		}
+		if year < 1900 {
+			return time.Time{}, fmt.Errorf("invalid panel time %q %q", date, clock)
+		}
+		gYear = year
+	}
+
+	t := time.Date(gYear, gMonth, gDay, hour, minute, second, 0, loc)
+	// روز خارج از ماه (مثل ۳۱ آبان) به ماه بعد نمی‌رود
+	if t.Day() != gDay {
+		return time.Time{}, fmt.Errorf("invalid panel time %q %q", date, clock)
+	}
+	return t, nil
+}
+
+// clockDrift is how many seconds the panel clock is behind the receive time; negative when
+// it runs ahead
+func clockDrift(receivedAt, panelTime time.Time) int {
+	return int(receivedAt.Sub(panelTime).Round(time.Second) / time.Second)
+}
+
+// checkClockDrift writes a journal warning, at most once an hour per branch, when drift is
+// beyond event.clockDriftToleranceSeconds
+func checkClockDrift(db *gorm.DB, branchCode string, drift int) {
+	tolerance := readIntSetting(db, "event.clockDriftToleranceSeconds", 300)
+	if tolerance <= 0 || (drift <= tolerance && drift >= -tolerance) {
+		return
+	}
+	now := time.Now()
+	if last, ok := driftWarnings.Load(branchCode); ok && now.Sub(last.(time.Time)) < time.Hour {
+		return
+	}
+	driftWarnings.Store(branchCode, now)
+	log.Printf("⚠️ Panel clock of branch %s is %s off", branchCode, time.Duration(drift)*time.Second)
+}
+
+// listenerReceiver is the receiver the UDP listener receives for, set in udp.receiverId;
+// empty means the branches that have no receiver
+func listenerReceiver(db *gorm.DB) string {
+	return strings.TrimSpace(readSetting(db, "udp.receiverId", ""))
+}
+
+// findPanelBranch returns the branch with the panel code the panel reported on receiverID,
+// or nil; panel codes are only unique per receiver
+func findPanelBranch(db *gorm.DB, receiverID string, panelCode string) *models.Branch {
+	code, err := strconv.Atoi(strings.TrimSpace(panelCode))
+	if err != nil {
+		return nil
+	}
+	query := db.Where(`"panelCode" = ?`, code)
+	if receiverID == "" {
+		query = query.Where(`"receiverId" IS NULL`)
+	} else {
+		query = query.Where(`"receiverId" = ?`, receiverID)
+	}
+	var branch models.Branch
+	if err := query.First(&branch).Error; err != nil {
+		return nil
+	}
+	return &branch
+}
+
+// panelCalendar returns the calendar the panel type reports dates in; Gregorian when the
+// branch has no panel type
+func panelCalendar(db *gorm.DB, panelTypeID string) string {
+	if panelTypeID == "" {
+		return CalendarGregorian
+	}
+	var panelType models.PanelType
+	if err := db.Unscoped().Select("id", "calendar").Where("id = ?", panelTypeID).Take(&panelType).Error; err != nil {
+		return CalendarGregorian
+	}
+	if !validCalendar(panelType.Calendar) {
+		return CalendarGregorian
+	}
+	return panelType.Calendar
+}
+
+// BackfillPanelTimes fills panelTime and clockDrift of the events that do not have them yet,
+// reading their date and time strings in the zone and calendar of their branch. Events whose
+// strings cannot be read are marked panelTimeInvalid, so later runs skip them until the
+// calendar of their panel type changes.
+func BackfillPanelTimes(db *gorm.DB) {
+	backfillMu.Lock()
+	defer backfillMu.Unlock()
+
+	type panelClock struct {
+		loc      *time.Location
+		calendar string
+	}
+	clocks := map[string]panelClock{}
+	clockOf := func(branchID string) panelClock {
+		if clock, ok := clocks[branchID]; ok {
+			return clock
+		}
+		var branch models.Branch
+		db.Unscoped().Select("id", "timeZone", "panelTypeId").Where("id = ?", branchID).Take(&branch)
+		clocks[branchID] = panelClock{panelLocation(db, branch.TimeZone), panelCalendar(db, branch.PanelTypeID)}
+		return clocks[branchID]
+	}
+
+	filled, invalid, last := 0, 0, ""
+	for {
+		var events []models.Event
+		err := db.Unscoped().
+			Select("id", "date", "time", "branchId", "createdAt").
+			Where(`"panelTime" IS NULL AND "date" <> '' AND NOT "panelTimeInvalid" AND id > ?`, last).
+			Order("id").Limit(500).
+			Find(&events).Error
+		if err != nil {
+			log.Printf("❌ Panel time backfill failed: %v", err)
+			return
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for _, event := range events {
+				clock := clockOf(event.BranchID)
+				panelTime, err := parsePanelTime(event.Date, event.Time, clock.calendar, clock.loc)
+				if err != nil {
+					if err := tx.Exec(`UPDATE "Event" SET "panelTimeInvalid" = ? WHERE id = ?`, true, event.ID).Error; err != nil {
+						return err
+					}
+					invalid++
+					continue
+				}
+				// مستقیم نوشته می‌شود تا نسخه رکورد و ActionLog دست نخورند
+				err = tx.Exec(`UPDATE "Event" SET "panelTime" = ?, "clockDrift" = ? WHERE id = ?`,
+					panelTime.UTC(), clockDrift(event.CreatedAt, panelTime), event.ID).Error
+				if err != nil {
+					return err
+				}
+				filled++
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("❌ Panel time backfill failed: %v", err)
+			return
+		}
+		last = events[len(events)-1].ID
+	}
+	if filled > 0 {
+		log.Printf("🕒 Panel time filled for %d events", filled)
+	}
+	if invalid > 0 {
+		log.Printf("⚠️ Panel time of %d events could not be read", invalid)
+	}
+}
+
+// PanelClockStatus is the clock state of a branch's panel, taken from its latest event
+type PanelClockStatus struct {
+	BranchID    string    `json:"branchId"`
+	BranchName  string    `json:"branchName"`
+	BranchCode  int       `json:"branchCode"`
+	TimeZone    string    `json:"timeZone"`
+	ClockDrift  int       `json:"clockDrift"`
+	LastEventAt time.Time `json:"lastEventAt"`
+}
+
+type PanelClockListResponse struct {
+	StatusCode int                `json:"statusCode"`
+	Message    string             `json:"message"`
+	Data       []PanelClockStatus `json:"data"`
+}
+
+// BranchTimeZoneRequest sets the zone of a branch's panel clock; an empty TimeZone falls back
+// to event.timeZone
+type BranchTimeZoneRequest struct {
+	TimeZone string `json:"timeZone"`
+	Version  *int   `json:"version"`
+}
+
+type BranchTimeZoneResponse struct {
+	StatusCode int            `json:"statusCode"`
+	Message    string         `json:"message"`
+	Data       *models.Branch `json:"data"`
+}
+
+// PanelTypeCalendarRequest sets the calendar (gregorian or jalali) of the dates a panel type
+// reports
+type PanelTypeCalendarRequest struct {
+	Calendar string `json:"calendar"`
+	Version  *int   `json:"version"`
+}
+
+type PanelTypeCalendarResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Message    string            `json:"message"`
+	Data       *models.PanelType `json:"data"`
+}
+
+// FindDrifting lists the branches whose latest event of the past day came from a panel clock
+// off by more than event.clockDriftToleranceSeconds
+func (s *PanelClockService) FindDrifting(token string) (*PanelClockListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelClockService.FindDrifting")
+	if err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	tolerance := readIntSetting(s.DB, "event.clockDriftToleranceSeconds", 300)
+	since := time.Now().Add(-24 * time.Hour)
+
+	var rows []struct {
+		BranchID   string
+		Name       string
+		Code       int
+		TimeZone   string
+		ClockDrift int
+		CreatedAt  time.Time
+	}
+	query := s.DB.Model(&models.Event{}).
+		Select(`"Event"."branchId" AS branch_id, "Branch".name, "Branch".code, "Branch"."timeZone" AS time_zone, "Event"."clockDrift" AS clock_drift, "Event"."createdAt" AS created_at`).
+		Joins(`JOIN "Branch" ON "Branch".id = "Event"."branchId" AND "Branch"."deletedAt" IS NULL`).
+		Where(`"Event"."clockDrift" IS NOT NULL AND "Event"."createdAt" >= ?`, since).
+		Where(`"Event"."createdAt" = (SELECT MAX(latest."createdAt") FROM "Event" latest WHERE latest."branchId" = "Event"."branchId" AND latest."clockDrift" IS NOT NULL AND latest."deletedAt" IS NULL)`)
+	if err := scope.Branches(query).Order(`"Branch".code`).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	drifting := []PanelClockStatus{}
+	for _, row := range rows {
+		if tolerance > 0 && row.ClockDrift <= tolerance && row.ClockDrift >= -tolerance {
+			continue
+		}
+		drifting = append(drifting, PanelClockStatus{
+			BranchID:    row.BranchID,
+			BranchName:  row.Name,
+			BranchCode:  row.Code,
+			TimeZone:    row.TimeZone,
+			ClockDrift:  row.ClockDrift,
+			LastEventAt: row.CreatedAt,
+		})
+	}
+	return &PanelClockListResponse{
+		StatusCode: 200,
+		Message:    "Drifting panel clocks fetched successfully",
+		Data:       drifting,
+	}, nil
+}
+
+// SetTimeZone sets the IANA time zone (e.g. Asia/Tehran) the panel clock of a branch runs in
+func (s *PanelClockService) SetTimeZone(token string, branchID string, req BranchTimeZoneRequest) (*BranchTimeZoneResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelClockService.SetTimeZone")
+	if err != nil {
+		return nil, err
+	}
+	zone := strings.TrimSpace(req.TimeZone)
+	if zone != "" {
+		if _, err := time.LoadLocation(zone); err != nil {
+			return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown time zone %q", zone)}
+		}
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	if !scope.AllowsBranch(s.DB, branchID) {
+		return nil, &ServiceError{StatusCode: 404, Message: "branch not found"}
+	}
+
+	var branch models.Branch
+	if err := s.DB.Where("id = ?", branchID).First(&branch).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "branch not found"}
+		}
+		return nil, err
+	}
+	err = updateVersioned(s.DB.WithContext(caller.Context()), &branch, branchID, req.Version, map[string]interface{}{"timeZone": zone})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BranchTimeZoneResponse{
+		StatusCode: 200,
+		Message:    "Branch time zone updated successfully",
+		Data:       &branch,
+	}, nil
+}
+
+// SetCalendar sets the calendar a panel type reports its dates in. The panel times of the
+// events from branches of that type are read again in the background with the new calendar.
+func (s *PanelClockService) SetCalendar(token string, panelTypeID string, req PanelTypeCalendarRequest) (*PanelTypeCalendarResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelClockService.SetCalendar")
+	if err != nil {
+		return nil, err
+	}
+	calendar := strings.ToLower(strings.TrimSpace(req.Calendar))
+	if !validCalendar(calendar) {
+		return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown calendar %q", req.Calendar)}
+	}
+
+	var panelType models.PanelType
+	if err := s.DB.Where("id = ?", panelTypeID).First(&panelType).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "panel type not found"}
+		}
+		return nil, err
+	}
+	changed := panelType.Calendar != calendar
+	err = updateVersioned(s.DB.WithContext(caller.Context()), &panelType, panelTypeID, req.Version, map[string]interface{}{"calendar": calendar})
+	if err != nil {
+		return nil, err
+	}
+
+	if changed {
+		// زمان‌های خوانده‌شده با تقویم قبلی پاک و دوباره پر می‌شوند؛ بدون ActionLog برای هر رویداد
+		err = s.DB.Exec(`UPDATE "Event" SET "panelTime" = NULL, "clockDrift" = NULL, "panelTimeInvalid" = ? WHERE "branchId" IN (SELECT id FROM "Branch" WHERE "panelTypeId" = ?)`, false, panelTypeID).Error
+		if err != nil {
+			return nil, err
+		}
+		go BackfillPanelTimes(s.DB)
+	}
+
+	return &PanelTypeCalendarResponse{
+		StatusCode: 200,
+		Message:    "Panel type calendar updated successfully",
+		Data:       &panelType,
+	}, nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+func TestParsePanelTime(t *testing.T) {
+	tehran := time.FixedZone("IRST", 3*3600+1800)
+	cases := []struct {
+		name     string
+		date     string
+		clock    string
+		calendar string
+		want     string // خالی یعنی خطا
+	}{
+		{"gregorian", "2024-05-01", "10:30", CalendarGregorian, "2024-05-01 10:30:00"},
+		{"gregorian seconds", "2024-05-01", "10:30:15", CalendarGregorian, "2024-05-01 10:30:15"},
+		{"gregorian two digit year", "24-05-01", "10:30", CalendarGregorian, "2024-05-01 10:30:00"},
+		{"gregorian leap day", "2024-02-29", "00:00", CalendarGregorian, "2024-02-29 00:00:00"},
+		{"gregorian day past month", "2023-02-29", "00:00", CalendarGregorian, ""},
+		{"jalali date read as gregorian", "1403-01-01", "00:00", CalendarGregorian, ""},
+		{"month out of range", "2024-13-01", "00:00", CalendarGregorian, ""},
+		{"hour out of range", "2024-05-01", "24:00", CalendarGregorian, ""},
+		{"missing minute", "2024-05-01", "10", CalendarGregorian, ""},
+		{"not a number", "2024-May-01", "10:30", CalendarGregorian, ""},
+		{"jalali", "1403-01-01", "08:15", CalendarJalali, "2024-03-20 08:15:00"},
+		{"jalali two digit year", "03-01-01", "08:15", CalendarJalali, "2024-03-20 08:15:00"},
+		{"jalali two digit year below pivot", "49-01-01", "00:00", CalendarJalali, "2070-03-20 00:00:00"},
+		{"jalali two digit year at pivot", "50-01-01", "00:00", CalendarJalali, "1971-03-21 00:00:00"},
+		{"jalali two digit past century", "99-12-30", "23:59", CalendarJalali, "2021-03-20 23:59:00"},
+		{"jalali leap esfand", "1403-12-30", "12:00", CalendarJalali, "2025-03-20 12:00:00"},
+		{"jalali esfand 30 of common year", "1402-12-30", "12:00", CalendarJalali, ""},
+		{"jalali mehr 31", "1403-07-31", "12:00", CalendarJalali, ""},
+		{"gregorian date read as jalali", "2024-05-01", "10:30", CalendarJalali, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parsePanelTime(c.date, c.clock, c.calendar, tehran)
+			if c.want == "" {
+				if err == nil {
+					t.Fatalf("parsePanelTime(%q, %q) = %s, want an error", c.date, c.clock, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parsePanelTime(%q, %q): %v", c.date, c.clock, err)
+			}
+			if got.Location() != tehran || got.Format("2006-01-02 15:04:05") != c.want {
+				t.Errorf("parsePanelTime(%q, %q) = %s, want %s in %s", c.date, c.clock, got, c.want, tehran)
+			}
+		})
+	}
+}
+
+func TestBackfillPanelTimesMarksInvalidEvents(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	events := []models.Event{
+		{ID: "valid", DedupHash: "valid", ConfirmationStatus: "Unconfirmed", Date: "2024-05-01", Time: "10:30", CreatedAt: now},
+		{ID: "invalid", DedupHash: "invalid", ConfirmationStatus: "Unconfirmed", Date: "2024-13-01", Time: "10:30", CreatedAt: now},
+	}
+	if err := db.Create(&events).Error; err != nil {
+		t.Fatalf("create events: %v", err)
+	}
+
+	BackfillPanelTimes(db)
+
+	var valid, invalid models.Event
+	db.Where("id = ?", "valid").Take(&valid)
+	db.Where("id = ?", "invalid").Take(&invalid)
+	if valid.PanelTime == nil || valid.PanelTimeInvalid {
+		t.Errorf("valid event: panelTime %v, panelTimeInvalid %v", valid.PanelTime, valid.PanelTimeInvalid)
+	}
+	if invalid.PanelTime != nil || !invalid.PanelTimeInvalid {
+		t.Errorf("invalid event: panelTime %v, panelTimeInvalid %v", invalid.PanelTime, invalid.PanelTimeInvalid)
+	}
+
+	// رویداد علامت‌خورده در اجرای بعدی دوباره خوانده نمی‌شود
+	var pending int64
+	db.Model(&models.Event{}).Where(`"panelTime" IS NULL AND "date" <> '' AND NOT "panelTimeInvalid"`).Count(&pending)
+	if pending != 0 {
+		t.Errorf("%d events are still pending after the backfill", pending)
+	}
+}
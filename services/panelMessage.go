@@ -0,0 +1,207 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// decodePanelMessage splits message with the delimiter of panelType and names its fields with
+// the event format. A trailing delimiter is ignored, like the one before the dedup hash.
+func decodePanelMessage(panelType *models.PanelType, message string) *PanelParseResult {
+	result := &PanelParseResult{Fields: []PanelParseField{}, Extra: []string{}, Problems: []string{}, values: map[string]string{}}
+	parts := strings.Split(strings.TrimSuffix(message, panelType.Delimiter), panelType.Delimiter)
+	for i, token := range panelType.EventFormat {
+		if i >= len(parts) {
+			result.problem("the message has %d fields but the format expects %d", len(parts), len(panelType.EventFormat))
+			result.short = true
+			break
+		}
+		value := strings.TrimSpace(parts[i])
+		result.Fields = append(result.Fields, PanelParseField{Position: i + 1, Token: token, Value: value})
+		if token != panelFormatSkip {
+			result.values[token] = value
+		}
+	}
+	if len(parts) > len(panelType.EventFormat) {
+		result.Extra = parts[len(panelType.EventFormat):]
+	}
+	return result
+}
+
+func (r *PanelParseResult) problem(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// number reads the field token as a number; false when the message does not have it or it is
+// not a number
+func (r *PanelParseResult) number(token string) (int, bool) {
+	value, ok := r.values[token]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		r.problem("%s %q is not a number", token, value)
+		return 0, false
+	}
+	return n, true
+}
+
+// resolve reads the panel time of a message decoded with panelType and finds the alarm of the
+// panel type and the partition, zone and employee of branch it points at. branch is nil when
+// no branch sent the message; alarms are only looked up for a saved panel type.
+func (r *PanelParseResult) resolve(db *gorm.DB, panelType *models.PanelType, branch *models.Branch) error {
+	r.Branch = branch
+
+	clockRead := true
+	for _, token := range panelFormatClock {
+		_, ok := r.values[token]
+		clockRead = clockRead && ok
+	}
+	if clockRead {
+		r.date, r.clock = panelDateStrings(r.values["year"], r.values["month"], r.values["day"], r.values["hour"], r.values["minute"])
+		if second, ok := r.values["second"]; ok {
+			r.clock += ":" + padNumber(second, 2)
+		}
+		zone := ""
+		if branch != nil {
+			zone = branch.TimeZone
+		}
+		panelTime, err := parsePanelTime(r.date, r.clock, panelType.Calendar, panelLocation(db, zone))
+		if err != nil {
+			r.problem("%s", err.Error())
+		} else {
+			r.PanelTime = &panelTime
+		}
+	}
+
+	if code, ok := r.number("alarmCode"); ok && panelType.ID != "" {
+		// برای پیام‌های UDP هشدار پروتکل IP در اولویت است
+		var alarms []models.Alarm
+		err := db.Where(`code = ? AND "panelTypeId" = ?`, code, panelType.ID).
+			Order(gorm.Expr("CASE WHEN protocol = ? THEN 0 ELSE 1 END", models.AlarmProtocolIP)).
+			Find(&alarms).Error
+		if err != nil {
+			return err
+		}
+		if len(alarms) == 0 {
+			r.problem("no alarm with code %d for this panel type", code)
+		} else {
+			r.Alarm = &alarms[0]
+		}
+	}
+
+	if branch == nil {
+		return nil
+	}
+	partitionID := branch.MainPartitionID
+	if localID, ok := r.number("partitionNumber"); ok {
+		var partition models.Partition
+		err := db.Where(`"branchId" = ? AND "localId" = ?`, branch.ID, localID).First(&partition).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		partitionID = partition.ID
+		if partition.ID == "" {
+			r.problem("the branch has no partition %d", localID)
+		}
+	}
+	if partitionID != "" {
+		var partition models.Partition
+		if err := db.Where("id = ?", partitionID).First(&partition).Error; err == nil {
+			r.Partition = &partition
+		}
+	}
+	if localID, ok := r.number("zoneId"); ok && r.Partition != nil {
+		var zone models.Zone
+		err := db.Where(`"partitionId" = ? AND "localId" = ?`, r.Partition.ID, localID).First(&zone).Error
+		if err == nil {
+			r.Zone = &zone
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.problem("partition %d has no zone %d", r.Partition.LocalID, localID)
+		} else {
+			return err
+		}
+	}
+	if localID, ok := r.number("employeeId"); ok {
+		var employee models.Employee
+		err := db.Where(`"branchId" = ? AND "localId" = ?`, branch.ID, localID).First(&employee).Error
+		if err == nil {
+			r.Employee = &employee
+		} else if errors.Is(err, gorm.ErrRecordNotFound) {
+			r.problem("the branch has no employee %d", localID)
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// description is what an event read from message is stored with: the label of its alarm, or
+// the message itself when the alarm is unknown
+func (r *PanelParseResult) description(message string) string {
+	if r.Alarm != nil && r.Alarm.Label != "" {
+		return r.Alarm.Label
+	}
+	return message
+}
+
+// readReceivedMessage reads a message received for receiverID with the event format of the
+// panel type of the branch that sent it. The panel types are tried in code order until the
+// panel code read with one belongs to a branch of that type on the receiver. A message no
+// branch claims is read with the first format it fits, without a branch or alarm; one that
+// fits no format is refused.
+func readReceivedMessage(db *gorm.DB, receiverID string, message string) (*PanelParseResult, *models.PanelType, error) {
+	var panelTypes []models.PanelType
+	if err := db.Order("code").Order("name").Find(&panelTypes).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var fallback *PanelParseResult
+	var fallbackType *models.PanelType
+	var fallbackBranch *models.Branch
+	for i := range panelTypes {
+		panelType := &panelTypes[i]
+		if checkPanelFormat(panelType.Delimiter, panelType.EventFormat) != nil {
+			continue
+		}
+		result := decodePanelMessage(panelType, message)
+		code, ok := result.values["panelCode"]
+		if result.short || !ok {
+			continue
+		}
+		branch := findPanelBranch(db, receiverID, code)
+		if branch != nil && branch.PanelTypeID == panelType.ID {
+			if err := result.resolve(db, panelType, branch); err != nil {
+				return nil, nil, err
+			}
+			return result, panelType, nil
+		}
+		if fallback == nil {
+			fallback, fallbackType = result, panelType
+			// شعبه بدون نوع پنل با هر قالبی که کد پنلش را پیدا کند خوانده می‌شود
+			if branch != nil && branch.PanelTypeID == "" {
+				fallbackBranch = branch
+			}
+		}
+	}
+	if fallback == nil {
+		return nil, nil, errors.New("the message does not fit the event format of any panel type")
+	}
+
+	if fallbackBranch == nil {
+		fallback.problem("no branch with panel code %q on this receiver", fallback.values["panelCode"])
+	}
+	// نوع پنل حدس زده شده است؛ هشدار با آن پیدا نمی‌شود و تاریخ میلادی خوانده می‌شود
+	guessed := &models.PanelType{Delimiter: fallbackType.Delimiter, EventFormat: fallbackType.EventFormat, Calendar: CalendarGregorian}
+	if err := fallback.resolve(db, guessed, fallbackBranch); err != nil {
+		return nil, nil, err
+	}
+	return fallback, fallbackType, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+func TestReadReceivedMessage(t *testing.T) {
+	db := newTestDB(t)
+	pazhonic := models.PanelType{ID: "pazhonic", Name: "PZH-MCU", Code: 1, Delimiter: ";", Calendar: CalendarGregorian,
+		EventFormat: []string{"year", "month", "day", "hour", "minute", "second", "panelCode", "alarmCode", "zoneId", "employeeId", "partitionNumber", "eventReference"}}
+	other := models.PanelType{ID: "other", Name: "OTHER", Code: 2, Delimiter: ",", Calendar: CalendarJalali,
+		EventFormat: []string{"panelCode", "alarmCode", "year", "month", "day", "hour", "minute"}}
+	rows := []any{
+		&pazhonic,
+		&other,
+		&models.Branch{ID: "branch", Code: 1, PanelCode: 1234, PanelTypeID: pazhonic.ID, TimeZone: "UTC"},
+		&models.Branch{ID: "other-branch", Code: 2, PanelCode: 4321, PanelTypeID: other.ID, TimeZone: "UTC"},
+		&models.Partition{ID: "partition", BranchID: "branch", LocalID: 1},
+		&models.Zone{ID: "zone", PartitionID: "partition", LocalID: 3},
+		&models.Employee{ID: "employee", BranchID: "branch", LocalID: 5},
+		&models.Alarm{ID: "alarm", Code: 130, Label: "test", Protocol: models.AlarmProtocolIP, PanelTypeID: pazhonic.ID},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatalf("create %T: %v", row, err)
+		}
+	}
+	if err := db.Model(&models.Branch{}).Where("id = ?", "branch").Update("mainPartitionId", "partition").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	result, panelType, err := readReceivedMessage(db, "", "2024;05;01;10;30;15;1234;130;3;5;1;77;")
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	if panelType.ID != pazhonic.ID || result.Branch == nil || result.Branch.ID != "branch" {
+		t.Fatalf("message read with %s for branch %+v", panelType.Name, result.Branch)
+	}
+	if result.Alarm == nil || result.Alarm.ID != "alarm" || result.Partition == nil || result.Partition.ID != "partition" ||
+		result.Zone == nil || result.Zone.ID != "zone" || result.Employee == nil || result.Employee.ID != "employee" {
+		t.Errorf("resolved alarm %v, partition %v, zone %v, employee %v", result.Alarm, result.Partition, result.Zone, result.Employee)
+	}
+	if got := result.description("2024;05;01;10;30;15;1234;130;3;5;1;77;"); got != "test" {
+		t.Errorf("event described as %q, want the alarm label", got)
+	}
+	want := time.Date(2024, 5, 1, 10, 30, 15, 0, time.UTC)
+	if result.PanelTime == nil || !result.PanelTime.Equal(want) || result.date != "2024-05-01" || result.clock != "10:30:15" {
+		t.Errorf("panel time %v (%s %s), want %s", result.PanelTime, result.date, result.clock, want)
+	}
+
+	// قالب دیگر با جداکننده و تقویم خودش خوانده می‌شود
+	result, panelType, err = readReceivedMessage(db, "", "4321,130,1403,1,1,9,0")
+	if err != nil {
+		t.Fatalf("read message of the other panel type: %v", err)
+	}
+	want = time.Date(2024, 3, 20, 9, 0, 0, 0, time.UTC)
+	if panelType.ID != other.ID || result.Branch == nil || result.Branch.ID != "other-branch" || result.Alarm != nil ||
+		result.PanelTime == nil || !result.PanelTime.Equal(want) {
+		t.Errorf("other message read with %s: branch %v, alarm %v, panel time %v", panelType.Name, result.Branch, result.Alarm, result.PanelTime)
+	}
+
+	// کد پنل ناشناخته رویداد را بدون شعبه و هشدار نگه می‌دارد
+	result, _, err = readReceivedMessage(db, "", "2024;05;01;10;30;15;9999;130;3;5;1;77")
+	if err != nil {
+		t.Fatalf("read message of an unknown panel: %v", err)
+	}
+	if result.Branch != nil || result.Alarm != nil || result.values["panelCode"] != "9999" || len(result.Problems) == 0 {
+		t.Errorf("unknown panel: branch %v, alarm %v, panel code %q, problems %v", result.Branch, result.Alarm, result.values["panelCode"], result.Problems)
+	}
+	// بدون هشدار، خود پیام توضیح رویداد است
+	if got := result.description("2024;05;01;10;30;15;9999;130;3;5;1;77"); got != "2024;05;01;10;30;15;9999;130;3;5;1;77" {
+		t.Errorf("event without an alarm described as %q, want the message", got)
+	}
+
+	// کد پنل روی گیرنده دیگر پیدا نمی‌شود
+	result, _, err = readReceivedMessage(db, "receiver", "2024;05;01;10;30;15;1234;130;3;5;1;77")
+	if err != nil || result.Branch != nil {
+		t.Errorf("message on another receiver found branch %v (%v)", result.Branch, err)
+	}
+
+	if _, _, err := readReceivedMessage(db, "", "hello"); err == nil {
+		t.Error("a message that fits no format was read")
+	}
+
+	// TestParse همان نتیجه پیام دریافتی را می‌دهد
+	owner := models.User{ID: "owner", Username: "owner", Password: "-", Type: "OWNER", Status: models.UserStatusOffline}
+	if err := db.Create(&owner).Error; err != nil {
+		t.Fatal(err)
+	}
+	token, err := newSession(db, owner.ID, "", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &PanelTypeService{DB: db, Authz: &Authorizer{DB: db}}
+	res, err := s.TestParse(token, PanelParseRequest{PanelTypeID: pazhonic.ID, Message: "2024;05;01;10;30;15;1234;130;3;5;1;77;"})
+	if err != nil {
+		t.Fatalf("TestParse: %v", err)
+	}
+	parsed := res.Data
+	if parsed.Branch == nil || parsed.Branch.ID != "branch" || parsed.Alarm == nil || parsed.Alarm.ID != "alarm" ||
+		parsed.Zone == nil || parsed.Zone.ID != "zone" || parsed.Employee == nil || parsed.Employee.ID != "employee" ||
+		parsed.PanelTime == nil || !parsed.PanelTime.Equal(time.Date(2024, 5, 1, 10, 30, 15, 0, time.UTC)) {
+		t.Errorf("TestParse = %+v", parsed)
+	}
+}
@@ -0,0 +1,399 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PanelTypeService manages the panel types and the format their messages are read with.
+// EventFormat names, in order, what each delimited field of a panel message holds.
+type PanelTypeService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+// panelFormatTokens are the fields a panel message can hold
+var panelFormatTokens = []string{
+	"year", "month", "day", "hour", "minute", "second",
+	"panelCode", "alarmCode", "zoneId", "employeeId", "partitionNumber", "eventReference",
+}
+
+// panelFormatSkip marks a field of the message that is not read; it may appear many times
+const panelFormatSkip = "ignore"
+
+// panelFormatRequired are needed to tie a message to its branch and alarm
+var panelFormatRequired = []string{"panelCode", "alarmCode"}
+
+// panelFormatClock are needed together to read the panel time; second is optional
+var panelFormatClock = []string{"year", "month", "day", "hour", "minute"}
+
+type PanelTypePage struct {
+	Total      int64              `json:"total"`
+	Page       int                `json:"page"`
+	Limit      int                `json:"limit"`
+	TotalPages int                `json:"totalPages"`
+	Data       []models.PanelType `json:"data"`
+}
+
+type PanelTypeListResponse struct {
+	StatusCode int           `json:"statusCode"`
+	Message    string        `json:"message"`
+	Data       PanelTypePage `json:"data"`
+}
+
+type PanelTypeResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Message    string            `json:"message"`
+	Data       *models.PanelType `json:"data"`
+}
+
+// CreatePanelTypeRequest adds a panel type; an empty Calendar is gregorian
+type CreatePanelTypeRequest struct {
+	Name        string   `json:"name"`
+	Model       string   `json:"model"`
+	Code        int      `json:"code"`
+	Delimiter   string   `json:"delimiter"`
+	EventFormat []string `json:"eventFormat"`
+	Calendar    string   `json:"calendar"`
+}
+
+// UpdatePanelTypeRequest carries only the fields to change; nil fields are left untouched.
+// The calendar is changed with PanelClockService.SetCalendar, which reads the stored event
+// times again.
+type UpdatePanelTypeRequest struct {
+	Name        *string   `json:"name"`
+	Model       *string   `json:"model"`
+	Code        *int      `json:"code"`
+	Delimiter   *string   `json:"delimiter"`
+	EventFormat *[]string `json:"eventFormat"`
+	// Version is the version the client read; the update fails with a conflict if it is stale
+	Version *int `json:"version"`
+}
+
+// PanelParseRequest reads Message with the panel type PanelTypeID. A non-empty Delimiter or
+// EventFormat replaces the stored one, so a format can be tried before it is saved; without
+// PanelTypeID both are needed. The branch is looked up on ReceiverID, or on the receiver of
+// the UDP listener when it is empty.
+type PanelParseRequest struct {
+	PanelTypeID string   `json:"panelTypeId"`
+	ReceiverID  string   `json:"receiverId"`
+	Delimiter   string   `json:"delimiter"`
+	EventFormat []string `json:"eventFormat"`
+	Message     string   `json:"message"`
+}
+
+// PanelParseField is one field of the message with the token it is read as
+type PanelParseField struct {
+	Position int    `json:"position"`
+	Token    string `json:"token"`
+	Value    string `json:"value"`
+}
+
+// PanelParseResult is what a message decodes to and the rows it points at. Problems lists
+// what could not be read or found; a nil row was not found.
+type PanelParseResult struct {
+	Fields    []PanelParseField `json:"fields"`
+	Extra     []string          `json:"extra"`
+	PanelTime *time.Time        `json:"panelTime"`
+	Branch    *models.Branch    `json:"branch"`
+	Alarm     *models.Alarm     `json:"alarm"`
+	Partition *models.Partition `json:"partition"`
+	Zone      *models.Zone      `json:"zone"`
+	Employee  *models.Employee  `json:"employee"`
+	Problems  []string          `json:"problems"`
+
+	// values are the fields by token; date and clock are the padded panel date and time
+	values      map[string]string
+	date, clock string
+	// short is set when the message has fewer fields than the format
+	short bool
+}
+
+type PanelParseResponse struct {
+	StatusCode     int              `json:"statusCode"`
+	Message        string           `json:"message"`
+	Data           PanelParseResult `json:"data"`
+	RedactedFields []string         `json:"redactedFields"`
+}
+
+// FindAll returns a page of the panel types ordered by code
+func (s *PanelTypeService) FindAll(token string, page, limit int) (*PanelTypeListResponse, error) {
+	if _, err := s.Authz.Authorize(token, "PanelTypeService.FindAll"); err != nil {
+		return nil, err
+	}
+	page, limit = normalizePage(page, limit)
+
+	query := s.DB.Model(&models.PanelType{})
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+	panelTypes := []models.PanelType{}
+	if err := query.Order("code").Order("name").Offset((page - 1) * limit).Limit(limit).Find(&panelTypes).Error; err != nil {
+		return nil, err
+	}
+	return &PanelTypeListResponse{
+		StatusCode: 200,
+		Message:    "Panel types fetched successfully",
+		Data:       PanelTypePage{Total: total, Page: page, Limit: limit, TotalPages: totalPages(total, limit), Data: panelTypes},
+	}, nil
+}
+
+// Create adds a panel type after checking its name, delimiter and event format
+func (s *PanelTypeService) Create(token string, req CreatePanelTypeRequest) (*PanelTypeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelTypeService.Create")
+	if err != nil {
+		return nil, err
+	}
+	calendar := strings.ToLower(strings.TrimSpace(req.Calendar))
+	if calendar == "" {
+		calendar = CalendarGregorian
+	}
+	if !validCalendar(calendar) {
+		return nil, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown calendar %q", req.Calendar)}
+	}
+	panelType := models.PanelType{
+		ID:          uuid.NewString(),
+		Name:        strings.TrimSpace(req.Name),
+		Model:       strings.TrimSpace(req.Model),
+		Code:        req.Code,
+		Delimiter:   req.Delimiter,
+		EventFormat: trimFormat(req.EventFormat),
+		Calendar:    calendar,
+	}
+	if err := checkPanelFormat(panelType.Delimiter, panelType.EventFormat); err != nil {
+		return nil, err
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := checkPanelTypeName(tx, panelType.Name, panelType.ID); err != nil {
+			return err
+		}
+		return tx.Create(&panelType).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PanelTypeResponse{StatusCode: 200, Message: "Panel type created successfully", Data: &panelType}, nil
+}
+
+// Update changes the given fields of a panel type; a new delimiter or event format is
+// checked together with the stored other half
+func (s *PanelTypeService) Update(token string, id string, req UpdatePanelTypeRequest) (*PanelTypeResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelTypeService.Update")
+	if err != nil {
+		return nil, err
+	}
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		updates["name"] = strings.TrimSpace(*req.Name)
+	}
+	if req.Model != nil {
+		updates["model"] = strings.TrimSpace(*req.Model)
+	}
+	if req.Code != nil {
+		updates["code"] = *req.Code
+	}
+	if req.Delimiter != nil {
+		updates["delimiter"] = *req.Delimiter
+	}
+	var format []string
+	if req.EventFormat != nil {
+		format = trimFormat(*req.EventFormat)
+		encoded, err := json.Marshal(format)
+		if err != nil {
+			return nil, err
+		}
+		updates["eventFormat"] = string(encoded)
+	}
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+
+	var panelType models.PanelType
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		var current models.PanelType
+		if err := tx.Where("id = ?", id).First(&current).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &ServiceError{StatusCode: 404, Message: "panel type not found"}
+			}
+			return err
+		}
+		if req.Delimiter != nil || req.EventFormat != nil {
+			delimiter := current.Delimiter
+			if req.Delimiter != nil {
+				delimiter = *req.Delimiter
+			}
+			if req.EventFormat == nil {
+				format = current.EventFormat
+			}
+			if err := checkPanelFormat(delimiter, format); err != nil {
+				return err
+			}
+		}
+		if req.Name != nil {
+			if err := checkPanelTypeName(tx, strings.TrimSpace(*req.Name), id); err != nil {
+				return err
+			}
+		}
+		return updateVersioned(tx, &panelType, id, req.Version, updates)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &PanelTypeResponse{StatusCode: 200, Message: "Panel type updated successfully", Data: &panelType}, nil
+}
+
+// TestParse decodes a sample message with a panel type's format and finds the branch, alarm,
+// partition, zone and employee it points at with the same code received messages are read
+// with. Branches outside the caller's locations are not found.
+func (s *PanelTypeService) TestParse(token string, req PanelParseRequest) (*PanelParseResponse, error) {
+	caller, err := s.Authz.Authorize(token, "PanelTypeService.TestParse")
+	if err != nil {
+		return nil, err
+	}
+	panelType := models.PanelType{Calendar: CalendarGregorian}
+	if req.PanelTypeID != "" {
+		if err := s.DB.Where("id = ?", req.PanelTypeID).First(&panelType).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, &ServiceError{StatusCode: 404, Message: "panel type not found"}
+			}
+			return nil, err
+		}
+	}
+	if req.Delimiter != "" {
+		panelType.Delimiter = req.Delimiter
+	}
+	if len(req.EventFormat) > 0 {
+		panelType.EventFormat = trimFormat(req.EventFormat)
+	}
+	if err := checkPanelFormat(panelType.Delimiter, panelType.EventFormat); err != nil {
+		return nil, err
+	}
+	message := strings.TrimRight(req.Message, "\r\n")
+	if strings.TrimSpace(message) == "" {
+		return nil, &ServiceError{StatusCode: 400, Message: "message is required"}
+	}
+
+	result := decodePanelMessage(&panelType, message)
+
+	// شعبه مثل پیام دریافتی از روی کد پنل روی گیرنده پیدا می‌شود
+	var branch *models.Branch
+	if code, ok := result.values["panelCode"]; ok {
+		scope, err := caller.locationScope(s.DB)
+		if err != nil {
+			return nil, err
+		}
+		receiverID := strings.TrimSpace(req.ReceiverID)
+		if receiverID == "" {
+			receiverID = listenerReceiver(s.DB)
+		}
+		branch = findPanelBranch(s.DB, receiverID, code)
+		if branch != nil && !scope.AllowsLocation(branch.LocationID) {
+			branch = nil
+		}
+		if branch == nil {
+			result.problem("no branch with panel code %q on this receiver", code)
+		}
+	}
+	if _, ok := result.values["alarmCode"]; ok && panelType.ID == "" {
+		result.problem("alarms are only looked up for a saved panel type")
+	}
+	if err := result.resolve(s.DB, &panelType, branch); err != nil {
+		return nil, err
+	}
+
+	redacted := []string{}
+	if result.Branch != nil {
+		redacted = append(redacted, caller.redact("Branch", result.Branch)...)
+	}
+	if result.Employee != nil {
+		redacted = append(redacted, caller.redact("Employee", result.Employee)...)
+	}
+	return &PanelParseResponse{
+		StatusCode:     200,
+		Message:        "Message parsed",
+		Data:           *result,
+		RedactedFields: redacted,
+	}, nil
+}
+
+// trimFormat trims the tokens of an event format
+func trimFormat(format []string) []string {
+	trimmed := make([]string, len(format))
+	for i, token := range format {
+		trimmed[i] = strings.TrimSpace(token)
+	}
+	return trimmed
+}
+
+// checkPanelFormat refuses an empty delimiter and an event format with unknown or repeated
+// tokens, without panelCode and alarmCode, or with only part of the panel time
+func checkPanelFormat(delimiter string, format []string) error {
+	if strings.TrimSpace(delimiter) == "" {
+		return &ServiceError{StatusCode: 400, Message: "delimiter is required"}
+	}
+	seen := map[string]bool{}
+	for _, token := range format {
+		if token == panelFormatSkip {
+			continue
+		}
+		known := false
+		for _, t := range panelFormatTokens {
+			known = known || t == token
+		}
+		if !known {
+			return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown event format field %q, expected one of: %s, %s", token, strings.Join(panelFormatTokens, ", "), panelFormatSkip)}
+		}
+		if seen[token] {
+			return &ServiceError{StatusCode: 400, Message: fmt.Sprintf("event format field %q is repeated", token)}
+		}
+		seen[token] = true
+	}
+	var missing []string
+	for _, token := range panelFormatRequired {
+		if !seen[token] {
+			missing = append(missing, token)
+		}
+	}
+	if len(missing) > 0 {
+		return &ServiceError{StatusCode: 400, Message: "the event format needs: " + strings.Join(missing, ", ")}
+	}
+	clock := 0
+	for _, token := range append([]string{"second"}, panelFormatClock...) {
+		if seen[token] {
+			clock++
+		}
+	}
+	if clock > 0 {
+		for _, token := range panelFormatClock {
+			if !seen[token] {
+				return &ServiceError{StatusCode: 400, Message: "the panel time needs all of: " + strings.Join(panelFormatClock, ", ")}
+			}
+		}
+	}
+	return nil
+}
+
+// checkPanelTypeName refuses an empty name or one another live panel type has
+func checkPanelTypeName(tx *gorm.DB, name string, id string) error {
+	if name == "" {
+		return &ServiceError{StatusCode: 400, Message: "name is required"}
+	}
+	var count int64
+	if err := tx.Model(&models.PanelType{}).Where("name = ? AND id <> ?", name, id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("a panel type named %q already exists", name)}
+	}
+	return nil
+}
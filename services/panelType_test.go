@@ -0,0 +1,36 @@
+package services
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckPanelFormat(t *testing.T) {
+	cases := []struct {
+		name      string
+		delimiter string
+		format    []string
+		err       string // خالی یعنی قالب پذیرفته می‌شود
+	}{
+		{"seeded format", ";", []string{"year", "month", "day", "hour", "minute", "second", "panelCode", "alarmCode", "zoneId", "employeeId", "partitionNumber", "eventReference"}, ""},
+		{"codes only", ",", []string{"panelCode", "alarmCode"}, ""},
+		{"ignored fields repeat", ";", []string{"ignore", "panelCode", "ignore", "alarmCode"}, ""},
+		{"clock without second", ";", []string{"panelCode", "alarmCode", "year", "month", "day", "hour", "minute"}, ""},
+		{"empty delimiter", " ", []string{"panelCode", "alarmCode"}, "delimiter is required"},
+		{"unknown field", ";", []string{"panelCode", "alarmCode", "branchName"}, `unknown event format field "branchName"`},
+		{"repeated field", ";", []string{"panelCode", "alarmCode", "panelCode"}, `"panelCode" is repeated`},
+		{"missing alarm code", ";", []string{"panelCode", "zoneId"}, "needs: alarmCode"},
+		{"empty format", ";", []string{}, "needs: panelCode, alarmCode"},
+		{"partial clock", ";", []string{"panelCode", "alarmCode", "hour", "minute"}, "the panel time needs all of"},
+		{"second alone", ";", []string{"panelCode", "alarmCode", "second"}, "the panel time needs all of"},
+	}
+	for _, c := range cases {
+		err := checkPanelFormat(c.delimiter, c.format)
+		switch {
+		case c.err == "" && err != nil:
+			t.Errorf("%s: %v", c.name, err)
+		case c.err != "" && (err == nil || !strings.Contains(err.Error(), c.err)):
+			t.Errorf("%s: %v, want an error containing %q", c.name, err, c.err)
+		}
+	}
+}
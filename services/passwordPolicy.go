@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// PasswordPolicy is read from AppSetting and applied on register, change and reset
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireSymbol bool `json:"requireSymbol"`
+	HistorySize   int  `json:"historySize"`
+	MaxAgeDays    int  `json:"maxAgeDays"`
+}
+
+type PasswordPolicyResponse struct {
+	StatusCode int            `json:"statusCode"`
+	Message    string         `json:"message"`
+	Data       PasswordPolicy `json:"data"`
+}
+
+func loadPasswordPolicy(db *gorm.DB) PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:     readIntSetting(db, "password.minLength", 8),
+		RequireUpper:  readBoolSetting(db, "password.requireUpper", true),
+		RequireLower:  readBoolSetting(db, "password.requireLower", true),
+		RequireDigit:  readBoolSetting(db, "password.requireDigit", true),
+		RequireSymbol: readBoolSetting(db, "password.requireSymbol", true),
+		HistorySize:   readIntSetting(db, "password.historySize", 5),
+		MaxAgeDays:    readIntSetting(db, "password.maxAgeDays", 90),
+	}
+}
+
+// GetPasswordPolicy returns the rules so the register and change forms can show them
+func (s *AuthService) GetPasswordPolicy() (*PasswordPolicyResponse, error) {
+	return &PasswordPolicyResponse{
+		StatusCode: 200,
+		Message:    "Password policy fetched successfully",
+		Data:       loadPasswordPolicy(s.DB),
+	}, nil
+}
+
+// Validate checks length and character classes and lists every rule the password breaks
+func (p PasswordPolicy) Validate(password string) error {
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+
+	var problems []string
+	if len([]rune(password)) < p.MinLength {
+		problems = append(problems, "be at least "+strconv.Itoa(p.MinLength)+" characters long")
+	}
+	if p.RequireUpper && !upper {
+		problems = append(problems, "contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		problems = append(problems, "contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		problems = append(problems, "contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		problems = append(problems, "contain a symbol")
+	}
+	if len(problems) > 0 {
+		return errors.New("password must " + strings.Join(problems, ", "))
+	}
+	return nil
+}
+
+// checkReuse refuses the current password and the last HistorySize ones
+func (p PasswordPolicy) checkReuse(db *gorm.DB, user *models.User, password string) error {
+	if user.Password != "" && bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) == nil {
+		return errors.New("new password must differ from the current one")
+	}
+	if p.HistorySize <= 0 {
+		return nil
+	}
+
+	var history []models.PasswordHistory
+	db.Where(`"userId" = ?`, user.ID).Order(`"createdAt" DESC`).Limit(p.HistorySize).Find(&history)
+	for _, h := range history {
+		if bcrypt.CompareHashAndPassword([]byte(h.PasswordHash), []byte(password)) == nil {
+			return errors.New("password was used recently, choose another one")
+		}
+	}
+	return nil
+}
+
+// expired reports whether the user's password is older than MaxAgeDays
+func (p PasswordPolicy) expired(user *models.User) bool {
+	if p.MaxAgeDays <= 0 {
+		return false
+	}
+	changed := user.CreatedAt
+	if user.PasswordChangedAt != nil {
+		changed = *user.PasswordChangedAt
+	}
+	return time.Since(changed) > time.Duration(p.MaxAgeDays)*24*time.Hour
+}
+
+// setPassword stores newHash for user, moves the old hash to the history and trims it
+func (p PasswordPolicy) setPassword(tx *gorm.DB, user *models.User, newHash string, mustChange bool) error {
+	if user.Password != "" {
+		entry := models.PasswordHistory{ID: uuid.New().String(), UserID: user.ID, PasswordHash: user.Password}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+		"password":           newHash,
+		"mustChangePassword": mustChange,
+		"passwordChangedAt":  &now,
+	}).Error
+	if err != nil {
+		return err
+	}
+
+	// فقط به اندازه‌ی سیاست تاریخچه نگه داشته می‌شود
+	keep := p.HistorySize
+	if keep < 0 {
+		keep = 0
+	}
+	var stale []string
+	tx.Model(&models.PasswordHistory{}).Where(`"userId" = ?`, user.ID).
+		Order(`"createdAt" DESC`).Offset(keep).Pluck("id", &stale)
+	if len(stale) > 0 {
+		return tx.Unscoped().Where("id IN ?", stale).Delete(&models.PasswordHistory{}).Error
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	strict := PasswordPolicy{MinLength: 8, RequireUpper: true, RequireLower: true, RequireDigit: true, RequireSymbol: true}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		broken   []string
+	}{
+		{"meets every rule", strict, "N3w!Passw0rd", nil},
+		{"too short", strict, "A1!a", []string{"at least 8 characters"}},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "رمزی", nil},
+		{"no uppercase", strict, "n3w!passw0rd", []string{"uppercase"}},
+		{"no lowercase", strict, "N3W!PASSW0RD", []string{"lowercase"}},
+		{"no digit", strict, "New!Password", []string{"digit"}},
+		{"no symbol", strict, "N3wPassw0rd", []string{"symbol"}},
+		{"every rule broken", strict, "", []string{"at least 8", "uppercase", "lowercase", "digit", "symbol"}},
+		{"rules switched off", PasswordPolicy{MinLength: 3}, "abc", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Validate(tt.password)
+			if len(tt.broken) == 0 {
+				if err != nil {
+					t.Fatalf("Validate(%q) = %v, want nil", tt.password, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate(%q) = nil, want an error about %v", tt.password, tt.broken)
+			}
+			for _, rule := range tt.broken {
+				if !strings.Contains(err.Error(), rule) {
+					t.Errorf("Validate(%q) = %q, missing %q", tt.password, err, rule)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyExpired(t *testing.T) {
+	old := time.Now().Add(-91 * 24 * time.Hour)
+	recent := time.Now().Add(-time.Hour)
+	tests := []struct {
+		name string
+		days int
+		user models.User
+		want bool
+	}{
+		{"changed recently", 90, models.User{PasswordChangedAt: &recent}, false},
+		{"changed too long ago", 90, models.User{PasswordChangedAt: &old}, true},
+		{"never changed, created long ago", 90, models.User{CreatedAt: old}, true},
+		{"no maximum age", 0, models.User{PasswordChangedAt: &old}, false},
+	}
+	for _, tt := range tests {
+		if got := (PasswordPolicy{MaxAgeDays: tt.days}).expired(&tt.user); got != tt.want {
+			t.Errorf("%s: expired() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPasswordHistory(t *testing.T) {
+	db := newTestDB(t)
+	policy := PasswordPolicy{HistorySize: 2}
+	hash := func(password string) string {
+		h, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(h)
+	}
+
+	user := models.User{ID: uuid.NewString(), Username: "history", Password: hash("first"), Type: "USER"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, password := range []string{"second", "third", "fourth"} {
+		if err := policy.checkReuse(db, &user, password); err != nil {
+			t.Fatalf("checkReuse(%q) = %v", password, err)
+		}
+		if err := policy.setPassword(db, &user, hash(password), false); err != nil {
+			t.Fatalf("setPassword(%q) = %v", password, err)
+		}
+		if err := db.Where("id = ?", user.ID).First(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+		// ترتیب تاریخچه با زمان ساخت تعیین می‌شود
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var kept int64
+	db.Model(&models.PasswordHistory{}).Where(`"userId" = ?`, user.ID).Count(&kept)
+	if kept != 2 {
+		t.Errorf("history keeps %d entries, want 2", kept)
+	}
+	for password, reused := range map[string]bool{"fourth": true, "third": true, "second": true, "first": false} {
+		err := policy.checkReuse(db, &user, password)
+		if (err != nil) != reused {
+			t.Errorf("checkReuse(%q) = %v, want reused %v", password, err, reused)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// RecycleBinService lists soft-deleted records and restores or permanently removes them.
+// Which action is needed depends on the model: READ to list, UPDATE to restore and DELETE
+// to purge. Both restore and purge go through the audit callbacks, so they land in ActionLog
+// as RESTORED and PURGED.
+type RecycleBinService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+// recycleRef points at rows of Model whose Column holds the id of another row
+type recycleRef struct {
+	Model  string
+	Column string
+}
+
+// recycleModel describes how a model behaves in the recycle bin
+type recycleModel struct {
+	// newRows returns a pointer to an empty slice of the model
+	newRows func() any
+	// unique lists the column sets no two live rows may share
+	unique [][]string
+	// parents must be live before a row can be restored
+	parents []recycleRef
+	// children belong to the row: restored with it when they were deleted together with it,
+	// purged with it, and a live child keeps it from being purged
+	children []recycleRef
+	// blockers are other rows that keep the row from being purged while they exist
+	blockers []recycleRef
+	// scope limits the rows to the caller's locations; nil when the model is not per location
+	scope func(l *LocationScope, db *gorm.DB) *gorm.DB
+}
+
+// recycleCascadeWindow is how long before its parent a child may have been deleted and still
+// count as deleted together with it
+const recycleCascadeWindow = time.Minute
+
+var recycleModels = map[string]recycleModel{
+	"Alarm": {
+		newRows:  func() any { return &[]models.Alarm{} },
+		unique:   [][]string{{"code", "protocol", "panelTypeId"}},
+		parents:  []recycleRef{{"PanelType", "panelTypeId"}},
+		blockers: []recycleRef{{"Event", "alarmId"}},
+	},
+	"AlarmCategory": {
+		newRows:  func() any { return &[]models.AlarmCategory{} },
+		unique:   [][]string{{"code"}},
+		blockers: []recycleRef{{"UserSetting", "alarmCategoryId"}},
+	},
+	"Branch": {
+		newRows:  func() any { return &[]models.Branch{} },
+		unique:   [][]string{{"code"}, {"receiverId", "panelCode"}},
+		parents:  []recycleRef{{"Location", "locationId"}, {"PanelType", "panelTypeId"}, {"Receiver", "receiverId"}},
+		children: []recycleRef{{"Partition", "branchId"}, {"Employee", "branchId"}, {"Equipment", "branchId"}},
+		blockers: []recycleRef{{"Event", "branchId"}},
+		scope: func(l *LocationScope, db *gorm.DB) *gorm.DB {
+			return l.Branches(db)
+		},
+	},
+	"Employee": {
+		newRows: func() any { return &[]models.Employee{} },
+		unique:  [][]string{{"branchId", "localId"}},
+		parents: []recycleRef{{"Branch", "branchId"}},
+		scope:   branchRowScope,
+	},
+	"Equipment": {
+		newRows: func() any { return &[]models.Equipment{} },
+		parents: []recycleRef{{"Branch", "branchId"}},
+		scope:   branchRowScope,
+	},
+	"Event": {
+		newRows: func() any { return &[]models.Event{} },
+		unique:  [][]string{{"dedupHash"}},
+		parents: []recycleRef{{"Branch", "branchId"}, {"Alarm", "alarmId"}},
+		scope: func(l *LocationScope, db *gorm.DB) *gorm.DB {
+			return l.Events(db)
+		},
+	},
+	"Location": {
+		newRows:  func() any { return &[]models.Location{} },
+		unique:   [][]string{{"label", "type", "parentId"}},
+		parents:  []recycleRef{{"Location", "parentId"}},
+		children: []recycleRef{{"Location", "parentId"}, {"UserLocation", "locationId"}},
+		blockers: []recycleRef{{"Branch", "locationId"}, {"User", "locationId"}},
+	},
+	"PanelType": {
+		newRows:  func() any { return &[]models.PanelType{} },
+		unique:   [][]string{{"name"}},
+		blockers: []recycleRef{{"Alarm", "panelTypeId"}, {"Branch", "panelTypeId"}},
+	},
+	"Partition": {
+		newRows:  func() any { return &[]models.Partition{} },
+		unique:   [][]string{{"branchId", "localId"}},
+		parents:  []recycleRef{{"Branch", "branchId"}},
+		children: []recycleRef{{"Zone", "partitionId"}},
+		scope:    branchRowScope,
+	},
+	"Receiver": {
+		newRows:  func() any { return &[]models.Receiver{} },
+		unique:   [][]string{{"token"}},
+		blockers: []recycleRef{{"Branch", "receiverId"}},
+	},
+	"User": {
+		newRows:  func() any { return &[]models.User{} },
+		unique:   [][]string{{"username"}},
+		parents:  []recycleRef{{"Location", "locationId"}},
+		children: []recycleRef{{"UserLocation", "userId"}, {"UserPermission", "userId"}, {"UserSetting", "userId"}, {"PersonalSetting", "userId"}},
+	},
+	"UserLocation": {
+		newRows: func() any { return &[]models.UserLocation{} },
+		unique:  [][]string{{"userId", "locationId"}},
+		parents: []recycleRef{{"User", "userId"}, {"Location", "locationId"}},
+	},
+	"UserPermission": {
+		newRows: func() any { return &[]models.UserPermission{} },
+		unique:  [][]string{{"userId", "permissionId"}},
+		parents: []recycleRef{{"User", "userId"}},
+	},
+	"UserSetting": {
+		newRows: func() any { return &[]models.UserSetting{} },
+		parents: []recycleRef{{"User", "userId"}, {"AlarmCategory", "alarmCategoryId"}},
+	},
+	"PersonalSetting": {
+		newRows: func() any { return &[]models.PersonalSetting{} },
+		parents: []recycleRef{{"User", "userId"}},
+	},
+	"Zone": {
+		newRows: func() any { return &[]models.Zone{} },
+		unique:  [][]string{{"partitionId", "localId"}},
+		parents: []recycleRef{{"Partition", "partitionId"}, {"ZoneType", "zoneTypeId"}},
+		scope: func(l *LocationScope, db *gorm.DB) *gorm.DB {
+			if l.Unrestricted {
+				return db
+			}
+			return db.Where(`"partitionId" IN (SELECT id FROM "Partition" WHERE "branchId" IN (SELECT id FROM "Branch" WHERE "locationId" IN ?))`, l.nonEmptyIDs())
+		},
+	},
+	"ZoneType": {
+		newRows:  func() any { return &[]models.ZoneType{} },
+		unique:   [][]string{{"label"}},
+		blockers: []recycleRef{{"Zone", "zoneTypeId"}},
+	},
+}
+
+// branchRowScope limits rows with a branchId to branches inside the scope
+func branchRowScope(l *LocationScope, db *gorm.DB) *gorm.DB {
+	if l.Unrestricted {
+		return db
+	}
+	return db.Where(`"branchId" IN (SELECT id FROM "Branch" WHERE "locationId" IN ?)`, l.nonEmptyIDs())
+}
+
+type RecycleBinModelsResponse struct {
+	StatusCode int      `json:"statusCode"`
+	Message    string   `json:"message"`
+	Data       []string `json:"data"`
+}
+
+type RecycleBinPage struct {
+	Total      int64 `json:"total"`
+	Page       int   `json:"page"`
+	Limit      int   `json:"limit"`
+	TotalPages int   `json:"totalPages"`
+	Data       any   `json:"data"`
+}
+
+type RecycleBinListResponse struct {
+	StatusCode     int            `json:"statusCode"`
+	Message        string         `json:"message"`
+	Data           RecycleBinPage `json:"data"`
+	RedactedFields []string       `json:"redactedFields"`
+}
+
+// RecycleBinResult lists every record restored or purged, keyed by model
+type RecycleBinResult map[string][]string
+
+type RecycleBinResponse struct {
+	StatusCode int              `json:"statusCode"`
+	Message    string           `json:"message"`
+	Data       RecycleBinResult `json:"data"`
+}
+
+// Models returns the models the caller may browse in the recycle bin
+func (s *RecycleBinService) Models(token string) (*RecycleBinModelsResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RecycleBinService.Models")
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range recycleModels {
+		if caller.Can(models.PermissionRead, name, "") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return &RecycleBinModelsResponse{
+		StatusCode: 200,
+		Message:    "Recycle bin models fetched successfully",
+		Data:       names,
+	}, nil
+}
+
+// FindAll returns a page of the deleted records of model, most recently deleted first
+func (s *RecycleBinService) FindAll(token string, model string, page, limit int) (*RecycleBinListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RecycleBinService.FindAll")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := recycleModelFor(caller, models.PermissionRead, model)
+	if err != nil {
+		return nil, err
+	}
+	page, limit = normalizePage(page, limit)
+
+	query, err := s.deletedRows(caller, spec, s.DB)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	if err := query.Model(spec.newRows()).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	rows := spec.newRows()
+	query, _ = s.deletedRows(caller, spec, s.DB)
+	if err := query.Order(`"deletedAt" DESC`).Offset((page - 1) * limit).Limit(limit).Find(rows).Error; err != nil {
+		return nil, err
+	}
+	// رمز عبور کاربران حذف‌شده هم نباید به فرانت برسد
+	if users, ok := rows.(*[]models.User); ok {
+		for i := range *users {
+			(*users)[i].Password = ""
+		}
+	}
+
+	return &RecycleBinListResponse{
+		StatusCode: 200,
+		Message:    "Deleted records fetched successfully",
+		Data: RecycleBinPage{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages(total, limit),
+			Data:       rows,
+		},
+		RedactedFields: caller.redact(model, rows),
+	}, nil
+}
+
+// Restore brings back a deleted record and the children deleted with it. Nothing is restored
+// when a parent is still deleted or a restored row would clash with a live one.
+func (s *RecycleBinService) Restore(token string, model string, id string) (*RecycleBinResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RecycleBinService.Restore")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := recycleModelFor(caller, models.PermissionUpdate, model)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.findDeleted(caller, spec, model, id); err != nil {
+		return nil, err
+	}
+
+	result := RecycleBinResult{}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		return restoreRow(tx, model, id, result)
+	})
+	if err != nil {
+		return nil, uniqueConflict(s.DB, err)
+	}
+	return &RecycleBinResponse{
+		StatusCode: 200,
+		Message:    "Record restored successfully",
+		Data:       result,
+	}, nil
+}
+
+// Purge removes a deleted record for good, together with its deleted children. Records still
+// referenced elsewhere, or with live children, are refused instead of being cascaded away.
+func (s *RecycleBinService) Purge(token string, model string, id string) (*RecycleBinResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RecycleBinService.Purge")
+	if err != nil {
+		return nil, err
+	}
+	spec, err := recycleModelFor(caller, models.PermissionDelete, model)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.findDeleted(caller, spec, model, id); err != nil {
+		return nil, err
+	}
+
+	result := RecycleBinResult{}
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		return purgeRow(tx, model, id, result)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &RecycleBinResponse{
+		StatusCode: 200,
+		Message:    "Record purged successfully",
+		Data:       result,
+	}, nil
+}
+
+func recycleModelFor(caller *Caller, action models.PermissionAction, model string) (recycleModel, error) {
+	spec, ok := recycleModels[model]
+	if !ok {
+		return spec, &ServiceError{StatusCode: 400, Message: fmt.Sprintf("unknown model %q", model)}
+	}
+	if !caller.Can(action, model, "") {
+		return spec, ErrForbidden
+	}
+	return spec, nil
+}
+
+// deletedRows selects the deleted rows of spec inside the caller's scope
+func (s *RecycleBinService) deletedRows(caller *Caller, spec recycleModel, db *gorm.DB) (*gorm.DB, error) {
+	query := db.Unscoped().Where(`"deletedAt" IS NOT NULL`)
+	if spec.scope != nil {
+		scope, err := caller.locationScope(s.DB)
+		if err != nil {
+			return nil, err
+		}
+		query = spec.scope(scope, query)
+	}
+	return query, nil
+}
+
+// findDeleted fails unless the deleted row model/id exists inside the caller's scope
+func (s *RecycleBinService) findDeleted(caller *Caller, spec recycleModel, model string, id string) error {
+	query, err := s.deletedRows(caller, spec, s.DB)
+	if err != nil {
+		return err
+	}
+	var count int64
+	if err := query.Model(spec.newRows()).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return &ServiceError{StatusCode: 404, Message: fmt.Sprintf("deleted %s not found", model)}
+	}
+	return nil
+}
+
+// loadRow reads a row of model, deleted or not, as column values
+func loadRow(tx *gorm.DB, model string, id string) (map[string]any, error) {
+	row := map[string]any{}
+	err := tx.Unscoped().Model(recycleModels[model].newRows()).Where("id = ?", id).Take(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{StatusCode: 404, Message: fmt.Sprintf("%s not found", model)}
+	}
+	return row, err
+}
+
+func restoreRow(tx *gorm.DB, model string, id string, result RecycleBinResult) error {
+	spec := recycleModels[model]
+	row, err := loadRow(tx, model, id)
+	if err != nil {
+		return err
+	}
+	if row["deletedAt"] == nil {
+		return nil
+	}
+	var deletedAt []time.Time
+	if err := tx.Unscoped().Model(spec.newRows()).Where("id = ?", id).Pluck("deletedAt", &deletedAt).Error; err != nil {
+		return err
+	}
+
+	for _, parent := range spec.parents {
+		parentID, _ := row[parent.Column].(string)
+		if parentID == "" {
+			continue
+		}
+		var live int64
+		if err := tx.Model(recycleModels[parent.Model].newRows()).Where("id = ?", parentID).Count(&live).Error; err != nil {
+			return err
+		}
+		if live == 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("restore the %s %s of this %s first", parent.Model, parentID, model)}
+		}
+	}
+	for _, columns := range spec.unique {
+		query := tx.Model(spec.newRows()).Where("id <> ?", id)
+		for _, column := range columns {
+			if row[column] == nil {
+				query = query.Where(fmt.Sprintf(`"%s" IS NULL`, column))
+			} else {
+				query = query.Where(fmt.Sprintf(`"%s" = ?`, column), row[column])
+			}
+		}
+		var clashes int64
+		if err := query.Count(&clashes).Error; err != nil {
+			return err
+		}
+		if clashes > 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("a live %s with the same %s already exists", model, strings.Join(columns, ", "))}
+		}
+	}
+
+	err = tx.Unscoped().Model(spec.newRows()).Where("id = ?", id).Update("deletedAt", nil).Error
+	if err != nil {
+		return err
+	}
+	result[model] = append(result[model], id)
+
+	// فرزندانی که همراه این ردیف حذف شده‌اند با آن برمی‌گردند؛ حذف همراه ممکن است کمی
+	// پیش از خود ردیف انجام شده باشد
+	since := deletedAt[0].Add(-recycleCascadeWindow)
+	for _, child := range spec.children {
+		var ids []string
+		err := tx.Unscoped().Model(recycleModels[child.Model].newRows()).
+			Where(fmt.Sprintf(`"%s" = ? AND "deletedAt" >= ?`, child.Column), id, since).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		for _, childID := range ids {
+			if err := restoreRow(tx, child.Model, childID, result); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func purgeRow(tx *gorm.DB, model string, id string, result RecycleBinResult) error {
+	spec := recycleModels[model]
+
+	for _, child := range spec.children {
+		childRows := tx.Model(recycleModels[child.Model].newRows()).Where(fmt.Sprintf(`"%s" = ?`, child.Column), id)
+		var live int64
+		if err := childRows.Count(&live).Error; err != nil {
+			return err
+		}
+		if live > 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("this %s still has %d %s records, delete them first", model, live, child.Model)}
+		}
+
+		var ids []string
+		err := tx.Unscoped().Model(recycleModels[child.Model].newRows()).
+			Where(fmt.Sprintf(`"%s" = ?`, child.Column), id).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return err
+		}
+		for _, childID := range ids {
+			if err := purgeRow(tx, child.Model, childID, result); err != nil {
+				return err
+			}
+		}
+	}
+
+	// ردیف‌های حذف‌شده هم کلید خارجی را نگه می‌دارند، پس همه شمرده می‌شوند
+	for _, blocker := range spec.blockers {
+		var count int64
+		err := tx.Unscoped().Model(recycleModels[blocker.Model].newRows()).
+			Where(fmt.Sprintf(`"%s" = ?`, blocker.Column), id).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return &ServiceError{StatusCode: 409, Message: fmt.Sprintf("this %s is still used by %d %s records", model, count, blocker.Model)}
+		}
+	}
+
+	if err := tx.Unscoped().Where("id = ?", id).Delete(spec.newRows()).Error; err != nil {
+		return err
+	}
+	result[model] = append(result[model], id)
+	return nil
+}
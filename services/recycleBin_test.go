@@ -0,0 +1,135 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// softDelete marks the row id of model deleted at the given time
+func softDelete(t *testing.T, db *gorm.DB, model any, id string, at time.Time) {
+	t.Helper()
+	if err := db.Unscoped().Model(model).Where("id = ?", id).Update("deletedAt", at).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecycleBinRestoreCascades(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	for _, row := range []any{
+		&models.Partition{ID: "main", BranchID: "north-branch", LocalID: 1},
+		&models.Partition{ID: "removed-earlier", BranchID: "north-branch", LocalID: 2},
+		&models.Employee{ID: "guard", BranchID: "north-branch", LocalID: 1, Name: "Ali", LastName: "Rezaei", Position: "Guard"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	outsider := newTestUser(t, db, models.User{ID: "outsider", LocationID: "shiraz"},
+		requirement{Action: models.PermissionUpdate, Model: "Branch"})
+	s := &RecycleBinService{DB: db, Authz: &Authorizer{DB: db}}
+
+	// فرزندان چند ثانیه پیش از شعبه حذف شده‌اند و پارتیشن دیگر ساعت‌ها قبل
+	now := time.Now()
+	softDelete(t, db, &models.Partition{}, "removed-earlier", now.Add(-2*time.Hour))
+	softDelete(t, db, &models.Partition{}, "main", now.Add(-5*time.Second))
+	softDelete(t, db, &models.Employee{}, "guard", now.Add(-5*time.Second))
+	softDelete(t, db, &models.Branch{}, "north-branch", now)
+
+	_, err := s.Restore(outsider, "Branch", "north-branch")
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.StatusCode != 404 {
+		t.Errorf("restoring a branch outside the scope: %v, want 404", err)
+	}
+	// فرزند قبل از والد برنمی‌گردد
+	if _, err := s.Restore(owner, "Partition", "main"); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 409 {
+		t.Errorf("restoring a partition of a deleted branch: %v, want 409", err)
+	}
+
+	res, err := s.Restore(owner, "Branch", "north-branch")
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	want := RecycleBinResult{"Branch": {"north-branch"}, "Partition": {"main"}, "Employee": {"guard"}}
+	for model, ids := range want {
+		if !sameIDs(res.Data[model], ids) {
+			t.Errorf("restored %s %v, want %v", model, res.Data[model], ids)
+		}
+	}
+	var partitions []string
+	db.Model(&models.Partition{}).Order("id").Pluck("id", &partitions)
+	if !sameIDs(partitions, []string{"main"}) {
+		t.Errorf("live partitions %v, want [main]", partitions)
+	}
+
+	// ردیفی که با یک ردیف زنده تداخل دارد برنمی‌گردد
+	if err := db.Create(&models.Partition{ID: "replacement", BranchID: "north-branch", LocalID: 2}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Restore(owner, "Partition", "removed-earlier"); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 409 {
+		t.Errorf("restoring a clashing partition: %v, want 409", err)
+	}
+}
+
+func TestRecycleBinPurgeRefusesReferencedRows(t *testing.T) {
+	db := newTestDB(t)
+	seedScopeTree(t, db)
+	for _, row := range []any{
+		&models.Branch{ID: "empty-branch", Code: 3, PanelCode: 3, LocationID: "north"},
+		&models.Partition{ID: "main", BranchID: "empty-branch", LocalID: 1},
+		&models.Employee{ID: "guard", BranchID: "empty-branch", LocalID: 1, Name: "Ali", LastName: "Rezaei", Position: "Guard"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	s := &RecycleBinService{DB: db, Authz: &Authorizer{DB: db}}
+	now := time.Now()
+
+	// رویدادهای شعبه، حتی حذف‌شده، جلوی حذف دائمی را می‌گیرند
+	softDelete(t, db, &models.Event{}, "north-event", now)
+	softDelete(t, db, &models.Branch{}, "north-branch", now)
+	var serviceErr *ServiceError
+	if _, err := s.Purge(owner, "Branch", "north-branch"); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 409 {
+		t.Errorf("purging a branch with events: %v, want 409", err)
+	}
+
+	// فرزند زنده هم جلوی حذف دائمی را می‌گیرد
+	softDelete(t, db, &models.Branch{}, "empty-branch", now)
+	if _, err := s.Purge(owner, "Branch", "empty-branch"); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 409 {
+		t.Errorf("purging a branch with a live partition: %v, want 409", err)
+	}
+
+	softDelete(t, db, &models.Partition{}, "main", now)
+	softDelete(t, db, &models.Employee{}, "guard", now)
+	res, err := s.Purge(owner, "Branch", "empty-branch")
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	var purged []string
+	for model, ids := range res.Data {
+		for _, id := range ids {
+			purged = append(purged, model+"/"+id)
+		}
+	}
+	sort.Strings(purged)
+	if !sameIDs(purged, []string{"Branch/empty-branch", "Employee/guard", "Partition/main"}) {
+		t.Errorf("purged %v", purged)
+	}
+	var left int64
+	db.Unscoped().Model(&models.Partition{}).Where(`"branchId" = ?`, "empty-branch").Count(&left)
+	if left != 0 {
+		t.Errorf("%d partitions of the purged branch left", left)
+	}
+	if _, err := s.Purge(owner, "Branch", "north-event"); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 404 {
+		t.Errorf("purging an unknown branch: %v, want 404", err)
+	}
+}
@@ -0,0 +1,438 @@
+package services
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// RegistrationService handles the approval queue of self-registered users and the
+// admin-approved password reset requests.
+type RegistrationService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+type PendingUser struct {
+	models.User
+	Location *models.Location `json:"location"`
+}
+
+type PendingUserPage struct {
+	Total      int64         `json:"total"`
+	Page       int           `json:"page"`
+	Limit      int           `json:"limit"`
+	TotalPages int           `json:"totalPages"`
+	Data       []PendingUser `json:"data"`
+}
+
+type PendingUsersResponse struct {
+	StatusCode     int             `json:"statusCode"`
+	Message        string          `json:"message"`
+	Data           PendingUserPage `json:"data"`
+	RedactedFields []string        `json:"redactedFields"`
+}
+
+type PasswordResetPage struct {
+	Total      int64                         `json:"total"`
+	Page       int                           `json:"page"`
+	Limit      int                           `json:"limit"`
+	TotalPages int                           `json:"totalPages"`
+	Data       []models.PasswordResetRequest `json:"data"`
+}
+
+type PasswordResetsResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Message    string            `json:"message"`
+	Data       PasswordResetPage `json:"data"`
+}
+
+type ApproveRequest struct {
+	PermissionIDs []string `json:"permissionIds"`
+	Version       *int     `json:"version"`
+}
+
+type RegistrationResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+}
+
+type ApproveResetResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	// TemporaryPassword is shown once to the admin; the user must change it at next login
+	TemporaryPassword string `json:"temporaryPassword"`
+}
+
+type PasswordResetRequestInput struct {
+	Username    string `json:"username"`
+	PhoneNumber string `json:"phoneNumber"`
+}
+
+// FindPending returns a page of registrations awaiting approval
+func (s *RegistrationService) FindPending(token string, page, limit int) (*PendingUsersResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.FindPending")
+	if err != nil {
+		return nil, err
+	}
+	page, limit = normalizePage(page, limit)
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	query := scope.Users(s.DB.Model(&models.User{}).Where("status = ?", models.UserStatusPending))
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	if err := query.Order(`"createdAt" ASC`).Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	redacted := caller.redact("User", &users)
+
+	locationIDs := make([]string, 0, len(users))
+	for _, u := range users {
+		locationIDs = append(locationIDs, u.LocationID)
+	}
+	var locations []models.Location
+	if len(locationIDs) > 0 {
+		s.DB.Where("id IN ?", locationIDs).Find(&locations)
+	}
+	byID := make(map[string]*models.Location, len(locations))
+	for i := range locations {
+		byID[locations[i].ID] = &locations[i]
+	}
+
+	pending := make([]PendingUser, 0, len(users))
+	for _, u := range users {
+		u.Password = ""
+		pending = append(pending, PendingUser{User: u, Location: byID[u.LocationID]})
+	}
+
+	return &PendingUsersResponse{
+		StatusCode: 200,
+		Message:    "Pending registrations fetched successfully",
+		Data: PendingUserPage{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages(total, limit),
+			Data:       pending,
+		},
+		RedactedFields: redacted,
+	}, nil
+}
+
+// Approve activates a pending registration and optionally grants permissions to it
+func (s *RegistrationService) Approve(token string, userID string, req ApproveRequest) (*RegistrationResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.Approve")
+	if err != nil {
+		return nil, err
+	}
+	if len(req.PermissionIDs) > 0 && !caller.Can(models.PermissionAssign, "Permission", "") {
+		return nil, ErrForbidden
+	}
+
+	user, err := s.pendingUser(caller, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// جز مالک، هیچ‌کس دسترسی‌ای را که خودش ندارد واگذار نمی‌کند
+	permissions := make([]models.Permission, 0, len(req.PermissionIDs))
+	for _, permissionID := range req.PermissionIDs {
+		var permission models.Permission
+		if err := s.DB.Where("id = ?", permissionID).First(&permission).Error; err != nil {
+			return nil, errors.New("unknown permission")
+		}
+		field := ""
+		if permission.Field != nil {
+			field = *permission.Field
+		}
+		if !caller.CanField(permission.Action, permission.Model, field) {
+			return nil, &ServiceError{StatusCode: 403, Message: "you cannot grant a permission you do not hold"}
+		}
+		permissions = append(permissions, permission)
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		err := updateVersioned(tx, user, user.ID, req.Version, map[string]interface{}{
+			"status":           models.UserStatusOffline,
+			"confirmationTime": time.Now().Format(time.RFC3339),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, permission := range permissions {
+			grant := models.UserPermission{ID: uuid.New().String(), UserID: user.ID, PermissionID: permission.ID}
+			if err := tx.Create(&grant).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrationResponse{StatusCode: 200, Message: "Registration approved"}, nil
+}
+
+// Reject marks a pending registration as rejected; the user can no longer log in
+func (s *RegistrationService) Reject(token string, userID string, version *int) (*RegistrationResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.Reject")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.pendingUser(caller, userID)
+	if err != nil {
+		return nil, err
+	}
+	err = updateVersioned(s.DB.WithContext(caller.Context()), user, user.ID, version, map[string]interface{}{
+		"status": models.UserStatusRejected,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RegistrationResponse{StatusCode: 200, Message: "Registration rejected"}, nil
+}
+
+// pendingUser returns a registration awaiting approval that the caller may act on
+func (s *RegistrationService) pendingUser(caller *Caller, userID string) (*models.User, error) {
+	var user models.User
+	err := s.DB.Where("id = ? AND status = ?", userID, models.UserStatusPending).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, &ServiceError{StatusCode: 404, Message: "pending registration not found"}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := caller.checkManaged(s.DB, &user, "pending registration not found"); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// RequestPasswordReset queues a reset for an admin. It answers the same way whether or not
+// the username and phone number match, so it cannot be used to probe accounts.
+func (s *RegistrationService) RequestPasswordReset(req PasswordResetRequestInput) (*RegistrationResponse, error) {
+	response := &RegistrationResponse{StatusCode: 201, Message: "Password reset request submitted"}
+
+	var user models.User
+	err := s.DB.Where("username = ? AND \"phoneNumber\" = ? AND status NOT IN ?", req.Username, req.PhoneNumber,
+		[]string{models.UserStatusPending, models.UserStatusRejected}).First(&user).Error
+	if err != nil {
+		return response, nil
+	}
+
+	var pending int64
+	s.DB.Model(&models.PasswordResetRequest{}).
+		Where(`"userId" = ? AND status = ?`, user.ID, models.ResetStatusPending).
+		Count(&pending)
+	if pending > 0 {
+		return response, nil
+	}
+
+	request := models.PasswordResetRequest{
+		ID:          uuid.New().String(),
+		UserID:      user.ID,
+		Username:    user.Username,
+		PhoneNumber: user.PhoneNumber,
+		Status:      models.ResetStatusPending,
+	}
+	if err := s.DB.Create(&request).Error; err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// FindPendingResets returns a page of password reset requests awaiting an admin
+func (s *RegistrationService) FindPendingResets(token string, page, limit int) (*PasswordResetsResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.FindPendingResets")
+	if err != nil {
+		return nil, err
+	}
+	page, limit = normalizePage(page, limit)
+
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	query := s.DB.Model(&models.PasswordResetRequest{}).Where(`"PasswordResetRequest".status = ?`, models.ResetStatusPending)
+	if !caller.IsOwner() {
+		users := scope.Users(s.DB.Model(&models.User{}).Select("id").Where(`"User".type <> ?`, "OWNER"))
+		query = query.Where(`"PasswordResetRequest"."userId" IN (?)`, users)
+	}
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var requests []models.PasswordResetRequest
+	if err := query.Order(`"requestedAt" ASC`).Offset((page - 1) * limit).Limit(limit).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	if !caller.CanField(models.PermissionRead, "User", "phoneNumber") {
+		for i := range requests {
+			requests[i].PhoneNumber = redactedValue
+		}
+	}
+
+	return &PasswordResetsResponse{
+		StatusCode: 200,
+		Message:    "Pending password resets fetched successfully",
+		Data: PasswordResetPage{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages(total, limit),
+			Data:       requests,
+		},
+	}, nil
+}
+
+// ApproveReset issues a one-time temporary password that must be changed at next login
+func (s *RegistrationService) ApproveReset(token string, requestID string, version *int) (*ApproveResetResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.ApproveReset")
+	if err != nil {
+		return nil, err
+	}
+
+	request, user, err := s.pendingReset(caller, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := loadPasswordPolicy(s.DB)
+	temporary, err := temporaryPassword(max(12, policy.MinLength))
+	if err != nil {
+		return nil, err
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(temporary), 10)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := policy.setPassword(tx, user, string(hashedPassword), true); err != nil {
+			return err
+		}
+		// نشست‌های قبلی کاربر باطل می‌شوند
+		if err := tx.Where(`"userId" = ?`, request.UserID).Delete(&models.Session{}).Error; err != nil {
+			return err
+		}
+		return resolveReset(tx, request, version, models.ResetStatusApproved, caller.User.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ApproveResetResponse{
+		StatusCode:        200,
+		Message:           "Password reset approved",
+		TemporaryPassword: temporary,
+	}, nil
+}
+
+// RejectReset closes a password reset request without changing the password
+func (s *RegistrationService) RejectReset(token string, requestID string, version *int) (*RegistrationResponse, error) {
+	caller, err := s.Authz.Authorize(token, "RegistrationService.RejectReset")
+	if err != nil {
+		return nil, err
+	}
+
+	request, _, err := s.pendingReset(caller, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if err := resolveReset(s.DB.WithContext(caller.Context()), request, version, models.ResetStatusRejected, caller.User.ID); err != nil {
+		return nil, err
+	}
+
+	return &RegistrationResponse{StatusCode: 200, Message: "Password reset rejected"}, nil
+}
+
+// pendingReset returns a reset awaiting an admin and its user, when the caller may act on it
+func (s *RegistrationService) pendingReset(caller *Caller, requestID string) (*models.PasswordResetRequest, *models.User, error) {
+	const notFound = "pending password reset not found"
+	var request models.PasswordResetRequest
+	err := s.DB.Where("id = ? AND status = ?", requestID, models.ResetStatusPending).First(&request).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, &ServiceError{StatusCode: 404, Message: notFound}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var user models.User
+	if err := s.DB.Where("id = ?", request.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, &ServiceError{StatusCode: 404, Message: notFound}
+		}
+		return nil, nil, err
+	}
+	// رمز موقت حساب مالک فقط به مالک داده می‌شود
+	if err := caller.checkManaged(s.DB, &user, notFound); err != nil {
+		return nil, nil, err
+	}
+	return &request, &user, nil
+}
+
+func resolveReset(db *gorm.DB, request *models.PasswordResetRequest, version *int, status models.ResetStatus, resolvedBy string) error {
+	now := time.Now()
+	return updateVersioned(db, request, request.ID, version, map[string]interface{}{
+		"status":     status,
+		"resolvedAt": &now,
+		"resolvedBy": resolvedBy,
+	})
+}
+
+// temporaryPassword returns a random password that satisfies the usual character classes
+func temporaryPassword(length int) (string, error) {
+	const (
+		lower  = "abcdefghijkmnpqrstuvwxyz"
+		upper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits = "23456789"
+		symbol = "@#$%&*!"
+	)
+	sets := []string{lower, upper, digits, symbol}
+	all := lower + upper + digits + symbol
+
+	password := make([]byte, length)
+	for i := range password {
+		set := all
+		if i < len(sets) {
+			set = sets[i]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			return "", err
+		}
+		password[i] = set[n.Int64()]
+	}
+
+	// جابجایی تصادفی تا کلاس‌ها همیشه اول نباشند
+	for i := len(password) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return "", err
+		}
+		j := n.Int64()
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"errors"
+	"testing"
+
+	"monitoring-with-go/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestApproveGrantsOnlyHeldPermissions(t *testing.T) {
+	db := newTestDB(t)
+	for _, row := range []any{
+		&models.Location{ID: "tehran", Label: "Tehran"},
+		&models.Permission{ID: "read-event", Action: models.PermissionRead, Model: "Event"},
+		&models.Permission{ID: "delete-event", Action: models.PermissionDelete, Model: "Event"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: "tehran"},
+		requirement{Action: models.PermissionUpdate, Model: "User"},
+		requirement{Action: models.PermissionAssign, Model: "Permission"},
+		requirement{Action: models.PermissionRead, Model: "Event"})
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	newTestUser(t, db, models.User{ID: "first", LocationID: "tehran", Status: models.UserStatusPending})
+	newTestUser(t, db, models.User{ID: "second", LocationID: "tehran", Status: models.UserStatusPending})
+	s := &RegistrationService{DB: db, Authz: &Authorizer{DB: db}}
+
+	grants := func(userID string) []string {
+		var ids []string
+		db.Model(&models.UserPermission{}).Where(`"userId" = ?`, userID).Order(`"permissionId"`).Pluck("permissionId", &ids)
+		return ids
+	}
+	status := func(userID string) string {
+		var user models.User
+		db.Where("id = ?", userID).Take(&user)
+		return user.Status
+	}
+
+	// دسترسی‌ای که تأییدکننده ندارد کل تأیید را رد می‌کند
+	_, err := s.Approve(admin, "first", ApproveRequest{PermissionIDs: []string{"read-event", "delete-event"}, Version: new(int)})
+	var serviceErr *ServiceError
+	if !errors.As(err, &serviceErr) || serviceErr.StatusCode != 403 {
+		t.Errorf("granting a permission the approver lacks: %v, want 403", err)
+	}
+	if status("first") != models.UserStatusPending || len(grants("first")) != 0 {
+		t.Errorf("refused approval left %s with grants %v", status("first"), grants("first"))
+	}
+
+	if _, err := s.Approve(admin, "first", ApproveRequest{PermissionIDs: []string{"read-event"}, Version: new(int)}); err != nil {
+		t.Fatalf("Approve: %v", err)
+	}
+	if status("first") != models.UserStatusOffline || !sameIDs(grants("first"), []string{"read-event"}) {
+		t.Errorf("approved user is %s with grants %v", status("first"), grants("first"))
+	}
+
+	// مالک هر دسترسی را واگذار می‌کند
+	if _, err := s.Approve(owner, "second", ApproveRequest{PermissionIDs: []string{"delete-event"}, Version: new(int)}); err != nil {
+		t.Fatalf("owner Approve: %v", err)
+	}
+	if !sameIDs(grants("second"), []string{"delete-event"}) {
+		t.Errorf("owner approval granted %v", grants("second"))
+	}
+
+	if _, err := s.Approve(admin, "first", ApproveRequest{Version: new(int)}); !errors.As(err, &serviceErr) || serviceErr.StatusCode != 404 {
+		t.Errorf("approving an active user: %v, want 404", err)
+	}
+}
+
+func TestPasswordResetFlow(t *testing.T) {
+	db := newTestDB(t)
+	admin := newTestUser(t, db, models.User{ID: "admin", Type: "OWNER"})
+	newTestUser(t, db, models.User{ID: "operator", PhoneNumber: "09120000000"})
+	if _, err := newSession(db, "operator", "", "test"); err != nil {
+		t.Fatal(err)
+	}
+	s := &RegistrationService{DB: db, Authz: &Authorizer{DB: db}}
+	requests := func() []models.PasswordResetRequest {
+		var rows []models.PasswordResetRequest
+		db.Where("status = ?", models.ResetStatusPending).Find(&rows)
+		return rows
+	}
+
+	// پاسخ درخواست با شماره اشتباه با درخواست درست یکی است
+	wrong, err := s.RequestPasswordReset(PasswordResetRequestInput{Username: "operator", PhoneNumber: "09990000000"})
+	if err != nil || len(requests()) != 0 {
+		t.Fatalf("wrong phone number: %v, %d requests", err, len(requests()))
+	}
+	right, err := s.RequestPasswordReset(PasswordResetRequestInput{Username: "operator", PhoneNumber: "09120000000"})
+	if err != nil || *right != *wrong {
+		t.Fatalf("reset request answered %+v (%v), a wrong number %+v", right, err, wrong)
+	}
+	// درخواست تکراری صف را پر نمی‌کند
+	if _, err := s.RequestPasswordReset(PasswordResetRequestInput{Username: "operator", PhoneNumber: "09120000000"}); err != nil {
+		t.Fatal(err)
+	}
+	pending := requests()
+	if len(pending) != 1 {
+		t.Fatalf("%d pending requests, want 1", len(pending))
+	}
+
+	res, err := s.ApproveReset(admin, pending[0].ID, new(int))
+	if err != nil {
+		t.Fatalf("ApproveReset: %v", err)
+	}
+	var user models.User
+	db.Where("id = ?", "operator").Take(&user)
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(res.TemporaryPassword)) != nil || !user.MustChangePassword {
+		t.Errorf("temporary password not set or not marked for change: %+v", user)
+	}
+	var sessions int64
+	db.Model(&models.Session{}).Where(`"userId" = ?`, "operator").Count(&sessions)
+	if sessions != 0 || len(requests()) != 0 {
+		t.Errorf("%d sessions and %d pending requests left after the reset", sessions, len(requests()))
+	}
+}
@@ -0,0 +1,117 @@
+package services
+
+import (
+	"strings"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/models"
+
+	"gorm.io/gorm"
+)
+
+// SearchService finds events, branches, zones and alarms by the words in their description,
+// name, address, code and labels, using the full-text index kept by the database triggers.
+// Events are also found by their alarm, branch and zone.
+type SearchService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+// searchKindModels maps a document kind to the model the caller needs READ on
+var searchKindModels = map[string]string{
+	database.SearchKindEvent:  "Event",
+	database.SearchKindBranch: "Branch",
+	database.SearchKindZone:   "Zone",
+	database.SearchKindAlarm:  "Alarm",
+}
+
+// SearchRequest is a search; an empty Kinds searches every kind the caller may read
+type SearchRequest struct {
+	Query string   `json:"query"`
+	Kinds []string `json:"kinds"`
+	Limit int      `json:"limit"`
+}
+
+// SearchHit is one match, best first. Snippet is the matching text with the found words in
+// [brackets]; Rank is the bm25 score, lower is better.
+type SearchHit struct {
+	Kind    string  `json:"kind"`
+	ID      string  `json:"id"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+type SearchResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Message    string      `json:"message"`
+	Data       []SearchHit `json:"data"`
+}
+
+// Search returns the best matches of the query among the rows inside the caller's scope
+func (s *SearchService) Search(token string, req SearchRequest) (*SearchResponse, error) {
+	caller, err := s.Authz.Authorize(token, "SearchService.Search")
+	if err != nil {
+		return nil, err
+	}
+	if !database.SearchAvailable(s.DB) {
+		return nil, &ServiceError{StatusCode: 501, Message: database.ErrSearchUnsupported.Error()}
+	}
+	match := database.SearchMatchQuery(req.Query)
+	if match == "" {
+		return nil, &ServiceError{StatusCode: 400, Message: "query is empty"}
+	}
+	_, limit := normalizePage(1, req.Limit)
+
+	requested := req.Kinds
+	if len(requested) == 0 {
+		requested = []string{database.SearchKindEvent, database.SearchKindBranch, database.SearchKindZone, database.SearchKindAlarm}
+	}
+	var kinds []string
+	for _, kind := range requested {
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		model, ok := searchKindModels[kind]
+		if !ok {
+			return nil, &ServiceError{StatusCode: 400, Message: "unknown search kind " + kind}
+		}
+		if caller.Can(models.PermissionRead, model, "") {
+			kinds = append(kinds, kind)
+		}
+	}
+	hits := []SearchHit{}
+	if len(kinds) == 0 {
+		return &SearchResponse{StatusCode: 200, Message: "Search completed successfully", Data: hits}, nil
+	}
+
+	query := s.DB.Table("SearchIndex").
+		Select(`d.kind, d."refId" AS id, "SearchIndex".title, snippet("SearchIndex", -1, '[', ']', '…', 12) AS snippet, bm25("SearchIndex", 4.0, 1.0) AS rank`).
+		Joins(`JOIN "SearchDocument" d ON d.docid = "SearchIndex".rowid`).
+		Where(`"SearchIndex" MATCH ?`, match).
+		Where("d.kind IN ?", kinds)
+
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+	if !scope.Unrestricted {
+		// هشدارها به شعبه وابسته نیستند؛ بقیه فقط در محدوده مکان‌های کاربر
+		locations := scope.nonEmptyIDs()
+		query = query.Where(`d.kind = ?
+			OR (d.kind = ? AND d."refId" IN (SELECT id FROM "Branch" WHERE "locationId" IN ?))
+			OR (d.kind = ? AND d."refId" IN (SELECT e.id FROM "Event" e JOIN "Branch" b ON b.id = e."branchId" WHERE b."locationId" IN ?))
+			OR (d.kind = ? AND d."refId" IN (SELECT z.id FROM "Zone" z JOIN "Partition" p ON p.id = z."partitionId" JOIN "Branch" b ON b.id = p."branchId" WHERE b."locationId" IN ?))`,
+			database.SearchKindAlarm,
+			database.SearchKindBranch, locations,
+			database.SearchKindEvent, locations,
+			database.SearchKindZone, locations)
+	}
+
+	if err := query.Order("rank").Limit(limit).Scan(&hits).Error; err != nil {
+		return nil, err
+	}
+	return &SearchResponse{
+		StatusCode: 200,
+		Message:    "Search completed successfully",
+		Data:       hits,
+	}, nil
+}
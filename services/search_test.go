@@ -0,0 +1,85 @@
+package services
+
+import (
+	"sort"
+	"testing"
+
+	"monitoring-with-go/database"
+	"monitoring-with-go/models"
+)
+
+func TestSearchScope(t *testing.T) {
+	db := newTestDB(t)
+	if !database.SearchAvailable(db) {
+		t.Skip(database.ErrSearchUnsupported)
+	}
+	seedScopeTree(t, db)
+	for _, row := range []any{
+		&models.Alarm{ID: "panic", Code: 120, Label: "آژیر کمک"},
+		&models.Partition{ID: "north-partition", BranchID: "north-branch", LocalID: 1},
+		&models.Zone{ID: "vault", Label: "Vault", PartitionID: "north-partition"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Model(&models.Branch{}).Where("id = ?", "north-branch").Update("name", "Vanak").Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.EnsureSearchIndex(db); err != nil {
+		t.Fatalf("EnsureSearchIndex: %v", err)
+	}
+	// رویدادی که بعد از ساخت نمایه تغییر کرده با تریگر به‌روز می‌شود
+	err := db.Model(&models.Event{}).Where("id = ?", "north-event").
+		Updates(map[string]any{"alarmId": "panic", "zoneId": "vault", "originalBranchCode": "4711"}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: "tehran"},
+		requirement{Action: models.PermissionRead, Model: "Event"},
+		requirement{Action: models.PermissionRead, Model: "Branch"})
+	outsider := newTestUser(t, db, models.User{ID: "outsider", LocationID: "shiraz"},
+		requirement{Action: models.PermissionRead, Model: "Event"},
+		requirement{Action: models.PermissionRead, Model: "Branch"})
+	s := &SearchService{DB: db, Authz: &Authorizer{DB: db}}
+
+	search := func(token, query string) []string {
+		t.Helper()
+		res, err := s.Search(token, SearchRequest{Query: query})
+		if err != nil {
+			t.Fatalf("Search %q: %v", query, err)
+		}
+		hits := []string{}
+		for _, hit := range res.Data {
+			hits = append(hits, hit.Kind+"/"+hit.ID)
+		}
+		sort.Strings(hits)
+		return hits
+	}
+	cases := []struct {
+		token, query string
+		want         []string
+	}{
+		// حرف عربی در جستجو با حرف فارسی نمایه یکی است
+		{admin, "آژير", []string{"event/north-event"}},
+		{admin, "vanak", []string{"branch/north-branch", "event/north-event"}},
+		{admin, "vault", []string{"event/north-event"}},
+		{admin, "4711", []string{"event/north-event"}},
+		{outsider, "vanak", []string{}},
+		{outsider, "آژیر", []string{}},
+	}
+	for _, c := range cases {
+		if got := search(c.token, c.query); !sameIDs(got, c.want) {
+			t.Errorf("%q found %v, want %v", c.query, got, c.want)
+		}
+	}
+
+	// رویداد حذف‌شده از نمایه بیرون می‌رود
+	if err := db.Delete(&models.Event{}, "id = ?", "north-event").Error; err != nil {
+		t.Fatal(err)
+	}
+	if got := search(admin, "vault"); len(got) != 0 {
+		t.Errorf("deleted event still found: %v", got)
+	}
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Caller is the authenticated user behind a bound method call
+type Caller struct {
+	User    models.User
+	Session models.Session
+	grants  map[string]bool
+	scope   *LocationScope
+}
+
+type callerKey struct{}
+
+const sessionStageTotp = "TOTP"
+
+// IsOwner reports whether the caller bypasses permission checks
+func (c *Caller) IsOwner() bool {
+	return c.User.Type == "OWNER"
+}
+
+// Can reports whether the caller holds action on model; when field is set a grant on that
+// field or on the whole model is enough.
+func (c *Caller) Can(action models.PermissionAction, model string, field string) bool {
+	if c.IsOwner() {
+		return true
+	}
+	if c.grants[grantKey(action, model, "")] {
+		return true
+	}
+	return field != "" && c.grants[grantKey(action, model, field)]
+}
+
+// Context returns a context carrying the caller, for db.WithContext
+func (c *Caller) Context() context.Context {
+	return context.WithValue(context.Background(), callerKey{}, c)
+}
+
+// CallerFromContext returns the caller stored by Caller.Context, or nil
+func CallerFromContext(ctx context.Context) *Caller {
+	if ctx == nil {
+		return nil
+	}
+	caller, _ := ctx.Value(callerKey{}).(*Caller)
+	return caller
+}
+
+func grantKey(action models.PermissionAction, model string, field string) string {
+	if field == "" {
+		return string(action) + ":" + model
+	}
+	return string(action) + ":" + model + "." + field
+}
+
+// newSession stores a session for userID and returns the raw token given to the frontend
+func newSession(db *gorm.DB, userID, authLogID, ip string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	hours := readIntSetting(db, "auth.sessionHours", 12)
+	session := models.Session{
+		ID:        uuid.New().String(),
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		AuthLogID: authLogID,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(time.Duration(hours) * time.Hour),
+	}
+	if err := db.Create(&session).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// findSession returns the live session for token
+func findSession(db *gorm.DB, token string) (*models.Session, error) {
+	if token == "" {
+		return nil, ErrUnauthorized
+	}
+	var session models.Session
+	err := db.Where(`"tokenHash" = ? AND "expiresAt" > ? AND COALESCE(stage, '') = ''`, hashToken(token), time.Now()).
+		First(&session).Error
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	return &session, nil
+}
+
+// newChallenge stores a short-lived half session that only VerifyTwoFactor accepts
+func newChallenge(db *gorm.DB, userID, ip string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+
+	challenge := models.Session{
+		ID:        uuid.New().String(),
+		TokenHash: hashToken(token),
+		UserID:    userID,
+		IP:        ip,
+		Stage:     sessionStageTotp,
+		ExpiresAt: time.Now().Add(5 * time.Minute),
+	}
+	if err := db.Create(&challenge).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// findChallenge returns the pending two-factor challenge for token
+func findChallenge(db *gorm.DB, token string) (*models.Session, error) {
+	var challenge models.Session
+	err := db.Where(`"tokenHash" = ? AND "expiresAt" > ? AND stage = ?`, hashToken(token), time.Now(), sessionStageTotp).
+		First(&challenge).Error
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	return &challenge, nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
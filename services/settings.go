@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// readSetting returns the AppSetting value for key, or def when the key is missing
+func readSetting(db *gorm.DB, key string, def string) string {
+	var setting models.AppSetting
+	if err := db.Where("key = ?", key).First(&setting).Error; err != nil {
+		return def
+	}
+	if strings.TrimSpace(setting.Value) == "" {
+		return def
+	}
+	return setting.Value
+}
+
+// readIntSetting returns the AppSetting value for key as an int, or def when it is missing or invalid
+func readIntSetting(db *gorm.DB, key string, def int) int {
+	value, err := strconv.Atoi(strings.TrimSpace(readSetting(db, key, "")))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// writeSetting creates or updates the AppSetting value for key
+func writeSetting(db *gorm.DB, key string, value string) error {
+	var setting models.AppSetting
+	err := db.Where("key = ?", key).First(&setting).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		setting = models.AppSetting{ID: uuid.New().String(), Key: key, Value: value, IsVisible: true}
+		return db.Create(&setting).Error
+	}
+	if err != nil {
+		return err
+	}
+	return db.Model(&setting).Update("value", value).Error
+}
+
+// readBoolSetting returns the AppSetting value for key as a bool, or def when it is missing or invalid
+func readBoolSetting(db *gorm.DB, key string, def bool) bool {
+	value, err := strconv.ParseBool(strings.TrimSpace(readSetting(db, key, "")))
+	if err != nil {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"monitoring-with-go/models"
+)
+
+// rfc6238Secret is the SHA-1 key of the RFC 6238 test vectors, "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTotpCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatalf("totpCode(%d): %v", tt.unix, err)
+		}
+		if got != tt.code {
+			t.Errorf("totpCode(%d) = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestVerifyTotp(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+	code := func(s int64) string {
+		c, err := totpCode(rfc6238Secret, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, code(step), 0, step, true},
+		{"previous step within skew", rfc6238Secret, code(step - 1), 0, step - 1, true},
+		{"next step within skew", rfc6238Secret, code(step + 1), 0, step + 1, true},
+		{"outside skew", rfc6238Secret, code(step - 2), 0, 0, false},
+		{"replayed step", rfc6238Secret, code(step), step, 0, false},
+		{"surrounding spaces", rfc6238Secret, " " + code(step) + " ", 0, step, true},
+		{"wrong length", rfc6238Secret, code(step)[:5], 0, 0, false},
+		{"wrong code", rfc6238Secret, "000000", 0, 0, false},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", code(step), 0, step, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := verifyTotp(tt.secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("verifyTotp() = (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := verifyTotp("not base32!", "123456", now, 0); ok {
+		t.Error("verifyTotp accepted a code for an invalid secret")
+	}
+}
+
+func TestResetTwoFactor(t *testing.T) {
+	db := newTestDB(t)
+	for _, row := range []any{
+		&models.Location{ID: "tehran", Label: "Tehran"},
+		&models.Location{ID: "shiraz", Label: "Shiraz"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: "tehran"}, requirement{Action: models.PermissionUpdate, Model: "User"})
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER", TotpEnabled: true, TotpSecret: "secret"})
+	newTestUser(t, db, models.User{ID: "inside", LocationID: "tehran", TotpEnabled: true, TotpSecret: "secret"})
+	newTestUser(t, db, models.User{ID: "outside", LocationID: "shiraz", TotpEnabled: true, TotpSecret: "secret"})
+	s := &AuthService{DB: db, Authz: &Authorizer{DB: db}}
+
+	enabled := func(userID string) bool {
+		var user models.User
+		db.Where("id = ?", userID).Take(&user)
+		return user.TotpEnabled
+	}
+	cases := []struct {
+		token, userID string
+		status        int
+		cleared       bool
+	}{
+		{admin, "owner", 403, false},
+		{admin, "outside", 404, false},
+		{admin, "missing", 404, false},
+		{admin, "inside", 0, true},
+		{owner, "outside", 0, true},
+	}
+	for _, c := range cases {
+		_, err := s.ResetTwoFactor(c.token, c.userID)
+		var serviceErr *ServiceError
+		switch {
+		case c.status == 0 && err != nil:
+			t.Errorf("reset %s: %v", c.userID, err)
+		case c.status != 0 && (!errors.As(err, &serviceErr) || serviceErr.StatusCode != c.status):
+			t.Errorf("reset %s: %v, want %d", c.userID, err, c.status)
+		}
+		if c.userID != "missing" && enabled(c.userID) == c.cleared {
+			t.Errorf("after reset %s two-factor enabled = %v", c.userID, enabled(c.userID))
+		}
+	}
+}
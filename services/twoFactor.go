@@ -0,0 +1,341 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+type TwoFactorEnrollmentResponse struct {
+	StatusCode int    `json:"statusCode"`
+	Message    string `json:"message"`
+	Secret     string `json:"secret"`
+	// ProvisioningURI is the otpauth:// URI; the frontend renders it as the QR code
+	ProvisioningURI string `json:"provisioningUri"`
+}
+
+type RecoveryCodesResponse struct {
+	StatusCode    int      `json:"statusCode"`
+	Message       string   `json:"message"`
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+type TwoFactorPolicyResponse struct {
+	StatusCode    int      `json:"statusCode"`
+	Message       string   `json:"message"`
+	RequiredTypes []string `json:"requiredTypes"`
+}
+
+// VerifyTwoFactor completes a login started by Login when TwoFactorRequired was returned.
+// code is either the current TOTP code or one of the unused recovery codes.
+func (s *AuthService) VerifyTwoFactor(challengeToken string, code string) (*LoginResponse, error) {
+	challenge, err := findChallenge(s.DB, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := s.DB.Where("id = ?", challenge.UserID).First(&user).Error; err != nil {
+		return nil, ErrUnauthorized
+	}
+
+	guard := &loginGuard{db: s.DB, policy: loadLoginPolicy(s.DB)}
+	if _, locked := guard.lockedUntil(user.Username, challenge.IP); locked {
+		guard.record(user.Username, user.ID, challenge.IP, models.AuthStatusLocked, "two-factor attempt while locked")
+		return nil, ErrTooManyAttempts
+	}
+
+	if err := s.verifySecondFactor(&user, code); err != nil {
+		guard.record(user.Username, user.ID, challenge.IP, models.AuthStatusFailed, "invalid two-factor code")
+		time.Sleep(guard.policy.delay(guard.userFailures(user.Username)))
+		return nil, err
+	}
+
+	if err := s.DB.Delete(challenge).Error; err != nil {
+		return nil, err
+	}
+	return s.startSession(guard, &user, challenge.IP)
+}
+
+// BeginTwoFactorEnrollment creates a new secret for the caller; it is enabled only after
+// ConfirmTwoFactorEnrollment proves the authenticator works.
+func (s *AuthService) BeginTwoFactorEnrollment(token string) (*TwoFactorEnrollmentResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.BeginTwoFactorEnrollment")
+	if err != nil {
+		return nil, err
+	}
+	if caller.User.TotpEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := newTotpSecret()
+	if err != nil {
+		return nil, err
+	}
+	err = s.DB.WithContext(caller.Context()).Model(&models.User{}).Where("id = ?", caller.User.ID).Updates(map[string]interface{}{
+		"totpSecret":   secret,
+		"totpLastStep": 0,
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := readSetting(s.DB, "auth.totpIssuer", "Monitoring")
+	return &TwoFactorEnrollmentResponse{
+		StatusCode:      200,
+		Message:         "Scan the code with an authenticator app and confirm it",
+		Secret:          secret,
+		ProvisioningURI: totpURI(issuer, caller.User.Username, secret),
+	}, nil
+}
+
+// ConfirmTwoFactorEnrollment enables two-factor authentication and returns fresh recovery codes
+func (s *AuthService) ConfirmTwoFactorEnrollment(token string, code string) (*RecoveryCodesResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.ConfirmTwoFactorEnrollment")
+	if err != nil {
+		return nil, err
+	}
+	user := caller.User
+	if user.TotpEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TotpSecret == "" {
+		return nil, errors.New("start the enrollment first")
+	}
+
+	step, ok := verifyTotp(user.TotpSecret, code, time.Now(), user.TotpLastStep)
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	var codes []string
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", user.ID).Updates(map[string]interface{}{
+			"totpEnabled":  true,
+			"totpLastStep": step,
+		}).Error
+		if err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &RecoveryCodesResponse{
+		StatusCode:    200,
+		Message:       "Two-factor authentication enabled",
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking a current code
+func (s *AuthService) RegenerateRecoveryCodes(token string, code string) (*RecoveryCodesResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.RegenerateRecoveryCodes")
+	if err != nil {
+		return nil, err
+	}
+	if !caller.User.TotpEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := s.verifySecondFactor(&caller.User, code); err != nil {
+		return nil, err
+	}
+
+	codes, err := replaceRecoveryCodes(s.DB, caller.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &RecoveryCodesResponse{
+		StatusCode:    200,
+		Message:       "Recovery codes regenerated",
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// DisableTwoFactor turns two-factor authentication off for the caller, unless the policy requires it
+func (s *AuthService) DisableTwoFactor(token string, code string) (*UnlockResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.DisableTwoFactor")
+	if err != nil {
+		return nil, err
+	}
+	if twoFactorRequiredFor(s.DB, &caller.User) {
+		return nil, &ServiceError{StatusCode: 403, Message: "two-factor authentication is required for your account"}
+	}
+	if err := s.verifySecondFactor(&caller.User, code); err != nil {
+		return nil, err
+	}
+	if err := clearTwoFactor(s.DB.WithContext(caller.Context()), caller.User.ID); err != nil {
+		return nil, err
+	}
+
+	return &UnlockResponse{StatusCode: 200, Message: "Two-factor authentication disabled"}, nil
+}
+
+// ResetTwoFactor clears the two-factor setup of another user who lost the authenticator
+func (s *AuthService) ResetTwoFactor(token string, userID string) (*UnlockResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.ResetTwoFactor")
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := s.DB.Where("id = ?", userID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "user not found"}
+		}
+		return nil, err
+	}
+	// رمز دوم مالک فقط به دست مالک پاک می‌شود
+	if err := caller.checkManaged(s.DB, &user, "user not found"); err != nil {
+		return nil, err
+	}
+	if err := clearTwoFactor(s.DB.WithContext(caller.Context()), userID); err != nil {
+		return nil, err
+	}
+
+	return &UnlockResponse{StatusCode: 200, Message: "Two-factor authentication reset"}, nil
+}
+
+// GetTwoFactorPolicy returns the user types that must use two-factor authentication
+func (s *AuthService) GetTwoFactorPolicy(token string) (*TwoFactorPolicyResponse, error) {
+	if _, err := s.Authz.Authorize(token, "AuthService.GetTwoFactorPolicy"); err != nil {
+		return nil, err
+	}
+	return &TwoFactorPolicyResponse{
+		StatusCode:    200,
+		Message:       "Two-factor policy fetched successfully",
+		RequiredTypes: twoFactorRequiredTypes(s.DB),
+	}, nil
+}
+
+// SetTwoFactorPolicy sets the user types that must use two-factor authentication; owner only
+func (s *AuthService) SetTwoFactorPolicy(token string, types []string) (*TwoFactorPolicyResponse, error) {
+	caller, err := s.Authz.Authorize(token, "AuthService.SetTwoFactorPolicy")
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsOwner() {
+		return nil, ErrForbidden
+	}
+
+	cleaned := make([]string, 0, len(types))
+	for _, t := range types {
+		if t = strings.ToUpper(strings.TrimSpace(t)); t != "" {
+			cleaned = append(cleaned, t)
+		}
+	}
+	if err := writeSetting(s.DB.WithContext(caller.Context()), "auth.require2faTypes", strings.Join(cleaned, ",")); err != nil {
+		return nil, err
+	}
+
+	return &TwoFactorPolicyResponse{
+		StatusCode:    200,
+		Message:       "Two-factor policy updated",
+		RequiredTypes: cleaned,
+	}, nil
+}
+
+// verifySecondFactor accepts a TOTP code (never the same step twice) or an unused recovery code
+func (s *AuthService) verifySecondFactor(user *models.User, code string) error {
+	if step, ok := verifyTotp(user.TotpSecret, code, time.Now(), user.TotpLastStep); ok {
+		return s.DB.Model(&models.User{}).Where("id = ?", user.ID).Update("totpLastStep", step).Error
+	}
+
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidTwoFactorCode
+	}
+	now := time.Now()
+	result := s.DB.Model(&models.RecoveryCode{}).
+		Where(`"userId" = ? AND "codeHash" = ? AND "usedAt" IS NULL`, user.ID, hashRecoveryCode(normalized)).
+		Update("usedAt", &now)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// twoFactorRequiredTypes reads the owner policy from AppSetting
+func twoFactorRequiredTypes(db *gorm.DB) []string {
+	types := []string{}
+	for _, t := range strings.Split(readSetting(db, "auth.require2faTypes", ""), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func twoFactorRequiredFor(db *gorm.DB, user *models.User) bool {
+	for _, t := range twoFactorRequiredTypes(db) {
+		if strings.EqualFold(t, user.Type) {
+			return true
+		}
+	}
+	return false
+}
+
+func clearTwoFactor(db *gorm.DB, userID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totpEnabled":  false,
+			"totpSecret":   "",
+			"totpLastStep": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where(`"userId" = ?`, userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// replaceRecoveryCodes drops the old codes of userID and returns new plain codes, stored hashed
+func replaceRecoveryCodes(db *gorm.DB, userID string) ([]string, error) {
+	if err := db.Where(`"userId" = ?`, userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(raw))
+		code = code[:4] + "-" + code[4:]
+
+		row := models.RecoveryCode{ID: uuid.New().String(), UserID: userID, CodeHash: hashRecoveryCode(normalizeRecoveryCode(code))}
+		if err := db.Create(&row).Error; err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
@@ -23,6 +23,14 @@ type UdpMessage struct {
 	IP      string
 }
 
+// eventListeners are called with every event stored by SaveEventToDatabase
+var eventListeners []func(models.Event)
+
+// OnEventSaved registers fn to be called after each stored event; register before starting the listener
+func OnEventSaved(fn func(models.Event)) {
+	eventListeners = append(eventListeners, fn)
+}
+
 // تعداد workerها
 const workerCount = 32
 const channelBufferSize = 10000
@@ -95,50 +103,82 @@ func processUdpData(payload []byte, ip string) error {
 		return fmt.Errorf("invalid message format")
 	}
 
+	// پیام با قالب نوع پنل شعبه فرستنده خوانده می‌شود؛ همان کدی که TestParse استفاده می‌کند
 	eventData := parts[0]
-	eventFields := strings.Split(eventData, ";")
-	if len(eventFields) < 12 {
-		return fmt.Errorf("invalid event data format")
+	received := strings.TrimRight(eventData, "\r\n")
+	receiverID := listenerReceiver(database.DB)
+	result, panelType, err := readReceivedMessage(database.DB, receiverID, received)
+	if err != nil {
+		return err
 	}
+	if len(result.Problems) > 0 {
+		log.Printf("⚠️ Message read with problems: %s", strings.Join(result.Problems, "; "))
+	}
+	panelCode := result.values["panelCode"]
 
-	// Example extraction (adjust based on your message format)
-	year, month, day := eventFields[0], eventFields[1], eventFields[2]
-	hour, minute := eventFields[3], eventFields[4]
-	panelCode := eventFields[7]
-	
-	dedupHash := BuildDedupHash(eventData, timestamp, ip, ";")
+	dedupHash := BuildDedupHash(eventData, timestamp, ip, panelType.Delimiter)
 	randomNumber := rand.Intn(901) + 100
+	receivedAt := time.Now()
+	// شماره‌های خام پیام کنار شناسه رکوردها نگه داشته می‌شوند
+	original := func(token string) string {
+		if value, ok := result.values[token]; ok {
+			return value
+		}
+		return uuid.New().String()
+	}
 
 	eventMap := map[string]interface{}{
 		"id":                  uuid.New().String(),
-		"originalZoneId":      uuid.New().String(),
-		"originalPartitionId": uuid.New().String(),
-		"referenceId":         uuid.New().String(),
-		"time":                fmt.Sprintf("%s:%s", hour, minute),
-		"date":                fmt.Sprintf("%s-%s-%s", year, month, day),
-		"originalEmployeeId":  uuid.New().String(),
+		"originalZoneId":      original("zoneId"),
+		"originalPartitionId": original("partitionNumber"),
+		"referenceId":         original("eventReference"),
+		"time":                result.clock,
+		"date":                result.date,
+		"originalEmployeeId":  original("employeeId"),
 		"originalBranchCode":  panelCode,
 		"ip":                  ip,
-		"description":         "description",
+		"description":         result.description(received),
 		"confirmationStatus":  "Unconfirmed",
-		"createdAt":           time.Now(),
-		"alarmId":             uuid.New().String(),
-		"branchId":            uuid.New().String(),
-		"zoneId":              uuid.New().String(),
-		"partitionId":         uuid.New().String(),
-		"employeeId":          uuid.New().String(),
+		"createdAt":           receivedAt,
 		"old_id":              randomNumber,
 		"version":             0,
 		"deletedAt":           nil,
 		"dedupHash":           dedupHash,
 	}
+	// رکوردهایی که پیدا نشدند خالی (NULL) می‌مانند
+	if result.Branch != nil {
+		eventMap["branchId"] = result.Branch.ID
+	}
+	if result.Alarm != nil {
+		eventMap["alarmId"] = result.Alarm.ID
+	}
+	if result.Partition != nil {
+		eventMap["partitionId"] = result.Partition.ID
+	}
+	if result.Zone != nil {
+		eventMap["zoneId"] = result.Zone.ID
+	}
+	if result.Employee != nil {
+		eventMap["employeeId"] = result.Employee.ID
+	}
+
+	// ساعت پنل در منطقه زمانی شعبه و با تقویم نوع پنل خوانده شده تا با زمان دریافت مقایسه شود
+	if result.PanelTime != nil {
+		drift := clockDrift(receivedAt, *result.PanelTime)
+		eventMap["panelTime"] = result.PanelTime.UTC()
+		eventMap["clockDrift"] = drift
+		checkClockDrift(database.DB, panelCode, drift)
+	} else if result.date != "" {
+		eventMap["panelTimeInvalid"] = true
+	}
 
 	return SaveEventToDatabase(eventMap)
 }
 
 // SaveEventToDatabase saves the event map into the DB asynchronously
 func SaveEventToDatabase(data map[string]interface{}) error {
-	return database.DB.Transaction(func(tx *gorm.DB) error {
+	var event models.Event
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
 
 		// var existing models.Event
 		// if err := tx.Where("dedupHash = ?", getString(data["dedupHash"])).First(&existing).Error; err == nil {
@@ -146,7 +186,7 @@ func SaveEventToDatabase(data map[string]interface{}) error {
 		// 	return nil // duplicate found, skip
 		// }
 
-		event := models.Event{
+		event = models.Event{
 			ID:                  getString(data["id"]),
 			OriginalZoneID:      getString(data["originalZoneId"]),
 			OriginalPartitionID: getString(data["originalPartitionId"]),
@@ -168,6 +208,9 @@ func SaveEventToDatabase(data map[string]interface{}) error {
 			Version:             getInt(data["version"]),
 			DeletedAt:           gorm.DeletedAt{},
 			DedupHash:           getString(data["dedupHash"]),
+			PanelTime:           getTimePtr(data["panelTime"]),
+			ClockDrift:          getIntPtr(data["clockDrift"]),
+			PanelTimeInvalid:    data["panelTimeInvalid"] == true,
 		}
 
 		if err := tx.Create(&event).Error; err != nil {
@@ -176,6 +219,14 @@ func SaveEventToDatabase(data map[string]interface{}) error {
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, listener := range eventListeners {
+		listener(event)
+	}
+	return nil
 }
 
 // Helpers
@@ -241,3 +292,21 @@ func getInt(val interface{}) int {
 		return 0
 	}
 }
+
+// getTimePtr is getTime for optional columns; a missing value stays nil
+func getTimePtr(val interface{}) *time.Time {
+	if val == nil {
+		return nil
+	}
+	t := getTime(val)
+	return &t
+}
+
+// getIntPtr is getInt for optional columns; a missing value stays nil
+func getIntPtr(val interface{}) *int {
+	if val == nil {
+		return nil
+	}
+	i := getInt(val)
+	return &i
+}
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"errors"
+
+	"monitoring-with-go/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type UserService struct {
+	DB    *gorm.DB
+	Authz *Authorizer
+}
+
+type UserPage struct {
+	Total      int64         `json:"total"`
+	Page       int           `json:"page"`
+	Limit      int           `json:"limit"`
+	TotalPages int           `json:"totalPages"`
+	Data       []models.User `json:"data"`
+}
+
+type UserListResponse struct {
+	StatusCode     int      `json:"statusCode"`
+	Message        string   `json:"message"`
+	Data           UserPage `json:"data"`
+	RedactedFields []string `json:"redactedFields"`
+}
+
+type UserResponse struct {
+	StatusCode     int          `json:"statusCode"`
+	Message        string       `json:"message"`
+	Data           *models.User `json:"data"`
+	RedactedFields []string     `json:"redactedFields"`
+}
+
+// UpdateUserRequest carries only the fields to change; nil fields are left untouched
+type UpdateUserRequest struct {
+	Fullname        *string `json:"fullname"`
+	FatherName      *string `json:"fatherName"`
+	NationalityCode *string `json:"nationalityCode"`
+	PersonalCode    *string `json:"personalCode"`
+	PhoneNumber     *string `json:"phoneNumber"`
+	Address         *string `json:"address"`
+	LocationID      *string `json:"locationId"`
+	AvatarUrl       *string `json:"avatarUrl"`
+	Type            *string `json:"type"`
+	// Version is the version the client read; the update fails with a conflict if it is stale
+	Version *int `json:"version"`
+}
+
+// FindAll returns a page of the users the caller may manage with the fields the caller may not read masked
+func (s *UserService) FindAll(token string, page, limit int) (*UserListResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.FindAll")
+	if err != nil {
+		return nil, err
+	}
+	page, limit = normalizePage(page, limit)
+
+	var total int64
+	if err := s.managedUsers(caller, s.DB.Model(&models.User{})).Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	var users []models.User
+	if err := s.managedUsers(caller, s.DB).Order(`"createdAt" DESC`).Offset((page - 1) * limit).Limit(limit).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	return &UserListResponse{
+		StatusCode: 200,
+		Message:    "Users fetched successfully",
+		Data: UserPage{
+			Total:      total,
+			Page:       page,
+			Limit:      limit,
+			TotalPages: totalPages(total, limit),
+			Data:       users,
+		},
+		RedactedFields: caller.redact("User", &users),
+	}, nil
+}
+
+// FindOne returns a single user with the fields the caller may not read masked
+func (s *UserService) FindOne(token string, id string) (*UserResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.FindOne")
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.managedUser(caller, id)
+	if err != nil {
+		return nil, err
+	}
+	user.Password = ""
+
+	return &UserResponse{
+		StatusCode:     200,
+		Message:        "User fetched successfully",
+		Data:           user,
+		RedactedFields: caller.redact("User", user),
+	}, nil
+}
+
+// Update changes the given fields of a user; touching a sensitive field needs a grant on it
+func (s *UserService) Update(token string, id string, req UpdateUserRequest) (*UserResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.Update")
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	var fields []string
+	set := func(field string, value *string) {
+		if value != nil {
+			updates[field] = *value
+			fields = append(fields, field)
+		}
+	}
+	set("fullname", req.Fullname)
+	set("fatherName", req.FatherName)
+	set("nationalityCode", req.NationalityCode)
+	set("personalCode", req.PersonalCode)
+	set("phoneNumber", req.PhoneNumber)
+	set("address", req.Address)
+	set("locationId", req.LocationID)
+	set("avatarUrl", req.AvatarUrl)
+	set("type", req.Type)
+	// مکان خالی یعنی بدون مکان؛ رشته خالی کلید خارجی را نقض می‌کند
+	if location, ok := updates["locationId"]; ok && location == "" {
+		updates["locationId"] = nil
+	}
+
+	if len(updates) == 0 {
+		return nil, errors.New("nothing to update")
+	}
+	// فقط مالک سیستم می‌تواند نوع کاربر را تغییر دهد
+	if req.Type != nil && !caller.IsOwner() {
+		return nil, ErrForbidden
+	}
+	if err := caller.checkFieldUpdates("User", fields); err != nil {
+		return nil, err
+	}
+
+	user, err := s.managedUser(caller, id)
+	if err != nil {
+		return nil, err
+	}
+	// کاربر فقط به مکانی داخل محدوده فراخواننده منتقل می‌شود
+	if location, ok := updates["locationId"]; ok && !caller.IsOwner() {
+		scope, err := caller.locationScope(s.DB)
+		if err != nil {
+			return nil, err
+		}
+		if location == nil || !scope.AllowsLocation(location.(string)) {
+			return nil, ErrForbidden
+		}
+	}
+	err = updateVersioned(s.DB.WithContext(caller.Context()), user, id, req.Version, updates)
+	user.Password = ""
+	if err != nil {
+		caller.redact("User", user)
+		return nil, err
+	}
+
+	return &UserResponse{
+		StatusCode:     200,
+		Message:        "User updated successfully",
+		Data:           user,
+		RedactedFields: caller.redact("User", user),
+	}, nil
+}
+
+// managedUsers limits a query on "User" to the users checkManaged accepts
+func (s *UserService) managedUsers(caller *Caller, db *gorm.DB) *gorm.DB {
+	if caller.IsOwner() {
+		return db
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		db.AddError(err)
+		return db
+	}
+	return scope.Users(db.Where(`"User".type <> ?`, "OWNER"))
+}
+
+// managedUser returns the user with id if the caller may manage it
+func (s *UserService) managedUser(caller *Caller, id string) (*models.User, error) {
+	var user models.User
+	if err := s.DB.Where("id = ?", id).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, &ServiceError{StatusCode: 404, Message: "user not found"}
+		}
+		return nil, err
+	}
+	if err := caller.checkManaged(s.DB, &user, "user not found"); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+type UserLocationsResponse struct {
+	StatusCode int               `json:"statusCode"`
+	Message    string            `json:"message"`
+	Data       []models.Location `json:"data"`
+}
+
+// FindLocations returns the locations assigned to a user besides its own LocationID
+func (s *UserService) FindLocations(token string, userID string) (*UserLocationsResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.FindLocations")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.managedUser(caller, userID); err != nil {
+		return nil, err
+	}
+
+	var locations []models.Location
+	err = s.DB.
+		Joins(`JOIN "UserLocation" ON "UserLocation"."locationId" = "Location".id AND "UserLocation"."deletedAt" IS NULL`).
+		Where(`"UserLocation"."userId" = ?`, userID).
+		Find(&locations).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserLocationsResponse{
+		StatusCode: 200,
+		Message:    "User locations fetched successfully",
+		Data:       locations,
+	}, nil
+}
+
+// AssignLocations replaces the locations assigned to a user
+func (s *UserService) AssignLocations(token string, userID string, locationIDs []string) (*UserLocationsResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.AssignLocations")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.managedUser(caller, userID); err != nil {
+		return nil, err
+	}
+	scope, err := caller.locationScope(s.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	var locations []models.Location
+	if len(locationIDs) > 0 {
+		if err := s.DB.Where("id IN ?", locationIDs).Find(&locations).Error; err != nil {
+			return nil, err
+		}
+		if len(locations) != len(locationIDs) {
+			return nil, errors.New("unknown location")
+		}
+	}
+	// فقط مکان‌های داخل محدوده فراخواننده واگذار می‌شوند
+	for _, location := range locations {
+		if !scope.AllowsLocation(location.ID) {
+			return nil, ErrForbidden
+		}
+	}
+
+	err = s.DB.WithContext(caller.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(`"userId" = ?`, userID).Delete(&models.UserLocation{}).Error; err != nil {
+			return err
+		}
+		for _, location := range locations {
+			row := models.UserLocation{ID: uuid.New().String(), UserID: userID, LocationID: location.ID}
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &UserLocationsResponse{
+		StatusCode: 200,
+		Message:    "User locations updated successfully",
+		Data:       locations,
+	}, nil
+}
+
+// Create adds an active user directly, skipping the registration queue
+func (s *UserService) Create(token string, req RegisterRequest) (*RegisterResponse, error) {
+	caller, err := s.Authz.Authorize(token, "UserService.Create")
+	if err != nil {
+		return nil, err
+	}
+	if err := createUser(s.DB.WithContext(caller.Context()), req, "USER", models.UserStatusOffline); err != nil {
+		return nil, err
+	}
+
+	return &RegisterResponse{
+		StatusCode: 201,
+		Message:    "User registered successfully",
+	}, nil
+}
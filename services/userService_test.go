@@ -0,0 +1,132 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"monitoring-with-go/models"
+)
+
+func TestUserServiceScope(t *testing.T) {
+	db := newTestDB(t)
+	tehran := "tehran"
+	for _, row := range []any{
+		&models.Location{ID: tehran, Label: "Tehran"},
+		&models.Location{ID: "north", Label: "North", ParentID: &tehran},
+		&models.Location{ID: "shiraz", Label: "Shiraz"},
+	} {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	admin := newTestUser(t, db, models.User{ID: "admin", LocationID: tehran},
+		requirement{Action: models.PermissionRead, Model: "User"},
+		requirement{Action: models.PermissionUpdate, Model: "User"})
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER", LocationID: tehran})
+	newTestUser(t, db, models.User{ID: "inside", LocationID: "north"})
+	newTestUser(t, db, models.User{ID: "outside", LocationID: "shiraz"})
+	newTestUser(t, db, models.User{ID: "no-location"})
+	s := &UserService{DB: db, Authz: &Authorizer{DB: db}}
+
+	ids := func(token string) []string {
+		res, err := s.FindAll(token, 1, 50)
+		if err != nil {
+			t.Fatalf("FindAll: %v", err)
+		}
+		var ids []string
+		for _, user := range res.Data.Data {
+			ids = append(ids, user.ID)
+		}
+		sort.Strings(ids)
+		if int(res.Data.Total) != len(ids) {
+			t.Errorf("total %d for %d users", res.Data.Total, len(ids))
+		}
+		return ids
+	}
+	if got := ids(admin); len(got) != 2 || got[0] != "admin" || got[1] != "inside" {
+		t.Errorf("admin sees %v, want [admin inside]", got)
+	}
+	if got := ids(owner); len(got) != 5 {
+		t.Errorf("owner sees %v, want every user", got)
+	}
+
+	status := func(err error) int {
+		var serviceErr *ServiceError
+		if errors.As(err, &serviceErr) {
+			return serviceErr.StatusCode
+		}
+		return 0
+	}
+	name := "Renamed"
+	cases := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"read inside", func() error { _, err := s.FindOne(admin, "inside"); return err }(), 0},
+		{"read outside", func() error { _, err := s.FindOne(admin, "outside"); return err }(), 404},
+		{"read owner", func() error { _, err := s.FindOne(admin, "owner"); return err }(), 403},
+		{"read unknown", func() error { _, err := s.FindOne(admin, "missing"); return err }(), 404},
+		{"update outside", func() error {
+			_, err := s.Update(admin, "outside", UpdateUserRequest{Fullname: &name, Version: new(int)})
+			return err
+		}(), 404},
+		{"update owner", func() error {
+			_, err := s.Update(admin, "owner", UpdateUserRequest{Fullname: &name, Version: new(int)})
+			return err
+		}(), 403},
+		{"move outside", func() error {
+			shiraz := "shiraz"
+			_, err := s.Update(admin, "inside", UpdateUserRequest{LocationID: &shiraz, Version: new(int)})
+			return err
+		}(), 403},
+		{"assign outside", func() error { _, err := s.AssignLocations(admin, "inside", []string{"shiraz"}); return err }(), 403},
+		{"update inside", func() error {
+			_, err := s.Update(admin, "inside", UpdateUserRequest{Fullname: &name, Version: new(int)})
+			return err
+		}(), 0},
+	}
+	for _, c := range cases {
+		if c.want == 0 && c.err != nil {
+			t.Errorf("%s: %v", c.name, c.err)
+		} else if got := status(c.err); got != c.want {
+			t.Errorf("%s: %v, want %d", c.name, c.err, c.want)
+		}
+	}
+}
+
+func TestUserServiceFieldPermissions(t *testing.T) {
+	db := newTestDB(t)
+	owner := newTestUser(t, db, models.User{ID: "owner", Type: "OWNER"})
+	limited := newTestUser(t, db, models.User{ID: "limited"},
+		requirement{Action: models.PermissionRead, Model: "User"},
+		requirement{Action: models.PermissionUpdate, Model: "User"},
+		requirement{Action: models.PermissionRead, Model: "User", Field: "phoneNumber"})
+	newTestUser(t, db, models.User{ID: "target", PhoneNumber: "09120000000", NationalityCode: "0012345678"})
+	s := &UserService{DB: db, Authz: &Authorizer{DB: db}}
+
+	res, err := s.FindOne(owner, "target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.RedactedFields) != 0 || res.Data.NationalityCode != "0012345678" || res.Data.Password != "" {
+		t.Errorf("owner read %+v, redacted %v", res.Data, res.RedactedFields)
+	}
+
+	caller, err := s.Authz.Authenticate(limited)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{PhoneNumber: "09120000000", NationalityCode: "0012345678"}
+	redacted := caller.redact("User", &user)
+	if len(redacted) != 1 || redacted[0] != "nationalityCode" || user.NationalityCode != redactedValue || user.PhoneNumber != "09120000000" {
+		t.Errorf("redacted %v to %+v", redacted, user)
+	}
+	if err := caller.checkFieldUpdates("User", []string{"fullname", "phoneNumber"}); err == nil {
+		t.Error("updating phoneNumber with only a read grant on it was accepted")
+	}
+	if err := caller.checkFieldUpdates("User", []string{"fullname", "address"}); err != nil {
+		t.Errorf("updating plain fields: %v", err)
+	}
+}